@@ -8,6 +8,15 @@ import (
 type Config struct {
 	DB   map[string]model.StoredData
 	Lock *sync.RWMutex
+	// MetricsAddr is the address of the HTTP listener serving /metrics,
+	// empty when metrics are not served.
+	MetricsAddr string
+	// LogLevel, LogFile and LogFormat set up the log: the least severe
+	// level logged, the file to log to, empty for the standard output, and
+	// text or json.
+	LogLevel  string
+	LogFile   string
+	LogFormat string
 }
 
 func NewConfig() *Config {
@@ -1,7 +1,18 @@
 package main
 
-import "redis-go-clone/internal/server"
+import (
+	"flag"
+	"redis-go-clone/cmd/config"
+	"redis-go-clone/internal/server"
+)
 
 func main() {
-	server.StartServer()
+	config := config.NewConfig()
+	flag.StringVar(&config.MetricsAddr, "metrics-addr", "", "serve Prometheus metrics on /metrics at this address, such as :9121")
+	flag.StringVar(&config.LogLevel, "loglevel", "notice", "least severe level logged: debug, verbose, notice or warning")
+	flag.StringVar(&config.LogFile, "logfile", "", "log to this file instead of the standard output; it is reopened on SIGHUP")
+	flag.StringVar(&config.LogFormat, "log-format", "text", "format of the log lines: text or json")
+	flag.Parse()
+
+	server.StartServer(config)
 }
@@ -0,0 +1,177 @@
+package handler
+
+import (
+	"redis-go-clone/internal/model"
+	"redis-go-clone/internal/redis_command"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// blockedClient is a connection parked inside a blocking command. done is
+// closed once the connection goes away.
+type blockedClient struct {
+	request *redis_command.BlockingRequest
+	reply   chan string
+	done    <-chan struct{}
+	served  bool
+	// waiting is true while the client is in the queues.
+	waiting bool
+}
+
+// gone reports whether the connection of the client went away.
+func (client *blockedClient) gone() bool {
+	select {
+	case <-client.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// blockedClients keeps, for every key, the FIFO queue of clients blocked on
+// it. When a key is signalled as ready the clients waiting on it are retried
+// in the order they blocked, so the longest-waiting client is served first.
+type blockedClients struct {
+	db   map[string]model.StoredData
+	dbMu *sync.RWMutex
+
+	// mu guards waiters and the served flags. It is taken before the store
+	// lock, never after it.
+	mu      sync.Mutex
+	waiters map[string][]*blockedClient
+	// blocked counts the clients in waiters. It is read without mu, since
+	// INFO may run under the store lock, which is taken after mu.
+	blocked atomic.Int64
+
+	// readyMu only guards readyKeys, because keys are signalled while the
+	// store lock is held.
+	readyMu   sync.Mutex
+	readyKeys []string
+	wake      chan struct{}
+}
+
+func newBlockedClients(db map[string]model.StoredData, dbMu *sync.RWMutex) *blockedClients {
+	b := &blockedClients{
+		db:      db,
+		dbMu:    dbMu,
+		waiters: make(map[string][]*blockedClient),
+		wake:    make(chan struct{}, 1),
+	}
+	go b.serveReadyKeys()
+	return b
+}
+
+// signalKeyAsReady is installed as the redis_command key-ready hook.
+func (b *blockedClients) signalKeyAsReady(key string) {
+	b.readyMu.Lock()
+	b.readyKeys = append(b.readyKeys, key)
+	b.readyMu.Unlock()
+
+	select {
+	case b.wake <- struct{}{}:
+	default:
+	}
+}
+
+// block parks the caller until request can be served, times out or done is
+// closed because the client went away, and returns the reply to send.
+func (b *blockedClients) block(request *redis_command.BlockingRequest, done <-chan struct{}) string {
+	b.mu.Lock()
+	// Retry once more while holding mu: a key signalled between the first
+	// attempt and now would otherwise be missed.
+	if reply, served := request.Retry(b.db, b.dbMu); served {
+		b.mu.Unlock()
+		return reply
+	}
+
+	client := &blockedClient{request: request, reply: make(chan string, 1), done: done, waiting: true}
+	for _, key := range request.Keys {
+		b.waiters[key] = append(b.waiters[key], client)
+	}
+	b.blocked.Add(1)
+	b.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if request.Timeout > 0 {
+		timer := time.NewTimer(request.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case reply := <-client.reply:
+		return reply
+	case <-timeout:
+	case <-done:
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if client.served {
+		return <-client.reply
+	}
+	b.unblock(client)
+	return request.TimeoutReply
+}
+
+// unblock removes client from every queue it waits in, if it still does.
+// mu must be held.
+func (b *blockedClients) unblock(client *blockedClient) {
+	if !client.waiting {
+		return
+	}
+	client.waiting = false
+	b.blocked.Add(-1)
+	for _, key := range client.request.Keys {
+		queue := slices.DeleteFunc(b.waiters[key], func(c *blockedClient) bool { return c == client })
+		if len(queue) == 0 {
+			delete(b.waiters, key)
+		} else {
+			b.waiters[key] = queue
+		}
+	}
+}
+
+func (b *blockedClients) serveReadyKeys() {
+	for range b.wake {
+		b.readyMu.Lock()
+		keys := b.readyKeys
+		b.readyKeys = nil
+		b.readyMu.Unlock()
+
+		for _, key := range keys {
+			b.serveKey(key)
+		}
+	}
+}
+
+// serveKey retries the clients blocked on key in FIFO order until one of
+// them cannot be served, which means the key ran dry again. Clients that
+// disconnected are dropped without popping anything for them.
+func (b *blockedClients) serveKey(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for len(b.waiters[key]) > 0 {
+		client := b.waiters[key][0]
+		if client.gone() {
+			b.unblock(client)
+			continue
+		}
+		reply, served := client.request.Retry(b.db, b.dbMu)
+		if !served {
+			return
+		}
+		client.served = true
+		b.unblock(client)
+		client.reply <- reply
+	}
+}
+
+// count returns how many clients are blocked. It does not take mu, so it
+// may be called with the store lock held.
+func (b *blockedClients) count() int {
+	return int(b.blocked.Load())
+}
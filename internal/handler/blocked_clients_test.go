@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"redis-go-clone/cmd/config"
+	"redis-go-clone/internal/model"
+	"redis-go-clone/internal/redis_command"
+	"sync"
+	"testing"
+	"time"
+)
+
+func blockOn(t *testing.T, b *blockedClients, cmdArray []any) <-chan string {
+	t.Helper()
+	_, request := redis_command.BZPopMin(cmdArray, b.db, b.dbMu)
+	if request == nil {
+		t.Fatalf("expected %v to block", cmdArray)
+	}
+
+	result := make(chan string, 1)
+	go func() { result <- b.block(request, nil) }()
+	return result
+}
+
+func waitForBlocked(t *testing.T, b *blockedClients, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for b.count() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d blocked clients, got %d", n, b.count())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestBlockedClientsServedInFIFOOrder(t *testing.T) {
+	db := make(map[string]model.StoredData)
+	mu := &sync.RWMutex{}
+	b := newBlockedClients(db, mu)
+	redis_command.SetKeyReadyHook(b.signalKeyAsReady)
+	defer redis_command.SetKeyReadyHook(nil)
+
+	first := blockOn(t, b, []any{"BZPOPMIN", "z", 0})
+	waitForBlocked(t, b, 1)
+	second := blockOn(t, b, []any{"BZPOPMIN", "other", "z", 0})
+	waitForBlocked(t, b, 2)
+
+	redis_command.ZAdd([]any{"ZADD", "z", 1, "a"}, db, mu)
+	if reply := <-first; reply != "*3\r\n$1\r\nz\r\n$1\r\na\r\n$1\r\n1\r\n" {
+		t.Errorf("expected first client to get a, got %q", reply)
+	}
+	waitForBlocked(t, b, 1)
+
+	redis_command.ZAdd([]any{"ZADD", "z", 2, "b"}, db, mu)
+	if reply := <-second; reply != "*3\r\n$1\r\nz\r\n$1\r\nb\r\n$1\r\n2\r\n" {
+		t.Errorf("expected second client to get b, got %q", reply)
+	}
+	waitForBlocked(t, b, 0)
+}
+
+func TestBlockedClientTimesOut(t *testing.T) {
+	db := make(map[string]model.StoredData)
+	mu := &sync.RWMutex{}
+	b := newBlockedClients(db, mu)
+
+	result := blockOn(t, b, []any{"BZPOPMAX", "z", "0.05"})
+	select {
+	case reply := <-result:
+		if reply != "*-1\r\n" {
+			t.Errorf("expected null reply on timeout, got %q", reply)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("blocked client did not time out")
+	}
+
+	if b.count() != 0 {
+		t.Errorf("expected no blocked clients after timeout, got %d", b.count())
+	}
+}
+
+func TestBlockedClientDisconnects(t *testing.T) {
+	h := NewClientHandler(config.NewConfig())
+	c := connect(t, h)
+
+	blocked := connect(t, h)
+	blocked.send("BZPOPMIN", "q", "0")
+	waitForBlocked(t, h.blocked, 1)
+	blocked.conn.Close()
+	waitForBlocked(t, h.blocked, 0)
+
+	c.send("ZADD", "q", "1", "job")
+	c.expect(":1\r\n")
+	c.send("ZCARD", "q")
+	c.expect(":1\r\n")
+
+	blocked = connect(t, h)
+	blocked.send("XREAD", "BLOCK", "0", "STREAMS", "s", "$")
+	waitForBlocked(t, h.blocked, 1)
+	blocked.conn.Close()
+	waitForBlocked(t, h.blocked, 0)
+}
+
+func TestServeKeySkipsDisconnectedClients(t *testing.T) {
+	db := make(map[string]model.StoredData)
+	mu := &sync.RWMutex{}
+	b := newBlockedClients(db, mu)
+
+	done := make(chan struct{})
+	_, request := redis_command.BZPopMin([]any{"BZPOPMIN", "z", 0}, db, mu)
+	client := &blockedClient{request: request, reply: make(chan string, 1), done: done, waiting: true}
+	b.waiters["z"] = []*blockedClient{client}
+	b.blocked.Add(1)
+	close(done)
+
+	redis_command.ZAdd([]any{"ZADD", "z", 1, "a"}, db, mu)
+	b.serveKey("z")
+	if client.served {
+		t.Error("expected a disconnected client not to be served")
+	}
+	if b.count() != 0 {
+		t.Errorf("expected no blocked clients, got %d", b.count())
+	}
+	if got := redis_command.ZCard([]any{"ZCARD", "z"}, db, mu); got != ":1\r\n" {
+		t.Errorf("expected the element to stay in the set, got %q", got)
+	}
+}
@@ -0,0 +1,296 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"redis-go-clone/internal/logging"
+	"redis-go-clone/internal/stats"
+	"redis-go-clone/internal/watch"
+	"redis-go-clone/pkg/resp"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// clientOutputLimit is how many replies may be queued for a client before a
+// publisher gives up on it and drops the connection.
+const clientOutputLimit = 1024
+
+// lastClientID is the id of the last client that connected.
+var lastClientID atomic.Int64
+
+// client is the state of one connection. Replies and pushed messages are
+// queued on out and written by a single goroutine, so pushes never wait for
+// the client to send a request and never interleave with a reply.
+type client struct {
+	conn      net.Conn
+	out       chan string
+	done      chan struct{}
+	closeOnce sync.Once
+	quit      bool
+
+	// id identifies the connection for as long as the server runs.
+	id      int64
+	created time.Time
+	// log logs with the id and address of the client.
+	log *slog.Logger
+
+	// infoMu guards what other connections read about this one for CLIENT
+	// LIST. The connection goroutine is the only writer, so it reads
+	// these fields without infoMu.
+	infoMu sync.Mutex
+	// name is the connection name, empty until one is set.
+	name            string
+	libName, libVer string
+	lastCommand     string
+	lastInteraction time.Time
+	noEvict         bool
+	blocked         bool
+	// counts is a snapshot of the subscriptions and transaction of the
+	// connection, taken after every command.
+	counts clientCounts
+
+	// queryBytes is the size of the request being processed, and
+	// outputBytes the size of the replies waiting in out.
+	queryBytes  atomic.Int64
+	outputBytes atomic.Int64
+
+	// The subscriptions are only used by the connection goroutine.
+	channels      map[string]struct{}
+	patterns      map[string]struct{}
+	shardChannels map[string]struct{}
+
+	// Transaction state between MULTI and EXEC or DISCARD.
+	multi       bool
+	multiFailed bool
+	queued      [][]any
+	watcher     *watch.Watcher
+}
+
+func newClient(conn net.Conn) *client {
+	id := lastClientID.Add(1)
+	now := time.Now()
+	c := &client{
+		conn:            conn,
+		id:              id,
+		created:         now,
+		lastInteraction: now,
+		lastCommand:     "NULL",
+		counts:          clientCounts{multi: -1},
+		log:             slog.Default().With("client_id", id, "addr", conn.RemoteAddr().String()),
+		out:             make(chan string, clientOutputLimit),
+		done:            make(chan struct{}),
+		channels:        make(map[string]struct{}),
+		patterns:        make(map[string]struct{}),
+		shardChannels:   make(map[string]struct{}),
+		watcher:         watch.NewWatcher(),
+	}
+	go c.writeLoop()
+	return c
+}
+
+// request is a command read from the connection, or err if the bytes
+// received are not valid RESP. size is how many bytes it took.
+type request struct {
+	command any
+	size    int
+	err     error
+}
+
+// readLoop reads requests from the connection and hands them to the
+// connection goroutine until the client goes away. Reading on its own
+// goroutine notices a client that disconnects while it is blocked or
+// paused: done is closed, which wakes it up.
+//
+// What is read is kept in a buffer that grows as needed: every complete
+// request in it is handed over, so that pipelined commands all run, and a
+// request that is not complete yet waits there for the next read.
+func (c *client) readLoop(requests chan<- request) {
+	defer close(requests)
+	defer c.close()
+
+	var buffer []byte
+	for {
+		// Make room for at least as much as is already buffered, so that a
+		// large request takes few reads.
+		if free := cap(buffer) - len(buffer); free < max(readBufferSize, len(buffer)) {
+			buffer = slices.Grow(buffer, max(readBufferSize, len(buffer)))
+		}
+		n, err := c.conn.Read(buffer[len(buffer):cap(buffer)])
+		if err != nil {
+			// Clients normally leave by closing the connection, and it is
+			// closed on our side after QUIT or when its output is full.
+			if errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) || errors.Is(err, io.ErrClosedPipe) {
+				logging.Verbose(c.log, "Client closed connection")
+			} else {
+				c.log.Warn("Error reading from client", "error", err)
+			}
+			return
+		}
+		stats.NetInput(n)
+		buffer = buffer[:len(buffer)+n]
+
+		input := string(buffer)
+		for len(input) > 0 {
+			command, rest, err := resp.DeserializeNext(input)
+			if errors.Is(err, resp.ErrIncomplete) {
+				break
+			}
+			if err != nil {
+				// Nothing after an invalid request can be told apart, so
+				// the whole buffer is dropped.
+				rest = ""
+			}
+			select {
+			case requests <- request{command: command, size: len(input) - len(rest), err: err}:
+			case <-c.done:
+				return
+			}
+			input = rest
+		}
+
+		if len(input) > maxQueryBufferSize {
+			c.log.Warn("Closing client that reached max query buffer length", "qbuf", len(input))
+			return
+		}
+		if len(input) == 0 && cap(buffer) > maxIdleBufferSize {
+			// Let go of the room a large request needed.
+			buffer = nil
+		} else {
+			buffer = append(buffer[:0], input...)
+		}
+	}
+}
+
+func (c *client) writeLoop() {
+	defer c.conn.Close()
+	for {
+		select {
+		case reply := <-c.out:
+			c.outputBytes.Add(-int64(len(reply)))
+			n, err := c.conn.Write([]byte(reply))
+			stats.NetOutput(n)
+			if err != nil {
+				c.close()
+				return
+			}
+		case <-c.done:
+			// Flush what was queued before closing, e.g. the reply to QUIT.
+			for {
+				select {
+				case reply := <-c.out:
+					n, err := c.conn.Write([]byte(reply))
+					stats.NetOutput(n)
+					if err != nil {
+						return
+					}
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// write queues a reply, waiting for room in the output queue.
+func (c *client) write(reply string) {
+	c.outputBytes.Add(int64(len(reply)))
+	select {
+	case c.out <- reply:
+	case <-c.done:
+		c.outputBytes.Add(-int64(len(reply)))
+	}
+}
+
+// Push queues a published message without blocking the publisher. A client
+// that lets its queue fill up is disconnected. Its connection is closed
+// right away, since the writer may be stuck writing to a client that
+// stopped reading.
+func (c *client) Push(reply string) {
+	c.outputBytes.Add(int64(len(reply)))
+	select {
+	case c.out <- reply:
+	case <-c.done:
+		c.outputBytes.Add(-int64(len(reply)))
+	default:
+		c.outputBytes.Add(-int64(len(reply)))
+		c.log.Warn("Closing client: output queue limit reached")
+		c.close()
+		c.conn.Close()
+	}
+}
+
+func (c *client) close() {
+	c.closeOnce.Do(func() { close(c.done) })
+}
+
+// subscriptions returns the number of channels and patterns subscribed to.
+// Shard channels are counted separately, as in Redis.
+func (c *client) subscriptions() int {
+	return len(c.channels) + len(c.patterns)
+}
+
+// subscribed reports whether the client is in subscriber mode.
+func (c *client) subscribed() bool {
+	return c.subscriptions()+len(c.shardChannels) > 0
+}
+
+// clientCounts are the subscriptions of a client, the commands it queued
+// in MULTI or -1 outside of it, and the keys it watches.
+type clientCounts struct {
+	sub, psub, ssub int
+	multi           int
+	watch           int
+}
+
+// beginCommand records the command about to run, which CLIENT LIST shows
+// until the next one.
+func (c *client) beginCommand(cmdArray []any) {
+	name := "NULL"
+	if len(cmdArray) > 0 {
+		name = strings.ToLower(argString(cmdArray[0]))
+		if containerCommands[strings.ToUpper(name)] && len(cmdArray) > 1 {
+			name += "|" + strings.ToLower(argString(cmdArray[1]))
+		}
+	}
+	c.infoMu.Lock()
+	defer c.infoMu.Unlock()
+	c.lastCommand = name
+	c.lastInteraction = time.Now()
+}
+
+// endCommand takes a snapshot of what the command may have changed.
+func (c *client) endCommand() {
+	counts := clientCounts{
+		sub:   len(c.channels),
+		psub:  len(c.patterns),
+		ssub:  len(c.shardChannels),
+		multi: -1,
+		watch: c.watcher.Len(),
+	}
+	if c.multi {
+		counts.multi = len(c.queued)
+	}
+	c.infoMu.Lock()
+	defer c.infoMu.Unlock()
+	c.counts = counts
+	c.lastInteraction = time.Now()
+}
+
+func (c *client) setBlocked(blocked bool) {
+	c.infoMu.Lock()
+	defer c.infoMu.Unlock()
+	c.blocked = blocked
+}
+
+// resetTransaction leaves MULTI and forgets the watched keys.
+func (c *client) resetTransaction() {
+	c.multi = false
+	c.multiFailed = false
+	c.queued = nil
+	c.watcher.Unwatch()
+}
@@ -0,0 +1,416 @@
+package handler
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// clients are the open connections by id, for CLIENT LIST and CLIENT KILL.
+type clients struct {
+	mu   sync.RWMutex
+	byID map[int64]*client
+}
+
+func newClients() *clients {
+	return &clients{byID: make(map[int64]*client)}
+}
+
+func (r *clients) add(c *client) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.byID[c.id] = c
+}
+
+func (r *clients) remove(c *client) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.byID, c.id)
+}
+
+// list returns the clients ordered by id.
+func (r *clients) list() []*client {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ids := slices.Sorted(maps.Keys(r.byID))
+	list := make([]*client, 0, len(ids))
+	for _, id := range ids {
+		list = append(list, r.byID[id])
+	}
+	return list
+}
+
+// pause holds back the commands of the clients during CLIENT PAUSE.
+type pause struct {
+	mu    sync.Mutex
+	until time.Time
+	all   bool
+	// resume is closed when the pause is lifted by CLIENT UNPAUSE.
+	resume chan struct{}
+}
+
+func newPause() *pause {
+	return &pause{resume: make(chan struct{})}
+}
+
+// start pauses the clients until the given time. A pause already in place
+// is only ever extended and made stricter.
+func (p *pause) start(until time.Time, all bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if time.Now().After(p.until) {
+		p.all = false
+	}
+	if until.After(p.until) {
+		p.until = until
+	}
+	p.all = p.all || all
+}
+
+func (p *pause) stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.until, p.all = time.Time{}, false
+	close(p.resume)
+	p.resume = make(chan struct{})
+}
+
+// pausedWriteCommands are held back by CLIENT PAUSE WRITE besides the
+// write commands, since they may change the store or be propagated.
+var pausedWriteCommands = map[string]bool{
+	"EVAL": true, "EVALSHA": true, "FCALL": true, "PUBLISH": true, "SPUBLISH": true, "PFCOUNT": true,
+}
+
+// wait holds c back while the clients are paused for the command it is
+// about to run, until the pause ends, or returns false if c goes away
+// meanwhile. CLIENT is never held back, so that a paused server can always
+// be unpaused.
+func (p *pause) wait(c *client, cmdName string) bool {
+	if cmdName == "CLIENT" {
+		return true
+	}
+	for {
+		p.mu.Lock()
+		remaining, all, resume := time.Until(p.until), p.all, p.resume
+		p.mu.Unlock()
+		if remaining <= 0 || !all && !pausedForWrites(c, cmdName) {
+			return true
+		}
+
+		timer := time.NewTimer(remaining)
+		select {
+		case <-timer.C:
+		case <-resume:
+		case <-c.done:
+			timer.Stop()
+			return false
+		}
+		timer.Stop()
+	}
+}
+
+// pausedForWrites reports whether CLIENT PAUSE WRITE holds back the command,
+// including an EXEC of a transaction that writes.
+func pausedForWrites(c *client, cmdName string) bool {
+	if cmdName == "EXEC" {
+		for _, queued := range c.queued {
+			if name, _ := queued[0].(string); writeCommands[strings.ToUpper(name)] {
+				return true
+			}
+		}
+		return false
+	}
+	return writeCommands[cmdName] || pausedWriteCommands[cmdName]
+}
+
+// clientCommand implements CLIENT ID, INFO, LIST, SETNAME, GETNAME, SETINFO,
+// KILL, PAUSE, UNPAUSE and NO-EVICT.
+func (h *ClientHandler) clientCommand(c *client, cmdArray []any) string {
+	args := argStrings(cmdArray[2:])
+	switch subcommand := strings.ToUpper(argString(cmdArray[1])); {
+	case subcommand == "ID" && len(args) == 0:
+		return ":" + strconv.FormatInt(c.id, 10) + "\r\n"
+	case subcommand == "INFO" && len(args) == 0:
+		return bulkReply(h.clientInfo(c) + "\n")
+	case subcommand == "LIST":
+		return h.clientList(args)
+	case subcommand == "SETNAME" && len(args) == 1:
+		if !validClientAttribute(args[0]) {
+			return "-ERR Client names cannot contain spaces, newlines or special characters.\r\n"
+		}
+		c.infoMu.Lock()
+		c.name = args[0]
+		c.infoMu.Unlock()
+		return "+OK\r\n"
+	case subcommand == "GETNAME" && len(args) == 0:
+		if c.name == "" {
+			return "$-1\r\n"
+		}
+		return bulkReply(c.name)
+	case subcommand == "SETINFO" && len(args) == 2:
+		return clientSetInfo(c, args[0], args[1])
+	case subcommand == "KILL" && len(args) == 1:
+		killed := h.clientKill(c, clientFilter{addr: args[0]})
+		if killed == 0 {
+			return "-ERR No such client\r\n"
+		}
+		return "+OK\r\n"
+	case subcommand == "KILL" && len(args) >= 2 && len(args)%2 == 0:
+		filter, reply := parseClientFilter(args)
+		if reply != "" {
+			return reply
+		}
+		return ":" + strconv.Itoa(h.clientKill(c, filter)) + "\r\n"
+	case subcommand == "PAUSE" && (len(args) == 1 || len(args) == 2):
+		timeout, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			return "-ERR timeout is not an integer or out of range\r\n"
+		}
+		if timeout < 0 {
+			return "-ERR timeout is negative\r\n"
+		}
+		all := true
+		if len(args) == 2 {
+			switch strings.ToUpper(args[1]) {
+			case "WRITE":
+				all = false
+			case "ALL":
+			default:
+				return "-ERR syntax error\r\n"
+			}
+		}
+		h.pause.start(time.Now().Add(time.Duration(timeout)*time.Millisecond), all)
+		return "+OK\r\n"
+	case subcommand == "UNPAUSE" && len(args) == 0:
+		h.pause.stop()
+		return "+OK\r\n"
+	case subcommand == "NO-EVICT" && len(args) == 1:
+		switch strings.ToUpper(args[0]) {
+		case "ON", "OFF":
+			c.infoMu.Lock()
+			c.noEvict = strings.EqualFold(args[0], "on")
+			c.infoMu.Unlock()
+			return "+OK\r\n"
+		default:
+			return "-ERR syntax error\r\n"
+		}
+	case slices.Contains([]string{"ID", "INFO", "SETNAME", "GETNAME", "SETINFO", "KILL", "PAUSE", "UNPAUSE", "NO-EVICT"}, subcommand):
+		return "-ERR wrong number of arguments for CLIENT " + subcommand + "\r\n"
+	default:
+		return "-ERR unknown subcommand '" + argString(cmdArray[1]) + "'. Try CLIENT HELP.\r\n"
+	}
+}
+
+// validClientAttribute reports whether s can be a client name or library
+// name or version: printable, without spaces.
+func validClientAttribute(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '!' || s[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func clientSetInfo(c *client, attribute, value string) string {
+	attribute = strings.ToLower(attribute)
+	if attribute != "lib-name" && attribute != "lib-ver" {
+		return "-ERR Unrecognized option '" + attribute + "'\r\n"
+	}
+	if !validClientAttribute(value) {
+		return "-ERR " + attribute + " cannot contain spaces, newlines or special characters.\r\n"
+	}
+	c.infoMu.Lock()
+	defer c.infoMu.Unlock()
+	if attribute == "lib-name" {
+		c.libName = value
+	} else {
+		c.libVer = value
+	}
+	return "+OK\r\n"
+}
+
+// clientType is the type CLIENT LIST and CLIENT KILL filter on: pubsub for
+// clients in subscriber mode, normal for the others.
+func clientType(c *client) string {
+	c.infoMu.Lock()
+	defer c.infoMu.Unlock()
+	if c.counts.sub+c.counts.psub+c.counts.ssub > 0 {
+		return "pubsub"
+	}
+	return "normal"
+}
+
+// clientTypes are the types of CLIENT LIST TYPE and CLIENT KILL TYPE. No
+// client is ever a master or a replica.
+var clientTypes = map[string]bool{"normal": true, "master": true, "replica": true, "slave": true, "pubsub": true}
+
+func (h *ClientHandler) clientList(args []string) string {
+	var clientTypeFilter string
+	var ids map[int64]bool
+	switch {
+	case len(args) == 0:
+	case len(args) == 2 && strings.EqualFold(args[0], "TYPE"):
+		clientTypeFilter = strings.ToLower(args[1])
+		if !clientTypes[clientTypeFilter] {
+			return "-ERR Unknown client type '" + args[1] + "'\r\n"
+		}
+	case len(args) >= 2 && strings.EqualFold(args[0], "ID"):
+		ids = make(map[int64]bool)
+		for _, arg := range args[1:] {
+			id, err := strconv.ParseInt(arg, 10, 64)
+			if err != nil || id <= 0 {
+				return "-ERR Invalid client ID\r\n"
+			}
+			ids[id] = true
+		}
+	default:
+		return "-ERR syntax error\r\n"
+	}
+
+	var b strings.Builder
+	for _, other := range h.clients.list() {
+		if clientTypeFilter != "" && clientType(other) != clientTypeFilter || ids != nil && !ids[other.id] {
+			continue
+		}
+		b.WriteString(h.clientInfo(other) + "\n")
+	}
+	return bulkReply(b.String())
+}
+
+// clientInfo describes a client on one line of "field=value" pairs, like
+// CLIENT LIST does.
+func (h *ClientHandler) clientInfo(c *client) string {
+	monitor := h.monitors.has(c)
+	c.infoMu.Lock()
+	defer c.infoMu.Unlock()
+	now := time.Now()
+	flags := ""
+	if monitor {
+		flags += "O"
+	}
+	if c.counts.sub+c.counts.psub+c.counts.ssub > 0 {
+		flags += "P"
+	}
+	if c.counts.multi >= 0 {
+		flags += "x"
+	}
+	if c.blocked {
+		flags += "b"
+	}
+	if c.noEvict {
+		flags += "e"
+	}
+	if flags == "" {
+		flags = "N"
+	}
+	queryBytes, outputBytes := c.queryBytes.Load(), c.outputBytes.Load()
+	return fmt.Sprintf("id=%d addr=%s laddr=%s name=%s age=%d idle=%d flags=%s db=0 sub=%d psub=%d ssub=%d multi=%d watch=%d "+
+		"qbuf=%d qbuf-free=%d obl=0 oll=%d omem=%d tot-mem=%d cmd=%s user=default resp=2 lib-name=%s lib-ver=%s",
+		c.id, c.conn.RemoteAddr(), c.conn.LocalAddr(), c.name,
+		int64(now.Sub(c.created).Seconds()), int64(now.Sub(c.lastInteraction).Seconds()), flags,
+		c.counts.sub, c.counts.psub, c.counts.ssub, c.counts.multi, c.counts.watch,
+		queryBytes, max(readBufferSize-queryBytes, 0), len(c.out), outputBytes, readBufferSize+outputBytes,
+		c.lastCommand, c.libName, c.libVer)
+}
+
+// clientFilter selects the clients CLIENT KILL disconnects. Empty fields
+// match every client.
+type clientFilter struct {
+	id         int64
+	clientType string
+	addr       string
+	laddr      string
+	user       string
+	maxAge     int64
+	skipMe     bool
+}
+
+func parseClientFilter(args []string) (clientFilter, string) {
+	filter := clientFilter{skipMe: true}
+	for i := 0; i < len(args); i += 2 {
+		value := args[i+1]
+		switch strings.ToUpper(args[i]) {
+		case "ID":
+			id, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || id <= 0 {
+				return filter, "-ERR client-id should be greater than 0\r\n"
+			}
+			filter.id = id
+		case "TYPE":
+			filter.clientType = strings.ToLower(value)
+			if !clientTypes[filter.clientType] {
+				return filter, "-ERR Unknown client type '" + value + "'\r\n"
+			}
+		case "ADDR":
+			filter.addr = value
+		case "LADDR":
+			filter.laddr = value
+		case "USER":
+			if value != "default" {
+				return filter, "-ERR No such user '" + value + "'\r\n"
+			}
+			filter.user = value
+		case "MAXAGE":
+			maxAge, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || maxAge < 0 {
+				return filter, "-ERR syntax error\r\n"
+			}
+			filter.maxAge = maxAge
+		case "SKIPME":
+			switch strings.ToLower(value) {
+			case "yes":
+				filter.skipMe = true
+			case "no":
+				filter.skipMe = false
+			default:
+				return filter, "-ERR syntax error\r\n"
+			}
+		default:
+			return filter, "-ERR syntax error\r\n"
+		}
+	}
+	return filter, ""
+}
+
+func (f clientFilter) match(c, other *client) bool {
+	switch {
+	case f.skipMe && other == c:
+		return false
+	case f.id != 0 && other.id != f.id:
+		return false
+	case f.clientType != "" && clientType(other) != f.clientType:
+		return false
+	case f.addr != "" && other.conn.RemoteAddr().String() != f.addr:
+		return false
+	case f.laddr != "" && other.conn.LocalAddr().String() != f.laddr:
+		return false
+	case f.maxAge > 0 && time.Since(other.created) < time.Duration(f.maxAge)*time.Second:
+		return false
+	}
+	return true
+}
+
+// clientKill disconnects the clients matching filter and returns how many.
+// The calling client c is disconnected once it got the reply.
+func (h *ClientHandler) clientKill(c *client, filter clientFilter) int {
+	killed := 0
+	for _, other := range h.clients.list() {
+		if !filter.match(c, other) {
+			continue
+		}
+		killed++
+		if other == c {
+			c.quit = true
+			continue
+		}
+		other.log.Info("Killed client")
+		other.close()
+		other.conn.Close()
+	}
+	return killed
+}
@@ -0,0 +1,204 @@
+package handler
+
+import (
+	"io"
+	"redis-go-clone/cmd/config"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clientFields sends CLIENT INFO and returns the fields of the reply.
+func (c *testConn) clientFields() map[string]string {
+	c.t.Helper()
+	c.send("CLIENT", "INFO")
+	return parseClientLine(c.readBulk())
+}
+
+func parseClientLine(line string) map[string]string {
+	fields := make(map[string]string)
+	for _, field := range strings.Fields(line) {
+		name, value, _ := strings.Cut(field, "=")
+		fields[name] = value
+	}
+	return fields
+}
+
+func clientID(fields map[string]string) int {
+	id, _ := strconv.Atoi(fields["id"])
+	return id
+}
+
+// listClients sends CLIENT LIST with args and returns the fields of every
+// client listed.
+func (c *testConn) listClients(args ...string) []map[string]string {
+	c.t.Helper()
+	c.send(append([]string{"CLIENT", "LIST"}, args...)...)
+	var clients []map[string]string
+	for _, line := range strings.Split(strings.TrimSuffix(c.readBulk(), "\n"), "\n") {
+		if line != "" {
+			clients = append(clients, parseClientLine(line))
+		}
+	}
+	return clients
+}
+
+func TestClientAttributes(t *testing.T) {
+	h := NewClientHandler(config.NewConfig())
+	client := connect(t, h)
+	admin := connect(t, h)
+
+	id := client.clientFields()["id"]
+	client.send("CLIENT", "ID")
+	client.expect(":" + id + "\r\n")
+
+	client.send("CLIENT", "GETNAME")
+	client.expect("$-1\r\n")
+	client.send("CLIENT", "SETNAME", "billing")
+	client.expect("+OK\r\n")
+	client.send("CLIENT", "SETNAME", "bad name")
+	client.expect("-ERR Client names cannot contain spaces, newlines or special characters.\r\n")
+	client.send("CLIENT", "GETNAME")
+	client.expect("$7\r\nbilling\r\n")
+	client.send("CLIENT", "SETINFO", "LIB-NAME", "go-redis")
+	client.expect("+OK\r\n")
+	client.send("CLIENT", "SETINFO", "lib-ver", "9.0.0")
+	client.expect("+OK\r\n")
+	client.send("CLIENT", "SETINFO", "lib-color", "red")
+	client.expect("-ERR Unrecognized option 'lib-color'\r\n")
+	client.send("CLIENT", "NO-EVICT", "on")
+	client.expect("+OK\r\n")
+	client.send("MULTI")
+	client.expect("+OK\r\n")
+	client.send("SET", "k", "v")
+	client.expect("+QUEUED\r\n")
+
+	fields := admin.listClients("ID", id)[0]
+	for name, want := range map[string]string{
+		"name": "billing", "lib-name": "go-redis", "lib-ver": "9.0.0", "flags": "xe", "multi": "1",
+		"db": "0", "sub": "0", "cmd": "set", "age": "0", "idle": "0",
+	} {
+		if fields[name] != want {
+			t.Errorf("CLIENT LIST %s = %q, want %q", name, fields[name], want)
+		}
+	}
+	if fields := admin.clientFields(); fields["cmd"] != "client|info" || fields["flags"] != "N" {
+		t.Errorf("CLIENT INFO = %v", fields)
+	}
+
+	client.send("DISCARD")
+	client.expect("+OK\r\n")
+	client.send("CLIENT", "NOPE")
+	client.expect("-ERR unknown subcommand 'NOPE'. Try CLIENT HELP.\r\n")
+	client.send("CLIENT", "GETNAME", "x")
+	client.expect("-ERR wrong number of arguments for CLIENT GETNAME\r\n")
+}
+
+func TestClientList(t *testing.T) {
+	h := NewClientHandler(config.NewConfig())
+	admin := connect(t, h)
+	subscriber := connect(t, h)
+	subscriber.send("SUBSCRIBE", "news")
+	subscriber.expect("*3\r\n$9\r\nsubscribe\r\n$4\r\nnews\r\n:1\r\n")
+
+	if all := admin.listClients(); len(all) != 2 || clientID(all[0]) >= clientID(all[1]) {
+		t.Fatalf("CLIENT LIST = %v, want 2 clients by id", all)
+	}
+	pubsub := admin.listClients("TYPE", "pubsub")
+	if len(pubsub) != 1 || pubsub[0]["cmd"] != "subscribe" || pubsub[0]["flags"] != "P" || pubsub[0]["sub"] != "1" {
+		t.Fatalf("CLIENT LIST TYPE pubsub = %v", pubsub)
+	}
+	if normal := admin.listClients("TYPE", "normal"); len(normal) != 1 || normal[0]["cmd"] != "client|list" || normal[0]["flags"] != "N" {
+		t.Errorf("CLIENT LIST TYPE normal = %v", normal)
+	}
+	if byID := admin.listClients("ID", pubsub[0]["id"], "12345678"); len(byID) != 1 || byID[0]["id"] != pubsub[0]["id"] {
+		t.Errorf("CLIENT LIST ID = %v", byID)
+	}
+
+	admin.send("CLIENT", "LIST", "TYPE", "robot")
+	admin.expect("-ERR Unknown client type 'robot'\r\n")
+	admin.send("CLIENT", "LIST", "ID", "x")
+	admin.expect("-ERR Invalid client ID\r\n")
+}
+
+// expectClosed waits until the server closes the connection.
+func (c *testConn) expectClosed() {
+	c.t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := c.conn.Read(make([]byte, 64)); err != io.EOF {
+		c.t.Fatalf("expected the connection to be closed, got %v", err)
+	}
+}
+
+func TestClientKill(t *testing.T) {
+	h := NewClientHandler(config.NewConfig())
+	admin := connect(t, h)
+	blocked := connect(t, h)
+	blockedID := blocked.clientFields()["id"]
+	blocked.send("BZPOPMIN", "queue", "0")
+
+	for deadline := time.Now().Add(time.Second); h.blocked.count() == 0; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("the client never blocked")
+		}
+	}
+	if clients := admin.listClients("ID", blockedID); len(clients) != 1 || clients[0]["flags"] != "b" {
+		t.Errorf("blocked client is listed as %v", clients)
+	}
+
+	admin.send("CLIENT", "KILL", "ID", blockedID)
+	admin.expect(":1\r\n")
+	blocked.expectClosed()
+	for deadline := time.Now().Add(time.Second); len(admin.listClients()) > 1 || h.blocked.count() > 0; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("the killed client is still connected or blocked")
+		}
+	}
+
+	// The caller is skipped unless SKIPME no is given.
+	admin.send("CLIENT", "KILL", "TYPE", "normal", "MAXAGE", "0")
+	admin.expect(":0\r\n")
+	admin.send("CLIENT", "KILL", "ID", "0")
+	admin.expect("-ERR client-id should be greater than 0\r\n")
+	admin.send("CLIENT", "KILL", "USER", "alice")
+	admin.expect("-ERR No such user 'alice'\r\n")
+	admin.send("CLIENT", "KILL", "1.2.3.4:5")
+	admin.expect("-ERR No such client\r\n")
+	admin.send("CLIENT", "KILL", "USER", "default", "SKIPME", "no")
+	admin.expect(":1\r\n")
+	admin.expectClosed()
+}
+
+func TestClientPause(t *testing.T) {
+	h := NewClientHandler(config.NewConfig())
+	admin := connect(t, h)
+	client := connect(t, h)
+
+	admin.send("CLIENT", "PAUSE", "10000", "WRITE")
+	admin.expect("+OK\r\n")
+	client.send("GET", "k")
+	client.expect("$-1\r\n")
+	client.send("SET", "k", "v")
+	client.conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	if n, _ := client.conn.Read(make([]byte, 16)); n != 0 {
+		t.Fatal("SET ran while writes were paused")
+	}
+	admin.send("CLIENT", "UNPAUSE")
+	admin.expect("+OK\r\n")
+	client.expect("+OK\r\n")
+
+	admin.send("CLIENT", "PAUSE", "100", "ALL")
+	admin.expect("+OK\r\n")
+	start := time.Now()
+	client.send("PING")
+	client.expect("+PONG\r\n")
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("PING replied after %v while all clients were paused for 100ms", elapsed)
+	}
+
+	admin.send("CLIENT", "PAUSE", "-1")
+	admin.expect("-ERR timeout is negative\r\n")
+	admin.send("CLIENT", "PAUSE", "10", "READ")
+	admin.expect("-ERR syntax error\r\n")
+}
@@ -1,51 +1,359 @@
 package handler
 
 import (
-	"log"
 	"net"
+	"os"
 	"redis-go-clone/cmd/config"
+	"redis-go-clone/internal/latency"
+	"redis-go-clone/internal/logging"
+	"redis-go-clone/internal/memory"
 	"redis-go-clone/internal/model"
+	"redis-go-clone/internal/notify"
+	"redis-go-clone/internal/pubsub"
 	"redis-go-clone/internal/redis_command"
-	"redis-go-clone/pkg/resp"
+	"redis-go-clone/internal/stats"
 	"strings"
 	"sync"
+	"time"
 )
 
 type ClientHandler struct {
-	config *config.Config
+	config    *config.Config
+	blocked   *blockedClients
+	pubsub    *pubsub.PubSub
+	scripting *scripting
+	slowlog   *slowlog
+	monitors  *monitors
+	clients   *clients
+	pause     *pause
+	// exit ends the process on SHUTDOWN.
+	exit func(code int)
 }
 
 func NewClientHandler(config *config.Config) *ClientHandler {
-	return &ClientHandler{config: config}
+	h := &ClientHandler{
+		config:   config,
+		blocked:  newBlockedClients(config.DB, config.Lock),
+		pubsub:   pubsub.New(),
+		slowlog:  &slowlog{},
+		monitors: newMonitors(),
+		clients:  newClients(),
+		pause:    newPause(),
+		exit:     os.Exit,
+	}
+	h.scripting = newScripting(h)
+	redis_command.SetKeyReadyHook(h.blocked.signalKeyAsReady)
+	redis_command.SetFunctionsDumpHook(h.scripting.dumpLibraries)
+	memory.Reset()
+	stats.Reset()
+	latency.Reset()
+	notify.SetPublisher(h.pubsub.Publish)
+	return h
 }
 
-func (h *ClientHandler) HandleClient(conn net.Conn) {
-	defer conn.Close()
+// The arity of a command follows the Redis convention: a positive arity is
+// the exact number of arguments including the command name, a negative one
+// the minimum. Commands validate their own arguments when they run; arity is
+// only checked up front when a command is queued inside MULTI, so that EXEC
+// can refuse a transaction before any of it runs.
 
-	buffer := make([]byte, 4096)
-	for {
-		n, err := conn.Read(buffer)
-		if err != nil {
-			log.Printf("Error reading from client: %v", err)
-			return
-		}
+type storeCommand struct {
+	arity int
+	run   func([]any, map[string]model.StoredData, *sync.RWMutex) string
+}
+
+// blockingCommand may park the client until one of its keys is ready.
+type blockingCommand struct {
+	arity int
+	run   func([]any, map[string]model.StoredData, *sync.RWMutex) (string, *redis_command.BlockingRequest)
+}
+
+// connectionCommand needs the state of the calling connection.
+type connectionCommand struct {
+	arity int
+	run   func(h *ClientHandler, c *client, cmdArray []any) string
+}
+
+var storeCommands = map[string]storeCommand{
+	"SET":              {-3, redis_command.Set},
+	"GET":              {2, redis_command.Get},
+	"EXIST":            {-2, redis_command.Exist},
+	"DEL":              {-2, redis_command.Del},
+	"LPUSH":            {-3, redis_command.LPush},
+	"RPUSH":            {-3, redis_command.RPush},
+	"INCR":             {2, redis_command.Incr},
+	"DECR":             {2, redis_command.Decr},
+	"SAVE":             {1, redis_command.Save},
+	"HSET":             {-4, redis_command.HSet},
+	"HGET":             {3, redis_command.HGet},
+	"HDEL":             {-3, redis_command.HDel},
+	"HGETALL":          {2, redis_command.HGetAll},
+	"HEXPIRE":          {-6, redis_command.HExpire},
+	"HPEXPIRE":         {-6, redis_command.HPExpire},
+	"HEXPIREAT":        {-6, redis_command.HExpireAt},
+	"HPEXPIREAT":       {-6, redis_command.HPExpireAt},
+	"HTTL":             {-5, redis_command.HTTL},
+	"HPTTL":            {-5, redis_command.HPTTL},
+	"HPERSIST":         {-5, redis_command.HPersist},
+	"SADD":             {-3, redis_command.SAdd},
+	"SREM":             {-3, redis_command.SRem},
+	"SISMEMBER":        {3, redis_command.SIsMember},
+	"SMISMEMBER":       {-3, redis_command.SMIsMember},
+	"SMEMBERS":         {2, redis_command.SMembers},
+	"SCARD":            {2, redis_command.SCard},
+	"SPOP":             {-2, redis_command.SPop},
+	"SRANDMEMBER":      {-2, redis_command.SRandMember},
+	"SMOVE":            {4, redis_command.SMove},
+	"SINTER":           {-2, redis_command.SInter},
+	"SINTERCARD":       {-3, redis_command.SInterCard},
+	"SUNION":           {-2, redis_command.SUnion},
+	"SDIFF":            {-2, redis_command.SDiff},
+	"SINTERSTORE":      {-3, redis_command.SInterStore},
+	"SUNIONSTORE":      {-3, redis_command.SUnionStore},
+	"SDIFFSTORE":       {-3, redis_command.SDiffStore},
+	"SSCAN":            {-3, redis_command.SScan},
+	"ZADD":             {-4, redis_command.ZAdd},
+	"ZINCRBY":          {4, redis_command.ZIncrBy},
+	"ZREM":             {-3, redis_command.ZRem},
+	"ZSCORE":           {3, redis_command.ZScore},
+	"ZMSCORE":          {-3, redis_command.ZMScore},
+	"ZCARD":            {2, redis_command.ZCard},
+	"ZRANK":            {-3, redis_command.ZRank},
+	"ZREVRANK":         {-3, redis_command.ZRevRank},
+	"ZRANGE":           {-4, redis_command.ZRange},
+	"ZRANGESTORE":      {-5, redis_command.ZRangeStore},
+	"ZCOUNT":           {4, redis_command.ZCount},
+	"ZLEXCOUNT":        {4, redis_command.ZLexCount},
+	"ZPOPMIN":          {-2, redis_command.ZPopMin},
+	"ZPOPMAX":          {-2, redis_command.ZPopMax},
+	"ZREMRANGEBYRANK":  {4, redis_command.ZRemRangeByRank},
+	"ZREMRANGEBYSCORE": {4, redis_command.ZRemRangeByScore},
+	"ZREMRANGEBYLEX":   {4, redis_command.ZRemRangeByLex},
+	"ZUNIONSTORE":      {-4, redis_command.ZUnionStore},
+	"ZINTERSTORE":      {-4, redis_command.ZInterStore},
+	"ZDIFFSTORE":       {-4, redis_command.ZDiffStore},
+	"ZUNION":           {-3, redis_command.ZUnion},
+	"ZINTER":           {-3, redis_command.ZInter},
+	"ZDIFF":            {-3, redis_command.ZDiff},
+	"ZSCAN":            {-3, redis_command.ZScan},
+	"SETBIT":           {4, redis_command.SetBit},
+	"GETBIT":           {3, redis_command.GetBit},
+	"BITCOUNT":         {-2, redis_command.BitCount},
+	"BITPOS":           {-3, redis_command.BitPos},
+	"BITOP":            {-4, redis_command.BitOp},
+	"BITFIELD":         {-2, redis_command.BitField},
+	"BITFIELD_RO":      {-2, redis_command.BitFieldRO},
+	"PFADD":            {-2, redis_command.PFAdd},
+	"PFCOUNT":          {-2, redis_command.PFCount},
+	"PFMERGE":          {-2, redis_command.PFMerge},
+	"PFDEBUG":          {3, redis_command.PFDebug},
+	"GEOADD":           {-5, redis_command.GeoAdd},
+	"GEOPOS":           {-2, redis_command.GeoPos},
+	"GEODIST":          {-4, redis_command.GeoDist},
+	"GEOHASH":          {-2, redis_command.GeoHash},
+	"GEOSEARCH":        {-7, redis_command.GeoSearch},
+	"GEOSEARCHSTORE":   {-8, redis_command.GeoSearchStore},
+	"XADD":             {-5, redis_command.XAdd},
+	"XRANGE":           {-4, redis_command.XRange},
+	"XREVRANGE":        {-4, redis_command.XRevRange},
+	"XLEN":             {2, redis_command.XLen},
+	"XDEL":             {-3, redis_command.XDel},
+	"XTRIM":            {-4, redis_command.XTrim},
+	"XINFO":            {-2, redis_command.XInfo},
+	"XGROUP":           {-2, redis_command.XGroup},
+	"XACK":             {-4, redis_command.XAck},
+	"XPENDING":         {-3, redis_command.XPending},
+	"XCLAIM":           {-6, redis_command.XClaim},
+	"XAUTOCLAIM":       {-6, redis_command.XAutoClaim},
+	"ZMPOP":            {-4, redis_command.ZMPop},
+	"CONFIG":           {-2, configCommand},
+	"FLUSHALL":         {-1, redis_command.FlushAll},
+	"FLUSHDB":          {-1, redis_command.FlushAll},
+}
+
+var blockingCommands = map[string]blockingCommand{
+	"BZPOPMIN":   {-3, redis_command.BZPopMin},
+	"BZPOPMAX":   {-3, redis_command.BZPopMax},
+	"BZMPOP":     {-5, redis_command.BZMPop},
+	"XREAD":      {-4, redis_command.XRead},
+	"XREADGROUP": {-7, redis_command.XReadGroup},
+}
+
+// scriptingCommand needs the connection and takes the store lock itself, to
+// hold it across all the commands a script calls.
+type scriptingCommand struct {
+	arity int
+	run   func(h *ClientHandler, c *client, cmdArray []any, mu *sync.RWMutex) string
+}
+
+// serverCommand inspects or manages the server itself. Like a
+// scriptingCommand it takes the store lock itself when it reads the store.
+type serverCommand struct {
+	arity int
+	run   func(h *ClientHandler, c *client, cmdArray []any, mu *sync.RWMutex) string
+}
+
+var (
+	connectionCommands map[string]connectionCommand
+	scriptingCommands  map[string]scriptingCommand
+	serverCommands     map[string]serverCommand
+)
+
+// The maps are filled in init because EXEC and scripts dispatch through
+// them.
+func init() {
+	connectionCommands = map[string]connectionCommand{
+		"SUBSCRIBE":    {-2, (*ClientHandler).subscribe},
+		"UNSUBSCRIBE":  {-1, (*ClientHandler).unsubscribe},
+		"PSUBSCRIBE":   {-2, (*ClientHandler).psubscribe},
+		"PUNSUBSCRIBE": {-1, (*ClientHandler).punsubscribe},
+		"SSUBSCRIBE":   {-2, (*ClientHandler).ssubscribe},
+		"SUNSUBSCRIBE": {-1, (*ClientHandler).sunsubscribe},
+		"PUBLISH":      {3, (*ClientHandler).publish},
+		"SPUBLISH":     {3, (*ClientHandler).spublish},
+		"PUBSUB":       {-2, (*ClientHandler).pubsubIntrospection},
+		"PING":         {-1, (*ClientHandler).ping},
+		"QUIT":         {-1, (*ClientHandler).quit},
+		"RESET":        {1, (*ClientHandler).reset},
+		"MULTI":        {1, (*ClientHandler).multi},
+		"EXEC":         {1, (*ClientHandler).exec},
+		"DISCARD":      {1, (*ClientHandler).discard},
+		"WATCH":        {-2, (*ClientHandler).watch},
+		"UNWATCH":      {1, (*ClientHandler).unwatch},
+		"MONITOR":      {1, (*ClientHandler).monitor},
+		"CLIENT":       {-2, (*ClientHandler).clientCommand},
+	}
+	scriptingCommands = map[string]scriptingCommand{
+		"EVAL":       {-3, (*ClientHandler).eval},
+		"EVALSHA":    {-3, (*ClientHandler).evalSha},
+		"EVAL_RO":    {-3, (*ClientHandler).evalRO},
+		"EVALSHA_RO": {-3, (*ClientHandler).evalShaRO},
+		"SCRIPT":     {-2, (*ClientHandler).scriptCommand},
+		"FCALL":      {-3, (*ClientHandler).fcall},
+		"FCALL_RO":   {-3, (*ClientHandler).fcallRO},
+		"FUNCTION":   {-2, (*ClientHandler).functionCommand},
+	}
+	serverCommands = map[string]serverCommand{
+		"MEMORY":   {-2, (*ClientHandler).memoryCommand},
+		"INFO":     {-1, (*ClientHandler).info},
+		"SLOWLOG":  {-2, (*ClientHandler).slowlogCommand},
+		"LATENCY":  {-2, (*ClientHandler).latencyCommand},
+		"SHUTDOWN": {-1, (*ClientHandler).shutdown},
+	}
+}
+
+// writeCommands may modify the store.
+var writeCommands = map[string]bool{
+	"SET": true, "DEL": true, "LPUSH": true, "RPUSH": true, "INCR": true, "DECR": true,
+	"HSET": true, "HDEL": true, "HEXPIRE": true, "HPEXPIRE": true, "HEXPIREAT": true,
+	"HPEXPIREAT": true, "HPERSIST": true,
+	"SADD": true, "SREM": true, "SPOP": true, "SMOVE": true,
+	"SINTERSTORE": true, "SUNIONSTORE": true, "SDIFFSTORE": true,
+	"ZADD": true, "ZINCRBY": true, "ZREM": true, "ZRANGESTORE": true, "ZPOPMIN": true,
+	"ZPOPMAX": true, "ZREMRANGEBYRANK": true, "ZREMRANGEBYSCORE": true, "ZREMRANGEBYLEX": true,
+	"ZUNIONSTORE": true, "ZINTERSTORE": true, "ZDIFFSTORE": true, "ZMPOP": true,
+	"BZPOPMIN": true, "BZPOPMAX": true, "BZMPOP": true,
+	"SETBIT": true, "BITOP": true, "BITFIELD": true,
+	"PFADD": true, "PFMERGE": true, "PFDEBUG": true,
+	"GEOADD": true, "GEOSEARCHSTORE": true,
+	"XADD": true, "XDEL": true, "XTRIM": true, "XGROUP": true, "XACK": true,
+	"XCLAIM": true, "XAUTOCLAIM": true, "XREADGROUP": true,
+	"FLUSHALL": true, "FLUSHDB": true,
+}
+
+// noScriptCommands cannot be called from scripts.
+var noScriptCommands = map[string]bool{
+	"SUBSCRIBE": true, "UNSUBSCRIBE": true, "PSUBSCRIBE": true, "PUNSUBSCRIBE": true,
+	"SSUBSCRIBE": true, "SUNSUBSCRIBE": true,
+	"MULTI": true, "EXEC": true, "DISCARD": true, "WATCH": true, "UNWATCH": true,
+	"QUIT": true, "RESET": true, "SAVE": true, "SHUTDOWN": true, "CONFIG": true,
+	"EVAL": true, "EVALSHA": true, "EVAL_RO": true, "EVALSHA_RO": true, "SCRIPT": true,
+	"FCALL": true, "FCALL_RO": true, "FUNCTION": true, "MONITOR": true, "CLIENT": true,
+}
+
+// containerCommands have subcommands, which CLIENT LIST shows with the
+// command as in client|list.
+var containerCommands = map[string]bool{
+	"CLIENT": true, "CONFIG": true, "MEMORY": true, "SLOWLOG": true, "LATENCY": true,
+	"SCRIPT": true, "FUNCTION": true, "PUBSUB": true, "XINFO": true, "XGROUP": true,
+}
+
+// skipSlowlogCommands are never logged in the slow log. EXEC is not, since
+// the commands of the transaction are logged one by one.
+var skipSlowlogCommands = map[string]bool{"EXEC": true}
+
+// commandArity returns the arity of a command and whether it exists.
+func commandArity(name string) (int, bool) {
+	if cmd, found := storeCommands[name]; found {
+		return cmd.arity, true
+	}
+	if cmd, found := blockingCommands[name]; found {
+		return cmd.arity, true
+	}
+	if cmd, found := connectionCommands[name]; found {
+		return cmd.arity, true
+	}
+	if cmd, found := scriptingCommands[name]; found {
+		return cmd.arity, true
+	}
+	if cmd, found := serverCommands[name]; found {
+		return cmd.arity, true
+	}
+	return 0, false
+}
 
-		// Deserialize the RESP message
-		input := string(buffer[:n])
-		command, err := resp.DeserializeRESP(input)
-		if err != nil {
-			log.Printf("Invalid RESP message: %v", err)
-			conn.Write([]byte("-ERR invalid RESP message\r\n"))
+const (
+	// readBufferSize is how many bytes of requests are read from a
+	// connection at once, at least.
+	readBufferSize = 4096
+	// maxIdleBufferSize is the largest read buffer a connection keeps
+	// once it has no request pending.
+	maxIdleBufferSize = 64 * 1024
+	// maxQueryBufferSize is how large a request may grow before the
+	// client is disconnected, like Redis' client-query-buffer-limit.
+	maxQueryBufferSize = 1 << 30
+)
+
+func (h *ClientHandler) HandleClient(conn net.Conn) {
+	c := newClient(conn)
+	stats.ClientConnected()
+	h.clients.add(c)
+	logging.Verbose(c.log, "Accepted client")
+	defer func() {
+		stats.ClientDisconnected()
+		h.clients.remove(c)
+		h.monitors.remove(c)
+		h.unsubscribeAll(c)
+		c.resetTransaction()
+		c.close()
+	}()
+
+	requests := make(chan request)
+	go c.readLoop(requests)
+	for req := range requests {
+		if req.err != nil {
+			logging.Verbose(c.log, "Invalid RESP message", "error", req.err)
+			c.write("-ERR invalid RESP message\r\n")
 			continue
 		}
+		c.queryBytes.Store(int64(req.size))
 
 		// Process the command
-		response := processCommand(command, h.config.DB, h.config.Lock)
-		conn.Write([]byte(response))
+		cmdArray, _ := req.command.([]any)
+		c.beginCommand(cmdArray)
+		reply := h.executeCommand(c, req.command)
+		c.queryBytes.Store(0)
+		c.endCommand()
+		c.write(reply)
+		if c.quit {
+			return
+		}
 	}
 }
 
-func processCommand(command any, db map[string]model.StoredData, mu *sync.RWMutex) string {
+// executeCommand runs a client command, queueing it instead when the client
+// is inside MULTI.
+func (h *ClientHandler) executeCommand(c *client, command any) string {
 	// Ensure the command is an array
 	cmdArray, ok := command.([]any)
 	if !ok || len(cmdArray) == 0 {
@@ -53,33 +361,112 @@ func processCommand(command any, db map[string]model.StoredData, mu *sync.RWMute
 	}
 
 	// First element is the command name
-	cmdName, ok := cmdArray[0].(string)
+	name, ok := cmdArray[0].(string)
 	if !ok {
 		return "-ERR invalid command name\r\n"
 	}
-	cmdName = strings.ToUpper(cmdName)
-
-	// Handle supported commands
-	switch cmdName {
-	case "SET":
-		return redis_command.Set(cmdArray, db, mu)
-	case "GET":
-		return redis_command.Get(cmdArray, db, mu)
-	case "EXIST":
-		return redis_command.Exist(cmdArray, db, mu)
-	case "DEL":
-		return redis_command.Del(cmdArray, db, mu)
-	case "LPUSH":
-		return redis_command.LPush(cmdArray, db, mu)
-	case "RPUSH":
-		return redis_command.RPush(cmdArray, db, mu)
-	case "INCR":
-		return redis_command.Incr(cmdArray, db, mu)
-	case "DECR":
-		return redis_command.Decr(cmdArray, db, mu)
-	case "SAVE":
-		return redis_command.Save(cmdArray, db, mu)
-	default:
-		return "-ERR unknown command\r\n"
+	cmdName := strings.ToUpper(name)
+
+	if reply := h.scripting.busyReply(); reply != "" && !allowedWhileBusy(cmdName, cmdArray) {
+		return rejected(cmdName, reply)
+	}
+	if c.subscribed() && !subscriberCommands[cmdName] {
+		return rejected(cmdName, "-ERR Can't execute '"+strings.ToLower(name)+"': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context\r\n")
+	}
+	if !allowedWhileBusy(cmdName, cmdArray) {
+		if reply := h.freeMemory(cmdName); reply != "" {
+			// Like any command refused inside MULTI, it fails the transaction.
+			if c.multi {
+				c.multiFailed = true
+			}
+			return rejected(cmdName, reply)
+		}
+	}
+	if c.multi && !transactionCommands[cmdName] {
+		return h.queueCommand(c, cmdName, cmdArray)
+	}
+	if !h.pause.wait(c, cmdName) {
+		// The client was killed while paused: its command must not run.
+		return ""
+	}
+	return h.runCommand(c, cmdName, cmdArray, h.config.Lock, callBlock|callSlowlog)
+}
+
+// callFlags tell runCommand how a command was called.
+type callFlags int
+
+const (
+	// callBlock lets a blocking command park the connection instead of
+	// replying right away.
+	callBlock callFlags = 1 << iota
+	// callSlowlog logs the command in the slow log if it is slow.
+	callSlowlog
+	// callScript marks a command called by a script.
+	callScript
+)
+
+// rejected counts a command refused before it ran and returns the refusal.
+func rejected(cmdName, reply string) string {
+	if _, found := commandArity(cmdName); !found {
+		cmdName = ""
+	}
+	stats.Rejected(strings.ToLower(cmdName), reply)
+	return reply
+}
+
+// runCommand dispatches a command by its upper-cased name, shows it to the
+// monitors, counts it and times it. Store commands take mu around their
+// access to the store.
+func (h *ClientHandler) runCommand(c *client, cmdName string, cmdArray []any, mu *sync.RWMutex, flags callFlags) string {
+	if _, found := commandArity(cmdName); !found {
+		return rejected(cmdName, "-ERR unknown command\r\n")
+	}
+
+	if monitored(cmdName, cmdArray) {
+		h.monitors.feed(c, cmdArray, flags&callScript != 0)
+	}
+
+	// The time a blocked client waits is not part of the command.
+	start := time.Now()
+	reply, request := h.dispatch(c, cmdName, cmdArray, mu)
+	if request != nil && flags&callBlock == 0 {
+		reply, request = request.TimeoutReply, nil
+	}
+	duration := time.Since(start)
+	stats.Call(strings.ToLower(cmdName), duration, reply)
+	if flags&callSlowlog != 0 && !skipSlowlogCommands[cmdName] {
+		h.slowlog.record(c, cmdArray, start, duration)
+		latency.Add(latency.Command, duration)
+	}
+	if request != nil {
+		c.setBlocked(true)
+		defer c.setBlocked(false)
+		return h.blocked.block(request, c.done)
+	}
+	return reply
+}
+
+// dispatch runs a command, or returns the request a blocking command parks
+// the client with.
+func (h *ClientHandler) dispatch(c *client, cmdName string, cmdArray []any, mu *sync.RWMutex) (string, *redis_command.BlockingRequest) {
+	if cmd, found := connectionCommands[cmdName]; found {
+		return cmd.run(h, c, cmdArray), nil
+	}
+	if cmd, found := scriptingCommands[cmdName]; found {
+		return cmd.run(h, c, cmdArray, mu), nil
+	}
+	if cmd, found := serverCommands[cmdName]; found {
+		return cmd.run(h, c, cmdArray, mu), nil
+	}
+	if cmd, found := blockingCommands[cmdName]; found {
+		reply, request := cmd.run(cmdArray, h.config.DB, mu)
+		if request == nil {
+			h.touchKeys(cmdName, cmdArray, mu)
+		}
+		return reply, request
 	}
+	cmd := storeCommands[cmdName]
+	reply := cmd.run(cmdArray, h.config.DB, mu)
+	h.touchKeys(cmdName, cmdArray, mu)
+	return reply, nil
 }
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"redis-go-clone/cmd/config"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestPipelinedCommands(t *testing.T) {
+	h := NewClientHandler(config.NewConfig())
+	client := connect(t, h)
+
+	client.sendRaw(encodeCommand("SET", "p1", "1") + encodeCommand("SET", "p2", "2") + encodeCommand("GET", "p2"))
+	client.expect("+OK\r\n+OK\r\n:2\r\n")
+	client.send("GET", "p1")
+	client.expect(":1\r\n")
+}
+
+func TestCommandSplitAcrossWrites(t *testing.T) {
+	h := NewClientHandler(config.NewConfig())
+	client := connect(t, h)
+
+	// The first write ends in the middle of the second command.
+	pipeline := encodeCommand("SET", "k", "value") + encodeCommand("GET", "k")
+	split := len(pipeline) - 5
+	client.sendRaw(pipeline[:split])
+	client.expect("+OK\r\n")
+	client.sendRaw(pipeline[split:])
+	client.expect("$5\r\nvalue\r\n")
+}
+
+func TestLargeValue(t *testing.T) {
+	h := NewClientHandler(config.NewConfig())
+	client := connect(t, h)
+
+	value := strings.Repeat("x", 3*readBufferSize+100)
+	client.send("SET", "big", value)
+	client.expect("+OK\r\n")
+	client.send("GET", "big")
+	client.expect("$" + strconv.Itoa(len(value)) + "\r\n" + value + "\r\n")
+}
+
+func TestInvalidRequest(t *testing.T) {
+	h := NewClientHandler(config.NewConfig())
+	client := connect(t, h)
+
+	client.sendRaw("?\r\n")
+	client.expect("-ERR invalid RESP message\r\n")
+	client.send("PING")
+	client.expect("+PONG\r\n")
+}
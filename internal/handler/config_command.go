@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"errors"
+	"redis-go-clone/internal/latency"
+	"redis-go-clone/internal/logging"
+	"redis-go-clone/internal/memory"
+	"redis-go-clone/internal/model"
+	"redis-go-clone/internal/notify"
+	"redis-go-clone/pkg/glob"
+	"redis-go-clone/pkg/resp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// configParam is a parameter that can be read and changed at runtime
+// through CONFIG GET and CONFIG SET.
+type configParam struct {
+	get func() string
+	set func(value string) error
+}
+
+var configParams = map[string]configParam{
+	"notify-keyspace-events":    {get: notify.Flags, set: notify.SetFlags},
+	"busy-reply-threshold":      {get: getScriptTimeLimit, set: setScriptTimeLimit},
+	"lua-time-limit":            {get: getScriptTimeLimit, set: setScriptTimeLimit},
+	"maxmemory":                 {get: memory.MaxMemory, set: memory.SetMaxMemory},
+	"maxmemory-policy":          {get: memory.Policy, set: memory.SetPolicy},
+	"maxmemory-samples":         {get: memory.Samples, set: memory.SetSamples},
+	"slowlog-log-slower-than":   {get: getSlowlogLogSlowerThan, set: setSlowlogLogSlowerThan},
+	"slowlog-max-len":           {get: getSlowlogMaxLen, set: setSlowlogMaxLen},
+	"latency-monitor-threshold": {get: latency.Threshold, set: latency.SetThreshold},
+	"loglevel":                  {get: logging.Level, set: logging.SetLevel},
+	"logfile":                   {get: logging.File, set: immutableConfig},
+	"log-format":                {get: logging.Format, set: immutableConfig},
+}
+
+// immutableConfig is the setter of the parameters that can only be set when
+// the server starts.
+func immutableConfig(string) error {
+	return errors.New("can't set immutable config")
+}
+
+// configCommand implements CONFIG GET parameter [parameter ...] and CONFIG
+// SET parameter value [parameter value ...].
+func configCommand(cmdArray []any, _ map[string]model.StoredData, _ *sync.RWMutex) string {
+	if len(cmdArray) < 2 {
+		return "-ERR wrong number of arguments for CONFIG\r\n"
+	}
+
+	switch subcommand := strings.ToUpper(argString(cmdArray[1])); {
+	case subcommand == "GET" && len(cmdArray) >= 3:
+		return configGet(argStrings(cmdArray[2:]))
+	case subcommand == "SET" && len(cmdArray) >= 4 && len(cmdArray)%2 == 0:
+		return configSet(argStrings(cmdArray[2:]))
+	case subcommand == "GET" || subcommand == "SET":
+		return "-ERR wrong number of arguments for CONFIG " + subcommand + "\r\n"
+	default:
+		return "-ERR unknown subcommand '" + argString(cmdArray[1]) + "'. Try CONFIG HELP.\r\n"
+	}
+}
+
+// configGet replies with the name and value of every parameter matching one
+// of the glob patterns.
+func configGet(patterns []string) string {
+	names := make([]string, 0, len(configParams))
+	for name := range configParams {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	result := []any{}
+	for _, name := range names {
+		for _, pattern := range patterns {
+			if glob.Match(strings.ToLower(pattern), name) {
+				result = append(result, name, configParams[name].get())
+				break
+			}
+		}
+	}
+	return resp.SerializeRESP(result, false)
+}
+
+// configSet applies every name/value pair, or none of them: when a value is
+// rejected the parameters already set get their previous value back.
+func configSet(pairs []string) string {
+	seen := make(map[string]bool)
+	for i := 0; i < len(pairs); i += 2 {
+		name := strings.ToLower(pairs[i])
+		if _, found := configParams[name]; !found {
+			return "-ERR Unknown option or number of arguments for CONFIG SET - '" + pairs[i] + "'\r\n"
+		}
+		if seen[name] {
+			return "-ERR CONFIG SET failed (possibly related to argument '" + name + "') - duplicate parameter\r\n"
+		}
+		seen[name] = true
+	}
+
+	previous := make([]string, 0, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		param := configParams[strings.ToLower(pairs[i])]
+		previous = append(previous, param.get())
+		if err := param.set(pairs[i+1]); err != nil {
+			for j := range previous[:len(previous)-1] {
+				configParams[strings.ToLower(pairs[2*j])].set(previous[j])
+			}
+			return "-ERR CONFIG SET failed (possibly related to argument '" + strings.ToLower(pairs[i]) + "') - " + err.Error() + "\r\n"
+		}
+	}
+	return "+OK\r\n"
+}
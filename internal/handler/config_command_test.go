@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"redis-go-clone/cmd/config"
+	"redis-go-clone/internal/notify"
+	"testing"
+)
+
+func TestConfigCommand(t *testing.T) {
+	t.Cleanup(func() { notify.SetFlags("") })
+
+	tests := []struct {
+		args []any
+		want string
+	}{
+		{[]any{"CONFIG", "GET", "notify-keyspace-events"}, "*2\r\n+notify-keyspace-events\r\n+\r\n"},
+		{[]any{"CONFIG", "SET", "notify-keyspace-events", "KEA"}, "+OK\r\n"},
+		{[]any{"CONFIG", "GET", "notify-*"}, "*2\r\n+notify-keyspace-events\r\n+AKE\r\n"},
+		{[]any{"CONFIG", "GET", "NOTIFY-KEYSPACE-EVENTS"}, "*2\r\n+notify-keyspace-events\r\n+AKE\r\n"},
+		{[]any{"CONFIG", "GET", "nomatch"}, "*0\r\n"},
+		{[]any{"CONFIG", "SET", "notify-keyspace-events", "KQ"}, "-ERR CONFIG SET failed (possibly related to argument 'notify-keyspace-events') - invalid notify-keyspace-events flag 'Q'\r\n"},
+		{[]any{"CONFIG", "GET", "notify-keyspace-events"}, "*2\r\n+notify-keyspace-events\r\n+AKE\r\n"},
+		{[]any{"CONFIG", "SET", "notify-keyspace-events", "Kx", "notify-keyspace-events", "E"}, "-ERR CONFIG SET failed (possibly related to argument 'notify-keyspace-events') - duplicate parameter\r\n"},
+		{[]any{"CONFIG", "SET", "unknown", "1"}, "-ERR Unknown option or number of arguments for CONFIG SET - 'unknown'\r\n"},
+		{[]any{"CONFIG", "SET", "notify-keyspace-events"}, "-ERR wrong number of arguments for CONFIG SET\r\n"},
+		{[]any{"CONFIG", "GET"}, "-ERR wrong number of arguments for CONFIG GET\r\n"},
+		{[]any{"CONFIG", "FOO"}, "-ERR unknown subcommand 'FOO'. Try CONFIG HELP.\r\n"},
+	}
+
+	for _, tt := range tests {
+		if got := configCommand(tt.args, nil, nil); got != tt.want {
+			t.Errorf("%v: expected %q, got %q", tt.args, tt.want, got)
+		}
+	}
+}
+
+func TestKeyspaceNotifications(t *testing.T) {
+	t.Cleanup(func() { notify.SetFlags("") })
+
+	h := NewClientHandler(config.NewConfig())
+	subscriber := connect(t, h)
+	client := connect(t, h)
+
+	client.send("CONFIG", "SET", "notify-keyspace-events", "KEA")
+	client.expect("+OK\r\n")
+	subscriber.send("PSUBSCRIBE", "__key*__:*")
+	subscriber.expect("*3\r\n$10\r\npsubscribe\r\n$10\r\n__key*__:*\r\n:1\r\n")
+
+	client.send("SADD", "s", "a")
+	client.expect(":1\r\n")
+	subscriber.expect("*4\r\n$8\r\npmessage\r\n$10\r\n__key*__:*\r\n$16\r\n__keyspace@0__:s\r\n$4\r\nsadd\r\n")
+	subscriber.expect("*4\r\n$8\r\npmessage\r\n$10\r\n__key*__:*\r\n$19\r\n__keyevent@0__:sadd\r\n$1\r\ns\r\n")
+
+	client.send("DEL", "s")
+	client.expect(":1\r\n")
+	subscriber.expect("*4\r\n$8\r\npmessage\r\n$10\r\n__key*__:*\r\n$16\r\n__keyspace@0__:s\r\n$3\r\ndel\r\n")
+	subscriber.expect("*4\r\n$8\r\npmessage\r\n$10\r\n__key*__:*\r\n$18\r\n__keyevent@0__:del\r\n$1\r\ns\r\n")
+
+	// Only the keyevent channel of list events is left.
+	client.send("CONFIG", "SET", "notify-keyspace-events", "El")
+	client.expect("+OK\r\n")
+	client.send("SADD", "s", "a")
+	client.expect(":1\r\n")
+	client.send("LPUSH", "l", "a")
+	client.expect("+OK\r\n")
+	subscriber.expect("*4\r\n$8\r\npmessage\r\n$10\r\n__key*__:*\r\n$20\r\n__keyevent@0__:lpush\r\n$1\r\nl\r\n")
+}
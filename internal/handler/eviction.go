@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"redis-go-clone/internal/memory"
+	"redis-go-clone/internal/stats"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const oomError = "OOM command not allowed when used memory > 'maxmemory'."
+
+// freeMemory evicts keys when the store uses more than maxmemory before a
+// command runs. Write commands are refused when not enough could be freed.
+func (h *ClientHandler) freeMemory(cmdName string) string {
+	if !memory.Limited() {
+		return ""
+	}
+	h.config.Lock.RLock()
+	over := memory.OverLimit(h.config.DB)
+	h.config.Lock.RUnlock()
+	if !over {
+		return ""
+	}
+
+	h.config.Lock.Lock()
+	freed := memory.Evict(h.config.DB)
+	h.config.Lock.Unlock()
+	if !freed && writeCommands[cmdName] {
+		return "-" + oomError + "\r\n"
+	}
+	return ""
+}
+
+// touchKeys records that a store command used its keys, for the access
+// clocks the LRU and LFU eviction policies rank keys by, and counts the keys
+// read commands found or missed. Only the read lock is taken for read
+// commands; write commands apply the recorded accesses to the clocks.
+func (h *ClientHandler) touchKeys(cmdName string, cmdArray []any, mu *sync.RWMutex) {
+	keys := commandKeys(cmdName, argStrings(cmdArray[1:]))
+	if len(keys) == 0 {
+		return
+	}
+	write := writeCommands[cmdName]
+
+	mu.RLock()
+	for _, key := range keys {
+		_, found := h.config.DB[key]
+		if !write {
+			stats.KeyspaceLookup(found)
+		}
+		if found {
+			memory.Access(key)
+		}
+	}
+	mu.RUnlock()
+
+	if write {
+		mu.Lock()
+		memory.ApplyAccesses(h.config.DB)
+		mu.Unlock()
+	}
+}
+
+// commandKeys returns the keys among the arguments of a store command.
+// Most commands take a single key first; the others are listed.
+func commandKeys(cmdName string, args []string) []string {
+	switch cmdName {
+	case "CONFIG", "FLUSHALL", "FLUSHDB", "SAVE":
+		return nil
+	case "DEL", "EXIST", "SINTER", "SUNION", "SDIFF", "SINTERSTORE", "SUNIONSTORE", "SDIFFSTORE", "PFCOUNT", "PFMERGE":
+		return args
+	case "SMOVE", "ZRANGESTORE", "GEOSEARCHSTORE":
+		return args[:min(len(args), 2)]
+	case "BITOP":
+		return args[min(len(args), 1):]
+	case "XGROUP", "XINFO", "PFDEBUG":
+		return args[min(len(args), 1):min(len(args), 2)]
+	case "ZUNIONSTORE", "ZINTERSTORE", "ZDIFFSTORE":
+		if len(args) == 0 {
+			return nil
+		}
+		return append([]string{args[0]}, numKeys(args[1:])...)
+	case "ZUNION", "ZINTER", "ZDIFF", "SINTERCARD", "ZMPOP":
+		return numKeys(args)
+	case "BZMPOP":
+		return numKeys(args[min(len(args), 1):])
+	case "BZPOPMIN", "BZPOPMAX":
+		return args[:max(len(args)-1, 0)]
+	case "XREAD", "XREADGROUP":
+		for i, arg := range args {
+			if strings.EqualFold(arg, "STREAMS") {
+				streams := args[i+1:]
+				return streams[:len(streams)/2]
+			}
+		}
+		return nil
+	default:
+		return args[:min(len(args), 1)]
+	}
+}
+
+// numKeys returns the keys of "numkeys key ...".
+func numKeys(args []string) []string {
+	if len(args) == 0 {
+		return nil
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 0 || n > len(args)-1 {
+		return nil
+	}
+	return args[1 : 1+n]
+}
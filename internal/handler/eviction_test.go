@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"redis-go-clone/cmd/config"
+	"redis-go-clone/internal/memory"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func restoreMemoryConfig(t *testing.T) {
+	t.Cleanup(func() {
+		memory.SetMaxMemory("0")
+		memory.SetPolicy(memory.NoEviction)
+		memory.SetSamples("5")
+	})
+}
+
+// limitMemory sets maxmemory just below what the store uses now.
+func limitMemory(t *testing.T, h *ClientHandler) {
+	t.Helper()
+	h.config.Lock.RLock()
+	used := memory.Used(h.config.DB)
+	h.config.Lock.RUnlock()
+	if err := memory.SetMaxMemory(strconv.FormatInt(used-1, 10)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEvictionLRU(t *testing.T) {
+	restoreMemoryConfig(t)
+	h := NewClientHandler(config.NewConfig())
+	client := connect(t, h)
+	events := connect(t, h)
+
+	client.send("CONFIG", "SET", "maxmemory-policy", "allkeys-lru", "maxmemory-samples", "64", "notify-keyspace-events", "Ee")
+	client.expect("+OK\r\n")
+	t.Cleanup(func() { configSet([]string{"notify-keyspace-events", ""}) })
+	events.send("SUBSCRIBE", "__keyevent@0__:evicted")
+	events.expect("*3\r\n$9\r\nsubscribe\r\n$22\r\n__keyevent@0__:evicted\r\n:1\r\n")
+
+	for _, key := range []string{"a", "b", "c"} {
+		client.send("SET", key, "value")
+		client.expect("+OK\r\n")
+		time.Sleep(2 * time.Millisecond)
+	}
+	client.send("GET", "a")
+	client.expect("$5\r\nvalue\r\n")
+
+	limitMemory(t, h)
+	client.send("GET", "b")
+	client.expect("$-1\r\n")
+	events.expect("*3\r\n$7\r\nmessage\r\n$22\r\n__keyevent@0__:evicted\r\n$1\r\nb\r\n")
+	client.send("GET", "a")
+	client.expect("$5\r\nvalue\r\n")
+	if evicted := memory.GetStats().EvictedKeys; evicted == 0 {
+		t.Error("expected the evicted keys stat to count the eviction")
+	}
+}
+
+func TestNoEvictionRefusesWrites(t *testing.T) {
+	restoreMemoryConfig(t)
+	h := NewClientHandler(config.NewConfig())
+	client := connect(t, h)
+
+	client.send("SET", "a", "value")
+	client.expect("+OK\r\n")
+	limitMemory(t, h)
+
+	client.send("SET", "b", "value")
+	client.expect("-OOM command not allowed when used memory > 'maxmemory'.\r\n")
+	client.send("GET", "a")
+	client.expect("$5\r\nvalue\r\n")
+
+	// used_memory is what the limit is checked against.
+	fields, _ := client.info("memory")
+	used, _ := strconv.ParseInt(fields["used_memory"], 10, 64)
+	if limit, _ := strconv.ParseInt(fields["maxmemory"], 10, 64); used != limit+1 {
+		t.Errorf("expected used_memory just over maxmemory %d, got %q", limit, fields["used_memory"])
+	}
+	if heap, _ := strconv.Atoi(fields["used_memory_heap"]); heap == 0 {
+		t.Errorf("expected used_memory_heap to report the Go heap, got %q", fields["used_memory_heap"])
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	fields, _ = client.info("stats")
+	if current, _ := strconv.Atoi(fields["current_eviction_exceeded_time"]); current < 5 {
+		t.Errorf("expected current_eviction_exceeded_time of at least 5ms, got %q", fields["current_eviction_exceeded_time"])
+	}
+
+	client.send("MULTI")
+	client.expect("+OK\r\n")
+	client.send("SET", "b", "value")
+	client.expect("-OOM command not allowed when used memory > 'maxmemory'.\r\n")
+	client.send("EXEC")
+	client.expect("-EXECABORT Transaction discarded because of previous errors.\r\n")
+
+	client.send("EVAL", "return redis.call('SET', 'b', 'value')", "0")
+	client.expect("-OOM command not allowed when used memory > 'maxmemory'.\r\n")
+	client.send("EVAL", "return redis.call('GET', 'a')", "0")
+	client.expect("$5\r\nvalue\r\n")
+
+	client.send("CONFIG", "GET", "maxmemory*")
+	client.expect("*6\r\n+maxmemory\r\n+" + memory.MaxMemory() + "\r\n+maxmemory-policy\r\n+noeviction\r\n+maxmemory-samples\r\n+5\r\n")
+
+	client.send("CONFIG", "SET", "maxmemory", "0")
+	client.expect("+OK\r\n")
+	fields, _ = client.info("stats")
+	if fields["current_eviction_exceeded_time"] != "0" {
+		t.Errorf("expected no current_eviction_exceeded_time without maxmemory, got %q", fields["current_eviction_exceeded_time"])
+	}
+	if total, _ := strconv.Atoi(fields["total_eviction_exceeded_time"]); total < 5 {
+		t.Errorf("expected total_eviction_exceeded_time of at least 5ms, got %q", fields["total_eviction_exceeded_time"])
+	}
+}
+
+func TestCommandKeys(t *testing.T) {
+	tests := []struct {
+		cmdName string
+		args    []string
+		want    []string
+	}{
+		{"GET", []string{"k"}, []string{"k"}},
+		{"SET", []string{"k", "v", "EX", "10"}, []string{"k"}},
+		{"DEL", []string{"a", "b"}, []string{"a", "b"}},
+		{"SMOVE", []string{"src", "dst", "m"}, []string{"src", "dst"}},
+		{"BITOP", []string{"AND", "dst", "a", "b"}, []string{"dst", "a", "b"}},
+		{"XINFO", []string{"STREAM", "s"}, []string{"s"}},
+		{"ZUNIONSTORE", []string{"dst", "2", "a", "b", "WEIGHTS", "1", "2"}, []string{"dst", "a", "b"}},
+		{"ZINTER", []string{"2", "a", "b"}, []string{"a", "b"}},
+		{"ZINTER", []string{"3", "a"}, nil},
+		{"BZMPOP", []string{"0", "1", "z", "MIN"}, []string{"z"}},
+		{"BZPOPMIN", []string{"a", "b", "0"}, []string{"a", "b"}},
+		{"XREAD", []string{"COUNT", "1", "STREAMS", "s1", "s2", "0", "0"}, []string{"s1", "s2"}},
+		{"FLUSHALL", nil, nil},
+		{"PFCOUNT", nil, nil},
+	}
+	for _, tt := range tests {
+		if got := commandKeys(tt.cmdName, tt.args); !slices.Equal(got, tt.want) {
+			t.Errorf("commandKeys(%s, %q) = %q, want %q", tt.cmdName, tt.args, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,486 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"redis-go-clone/pkg/glob"
+	"redis-go-clone/pkg/lua"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// libraryLoadTimeout bounds the time the code of a library may run while it
+// is loaded, which only has to register its functions.
+const libraryLoadTimeout = 500 * time.Millisecond
+
+var errLoadTimeout = errors.New("FUNCTION LOAD timeout")
+
+// library is a named set of functions, loaded from Lua code starting with a
+// "#!lua name=<library>" header.
+type library struct {
+	name      string
+	code      string
+	functions map[string]*libraryFunction
+}
+
+type libraryFunction struct {
+	name        string
+	description string
+	flags       []string
+	fn          lua.Value
+	library     *library
+}
+
+func (f *libraryFunction) hasFlag(flag string) bool {
+	return slices.Contains(f.flags, flag)
+}
+
+var functionFlags = []string{"no-writes", "allow-oom", "allow-stale", "no-cluster", "allow-cross-slot-keys"}
+
+// validName reports whether a library or function name only has letters,
+// digits and underscores.
+func validName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if !(r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// parseShebang returns the library name given in the header of its code.
+func parseShebang(code string) (string, error) {
+	if !strings.HasPrefix(code, "#!") {
+		return "", errors.New("Missing library metadata")
+	}
+	header, _, _ := strings.Cut(code[2:], "\n")
+	fields := strings.Fields(header)
+	if len(fields) == 0 || !strings.EqualFold(fields[0], "lua") {
+		engine := ""
+		if len(fields) > 0 {
+			engine = fields[0]
+		}
+		return "", fmt.Errorf("Engine '%s' not found", engine)
+	}
+	name := ""
+	for _, field := range fields[1:] {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok || key != "name" {
+			return "", fmt.Errorf("Invalid metadata value given: %s", field)
+		}
+		name = value
+	}
+	if name == "" {
+		return "", errors.New("Library name was not given")
+	}
+	if !validName(name) {
+		return "", errors.New("Library names can only contain letters, numbers, or underscores(_) and must be at least one character long")
+	}
+	return name, nil
+}
+
+// loadLibrary compiles the code of a library and runs it to collect the
+// functions it registers. The caller holds the store lock, as the
+// interpreter is used.
+func (s *scripting) loadLibrary(code string) (*library, error) {
+	name, err := parseShebang(code)
+	if err != nil {
+		return nil, err
+	}
+	fn, err := s.lua.Load(code, "user_function")
+	if err != nil {
+		return nil, fmt.Errorf("Error compiling function: %v", err)
+	}
+
+	lib := &library{name: name, code: code, functions: make(map[string]*libraryFunction)}
+	s.mu.Lock()
+	s.loading, s.loadStart = lib, time.Now()
+	s.mu.Unlock()
+	_, err = s.lua.Call(fn)
+	s.mu.Lock()
+	s.loading = nil
+	s.mu.Unlock()
+
+	if err != nil {
+		// redis.call raises error tables, whose message has an error code.
+		var luaErr *lua.Error
+		if errors.As(err, &luaErr) {
+			if t, ok := luaErr.Value.(*lua.Table); ok {
+				if msg, ok := t.Get("err").(string); ok {
+					return nil, fmt.Errorf("Error registering functions: %s", strings.TrimPrefix(msg, "ERR "))
+				}
+			}
+		}
+		return nil, fmt.Errorf("Error registering functions: %v", err)
+	}
+	if len(lib.functions) == 0 {
+		return nil, errors.New("No functions registered")
+	}
+	return lib, nil
+}
+
+// registerFunction implements redis.register_function, called either as
+// (name, callback) or with a table of named arguments: function_name,
+// callback, flags and description.
+func (s *scripting) registerFunction(l *lua.State, args []lua.Value) {
+	lib := s.loading
+	if lib == nil {
+		l.RaiseError("redis.register_function can only be called on FUNCTION LOAD command")
+	}
+
+	f := &libraryFunction{library: lib}
+	switch len(args) {
+	case 1:
+		t, ok := args[0].(*lua.Table)
+		if !ok {
+			l.RaiseError("calling redis.register_function with a single argument is only applicable to Lua table (representing named arguments).")
+		}
+		for key, value, _ := t.Next(nil); key != nil; key, value, _ = t.Next(key) {
+			switch key {
+			case "function_name":
+				name, ok := value.(string)
+				if !ok {
+					l.RaiseError("function_name argument given to redis.register_function must be a string")
+				}
+				f.name = name
+			case "callback":
+				f.fn = value
+			case "description":
+				description, ok := value.(string)
+				if !ok {
+					l.RaiseError("description argument given to redis.register_function must be a string")
+				}
+				f.description = description
+			case "flags":
+				flags, ok := value.(*lua.Table)
+				if !ok {
+					l.RaiseError("flags argument to redis.register_function must be a table representing function flags")
+				}
+				for i := 1; i <= flags.Len(); i++ {
+					flag, ok := flags.Get(float64(i)).(string)
+					if !ok || !slices.Contains(functionFlags, flag) {
+						l.RaiseError("unknown flag given")
+					}
+					f.flags = append(f.flags, flag)
+				}
+			default:
+				l.RaiseError("unknown argument given to redis.register_function")
+			}
+		}
+		if f.name == "" {
+			l.RaiseError("redis.register_function must get a function name argument")
+		}
+		if f.fn == nil {
+			l.RaiseError("redis.register_function must get a callback argument")
+		}
+	case 2:
+		name, ok := args[0].(string)
+		if !ok {
+			l.RaiseError("first argument to redis.register_function must be a string")
+		}
+		f.name, f.fn = name, args[1]
+	default:
+		l.RaiseError("wrong number of arguments to redis.register_function")
+	}
+
+	if lua.TypeName(f.fn) != "function" {
+		l.RaiseError("callback argument given to redis.register_function must be a function")
+	}
+	if !validName(f.name) {
+		l.RaiseError("Function names can only contain letters, numbers, or underscores(_) and must be at least one character long")
+	}
+	if _, found := lib.functions[f.name]; found {
+		l.RaiseError("Function already exists in the library")
+	}
+	lib.functions[f.name] = f
+}
+
+// installLibraries adds loaded libraries following a FUNCTION RESTORE
+// policy: APPEND refuses libraries that already exist, REPLACE replaces
+// them and FLUSH removes all the others first. Nothing changes when a
+// library or function name is taken.
+func (s *scripting) installLibraries(libs []*library, policy string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	libraries := make(map[string]*library)
+	if policy != "FLUSH" {
+		maps.Copy(libraries, s.libraries)
+	}
+	for _, lib := range libs {
+		if _, found := libraries[lib.name]; found && policy != "REPLACE" {
+			return fmt.Errorf("Library '%s' already exists", lib.name)
+		}
+		libraries[lib.name] = lib
+	}
+
+	functions := make(map[string]*libraryFunction)
+	for _, name := range slices.Sorted(maps.Keys(libraries)) {
+		for _, f := range libraries[name].functions {
+			if _, found := functions[f.name]; found {
+				return fmt.Errorf("Function %s already exists", f.name)
+			}
+			functions[f.name] = f
+		}
+	}
+	s.libraries, s.functions = libraries, functions
+	return nil
+}
+
+// restoreLibraries loads the libraries of a FUNCTION DUMP payload and
+// installs them. The caller holds the store lock.
+func (s *scripting) restoreLibraries(payload []byte, policy string) error {
+	var codes []string
+	if err := json.Unmarshal(payload, &codes); err != nil {
+		return errors.New("payload version or checksum are wrong")
+	}
+	libs := make([]*library, 0, len(codes))
+	for _, code := range codes {
+		lib, err := s.loadLibrary(code)
+		if err != nil {
+			return err
+		}
+		libs = append(libs, lib)
+	}
+	return s.installLibraries(libs, policy)
+}
+
+// dumpLibraries serializes the code of every library, sorted by name.
+func (s *scripting) dumpLibraries() []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	codes := make([]string, 0, len(s.libraries))
+	for _, name := range slices.Sorted(maps.Keys(s.libraries)) {
+		codes = append(codes, s.libraries[name].code)
+	}
+	payload, _ := json.Marshal(codes)
+	return payload
+}
+
+// RestoreFunctions replaces the function libraries with the ones of a
+// payload written by FUNCTION DUMP, which is how they are reloaded at
+// startup.
+func (h *ClientHandler) RestoreFunctions(payload []byte) error {
+	h.config.Lock.Lock()
+	defer h.config.Lock.Unlock()
+	return h.scripting.restoreLibraries(payload, "FLUSH")
+}
+
+// fcall implements FCALL function numkeys [key ...] [arg ...].
+func (h *ClientHandler) fcall(c *client, cmdArray []any, mu *sync.RWMutex) string {
+	return h.fcallFunction(c, cmdArray, mu, false)
+}
+
+// fcallRO is FCALL for functions flagged no-writes.
+func (h *ClientHandler) fcallRO(c *client, cmdArray []any, mu *sync.RWMutex) string {
+	return h.fcallFunction(c, cmdArray, mu, true)
+}
+
+func (h *ClientHandler) fcallFunction(c *client, cmdArray []any, mu *sync.RWMutex, readOnly bool) string {
+	if len(cmdArray) < 3 {
+		return "-ERR wrong number of arguments for " + strings.ToUpper(argString(cmdArray[0])) + "\r\n"
+	}
+	h.scripting.mu.Lock()
+	f, found := h.scripting.functions[argString(cmdArray[1])]
+	h.scripting.mu.Unlock()
+	if !found {
+		return "-ERR Function not found\r\n"
+	}
+	noWrites := f.hasFlag("no-writes")
+	if readOnly && !noWrites {
+		return "-ERR Can not execute a script with write flag using *_ro command.\r\n"
+	}
+	run := scriptRun{
+		c:        c,
+		name:     f.name,
+		function: true,
+		readOnly: noWrites,
+		allowOOM: f.hasFlag("allow-oom"),
+		command:  argStrings(cmdArray),
+	}
+	return h.runScript(c, run, f.fn, cmdArray[2:], mu)
+}
+
+// functionCommand implements the FUNCTION subcommands: LOAD, DELETE, FLUSH,
+// LIST, DUMP, RESTORE, KILL and STATS.
+func (h *ClientHandler) functionCommand(_ *client, cmdArray []any, mu *sync.RWMutex) string {
+	if len(cmdArray) < 2 {
+		return "-ERR wrong number of arguments for FUNCTION\r\n"
+	}
+	s := h.scripting
+	args := argStrings(cmdArray[2:])
+	switch subcommand := strings.ToUpper(argString(cmdArray[1])); subcommand {
+	case "LOAD":
+		policy := "APPEND"
+		if len(args) == 2 && strings.EqualFold(args[0], "REPLACE") {
+			policy = "REPLACE"
+		} else if len(args) != 1 {
+			return "-ERR wrong number of arguments for FUNCTION LOAD\r\n"
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		lib, err := s.loadLibrary(args[len(args)-1])
+		if err == nil {
+			err = s.installLibraries([]*library{lib}, policy)
+		}
+		if err != nil {
+			return "-ERR " + oneLine(err.Error()) + "\r\n"
+		}
+		return "$" + strconv.Itoa(len(lib.name)) + "\r\n" + lib.name + "\r\n"
+	case "DELETE":
+		if len(args) != 1 {
+			return "-ERR wrong number of arguments for FUNCTION DELETE\r\n"
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		lib, found := s.libraries[args[0]]
+		if !found {
+			return "-ERR Library not found\r\n"
+		}
+		delete(s.libraries, lib.name)
+		for name := range lib.functions {
+			delete(s.functions, name)
+		}
+		return "+OK\r\n"
+	case "FLUSH":
+		if len(args) > 1 || (len(args) == 1 && !strings.EqualFold(args[0], "ASYNC") && !strings.EqualFold(args[0], "SYNC")) {
+			return "-ERR syntax error\r\n"
+		}
+		s.mu.Lock()
+		clear(s.libraries)
+		clear(s.functions)
+		s.mu.Unlock()
+		return "+OK\r\n"
+	case "LIST":
+		return s.list(args)
+	case "DUMP":
+		if len(args) != 0 {
+			return "-ERR wrong number of arguments for FUNCTION DUMP\r\n"
+		}
+		payload := s.dumpLibraries()
+		return "$" + strconv.Itoa(len(payload)) + "\r\n" + string(payload) + "\r\n"
+	case "RESTORE":
+		if len(args) < 1 || len(args) > 2 {
+			return "-ERR wrong number of arguments for FUNCTION RESTORE\r\n"
+		}
+		policy := "APPEND"
+		if len(args) == 2 {
+			policy = strings.ToUpper(args[1])
+			if policy != "FLUSH" && policy != "APPEND" && policy != "REPLACE" {
+				return "-ERR Wrong restore policy given, value should be either FLUSH, APPEND or REPLACE.\r\n"
+			}
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if err := s.restoreLibraries([]byte(args[0]), policy); err != nil {
+			return "-ERR " + oneLine(err.Error()) + "\r\n"
+		}
+		return "+OK\r\n"
+	case "KILL":
+		if len(args) != 0 {
+			return "-ERR wrong number of arguments for FUNCTION KILL\r\n"
+		}
+		return s.kill(true)
+	case "STATS":
+		if len(args) != 0 {
+			return "-ERR wrong number of arguments for FUNCTION STATS\r\n"
+		}
+		return s.stats()
+	default:
+		return "-ERR unknown subcommand '" + argString(cmdArray[1]) + "'. Try FUNCTION HELP.\r\n"
+	}
+}
+
+// list implements FUNCTION LIST [LIBRARYNAME pattern] [WITHCODE].
+func (s *scripting) list(args []string) string {
+	pattern, withCode := "*", false
+	for i := 0; i < len(args); i++ {
+		switch {
+		case strings.EqualFold(args[i], "WITHCODE") && !withCode:
+			withCode = true
+		case strings.EqualFold(args[i], "LIBRARYNAME") && i+1 < len(args):
+			pattern = args[i+1]
+			i++
+		default:
+			return "-ERR Unknown argument " + args[i] + "\r\n"
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	libraries := []any{}
+	for _, name := range slices.Sorted(maps.Keys(s.libraries)) {
+		if !glob.Match(pattern, name) {
+			continue
+		}
+		lib := s.libraries[name]
+		functions := []any{}
+		for _, fname := range slices.Sorted(maps.Keys(lib.functions)) {
+			f := lib.functions[fname]
+			var description any
+			if f.description != "" {
+				description = f.description
+			}
+			flags := make([]any, len(f.flags))
+			for i, flag := range f.flags {
+				flags[i] = flag
+			}
+			functions = append(functions, []any{"name", f.name, "description", description, "flags", flags})
+		}
+		entry := []any{"library_name", lib.name, "engine", "LUA", "functions", functions}
+		if withCode {
+			entry = append(entry, "library_code", lib.code)
+		}
+		libraries = append(libraries, entry)
+	}
+	return bulkReply(libraries)
+}
+
+// stats implements FUNCTION STATS: the script running, if any, and the
+// number of libraries and functions.
+func (s *scripting) stats() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var running any
+	if s.running != nil {
+		command := make([]any, len(s.running.command))
+		for i, arg := range s.running.command {
+			command[i] = arg
+		}
+		running = []any{
+			"name", s.running.name,
+			"command", command,
+			"duration_ms", int(time.Since(s.running.start).Milliseconds()),
+		}
+	}
+	engines := []any{"LUA", []any{"libraries_count", len(s.libraries), "functions_count", len(s.functions)}}
+	return bulkReply([]any{"running_script", running, "engines", engines})
+}
+
+// bulkReply encodes strings as bulk strings, ints as integers, slices as
+// arrays and nil as a null bulk string.
+func bulkReply(v any) string {
+	switch v := v.(type) {
+	case string:
+		return "$" + strconv.Itoa(len(v)) + "\r\n" + v + "\r\n"
+	case int:
+		return ":" + strconv.Itoa(v) + "\r\n"
+	case []any:
+		var b strings.Builder
+		b.WriteString("*" + strconv.Itoa(len(v)) + "\r\n")
+		for _, item := range v {
+			b.WriteString(bulkReply(item))
+		}
+		return b.String()
+	default:
+		return "$-1\r\n"
+	}
+}
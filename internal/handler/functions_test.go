@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"redis-go-clone/cmd/config"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const testLibrary = "#!lua name=mylib\n" +
+	"redis.register_function('set', function(keys, args) return redis.call('SET', keys[1], args[1]) end)\n" +
+	"redis.register_function{function_name='get', callback=function(keys) return redis.call('GET', keys[1]) end, flags={'no-writes'}, description='reads a key'}\n"
+
+func bulk(s string) string {
+	return "$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n"
+}
+
+func TestFunctionLoadAndCall(t *testing.T) {
+	h := NewClientHandler(config.NewConfig())
+	client := connect(t, h)
+
+	client.send("FUNCTION", "LOAD", testLibrary)
+	client.expect("$5\r\nmylib\r\n")
+	client.send("FCALL", "set", "1", "k", "v")
+	client.expect("+OK\r\n")
+	client.send("FCALL_RO", "get", "1", "k")
+	client.expect("$1\r\nv\r\n")
+	client.send("FCALL_RO", "set", "1", "k", "w")
+	client.expect("-ERR Can not execute a script with write flag using *_ro command.\r\n")
+	client.send("FCALL", "nope", "0")
+	client.expect("-ERR Function not found\r\n")
+
+	client.send("FUNCTION", "LOAD", testLibrary)
+	client.expect("-ERR Library 'mylib' already exists\r\n")
+	client.send("FUNCTION", "LOAD", "REPLACE", testLibrary)
+	client.expect("$5\r\nmylib\r\n")
+	client.send("FUNCTION", "LOAD", "#!lua name=other\nredis.register_function('get', function() return 1 end)")
+	client.expect("-ERR Function get already exists\r\n")
+
+	client.send("FUNCTION", "LIST", "LIBRARYNAME", "my*")
+	client.expect("*1\r\n*6\r\n$12\r\nlibrary_name\r\n$5\r\nmylib\r\n$6\r\nengine\r\n$3\r\nLUA\r\n$9\r\nfunctions\r\n*2\r\n" +
+		"*6\r\n$4\r\nname\r\n$3\r\nget\r\n$11\r\ndescription\r\n$11\r\nreads a key\r\n$5\r\nflags\r\n*1\r\n$9\r\nno-writes\r\n" +
+		"*6\r\n$4\r\nname\r\n$3\r\nset\r\n$11\r\ndescription\r\n$-1\r\n$5\r\nflags\r\n*0\r\n")
+	client.send("FUNCTION", "LIST", "WITHCODE", "LIBRARYNAME", "x*")
+	client.expect("*0\r\n")
+	client.send("FUNCTION", "STATS")
+	client.expect("*4\r\n$14\r\nrunning_script\r\n$-1\r\n$7\r\nengines\r\n*2\r\n$3\r\nLUA\r\n*4\r\n$15\r\nlibraries_count\r\n:1\r\n$15\r\nfunctions_count\r\n:2\r\n")
+
+	client.send("FUNCTION", "DELETE", "mylib")
+	client.expect("+OK\r\n")
+	client.send("FUNCTION", "DELETE", "mylib")
+	client.expect("-ERR Library not found\r\n")
+	client.send("FCALL", "get", "1", "k")
+	client.expect("-ERR Function not found\r\n")
+}
+
+func TestFunctionLoadErrors(t *testing.T) {
+	h := NewClientHandler(config.NewConfig())
+
+	tests := []struct {
+		name string
+		code string
+		want string
+	}{
+		{"Missing header", "return 1", "-ERR Missing library metadata\r\n"},
+		{"Unknown engine", "#!js name=lib\n", "-ERR Engine 'js' not found\r\n"},
+		{"Bad metadata", "#!lua version=1\n", "-ERR Invalid metadata value given: version=1\r\n"},
+		{"Missing name", "#!lua\n", "-ERR Library name was not given\r\n"},
+		{"Bad name", "#!lua name=my-lib\n", "-ERR Library names can only contain letters, numbers, or underscores(_) and must be at least one character long\r\n"},
+		{"No functions", "#!lua name=lib\nlocal x = 1", "-ERR No functions registered\r\n"},
+		{"Compile error", "#!lua name=lib\nreturn (", "-ERR Error compiling function: user_function:2: unexpected symbol near '<eof>'\r\n"},
+		{"Unknown flag", "#!lua name=lib\nredis.register_function{function_name='f', callback=function() end, flags={'fast'}}",
+			"-ERR Error registering functions: user_function:2: unknown flag given\r\n"},
+		{"Duplicate function", "#!lua name=lib\nredis.register_function('f', function() end)\nredis.register_function('f', function() end)",
+			"-ERR Error registering functions: user_function:3: Function already exists in the library\r\n"},
+		{"Call while loading", "#!lua name=lib\nredis.call('SET', 'k', 'v')",
+			"-ERR Error registering functions: redis.call can not be called while loading a library\r\n"},
+		{"Load timeout", "#!lua name=lib\nwhile true do end", "-ERR Error registering functions: FUNCTION LOAD timeout\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := connect(t, h)
+			client.send("FUNCTION", "LOAD", tt.code)
+			client.expect(tt.want)
+		})
+	}
+}
+
+func TestFunctionRegisterOutsideLoad(t *testing.T) {
+	h := NewClientHandler(config.NewConfig())
+	client := connect(t, h)
+
+	client.send("FUNCTION", "LOAD", "#!lua name=lib\nredis.register_function('f', function() redis.register_function('g', function() end) end)")
+	client.expect("$3\r\nlib\r\n")
+	client.send("FCALL", "f", "0")
+	client.expect("-ERR user_function:2: redis.register_function can only be called on FUNCTION LOAD command script: f\r\n")
+}
+
+func TestFunctionDumpAndRestore(t *testing.T) {
+	h := NewClientHandler(config.NewConfig())
+	client := connect(t, h)
+
+	client.send("FUNCTION", "LOAD", testLibrary)
+	client.expect("$5\r\nmylib\r\n")
+	payload := string(h.scripting.dumpLibraries())
+	client.send("FUNCTION", "DUMP")
+	client.expect(bulk(payload))
+
+	client.send("FUNCTION", "RESTORE", payload)
+	client.expect("-ERR Library 'mylib' already exists\r\n")
+	client.send("FUNCTION", "RESTORE", payload, "REPLACE")
+	client.expect("+OK\r\n")
+	client.send("FUNCTION", "RESTORE", payload, "MERGE")
+	client.expect("-ERR Wrong restore policy given, value should be either FLUSH, APPEND or REPLACE.\r\n")
+	client.send("FUNCTION", "RESTORE", "garbage")
+	client.expect("-ERR payload version or checksum are wrong\r\n")
+
+	client.send("FUNCTION", "FLUSH")
+	client.expect("+OK\r\n")
+	client.send("FUNCTION", "LIST")
+	client.expect("*0\r\n")
+
+	// The libraries are reloaded at startup the same way.
+	restarted := NewClientHandler(config.NewConfig())
+	if err := restarted.RestoreFunctions([]byte(payload)); err != nil {
+		t.Fatalf("RestoreFunctions failed: %v", err)
+	}
+	client = connect(t, restarted)
+	client.send("FCALL", "set", "1", "k", "v")
+	client.expect("+OK\r\n")
+}
+
+func TestFunctionBusyAndKill(t *testing.T) {
+	h := NewClientHandler(config.NewConfig())
+	runner := connect(t, h)
+	other := connect(t, h)
+	t.Cleanup(func() { setScriptTimeLimit("5000") })
+
+	other.send("CONFIG", "SET", "busy-reply-threshold", "50")
+	other.expect("+OK\r\n")
+	other.send("FUNCTION", "LOAD", "#!lua name=lib\nredis.register_function('spin', function() while true do end end)")
+	other.expect("$3\r\nlib\r\n")
+
+	runner.send("FCALL", "spin", "0")
+	time.Sleep(100 * time.Millisecond)
+	other.send("GET", "k")
+	other.expect("-BUSY Redis is busy running a function. You can only call FUNCTION KILL or SHUTDOWN NOSAVE.\r\n")
+	other.send("SCRIPT", "KILL")
+	other.expect("-BUSY Redis is busy running a function. You can only call FUNCTION KILL or SHUTDOWN NOSAVE.\r\n")
+	other.send("FUNCTION", "KILL")
+	other.expect("+OK\r\n")
+	runner.expect("-ERR Script killed by user with SCRIPT KILL...\r\n")
+}
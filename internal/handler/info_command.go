@@ -0,0 +1,300 @@
+package handler
+
+import (
+	"fmt"
+	"maps"
+	"net"
+	"os"
+	"redis-go-clone/internal/memory"
+	"redis-go-clone/internal/model"
+	"redis-go-clone/internal/stats"
+	"redis-go-clone/internal/watch"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// redisVersion is the Redis version whose commands this server follows.
+const redisVersion = "7.4.0"
+
+// infoWriter builds the "field:value" lines of an INFO section.
+type infoWriter struct {
+	strings.Builder
+}
+
+func (w *infoWriter) field(name string, value any) {
+	fmt.Fprintf(w, "%s:%v\r\n", name, value)
+}
+
+// infoSection is a section of INFO. The ones not in the default sections
+// are only printed when asked for by name or with INFO all.
+type infoSection struct {
+	name     string
+	title    string
+	optional bool
+	write    func(h *ClientHandler, c *client, w *infoWriter, mu *sync.RWMutex)
+}
+
+var infoSections = []infoSection{
+	{"server", "Server", false, (*ClientHandler).infoServer},
+	{"clients", "Clients", false, (*ClientHandler).infoClients},
+	{"memory", "Memory", false, (*ClientHandler).infoMemory},
+	{"persistence", "Persistence", false, (*ClientHandler).infoPersistence},
+	{"stats", "Stats", false, (*ClientHandler).infoStats},
+	{"replication", "Replication", false, (*ClientHandler).infoReplication},
+	{"cpu", "CPU", false, (*ClientHandler).infoCPU},
+	{"commandstats", "Commandstats", true, (*ClientHandler).infoCommandStats},
+	{"errorstats", "Errorstats", false, (*ClientHandler).infoErrorStats},
+	{"latencystats", "Latencystats", true, (*ClientHandler).infoLatencyStats},
+	{"keyspace", "Keyspace", false, (*ClientHandler).infoKeyspace},
+}
+
+// info implements INFO [section ...]. Sections are named case-insensitively;
+// "default", "all" and "everything" select groups of them and unknown names
+// are ignored.
+func (h *ClientHandler) info(c *client, cmdArray []any, mu *sync.RWMutex) string {
+	selected := make(map[string]bool)
+	all, defaults := false, len(cmdArray) == 1
+	for _, arg := range argStrings(cmdArray[1:]) {
+		switch name := strings.ToLower(arg); name {
+		case "all", "everything":
+			all = true
+		case "default":
+			defaults = true
+		default:
+			selected[name] = true
+		}
+	}
+
+	var sections []string
+	for _, section := range infoSections {
+		if !all && !selected[section.name] && (!defaults || section.optional) {
+			continue
+		}
+		w := &infoWriter{}
+		w.WriteString("# " + section.title + "\r\n")
+		section.write(h, c, w, mu)
+		sections = append(sections, w.String())
+	}
+	return bulkReply(strings.Join(sections, "\r\n"))
+}
+
+func (h *ClientHandler) infoServer(c *client, w *infoWriter, _ *sync.RWMutex) {
+	uptime := time.Since(stats.StartTime)
+	executable, _ := os.Executable()
+	w.field("redis_version", redisVersion)
+	w.field("redis_mode", "standalone")
+	w.field("os", runtime.GOOS+" "+runtime.GOARCH)
+	w.field("arch_bits", strconv.IntSize)
+	w.field("multiplexing_api", "goroutines")
+	w.field("go_version", runtime.Version())
+	w.field("process_id", os.Getpid())
+	w.field("run_id", stats.RunID)
+	w.field("tcp_port", tcpPort(c))
+	w.field("server_time_usec", time.Now().UnixMicro())
+	w.field("uptime_in_seconds", int64(uptime.Seconds()))
+	w.field("uptime_in_days", int64(uptime.Hours()/24))
+	w.field("hz", 10)
+	w.field("executable", executable)
+	w.field("config_file", "")
+}
+
+// tcpPort returns the port the client connected to, or 0 when it is not a
+// TCP connection.
+func tcpPort(c *client) string {
+	_, port, err := net.SplitHostPort(c.conn.LocalAddr().String())
+	if err != nil {
+		return "0"
+	}
+	return port
+}
+
+func (h *ClientHandler) infoClients(_ *client, w *infoWriter, _ *sync.RWMutex) {
+	watchingClients, watchedKeys := watch.Watching()
+	w.field("connected_clients", stats.Get().ConnectedClients)
+	w.field("blocked_clients", h.blocked.count())
+	w.field("pubsub_clients", h.pubsub.NumClients())
+	w.field("watching_clients", watchingClients)
+	w.field("total_watched_keys", watchedKeys)
+}
+
+func (h *ClientHandler) infoMemory(_ *client, w *infoWriter, mu *sync.RWMutex) {
+	r := h.readMemory(mu)
+	scripts, functions, libraries := h.scripting.cacheCounts()
+	maxMemory, _ := strconv.ParseInt(memory.MaxMemory(), 10, 64)
+	// used_memory is what maxmemory is enforced against; the numbers from
+	// the Go heap are reported on their own.
+	w.field("used_memory", r.dataset.Bytes)
+	w.field("used_memory_human", bytesToHuman(uint64(r.dataset.Bytes)))
+	w.field("used_memory_heap", r.HeapAlloc)
+	w.field("used_memory_heap_human", bytesToHuman(r.HeapAlloc))
+	w.field("used_memory_rss", r.Sys)
+	w.field("used_memory_rss_human", bytesToHuman(r.Sys))
+	w.field("used_memory_peak", r.Peak)
+	w.field("used_memory_peak_human", bytesToHuman(r.Peak))
+	w.field("used_memory_peak_perc", formatRatio(float64(r.HeapAlloc)*100/float64(r.Peak))+"%")
+	w.field("used_memory_overhead", r.overhead)
+	w.field("used_memory_startup", r.Startup)
+	w.field("used_memory_dataset", r.datasetBytes)
+	w.field("used_memory_dataset_perc", formatRatio(r.datasetPercentage())+"%")
+	w.field("used_memory_scripts_eval", r.scripts)
+	w.field("number_of_cached_scripts", scripts)
+	w.field("number_of_functions", functions)
+	w.field("number_of_libraries", libraries)
+	w.field("used_memory_functions", r.functions)
+	w.field("maxmemory", maxMemory)
+	w.field("maxmemory_human", bytesToHuman(uint64(maxMemory)))
+	w.field("maxmemory_policy", memory.Policy())
+	w.field("mem_not_counted_for_evict", 0)
+	w.field("allocator_allocated", r.HeapAlloc)
+	w.field("allocator_active", r.HeapInuse)
+	w.field("allocator_resident", r.HeapSys-r.HeapReleased)
+	w.field("mem_fragmentation_ratio", formatRatio(float64(r.Sys)/float64(r.HeapAlloc)))
+	w.field("mem_fragmentation_bytes", int64(r.Sys)-int64(r.HeapAlloc))
+	w.field("mem_allocator", "go-"+runtime.Version())
+}
+
+// bytesToHuman formats a number of bytes the way INFO does, such as 1.50M.
+func bytesToHuman(n uint64) string {
+	for _, unit := range []struct {
+		suffix string
+		size   uint64
+	}{{"P", 1 << 50}, {"T", 1 << 40}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}} {
+		if n >= unit.size {
+			return strconv.FormatFloat(float64(n)/float64(unit.size), 'f', 2, 64) + unit.suffix
+		}
+	}
+	return strconv.FormatUint(n, 10) + "B"
+}
+
+func (h *ClientHandler) infoPersistence(_ *client, w *infoWriter, _ *sync.RWMutex) {
+	counters := stats.Get()
+	saveStatus := "ok"
+	if counters.LastSaveFailed {
+		saveStatus = "err"
+	}
+	w.field("loading", 0)
+	w.field("rdb_changes_since_last_save", counters.Dirty)
+	w.field("rdb_bgsave_in_progress", 0)
+	w.field("rdb_last_save_time", counters.LastSave.Unix())
+	w.field("rdb_last_bgsave_status", saveStatus)
+	w.field("aof_enabled", 0)
+	w.field("aof_rewrite_in_progress", 0)
+}
+
+func (h *ClientHandler) infoStats(_ *client, w *infoWriter, _ *sync.RWMutex) {
+	counters := stats.Get()
+	rates := stats.GetInstantaneous()
+	w.field("total_connections_received", counters.ConnectionsReceived)
+	w.field("total_commands_processed", counters.CommandsProcessed)
+	w.field("instantaneous_ops_per_sec", int64(rates.Ops))
+	w.field("total_net_input_bytes", counters.NetInputBytes)
+	w.field("total_net_output_bytes", counters.NetOutputBytes)
+	w.field("instantaneous_input_kbps", formatRatio(rates.InputBytes/1024))
+	w.field("instantaneous_output_kbps", formatRatio(rates.OutputBytes/1024))
+	w.field("rejected_connections", 0)
+	w.field("expired_keys", counters.ExpiredKeys)
+	eviction := memory.GetStats()
+	w.field("evicted_keys", eviction.EvictedKeys)
+	w.field("evicted_clients", 0)
+	w.field("total_eviction_exceeded_time", eviction.ExceededTime.Milliseconds())
+	w.field("current_eviction_exceeded_time", eviction.CurrentExceededTime.Milliseconds())
+	w.field("keyspace_hits", counters.KeyspaceHits)
+	w.field("keyspace_misses", counters.KeyspaceMisses)
+	w.field("pubsub_channels", len(h.pubsub.Channels("")))
+	w.field("pubsub_patterns", h.pubsub.NumPat())
+	w.field("pubsub_shardchannels", len(h.pubsub.ShardChannels("")))
+	w.field("total_error_replies", counters.ErrorReplies)
+}
+
+func (h *ClientHandler) infoReplication(_ *client, w *infoWriter, _ *sync.RWMutex) {
+	w.field("role", "master")
+	w.field("connected_slaves", 0)
+	w.field("master_replid", stats.RunID)
+	w.field("master_repl_offset", 0)
+	w.field("repl_backlog_active", 0)
+}
+
+func (h *ClientHandler) infoCPU(_ *client, w *infoWriter, _ *sync.RWMutex) {
+	sys, user := stats.CPU()
+	w.field("used_cpu_sys", strconv.FormatFloat(sys.Seconds(), 'f', 6, 64))
+	w.field("used_cpu_user", strconv.FormatFloat(user.Seconds(), 'f', 6, 64))
+	w.field("used_cpu_sys_children", "0.000000")
+	w.field("used_cpu_user_children", "0.000000")
+}
+
+func (h *ClientHandler) infoCommandStats(_ *client, w *infoWriter, _ *sync.RWMutex) {
+	commands := stats.Commands()
+	for _, name := range slices.Sorted(maps.Keys(commands)) {
+		cmd := commands[name]
+		usec := cmd.Duration.Microseconds()
+		perCall := 0.0
+		if cmd.Calls > 0 {
+			perCall = float64(cmd.Duration.Nanoseconds()) / 1000 / float64(cmd.Calls)
+		}
+		w.field("cmdstat_"+name, fmt.Sprintf("calls=%d,usec=%d,usec_per_call=%.2f,rejected_calls=%d,failed_calls=%d",
+			cmd.Calls, usec, perCall, cmd.RejectedCalls, cmd.FailedCalls))
+	}
+}
+
+func (h *ClientHandler) infoErrorStats(_ *client, w *infoWriter, _ *sync.RWMutex) {
+	errors := stats.Errors()
+	for _, prefix := range slices.Sorted(maps.Keys(errors)) {
+		w.field("errorstat_"+prefix, "count="+strconv.FormatInt(errors[prefix], 10))
+	}
+}
+
+func (h *ClientHandler) infoLatencyStats(_ *client, w *infoWriter, _ *sync.RWMutex) {
+	commands := stats.Commands()
+	for _, name := range slices.Sorted(maps.Keys(commands)) {
+		latency := commands[name].Latency
+		if latency.Count() == 0 {
+			continue
+		}
+		percentiles := make([]string, 0, 3)
+		for _, p := range []struct {
+			name       string
+			percentile float64
+		}{{"p50", 50}, {"p99", 99}, {"p99.9", 99.9}} {
+			usec := float64(latency.Percentile(p.percentile).Nanoseconds()) / 1000
+			percentiles = append(percentiles, p.name+"="+strconv.FormatFloat(usec, 'f', 3, 64))
+		}
+		w.field("latency_percentiles_usec_"+name, strings.Join(percentiles, ","))
+	}
+}
+
+// keyspaceTTLSamples is how many keys the average TTL is estimated from.
+const keyspaceTTLSamples = 64
+
+func (h *ClientHandler) infoKeyspace(_ *client, w *infoWriter, mu *sync.RWMutex) {
+	mu.RLock()
+	defer mu.RUnlock()
+	dataset := memory.Dataset(h.config.DB)
+	if dataset.Keys == 0 {
+		return
+	}
+	w.field("db0", fmt.Sprintf("keys=%d,expires=%d,avg_ttl=%d", dataset.Keys, dataset.Expires, averageTTL(h.config.DB)))
+}
+
+// averageTTL estimates the average TTL of the keys with one, in
+// milliseconds, from the first keys of the store.
+func averageTTL(store map[string]model.StoredData) int64 {
+	now := time.Now().UnixMilli()
+	var total, n, seen int64
+	for _, data := range store {
+		if seen++; seen > keyspaceTTLSamples {
+			break
+		}
+		if data.ExpiryDate > 0 {
+			total += max(data.ExpiryDate*1000-now, 0)
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return total / n
+}
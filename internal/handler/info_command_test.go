@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"io"
+	"redis-go-clone/cmd/config"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readBulk reads a bulk string reply of any length.
+func (c *testConn) readBulk() string {
+	c.t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(time.Second))
+	var header []byte
+	b := make([]byte, 1)
+	for !strings.HasSuffix(string(header), "\r\n") {
+		if _, err := io.ReadFull(c.conn, b); err != nil {
+			c.t.Fatalf("read failed: %v", err)
+		}
+		header = append(header, b[0])
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(string(header[1:]), "\r\n"))
+	if header[0] != '$' || err != nil {
+		c.t.Fatalf("expected a bulk string, got %q", header)
+	}
+	body := make([]byte, n+2)
+	if _, err := io.ReadFull(c.conn, body); err != nil {
+		c.t.Fatalf("read failed: %v", err)
+	}
+	return string(body[:n])
+}
+
+// info sends INFO with args and returns its fields and section titles.
+func (c *testConn) info(args ...string) (map[string]string, []string) {
+	c.t.Helper()
+	c.send(append([]string{"INFO"}, args...)...)
+	fields := make(map[string]string)
+	var sections []string
+	for _, line := range strings.Split(c.readBulk(), "\r\n") {
+		if title, found := strings.CutPrefix(line, "# "); found {
+			sections = append(sections, title)
+		} else if name, value, found := strings.Cut(line, ":"); found {
+			fields[name] = value
+		}
+	}
+	return fields, sections
+}
+
+func TestInfoSections(t *testing.T) {
+	h := NewClientHandler(config.NewConfig())
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{nil, "Server,Clients,Memory,Persistence,Stats,Replication,CPU,Errorstats,Keyspace"},
+		{[]string{"default"}, "Server,Clients,Memory,Persistence,Stats,Replication,CPU,Errorstats,Keyspace"},
+		{[]string{"all"}, "Server,Clients,Memory,Persistence,Stats,Replication,CPU,Commandstats,Errorstats,Latencystats,Keyspace"},
+		{[]string{"CPU"}, "CPU"},
+		{[]string{"keyspace", "server", "nonsense"}, "Server,Keyspace"},
+		{[]string{"nonsense"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			client := connect(t, h)
+			if _, sections := client.info(tt.args...); strings.Join(sections, ",") != tt.want {
+				t.Errorf("INFO %v printed %v, want %s", tt.args, sections, tt.want)
+			}
+		})
+	}
+}
+
+func TestInfoCounters(t *testing.T) {
+	h := NewClientHandler(config.NewConfig())
+	client := connect(t, h)
+
+	client.send("SET", "k", "v")
+	client.expect("+OK\r\n")
+	client.send("GET", "k")
+	client.expect("$1\r\nv\r\n")
+	client.send("GET", "missing")
+	client.expect("$-1\r\n")
+	client.send("HGET", "k", "f")
+	client.expect("-ERR value is not type of hash\r\n")
+	client.send("NOPE")
+	client.expect("-ERR unknown command\r\n")
+
+	fields, _ := client.info("everything")
+	for name, want := range map[string]string{
+		"keyspace_hits":    "2",
+		"keyspace_misses":  "1",
+		"db0":              "keys=1,expires=0,avg_ttl=0",
+		"errorstat_ERR":    "count=2",
+		"role":             "master",
+		"maxmemory_policy": "noeviction",
+	} {
+		if fields[name] != want {
+			t.Errorf("%s = %q, want %q", name, fields[name], want)
+		}
+	}
+
+	for name, want := range map[string]string{
+		"cmdstat_get":  "calls=2,",
+		"cmdstat_set":  "calls=1,",
+		"cmdstat_hget": "calls=1,",
+	} {
+		if !strings.HasPrefix(fields[name], want) {
+			t.Errorf("%s = %q, want it to start with %q", name, fields[name], want)
+		}
+	}
+	if !strings.HasSuffix(fields["cmdstat_hget"], "rejected_calls=0,failed_calls=1") {
+		t.Errorf("cmdstat_hget = %q, want one failed call", fields["cmdstat_hget"])
+	}
+	if _, found := fields["cmdstat_nope"]; found {
+		t.Error("unknown commands should not have command stats")
+	}
+	if !strings.HasPrefix(fields["latency_percentiles_usec_get"], "p50=") {
+		t.Errorf("latency_percentiles_usec_get = %q", fields["latency_percentiles_usec_get"])
+	}
+}
+
+func TestInfoCountsRejectedCommands(t *testing.T) {
+	h := NewClientHandler(config.NewConfig())
+	client := connect(t, h)
+
+	client.send("MULTI")
+	client.expect("+OK\r\n")
+	client.send("GET")
+	client.expect("-ERR wrong number of arguments for GET\r\n")
+	client.send("DISCARD")
+	client.expect("+OK\r\n")
+
+	fields, _ := client.info("commandstats")
+	if want := "calls=0,usec=0,usec_per_call=0.00,rejected_calls=1,failed_calls=0"; fields["cmdstat_get"] != want {
+		t.Errorf("cmdstat_get = %q, want %q", fields["cmdstat_get"], want)
+	}
+}
+
+// INFO inside a transaction runs under the store lock, while a client
+// blocked on a key the transaction fills is being served.
+func TestInfoBlockedClientsInTransaction(t *testing.T) {
+	h := NewClientHandler(config.NewConfig())
+	blocked := connect(t, h)
+	client := connect(t, h)
+
+	blocked.send("BZPOPMIN", "dq", "0")
+	for deadline := time.Now().Add(time.Second); h.blocked.count() == 0; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("the client never blocked")
+		}
+	}
+
+	client.send("MULTI")
+	client.expect("+OK\r\n")
+	for _, args := range [][]string{
+		{"ZADD", "dq", "1", "x"},
+		{"EVAL", "for i = 1, 2000000 do end", "0"},
+		{"INFO", "clients"},
+	} {
+		client.send(args...)
+		client.expect("+QUEUED\r\n")
+	}
+	client.send("EXEC")
+	client.expect("*3\r\n:1\r\n$-1\r\n")
+	if info := client.readBulk(); !strings.Contains(info, "blocked_clients:1\r\n") {
+		t.Errorf("INFO clients = %q", info)
+	}
+	blocked.expect("*3\r\n$2\r\ndq\r\n$1\r\nx\r\n$1\r\n1\r\n")
+}
@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"maps"
+	"redis-go-clone/internal/latency"
+	"redis-go-clone/internal/stats"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// latencyCommand implements LATENCY LATEST, HISTORY, RESET, GRAPH, DOCTOR
+// and HISTOGRAM.
+func (h *ClientHandler) latencyCommand(_ *client, cmdArray []any, _ *sync.RWMutex) string {
+	if len(cmdArray) < 2 {
+		return "-ERR wrong number of arguments for LATENCY\r\n"
+	}
+	args := argStrings(cmdArray[2:])
+	switch subcommand := strings.ToUpper(argString(cmdArray[1])); {
+	case subcommand == "LATEST" && len(args) == 0:
+		latest := []any{}
+		for _, event := range latency.GetLatest() {
+			latest = append(latest, []any{event.Event, int(event.Time.Unix()), int(event.Latency), int(event.Max)})
+		}
+		return bulkReply(latest)
+	case subcommand == "HISTORY" && len(args) == 1:
+		history := []any{}
+		for _, sample := range latency.History(args[0]) {
+			history = append(history, []any{int(sample.Time.Unix()), int(sample.Latency)})
+		}
+		return bulkReply(history)
+	case subcommand == "RESET":
+		return bulkReply(latency.Reset(args...))
+	case subcommand == "GRAPH" && len(args) == 1:
+		graph, found := latency.Graph(args[0])
+		if !found {
+			return "-ERR No samples available for event '" + args[0] + "'\r\n"
+		}
+		return bulkReply(graph)
+	case subcommand == "DOCTOR" && len(args) == 0:
+		return bulkReply(latency.Doctor())
+	case subcommand == "HISTOGRAM":
+		return latencyHistogram(args)
+	case subcommand == "LATEST" || subcommand == "HISTORY" || subcommand == "GRAPH" || subcommand == "DOCTOR":
+		return "-ERR wrong number of arguments for LATENCY " + subcommand + "\r\n"
+	default:
+		return "-ERR unknown subcommand '" + argString(cmdArray[1]) + "'. Try LATENCY HELP.\r\n"
+	}
+}
+
+// latencyHistogram replies with the latency distribution of the given
+// commands, or of every command that ran when none is given: for each,
+// how many calls took less than each power of two microseconds.
+func latencyHistogram(names []string) string {
+	commands := stats.Commands()
+	if len(names) == 0 {
+		names = slices.Sorted(maps.Keys(commands))
+	}
+
+	reply := []any{}
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = strings.ToLower(name)
+		cmd, found := commands[name]
+		if !found || cmd.Calls == 0 || seen[name] {
+			continue
+		}
+		seen[name] = true
+		buckets := []any{}
+		for _, power := range cmd.Latency.PowersOfTwo() {
+			buckets = append(buckets, int(power.Limit.Nanoseconds()/1000), int(power.Count))
+		}
+		reply = append(reply, name, []any{"calls", int(cmd.Calls), "histogram_usec", buckets})
+	}
+	return bulkReply(reply)
+}
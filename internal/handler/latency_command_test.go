@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"redis-go-clone/cmd/config"
+	"redis-go-clone/internal/latency"
+	"redis-go-clone/internal/stats"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLatencyCommand(t *testing.T) {
+	h := NewClientHandler(config.NewConfig())
+	client := connect(t, h)
+	t.Cleanup(func() { latency.SetThreshold("0") })
+
+	client.send("LATENCY", "LATEST")
+	client.expect("*0\r\n")
+	client.send("CONFIG", "SET", "latency-monitor-threshold", "10")
+	client.expect("+OK\r\n")
+
+	latency.Add(latency.ExpireCycle, 25*time.Millisecond)
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	client.send("LATENCY", "LATEST")
+	client.expect("*1\r\n*4\r\n$12\r\nexpire-cycle\r\n:" + now + "\r\n:25\r\n:25\r\n")
+	client.send("LATENCY", "HISTORY", "expire-cycle")
+	client.expect("*1\r\n*2\r\n:" + now + "\r\n:25\r\n")
+	client.send("LATENCY", "HISTORY", "save")
+	client.expect("*0\r\n")
+
+	client.send("LATENCY", "GRAPH", "save")
+	client.expect("-ERR No samples available for event 'save'\r\n")
+	client.send("LATENCY", "GRAPH", "expire-cycle")
+	if graph := client.readBulk(); !strings.HasPrefix(graph, "expire-cycle - high 25 ms, low 25 ms (all time high 25 ms)\n") {
+		t.Errorf("LATENCY GRAPH replied %q", graph)
+	}
+	client.send("LATENCY", "DOCTOR")
+	if doctor := client.readBulk(); !strings.Contains(doctor, "1. expire-cycle: 1 latency spikes") {
+		t.Errorf("LATENCY DOCTOR replied %q", doctor)
+	}
+
+	client.send("LATENCY", "RESET")
+	client.expect(":1\r\n")
+	client.send("LATENCY", "LATEST")
+	client.expect("*0\r\n")
+	client.send("LATENCY", "NOPE")
+	client.expect("-ERR unknown subcommand 'NOPE'. Try LATENCY HELP.\r\n")
+}
+
+func TestLatencyHistogram(t *testing.T) {
+	h := NewClientHandler(config.NewConfig())
+	client := connect(t, h)
+
+	stats.Call("get", 3*time.Microsecond, "$1\r\nv\r\n")
+	stats.Call("get", 100*time.Microsecond, "$1\r\nv\r\n")
+	client.send("LATENCY", "HISTOGRAM", "get", "GET", "nope")
+	client.expect("*2\r\n$3\r\nget\r\n*4\r\n$5\r\ncalls\r\n:2\r\n$14\r\nhistogram_usec\r\n*4\r\n:4\r\n:1\r\n:131\r\n:2\r\n")
+
+	client.send("LATENCY", "HISTOGRAM", "nope")
+	client.expect("*0\r\n")
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"os"
+	"path/filepath"
+	"redis-go-clone/cmd/config"
+	"redis-go-clone/internal/logging"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClientLog(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "redis.log")
+	if err := logging.Setup(name, "text"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		logging.Setup("", "text")
+		logging.SetLevel("notice")
+	})
+
+	h := NewClientHandler(config.NewConfig())
+	admin := connect(t, h)
+	admin.send("CONFIG", "SET", "loglevel", "verbose")
+	admin.expect("+OK\r\n")
+	admin.send("CONFIG", "SET", "logfile", "other.log")
+	admin.expect("-ERR CONFIG SET failed (possibly related to argument 'logfile') - can't set immutable config\r\n")
+
+	client := connect(t, h)
+	client.send("EVAL", "redis.log(redis.LOG_WARNING, 'from', 'script')", "0")
+	client.expect("$-1\r\n")
+	client.send("EVAL", "redis.log(9, 'x')", "0")
+	client.expect("-ERR user_script:1: Invalid debug level.")
+	client.conn.Close()
+
+	var log string
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		data, _ := os.ReadFile(name)
+		if log = string(data); strings.Count(log, "Client closed connection") == 1 {
+			break
+		}
+	}
+	for _, want := range []string{
+		"level=verbose msg=\"Accepted client\" client_id=",
+		"level=warning msg=\"from script\" client_id=",
+		"level=verbose msg=\"Client closed connection\" client_id=",
+	} {
+		if !strings.Contains(log, want) {
+			t.Errorf("log is missing %q:\n%s", want, log)
+		}
+	}
+	if strings.Contains(log, "Error reading") {
+		t.Errorf("closing the connection was logged as an error:\n%s", log)
+	}
+}
@@ -0,0 +1,210 @@
+package handler
+
+import (
+	"fmt"
+	"redis-go-clone/internal/memory"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// memoryUsageSamples is how many elements of an aggregate value MEMORY
+// USAGE looks at by default.
+const memoryUsageSamples = 5
+
+// memoryCommand implements MEMORY USAGE, STATS, DOCTOR and MALLOC-STATS.
+func (h *ClientHandler) memoryCommand(_ *client, cmdArray []any, mu *sync.RWMutex) string {
+	if len(cmdArray) < 2 {
+		return "-ERR wrong number of arguments for MEMORY\r\n"
+	}
+	args := argStrings(cmdArray[2:])
+	switch subcommand := strings.ToUpper(argString(cmdArray[1])); subcommand {
+	case "USAGE":
+		return h.memoryUsage(args, mu)
+	case "STATS", "DOCTOR", "MALLOC-STATS":
+		if len(args) != 0 {
+			return "-ERR wrong number of arguments for MEMORY " + subcommand + "\r\n"
+		}
+		switch subcommand {
+		case "STATS":
+			return h.memoryStats(mu)
+		case "DOCTOR":
+			return bulkReply(h.memoryDoctor(mu))
+		default:
+			return bulkReply(mallocStats())
+		}
+	default:
+		return "-ERR unknown subcommand '" + argString(cmdArray[1]) + "'. Try MEMORY HELP.\r\n"
+	}
+}
+
+// memoryUsage implements MEMORY USAGE key [SAMPLES count], where a count of
+// 0 looks at every element.
+func (h *ClientHandler) memoryUsage(args []string, mu *sync.RWMutex) string {
+	if len(args) != 1 && (len(args) != 3 || !strings.EqualFold(args[1], "SAMPLES")) {
+		return "-ERR syntax error\r\n"
+	}
+	samples := memoryUsageSamples
+	if len(args) == 3 {
+		n, err := strconv.Atoi(args[2])
+		if err != nil || n < 0 {
+			return "-ERR value is not an integer or out of range\r\n"
+		}
+		samples = n
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+	data, found := h.config.DB[args[0]]
+	if !found {
+		return "$-1\r\n"
+	}
+	return ":" + strconv.Itoa(data.MemoryUsage(args[0], samples)) + "\r\n"
+}
+
+// memoryReport is the memory of the process as the Go runtime reports it,
+// broken down with the estimates of what the keys and the script caches
+// take. MEMORY STATS and INFO memory both report it.
+type memoryReport struct {
+	memory.Allocator
+	dataset            memory.Usage
+	scripts, functions int
+	overhead           int
+	datasetBytes       int
+}
+
+func (h *ClientHandler) readMemory(mu *sync.RWMutex) memoryReport {
+	mu.RLock()
+	dataset := memory.Dataset(h.config.DB)
+	mu.RUnlock()
+	r := memoryReport{Allocator: memory.ReadAllocator(), dataset: dataset}
+	r.scripts, r.functions = h.scripting.cacheSizes()
+	r.overhead = int(r.Startup) + int(dataset.MainOverhead+dataset.ExpiresOverhead) + r.scripts + r.functions
+	r.datasetBytes = int(dataset.Bytes - dataset.MainOverhead - dataset.ExpiresOverhead)
+	return r
+}
+
+// datasetPercentage is the share of what was allocated since startup that
+// holds the keys.
+func (r memoryReport) datasetPercentage() float64 {
+	if r.HeapAlloc <= r.Startup {
+		return 0
+	}
+	return float64(r.datasetBytes) * 100 / float64(r.HeapAlloc-r.Startup)
+}
+
+func (h *ClientHandler) memoryStats(mu *sync.RWMutex) string {
+	r := h.readMemory(mu)
+	total := int(r.HeapAlloc)
+	bytesPerKey := 0
+	if r.dataset.Keys > 0 {
+		bytesPerKey = max(total-int(r.Startup), 0) / int(r.dataset.Keys)
+	}
+
+	return bulkReply([]any{
+		"peak.allocated", int(r.Peak),
+		"total.allocated", total,
+		"startup.allocated", int(r.Startup),
+		"lua.caches", r.scripts,
+		"functions.caches", r.functions,
+		"db.0", []any{
+			"overhead.hashtable.main", int(r.dataset.MainOverhead),
+			"overhead.hashtable.expires", int(r.dataset.ExpiresOverhead),
+		},
+		"overhead.total", r.overhead,
+		"keys.count", int(r.dataset.Keys),
+		"keys.bytes-per-key", bytesPerKey,
+		"dataset.bytes", r.datasetBytes,
+		"dataset.percentage", formatRatio(r.datasetPercentage()),
+		"peak.percentage", formatRatio(float64(total) * 100 / float64(r.Peak)),
+		"allocator.allocated", total,
+		"allocator.active", int(r.HeapInuse),
+		"allocator.resident", int(r.HeapSys - r.HeapReleased),
+		"allocator-fragmentation.ratio", formatRatio(float64(r.HeapInuse) / float64(r.HeapAlloc)),
+		"allocator-fragmentation.bytes", int(r.HeapInuse - r.HeapAlloc),
+		"fragmentation", formatRatio(float64(r.Sys) / float64(r.HeapAlloc)),
+		"fragmentation.bytes", int(r.Sys - r.HeapAlloc),
+	})
+}
+
+func formatRatio(f float64) string {
+	return strconv.FormatFloat(f, 'f', 2, 64)
+}
+
+// memoryDoctor looks for the memory issues it knows about and explains
+// them.
+func (h *ClientHandler) memoryDoctor(mu *sync.RWMutex) string {
+	allocator := memory.ReadAllocator()
+	if allocator.HeapAlloc < 5<<20 {
+		return "Hi Sam, this instance is empty or is using very little memory, my issues detector can't be used in these conditions. " +
+			"Please, leave for your mission on Earth and fill it with some data. " +
+			"The new Sam and I will be back to our programming as soon as I finished rebooting."
+	}
+
+	var issues []string
+	if allocator.Peak > allocator.HeapAlloc*3/2 {
+		issues = append(issues, "Peak memory: In the past this instance used more than 150% the memory that is currently using. "+
+			"The Go runtime returns memory to the system slowly after a peak, so the process may look bigger than the data it holds.")
+	}
+	if fragmentation := allocator.HeapInuse - allocator.HeapAlloc; fragmentation > 10<<20 &&
+		float64(allocator.HeapInuse)/float64(allocator.HeapAlloc) > 1.4 {
+		issues = append(issues, fmt.Sprintf("High allocator fragmentation: The heap spans %d bytes more than the live objects need. "+
+			"This is usually due to deleting many keys after a peak and goes away as the heap is reused.", fragmentation))
+	}
+	if memory.Limited() {
+		mu.RLock()
+		used := memory.Used(h.config.DB)
+		mu.RUnlock()
+		limit, _ := strconv.ParseInt(memory.MaxMemory(), 10, 64)
+		if memory.Policy() == memory.NoEviction && used*10 > limit*9 {
+			issues = append(issues, "Maxmemory: The keys use more than 90% of maxmemory and the policy is noeviction, "+
+				"so write commands will soon be refused. Consider raising maxmemory or setting an eviction policy.")
+		}
+	}
+
+	if len(issues) == 0 {
+		return "Hi Sam, I can't find any memory issue in your instance. I can only account for what occurs on this base."
+	}
+	var report strings.Builder
+	report.WriteString("Sam, I detected a few issues in this Redis instance memory implants:\n\n")
+	for _, issue := range issues {
+		report.WriteString(" * " + issue + "\n\n")
+	}
+	report.WriteString("I'm here to keep you safe, Sam. I want to help you.\n")
+	return report.String()
+}
+
+// mallocStats describes the Go runtime memory statistics, which take the
+// place of the allocator ones in Redis.
+func mallocStats() string {
+	stats := memory.ReadAllocator()
+	var b strings.Builder
+	b.WriteString("Go runtime memory statistics:\n")
+	for _, stat := range []struct {
+		name  string
+		value uint64
+	}{
+		{"heap_alloc", stats.HeapAlloc},
+		{"heap_sys", stats.HeapSys},
+		{"heap_idle", stats.HeapIdle},
+		{"heap_inuse", stats.HeapInuse},
+		{"heap_released", stats.HeapReleased},
+		{"heap_objects", stats.HeapObjects},
+		{"stack_inuse", stats.StackInuse},
+		{"stack_sys", stats.StackSys},
+		{"mspan_inuse", stats.MSpanInuse},
+		{"mcache_inuse", stats.MCacheInuse},
+		{"gc_sys", stats.GCSys},
+		{"other_sys", stats.OtherSys},
+		{"sys", stats.Sys},
+		{"total_alloc", stats.TotalAlloc},
+		{"mallocs", stats.Mallocs},
+		{"frees", stats.Frees},
+		{"next_gc", stats.NextGC},
+		{"num_gc", uint64(stats.NumGC)},
+		{"pause_total_ns", stats.PauseTotalNs},
+	} {
+		fmt.Fprintf(&b, "%s:%d\n", stat.name, stat.value)
+	}
+	return b.String()
+}
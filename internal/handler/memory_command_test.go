@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"redis-go-clone/cmd/config"
+	"strings"
+	"testing"
+)
+
+func TestMemoryUsage(t *testing.T) {
+	h := NewClientHandler(config.NewConfig())
+	client := connect(t, h)
+
+	client.send("SET", "k", "value")
+	client.expect("+OK\r\n")
+	client.send("HSET", "h", "f1", "v1", "f2", "v2")
+	client.expect(":2\r\n")
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"String", []string{"k"}, ":72\r\n"},
+		{"Missing key", []string{"missing"}, "$-1\r\n"},
+		{"All samples", []string{"h", "SAMPLES", "0"}, ":222\r\n"},
+		{"Negative samples", []string{"k", "SAMPLES", "-1"}, "-ERR value is not an integer or out of range\r\n"},
+		{"Syntax error", []string{"k", "COUNT", "1"}, "-ERR syntax error\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := connect(t, h)
+			client.send(append([]string{"MEMORY", "USAGE"}, tt.args...)...)
+			client.expect(tt.want)
+		})
+	}
+}
+
+func TestMemoryStatsDoctorAndMallocStats(t *testing.T) {
+	h := NewClientHandler(config.NewConfig())
+	client := connect(t, h)
+	client.send("SET", "k", "value")
+	client.expect("+OK\r\n")
+
+	stats := h.memoryCommand(nil, []any{"MEMORY", "STATS"}, h.config.Lock)
+	for _, field := range []string{"peak.allocated", "total.allocated", "overhead.hashtable.main", "dataset.bytes", "fragmentation"} {
+		if !strings.Contains(stats, bulk(field)) {
+			t.Errorf("MEMORY STATS has no %s field: %q", field, stats)
+		}
+	}
+	if !strings.Contains(stats, "$10\r\nkeys.count\r\n:1\r\n") {
+		t.Errorf("MEMORY STATS should count one key: %q", stats)
+	}
+
+	if doctor := h.memoryCommand(nil, []any{"MEMORY", "DOCTOR"}, h.config.Lock); !strings.Contains(doctor, "Sam") {
+		t.Errorf("unexpected MEMORY DOCTOR reply %q", doctor)
+	}
+	if malloc := h.memoryCommand(nil, []any{"MEMORY", "MALLOC-STATS"}, h.config.Lock); !strings.Contains(malloc, "\nheap_alloc:") {
+		t.Errorf("unexpected MEMORY MALLOC-STATS reply %q", malloc)
+	}
+
+	client.send("MEMORY", "STATS", "extra")
+	client.expect("-ERR wrong number of arguments for MEMORY STATS\r\n")
+	client.send("MEMORY", "NOPE")
+	client.expect("-ERR unknown subcommand 'NOPE'. Try MEMORY HELP.\r\n")
+}
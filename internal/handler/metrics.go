@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"redis-go-clone/internal/memory"
+	"redis-go-clone/internal/stats"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// metricsLatencyBuckets are the upper bounds of the command latency
+// histograms: every other power of two nanoseconds from about 1µs to 4s,
+// which stats.Histogram counts exactly.
+var metricsLatencyBuckets = func() []time.Duration {
+	buckets := make([]time.Duration, 12)
+	for i := range buckets {
+		buckets[i] = time.Duration(1024) << (2 * i)
+	}
+	return buckets
+}()
+
+// metricsWriter builds a page in the Prometheus text exposition format.
+type metricsWriter struct {
+	strings.Builder
+}
+
+// family starts the samples of a metric with its help and type.
+func (w *metricsWriter) family(name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// sample writes a value of a metric, with labels given as name/value pairs.
+func (w *metricsWriter) sample(name string, value any, labels ...string) {
+	w.WriteString(name)
+	if len(labels) > 0 {
+		w.WriteByte('{')
+		for i := 0; i < len(labels); i += 2 {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(labels[i] + `="` + escapeLabel(labels[i+1]) + `"`)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	switch v := value.(type) {
+	case float64:
+		w.WriteString(formatMetric(v))
+	default:
+		fmt.Fprint(w, v)
+	}
+	w.WriteByte('\n')
+}
+
+// metric writes a metric that has a single sample without labels.
+func (w *metricsWriter) metric(name, kind, help string, value any) {
+	w.family(name, kind, help)
+	w.sample(name, value)
+}
+
+func formatMetric(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+// Metrics returns an HTTP handler serving the state of the server on
+// /metrics in the Prometheus text format, so that it can be scraped without
+// an exporter.
+func (h *ClientHandler) Metrics() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Write([]byte(h.metrics()))
+	})
+	return mux
+}
+
+func (h *ClientHandler) metrics() string {
+	w := &metricsWriter{}
+	counters := stats.Get()
+	rates := stats.GetInstantaneous()
+
+	w.metric("redis_start_time_seconds", "gauge", "Time the server started, in seconds since the epoch.", stats.StartTime.Unix())
+	w.metric("redis_uptime_in_seconds", "gauge", "Seconds since the server started.", int64(time.Since(stats.StartTime).Seconds()))
+
+	w.metric("redis_connected_clients", "gauge", "Number of client connections.", counters.ConnectedClients)
+	w.metric("redis_blocked_clients", "gauge", "Number of clients waiting on a blocking command.", h.blocked.count())
+	w.metric("redis_connections_received_total", "counter", "Connections accepted by the server.", counters.ConnectionsReceived)
+
+	w.metric("redis_commands_processed_total", "counter", "Commands processed by the server.", counters.CommandsProcessed)
+	w.metric("redis_instantaneous_ops_per_sec", "gauge", "Commands processed per second, averaged over the last samples.", rates.Ops)
+	w.metric("redis_net_input_bytes_total", "counter", "Bytes read from clients.", counters.NetInputBytes)
+	w.metric("redis_net_output_bytes_total", "counter", "Bytes written to clients.", counters.NetOutputBytes)
+	w.metric("redis_keyspace_hits_total", "counter", "Lookups of keys that were found.", counters.KeyspaceHits)
+	w.metric("redis_keyspace_misses_total", "counter", "Lookups of keys that were missing.", counters.KeyspaceMisses)
+	w.metric("redis_expired_keys_total", "counter", "Keys deleted because their TTL expired.", counters.ExpiredKeys)
+	w.metric("redis_evicted_keys_total", "counter", "Keys evicted to stay under maxmemory.", memory.GetStats().EvictedKeys)
+
+	errors := stats.Errors()
+	w.family("redis_errors_total", "counter", "Error replies by error prefix.")
+	for _, prefix := range slices.Sorted(maps.Keys(errors)) {
+		w.sample("redis_errors_total", errors[prefix], "err", prefix)
+	}
+	h.commandMetrics(w)
+
+	r := h.readMemory(h.config.Lock)
+	maxMemory, _ := strconv.ParseInt(memory.MaxMemory(), 10, 64)
+	w.metric("redis_memory_used_bytes", "gauge", "Estimated bytes taken by the keys, as counted against maxmemory.", r.dataset.Bytes)
+	w.metric("redis_memory_used_heap_bytes", "gauge", "Bytes allocated by the server.", r.HeapAlloc)
+	w.metric("redis_memory_used_rss_bytes", "gauge", "Bytes obtained from the operating system.", r.Sys)
+	w.metric("redis_memory_used_peak_bytes", "gauge", "Highest number of bytes allocated.", r.Peak)
+	w.metric("redis_memory_used_dataset_bytes", "gauge", "Estimated bytes taken by the keys and their values.", r.datasetBytes)
+	w.metric("redis_memory_max_bytes", "gauge", "The maxmemory limit, 0 when there is none.", maxMemory)
+
+	w.family("redis_db_keys", "gauge", "Number of keys by database.")
+	w.sample("redis_db_keys", r.dataset.Keys, "db", "db0")
+	w.family("redis_db_keys_expiring", "gauge", "Number of keys with a TTL by database.")
+	w.sample("redis_db_keys_expiring", r.dataset.Expires, "db", "db0")
+
+	saveStatus := 1
+	if counters.LastSaveFailed {
+		saveStatus = 0
+	}
+	w.metric("redis_rdb_changes_since_last_save", "gauge", "Changes to the keys since the last save.", counters.Dirty)
+	w.metric("redis_rdb_last_save_timestamp_seconds", "gauge", "Time of the last successful save, in seconds since the epoch.", counters.LastSave.Unix())
+	w.metric("redis_rdb_last_save_status", "gauge", "1 if the last save succeeded, 0 if it failed.", saveStatus)
+	return w.String()
+}
+
+// commandMetrics writes the calls of every command that ran or was
+// rejected, and a histogram of how long the calls took.
+func (h *ClientHandler) commandMetrics(w *metricsWriter) {
+	commands := stats.Commands()
+	names := slices.Sorted(maps.Keys(commands))
+
+	w.family("redis_commands_total", "counter", "Calls by command.")
+	for _, name := range names {
+		w.sample("redis_commands_total", commands[name].Calls, "cmd", name)
+	}
+	w.family("redis_commands_rejected_calls_total", "counter", "Calls refused before they ran, by command.")
+	for _, name := range names {
+		w.sample("redis_commands_rejected_calls_total", commands[name].RejectedCalls, "cmd", name)
+	}
+	w.family("redis_commands_failed_calls_total", "counter", "Calls that replied with an error, by command.")
+	for _, name := range names {
+		w.sample("redis_commands_failed_calls_total", commands[name].FailedCalls, "cmd", name)
+	}
+
+	w.family("redis_commands_duration_seconds", "histogram", "Time spent running each command.")
+	for _, name := range names {
+		cmd := commands[name]
+		for _, limit := range metricsLatencyBuckets {
+			w.sample("redis_commands_duration_seconds_bucket", cmd.Latency.CountBelow(limit), "cmd", name, "le", formatMetric(limit.Seconds()))
+		}
+		w.sample("redis_commands_duration_seconds_bucket", cmd.Latency.Count(), "cmd", name, "le", "+Inf")
+		w.sample("redis_commands_duration_seconds_sum", cmd.Duration.Seconds(), "cmd", name)
+		w.sample("redis_commands_duration_seconds_count", cmd.Latency.Count(), "cmd", name)
+	}
+}
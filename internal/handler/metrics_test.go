@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"redis-go-clone/cmd/config"
+	"redis-go-clone/internal/stats"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetrics(t *testing.T) {
+	h := NewClientHandler(config.NewConfig())
+	client := connect(t, h)
+	client.send("SET", "k", "v")
+	client.expect("+OK\r\n")
+	client.send("HGET", "k", "f")
+	client.expect("-ERR value is not type of hash\r\n")
+	stats.Call("get", 3*time.Microsecond, "$1\r\nv\r\n")
+	stats.Call("get", 100*time.Microsecond, "$1\r\nv\r\n")
+
+	recorder := httptest.NewRecorder()
+	h.Metrics().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	if recorder.Code != 200 || !strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Fatalf("GET /metrics replied %d with %q", recorder.Code, recorder.Header().Get("Content-Type"))
+	}
+	lines := make(map[string]bool)
+	for _, line := range strings.Split(recorder.Body.String(), "\n") {
+		lines[line] = true
+	}
+
+	for _, want := range []string{
+		"# TYPE redis_commands_total counter",
+		`redis_commands_total{cmd="set"} 1`,
+		`redis_commands_failed_calls_total{cmd="hget"} 1`,
+		`redis_errors_total{err="ERR"} 1`,
+		"# TYPE redis_commands_duration_seconds histogram",
+		`redis_commands_duration_seconds_bucket{cmd="get",le="1.024e-06"} 0`,
+		`redis_commands_duration_seconds_bucket{cmd="get",le="4.096e-06"} 1`,
+		`redis_commands_duration_seconds_bucket{cmd="get",le="0.000262144"} 2`,
+		`redis_commands_duration_seconds_bucket{cmd="get",le="+Inf"} 2`,
+		`redis_commands_duration_seconds_sum{cmd="get"} 0.000103`,
+		`redis_commands_duration_seconds_count{cmd="get"} 2`,
+		`redis_db_keys{db="db0"} 1`,
+		`redis_db_keys_expiring{db="db0"} 0`,
+		"redis_rdb_last_save_status 1",
+	} {
+		if !lines[want] {
+			t.Errorf("metrics are missing %q", want)
+		}
+	}
+
+	recorder = httptest.NewRecorder()
+	h.Metrics().ServeHTTP(recorder, httptest.NewRequest("GET", "/other", nil))
+	if recorder.Code != 404 {
+		t.Errorf("GET /other replied %d, want 404", recorder.Code)
+	}
+}
+
+func TestEscapeLabel(t *testing.T) {
+	if got := escapeLabel("a\"b\\c\nd"); got != `a\"b\\c\nd` {
+		t.Errorf("escapeLabel() = %q", got)
+	}
+}
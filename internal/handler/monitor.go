@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// skipMonitorCommands are not shown to monitors: like in Redis, the
+// administrative commands and QUIT.
+var skipMonitorCommands = map[string]bool{
+	"QUIT": true, "MONITOR": true, "CONFIG": true, "SAVE": true, "SHUTDOWN": true, "SLOWLOG": true,
+	"LATENCY": true,
+}
+
+// skipMonitorClientSubcommands are the administrative CLIENT subcommands,
+// which are not shown to monitors either.
+var skipMonitorClientSubcommands = map[string]bool{
+	"LIST": true, "INFO": true, "KILL": true, "PAUSE": true, "UNPAUSE": true, "NO-EVICT": true,
+}
+
+// monitored reports whether a command is shown to the monitors.
+func monitored(cmdName string, cmdArray []any) bool {
+	if cmdName == "CLIENT" && len(cmdArray) > 1 {
+		return !skipMonitorClientSubcommands[strings.ToUpper(argString(cmdArray[1]))]
+	}
+	return !skipMonitorCommands[cmdName]
+}
+
+// monitors are the clients that ran MONITOR. Every command is pushed to
+// them without waiting, so a monitor that reads too slowly is disconnected
+// by its output limit instead of slowing down the other clients.
+type monitors struct {
+	mu      sync.RWMutex
+	clients map[*client]struct{}
+}
+
+func newMonitors() *monitors {
+	return &monitors{clients: make(map[*client]struct{})}
+}
+
+func (m *monitors) add(c *client) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.clients[c] = struct{}{}
+}
+
+func (m *monitors) remove(c *client) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.clients, c)
+}
+
+func (m *monitors) has(c *client) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, found := m.clients[c]
+	return found
+}
+
+// feed shows a command c is about to run to the monitors. Commands called
+// by scripts are shown as coming from lua.
+func (m *monitors) feed(c *client, cmdArray []any, fromScript bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if len(m.clients) == 0 {
+		return
+	}
+
+	now := time.Now()
+	source := c.conn.RemoteAddr().String()
+	if fromScript {
+		source = "lua"
+	}
+	var line strings.Builder
+	fmt.Fprintf(&line, "+%d.%06d [0 %s]", now.Unix(), now.Nanosecond()/1000, source)
+	for _, arg := range cmdArray {
+		line.WriteString(" " + quote(argString(arg)))
+	}
+	line.WriteString("\r\n")
+	for monitor := range m.clients {
+		monitor.Push(line.String())
+	}
+}
+
+// quote returns s in double quotes with quotes, backslashes and
+// non-printable bytes escaped, the way MONITOR shows arguments.
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\', '"':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\a':
+			b.WriteString(`\a`)
+		case '\b':
+			b.WriteString(`\b`)
+		default:
+			if c < ' ' || c > '~' {
+				fmt.Fprintf(&b, `\x%02x`, c)
+			} else {
+				b.WriteByte(c)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// monitor turns the connection into a monitor, which is shown every
+// command the server runs until it disconnects or sends RESET.
+func (h *ClientHandler) monitor(c *client, _ []any) string {
+	h.monitors.add(c)
+	return "+OK\r\n"
+}
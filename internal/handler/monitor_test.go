@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"redis-go-clone/cmd/config"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// expectMonitorLine reads a line pushed to a monitor and checks what
+// follows its timestamp.
+func (c *testConn) expectMonitorLine(want string) {
+	c.t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(time.Second))
+	var line strings.Builder
+	b := make([]byte, 1)
+	for !strings.HasSuffix(line.String(), "\r\n") {
+		if _, err := c.conn.Read(b); err != nil {
+			c.t.Fatalf("expected a monitor line, read failed: %v (got %q)", err, line.String())
+		}
+		line.WriteByte(b[0])
+	}
+	pattern := regexp.MustCompile(`^\+\d+\.\d{6} (.*)\r\n$`)
+	match := pattern.FindStringSubmatch(line.String())
+	if match == nil || match[1] != want {
+		c.t.Fatalf("expected monitor line %q, got %q", want, line.String())
+	}
+}
+
+func TestMonitor(t *testing.T) {
+	h := NewClientHandler(config.NewConfig())
+	monitor := connect(t, h)
+	client := connect(t, h)
+
+	monitor.send("MONITOR")
+	monitor.expect("+OK\r\n")
+
+	client.send("SET", "k", "a \"b\"\n\x01")
+	client.expect("+OK\r\n")
+	monitor.expectMonitorLine(`[0 pipe] "SET" "k" "a \"b\"\n\x01"`)
+
+	client.send("EVAL", "return redis.call('GET', KEYS[1])", "1", "k")
+	client.expect("$7\r\na \"b\"\n\x01\r\n")
+	monitor.expectMonitorLine(`[0 pipe] "EVAL" "return redis.call('GET', KEYS[1])" "1" "k"`)
+	monitor.expectMonitorLine(`[0 lua] "GET" "k"`)
+
+	// Administrative commands are not shown.
+	client.send("CONFIG", "GET", "maxmemory")
+	client.expect("*2\r\n+maxmemory\r\n+0\r\n")
+	client.send("PING")
+	client.expect("+PONG\r\n")
+	monitor.expectMonitorLine(`[0 pipe] "PING"`)
+
+	monitor.send("RESET")
+	monitor.expectMonitorLine(`[0 pipe] "RESET"`)
+	monitor.expect("+RESET\r\n")
+	h.monitors.mu.RLock()
+	defer h.monitors.mu.RUnlock()
+	if len(h.monitors.clients) != 0 {
+		t.Error("RESET should stop monitoring")
+	}
+}
+
+func TestMonitorSlowReaderIsDisconnected(t *testing.T) {
+	h := NewClientHandler(config.NewConfig())
+	monitor := connect(t, h)
+	client := connect(t, h)
+
+	monitor.send("MONITOR")
+	monitor.expect("+OK\r\n")
+
+	// The monitor never reads, which must not hold up the client.
+	for i := 0; i < clientOutputLimit+10; i++ {
+		client.send("PING")
+		client.expect("+PONG\r\n")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		h.monitors.mu.RLock()
+		n := len(h.monitors.clients)
+		h.monitors.mu.RUnlock()
+		if n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("slow monitor was not disconnected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"plain", `"plain"`},
+		{"", `""`},
+		{`back\slash`, `"back\\slash"`},
+		{"tab\tcr\rbell\abs\b", `"tab\tcr\rbell\abs\b"`},
+		{"\x00\xff", `"\x00\xff"`},
+	}
+	for _, tt := range tests {
+		if got := quote(tt.in); got != tt.want {
+			t.Errorf("quote(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
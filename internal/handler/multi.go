@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// transactionCommands run right away even inside MULTI.
+var transactionCommands = map[string]bool{
+	"MULTI":   true,
+	"EXEC":    true,
+	"DISCARD": true,
+	"WATCH":   true,
+	"QUIT":    true,
+	"RESET":   true,
+}
+
+func (h *ClientHandler) multi(c *client, _ []any) string {
+	if c.multi {
+		return "-ERR MULTI calls can not be nested\r\n"
+	}
+	c.multi = true
+	return "+OK\r\n"
+}
+
+// queueCommand adds a command to the transaction of c. Unknown commands and
+// wrong argument counts are reported now and make the whole transaction fail
+// on EXEC.
+func (h *ClientHandler) queueCommand(c *client, cmdName string, cmdArray []any) string {
+	arity, found := commandArity(cmdName)
+	if !found {
+		c.multiFailed = true
+		return rejected(cmdName, "-ERR unknown command\r\n")
+	}
+	if (arity > 0 && len(cmdArray) != arity) || len(cmdArray) < -arity {
+		c.multiFailed = true
+		return rejected(cmdName, "-ERR wrong number of arguments for "+cmdName+"\r\n")
+	}
+
+	c.queued = append(c.queued, cmdArray)
+	return "+QUEUED\r\n"
+}
+
+// exec runs the queued commands with the store locked for the whole
+// transaction, so no other client sees it half done. A command failing at
+// runtime does not stop the others: its error is part of the reply array.
+// The transaction is skipped with a null reply when a watched key changed.
+func (h *ClientHandler) exec(c *client, _ []any) string {
+	if !c.multi {
+		return "-ERR EXEC without MULTI\r\n"
+	}
+	if c.multiFailed {
+		c.resetTransaction()
+		return "-EXECABORT Transaction discarded because of previous errors.\r\n"
+	}
+
+	h.config.Lock.Lock()
+	defer h.config.Lock.Unlock()
+
+	queued, dirty := c.queued, c.watcher.Dirty(time.Now().Unix())
+	c.resetTransaction()
+	if dirty {
+		return "*-1\r\n"
+	}
+
+	// The commands lock this private mutex instead of the store lock we
+	// already hold. Blocking commands never block inside a transaction.
+	unlocked := &sync.RWMutex{}
+	var reply strings.Builder
+	reply.WriteString("*" + strconv.Itoa(len(queued)) + "\r\n")
+	for _, cmdArray := range queued {
+		cmdName := strings.ToUpper(cmdArray[0].(string))
+		reply.WriteString(h.runCommand(c, cmdName, cmdArray, unlocked, callSlowlog))
+	}
+	return reply.String()
+}
+
+func (h *ClientHandler) discard(c *client, _ []any) string {
+	if !c.multi {
+		return "-ERR DISCARD without MULTI\r\n"
+	}
+	c.resetTransaction()
+	return "+OK\r\n"
+}
+
+// watch watches keys for changes until the next EXEC, DISCARD or UNWATCH.
+func (h *ClientHandler) watch(c *client, cmdArray []any) string {
+	if c.multi {
+		return "-ERR WATCH inside MULTI is not allowed\r\n"
+	}
+
+	h.config.Lock.RLock()
+	defer h.config.Lock.RUnlock()
+
+	now := time.Now().Unix()
+	for _, key := range argStrings(cmdArray[1:]) {
+		// A key that already expired is watched as a missing key.
+		expiryDate := h.config.DB[key].ExpiryDate
+		if expiryDate <= now {
+			expiryDate = 0
+		}
+		c.watcher.Watch(key, expiryDate)
+	}
+	return "+OK\r\n"
+}
+
+func (h *ClientHandler) unwatch(c *client, _ []any) string {
+	c.watcher.Unwatch()
+	return "+OK\r\n"
+}
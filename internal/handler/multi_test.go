@@ -0,0 +1,237 @@
+package handler
+
+import (
+	"redis-go-clone/cmd/config"
+	"redis-go-clone/internal/model"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestMultiExec(t *testing.T) {
+	h := NewClientHandler(config.NewConfig())
+	client := connect(t, h)
+
+	client.send("EXEC")
+	client.expect("-ERR EXEC without MULTI\r\n")
+	client.send("DISCARD")
+	client.expect("-ERR DISCARD without MULTI\r\n")
+
+	client.send("MULTI")
+	client.expect("+OK\r\n")
+	client.send("MULTI")
+	client.expect("-ERR MULTI calls can not be nested\r\n")
+	client.send("SET", "from", "alice")
+	client.expect("+QUEUED\r\n")
+	client.send("SADD", "from", "x")
+	client.expect("+QUEUED\r\n")
+	client.send("SET", "to", "bob")
+	client.expect("+QUEUED\r\n")
+	client.send("GET", "to")
+	client.expect("+QUEUED\r\n")
+	// The wrong-type error is returned in place, the other commands run.
+	client.send("EXEC")
+	client.expect("*4\r\n+OK\r\n-ERR value is not type of set\r\n+OK\r\n$3\r\nbob\r\n")
+
+	client.send("GET", "from")
+	client.expect("$5\r\nalice\r\n")
+}
+
+func TestMultiExecPipelined(t *testing.T) {
+	h := NewClientHandler(config.NewConfig())
+	client := connect(t, h)
+
+	// Client libraries send a whole transaction in one write. A PFADD of
+	// many elements also takes more than one read.
+	elements := []string{"PFADD", "hll"}
+	for i := range 1000 {
+		elements = append(elements, "element-"+strconv.Itoa(i))
+	}
+	client.sendRaw(encodeCommand("MULTI") + encodeCommand("SET", "k", "v") + encodeCommand(elements...) +
+		encodeCommand("GET", "k") + encodeCommand("EXEC"))
+	client.expect("+OK\r\n+QUEUED\r\n+QUEUED\r\n+QUEUED\r\n*3\r\n+OK\r\n:1\r\n$1\r\nv\r\n")
+
+	// The connection is out of MULTI.
+	client.send("GET", "k")
+	client.expect("$1\r\nv\r\n")
+}
+
+func TestMultiDiscard(t *testing.T) {
+	h := NewClientHandler(config.NewConfig())
+	client := connect(t, h)
+
+	client.send("MULTI")
+	client.expect("+OK\r\n")
+	client.send("SET", "k", "v")
+	client.expect("+QUEUED\r\n")
+	client.send("DISCARD")
+	client.expect("+OK\r\n")
+	client.send("GET", "k")
+	client.expect("$-1\r\n")
+
+	// RESET also leaves the transaction.
+	client.send("MULTI")
+	client.expect("+OK\r\n")
+	client.send("RESET")
+	client.expect("+RESET\r\n")
+	client.send("EXEC")
+	client.expect("-ERR EXEC without MULTI\r\n")
+}
+
+func TestMultiExecAbort(t *testing.T) {
+	h := NewClientHandler(config.NewConfig())
+	client := connect(t, h)
+
+	client.send("MULTI")
+	client.expect("+OK\r\n")
+	client.send("SET", "k", "v")
+	client.expect("+QUEUED\r\n")
+	client.send("NOSUCHCOMMAND")
+	client.expect("-ERR unknown command\r\n")
+	client.send("GET")
+	client.expect("-ERR wrong number of arguments for GET\r\n")
+	client.send("EXEC")
+	client.expect("-EXECABORT Transaction discarded because of previous errors.\r\n")
+
+	client.send("GET", "k")
+	client.expect("$-1\r\n")
+}
+
+func TestMultiExecBlockingCommand(t *testing.T) {
+	h := NewClientHandler(config.NewConfig())
+	client := connect(t, h)
+
+	client.send("MULTI")
+	client.expect("+OK\r\n")
+	client.send("BZPOPMIN", "z", "0")
+	client.expect("+QUEUED\r\n")
+	client.send("ZADD", "z", "1", "a")
+	client.expect("+QUEUED\r\n")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		client.send("EXEC")
+		client.expect("*2\r\n*-1\r\n:1\r\n")
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("EXEC blocked on BZPOPMIN")
+	}
+}
+
+func TestMultiExecIsAtomic(t *testing.T) {
+	h := NewClientHandler(config.NewConfig())
+	writer := connect(t, h)
+	reader := connect(t, h)
+
+	writer.send("MULTI")
+	writer.expect("+OK\r\n")
+	for i := 0; i < 50; i++ {
+		writer.send("SADD", "s", "a")
+		writer.expect("+QUEUED\r\n")
+		writer.send("SREM", "s", "a")
+		writer.expect("+QUEUED\r\n")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			reader.send("SCARD", "s")
+			reader.expect(":0\r\n")
+		}
+	}()
+
+	writer.send("EXEC")
+	writer.expect("*100\r\n")
+	for i := 0; i < 50; i++ {
+		writer.expect(":1\r\n:1\r\n")
+	}
+	<-done
+}
+
+func TestWatch(t *testing.T) {
+	h := NewClientHandler(config.NewConfig())
+	client := connect(t, h)
+	other := connect(t, h)
+
+	// An untouched watched key lets the transaction run.
+	client.send("WATCH", "balance")
+	client.expect("+OK\r\n")
+	client.send("MULTI")
+	client.expect("+OK\r\n")
+	client.send("WATCH", "balance")
+	client.expect("-ERR WATCH inside MULTI is not allowed\r\n")
+	client.send("SET", "balance", "low")
+	client.expect("+QUEUED\r\n")
+	client.send("EXEC")
+	client.expect("*1\r\n+OK\r\n")
+
+	// A modification by another client aborts it with a null reply.
+	client.send("WATCH", "balance", "other")
+	client.expect("+OK\r\n")
+	other.send("SET", "balance", "high")
+	other.expect("+OK\r\n")
+	client.send("MULTI")
+	client.expect("+OK\r\n")
+	client.send("SET", "balance", "low")
+	client.expect("+QUEUED\r\n")
+	client.send("EXEC")
+	client.expect("*-1\r\n")
+	client.send("GET", "balance")
+	client.expect("$4\r\nhigh\r\n")
+
+	// EXEC forgets the watched keys.
+	other.send("SET", "balance", "zero")
+	other.expect("+OK\r\n")
+	client.send("MULTI")
+	client.expect("+OK\r\n")
+	client.send("EXEC")
+	client.expect("*0\r\n")
+
+	// So does UNWATCH.
+	client.send("WATCH", "balance")
+	client.expect("+OK\r\n")
+	client.send("UNWATCH")
+	client.expect("+OK\r\n")
+	other.send("DEL", "balance")
+	other.expect(":1\r\n")
+	client.send("MULTI")
+	client.expect("+OK\r\n")
+	client.send("EXEC")
+	client.expect("*0\r\n")
+
+	// Flushing touches every existing key.
+	other.send("SADD", "s", "a")
+	other.expect(":1\r\n")
+	client.send("WATCH", "s")
+	client.expect("+OK\r\n")
+	other.send("FLUSHALL")
+	other.expect("+OK\r\n")
+	client.send("MULTI")
+	client.expect("+OK\r\n")
+	client.send("EXEC")
+	client.expect("*-1\r\n")
+}
+
+func TestWatchExpiredKey(t *testing.T) {
+	cfg := config.NewConfig()
+	expiryDate := time.Now().Unix() + 1
+	cfg.DB["lock"] = model.StoredData{Value: "owner", ExpiryDate: expiryDate}
+	h := NewClientHandler(cfg)
+	client := connect(t, h)
+
+	client.send("WATCH", "lock")
+	client.expect("+OK\r\n")
+	client.send("MULTI")
+	client.expect("+OK\r\n")
+	client.send("DEL", "lock")
+	client.expect("+QUEUED\r\n")
+
+	// No expiry manager runs here, so the key is still in the store.
+	time.Sleep(time.Until(time.Unix(expiryDate, 0)))
+	client.send("EXEC")
+	client.expect("*-1\r\n")
+}
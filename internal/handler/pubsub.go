@@ -0,0 +1,298 @@
+package handler
+
+import (
+	"redis-go-clone/internal/pubsub"
+	"redis-go-clone/pkg/keyslot"
+	"redis-go-clone/pkg/resp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// subscriberCommands are the only commands allowed once a client has
+// subscribed to a channel, pattern or shard channel.
+var subscriberCommands = map[string]bool{
+	"SUBSCRIBE":    true,
+	"UNSUBSCRIBE":  true,
+	"PSUBSCRIBE":   true,
+	"PUNSUBSCRIBE": true,
+	"SSUBSCRIBE":   true,
+	"SUNSUBSCRIBE": true,
+	"PING":         true,
+	"QUIT":         true,
+	"RESET":        true,
+}
+
+func (h *ClientHandler) subscribe(c *client, cmdArray []any) string {
+	if len(cmdArray) < 2 {
+		return "-ERR wrong number of arguments for SUBSCRIBE\r\n"
+	}
+
+	var reply strings.Builder
+	for _, arg := range cmdArray[1:] {
+		channel := argString(arg)
+		if _, found := c.channels[channel]; !found {
+			c.channels[channel] = struct{}{}
+			h.pubsub.Subscribe(c, channel)
+		}
+		reply.WriteString(pubsub.Reply("subscribe", channel, c.subscriptions()))
+	}
+	return reply.String()
+}
+
+func (h *ClientHandler) psubscribe(c *client, cmdArray []any) string {
+	if len(cmdArray) < 2 {
+		return "-ERR wrong number of arguments for PSUBSCRIBE\r\n"
+	}
+
+	var reply strings.Builder
+	for _, arg := range cmdArray[1:] {
+		pattern := argString(arg)
+		if _, found := c.patterns[pattern]; !found {
+			c.patterns[pattern] = struct{}{}
+			h.pubsub.PSubscribe(c, pattern)
+		}
+		reply.WriteString(pubsub.Reply("psubscribe", pattern, c.subscriptions()))
+	}
+	return reply.String()
+}
+
+// unsubscribe leaves the given channels, or every channel when none is
+// given.
+func (h *ClientHandler) unsubscribe(c *client, cmdArray []any) string {
+	channels := argStrings(cmdArray[1:])
+	if len(channels) == 0 {
+		channels = sortedKeys(c.channels)
+		if len(channels) == 0 {
+			return pubsub.Reply("unsubscribe", nil, c.subscriptions())
+		}
+	}
+
+	var reply strings.Builder
+	for _, channel := range channels {
+		if _, found := c.channels[channel]; found {
+			delete(c.channels, channel)
+			h.pubsub.Unsubscribe(c, channel)
+		}
+		reply.WriteString(pubsub.Reply("unsubscribe", channel, c.subscriptions()))
+	}
+	return reply.String()
+}
+
+func (h *ClientHandler) punsubscribe(c *client, cmdArray []any) string {
+	patterns := argStrings(cmdArray[1:])
+	if len(patterns) == 0 {
+		patterns = sortedKeys(c.patterns)
+		if len(patterns) == 0 {
+			return pubsub.Reply("punsubscribe", nil, c.subscriptions())
+		}
+	}
+
+	var reply strings.Builder
+	for _, pattern := range patterns {
+		if _, found := c.patterns[pattern]; found {
+			delete(c.patterns, pattern)
+			h.pubsub.PUnsubscribe(c, pattern)
+		}
+		reply.WriteString(pubsub.Reply("punsubscribe", pattern, c.subscriptions()))
+	}
+	return reply.String()
+}
+
+// ssubscribe subscribes to shard channels, which must all hash to the same
+// slot.
+func (h *ClientHandler) ssubscribe(c *client, cmdArray []any) string {
+	if len(cmdArray) < 2 {
+		return "-ERR wrong number of arguments for SSUBSCRIBE\r\n"
+	}
+	channels := argStrings(cmdArray[1:])
+	if !sameSlot(channels) {
+		return crossSlotReply
+	}
+
+	var reply strings.Builder
+	for _, channel := range channels {
+		if _, found := c.shardChannels[channel]; !found {
+			c.shardChannels[channel] = struct{}{}
+			h.pubsub.SSubscribe(c, channel)
+		}
+		reply.WriteString(pubsub.Reply("ssubscribe", channel, len(c.shardChannels)))
+	}
+	return reply.String()
+}
+
+func (h *ClientHandler) sunsubscribe(c *client, cmdArray []any) string {
+	channels := argStrings(cmdArray[1:])
+	if len(channels) == 0 {
+		channels = sortedKeys(c.shardChannels)
+		if len(channels) == 0 {
+			return pubsub.Reply("sunsubscribe", nil, 0)
+		}
+	} else if !sameSlot(channels) {
+		return crossSlotReply
+	}
+
+	var reply strings.Builder
+	for _, channel := range channels {
+		if _, found := c.shardChannels[channel]; found {
+			delete(c.shardChannels, channel)
+			h.pubsub.SUnsubscribe(c, channel)
+		}
+		reply.WriteString(pubsub.Reply("sunsubscribe", channel, len(c.shardChannels)))
+	}
+	return reply.String()
+}
+
+const crossSlotReply = "-CROSSSLOT Keys in request don't hash to the same slot\r\n"
+
+// sameSlot reports whether every channel maps to the same hash slot, which
+// is what lets a cluster serve them from a single shard.
+func sameSlot(channels []string) bool {
+	for _, channel := range channels[1:] {
+		if keyslot.Slot(channel) != keyslot.Slot(channels[0]) {
+			return false
+		}
+	}
+	return true
+}
+
+// unsubscribeAll drops every subscription of c, e.g. when it disconnects.
+func (h *ClientHandler) unsubscribeAll(c *client) {
+	for channel := range c.channels {
+		h.pubsub.Unsubscribe(c, channel)
+	}
+	for pattern := range c.patterns {
+		h.pubsub.PUnsubscribe(c, pattern)
+	}
+	for channel := range c.shardChannels {
+		h.pubsub.SUnsubscribe(c, channel)
+	}
+	clear(c.channels)
+	clear(c.patterns)
+	clear(c.shardChannels)
+}
+
+func (h *ClientHandler) publish(_ *client, cmdArray []any) string {
+	if len(cmdArray) != 3 {
+		return "-ERR wrong number of arguments for PUBLISH\r\n"
+	}
+	receivers := h.pubsub.Publish(argString(cmdArray[1]), argString(cmdArray[2]))
+	return resp.SerializeRESP(receivers, false)
+}
+
+func (h *ClientHandler) spublish(_ *client, cmdArray []any) string {
+	if len(cmdArray) != 3 {
+		return "-ERR wrong number of arguments for SPUBLISH\r\n"
+	}
+	receivers := h.pubsub.SPublish(argString(cmdArray[1]), argString(cmdArray[2]))
+	return resp.SerializeRESP(receivers, false)
+}
+
+// pubsubIntrospection implements PUBSUB CHANNELS [pattern], PUBSUB NUMSUB
+// [channel ...], PUBSUB NUMPAT and their SHARDCHANNELS and SHARDNUMSUB
+// counterparts for shard channels.
+func (h *ClientHandler) pubsubIntrospection(_ *client, cmdArray []any) string {
+	if len(cmdArray) < 2 {
+		return "-ERR wrong number of arguments for PUBSUB\r\n"
+	}
+
+	subcommand := strings.ToUpper(argString(cmdArray[1]))
+	switch {
+	case (subcommand == "CHANNELS" || subcommand == "SHARDCHANNELS") && len(cmdArray) <= 3:
+		pattern := ""
+		if len(cmdArray) == 3 {
+			pattern = argString(cmdArray[2])
+		}
+		active := h.pubsub.Channels
+		if subcommand == "SHARDCHANNELS" {
+			active = h.pubsub.ShardChannels
+		}
+		channels := []any{}
+		for _, channel := range active(pattern) {
+			channels = append(channels, channel)
+		}
+		return resp.SerializeBulk(channels)
+	case subcommand == "NUMSUB" || subcommand == "SHARDNUMSUB":
+		numSub := h.pubsub.NumSub
+		if subcommand == "SHARDNUMSUB" {
+			numSub = h.pubsub.ShardNumSub
+		}
+		result := []any{}
+		for _, channel := range argStrings(cmdArray[2:]) {
+			result = append(result, channel, numSub(channel))
+		}
+		return resp.SerializeBulk(result)
+	case subcommand == "NUMPAT" && len(cmdArray) == 2:
+		return resp.SerializeRESP(h.pubsub.NumPat(), false)
+	case subcommand == "CHANNELS" || subcommand == "SHARDCHANNELS" || subcommand == "NUMPAT":
+		return "-ERR wrong number of arguments for PUBSUB\r\n"
+	default:
+		return "-ERR unknown subcommand '" + argString(cmdArray[1]) + "'. Try PUBSUB HELP.\r\n"
+	}
+}
+
+// ping replies PONG, or in subscriber mode with a "pong" push-style array.
+func (h *ClientHandler) ping(c *client, cmdArray []any) string {
+	if len(cmdArray) > 2 {
+		return "-ERR wrong number of arguments for PING\r\n"
+	}
+
+	message := ""
+	if len(cmdArray) == 2 {
+		message = argString(cmdArray[1])
+	}
+	switch {
+	case c.subscribed():
+		return pubsub.Reply("pong", message)
+	case len(cmdArray) == 2:
+		return resp.SerializeRESP(message, true)
+	default:
+		return "+PONG\r\n"
+	}
+}
+
+func (h *ClientHandler) quit(c *client, _ []any) string {
+	c.quit = true
+	return "+OK\r\n"
+}
+
+// reset returns the connection to its initial state.
+func (h *ClientHandler) reset(c *client, _ []any) string {
+	h.monitors.remove(c)
+	h.unsubscribeAll(c)
+	c.resetTransaction()
+	c.infoMu.Lock()
+	c.noEvict = false
+	c.infoMu.Unlock()
+	return "+RESET\r\n"
+}
+
+// argString converts a parsed RESP argument, which may have been turned
+// into an int, back into its string form.
+func argString(arg any) string {
+	switch v := arg.(type) {
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	default:
+		return ""
+	}
+}
+
+func argStrings(args []any) []string {
+	result := make([]string, 0, len(args))
+	for _, arg := range args {
+		result = append(result, argString(arg))
+	}
+	return result
+}
+
+func sortedKeys(set map[string]struct{}) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
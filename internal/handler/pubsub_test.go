@@ -0,0 +1,167 @@
+package handler
+
+import (
+	"io"
+	"net"
+	"redis-go-clone/cmd/config"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testConn is one end of an in-memory connection served by a ClientHandler.
+type testConn struct {
+	t    *testing.T
+	conn net.Conn
+}
+
+func connect(t *testing.T, h *ClientHandler) *testConn {
+	t.Helper()
+	server, conn := net.Pipe()
+	go h.HandleClient(server)
+	t.Cleanup(func() { conn.Close() })
+	return &testConn{t: t, conn: conn}
+}
+
+func (c *testConn) send(args ...string) {
+	c.t.Helper()
+	c.sendRaw(encodeCommand(args...))
+}
+
+// sendRaw writes data as it is, e.g. several commands at once.
+func (c *testConn) sendRaw(data string) {
+	c.t.Helper()
+	if _, err := c.conn.Write([]byte(data)); err != nil {
+		c.t.Fatalf("write failed: %v", err)
+	}
+}
+
+// encodeCommand encodes a command the way clients send it.
+func encodeCommand(args ...string) string {
+	var b strings.Builder
+	b.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		b.WriteString("$" + strconv.Itoa(len(arg)) + "\r\n" + arg + "\r\n")
+	}
+	return b.String()
+}
+
+// expect reads exactly len(want) bytes and compares them with want.
+func (c *testConn) expect(want string) {
+	c.t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(time.Second))
+	got := make([]byte, len(want))
+	if _, err := io.ReadFull(c.conn, got); err != nil {
+		c.t.Fatalf("expected %q, read failed: %v (got %q)", want, err, got)
+	}
+	if string(got) != want {
+		c.t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+func TestPubSub(t *testing.T) {
+	h := NewClientHandler(config.NewConfig())
+	subscriber := connect(t, h)
+	publisher := connect(t, h)
+
+	subscriber.send("SUBSCRIBE", "news", "sport")
+	subscriber.expect("*3\r\n$9\r\nsubscribe\r\n$4\r\nnews\r\n:1\r\n*3\r\n$9\r\nsubscribe\r\n$5\r\nsport\r\n:2\r\n")
+	subscriber.send("PSUBSCRIBE", "n*")
+	subscriber.expect("*3\r\n$10\r\npsubscribe\r\n$2\r\nn*\r\n:3\r\n")
+
+	subscriber.send("GET", "x")
+	subscriber.expect("-ERR Can't execute 'get': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context\r\n")
+	subscriber.send("PING")
+	subscriber.expect("*2\r\n$4\r\npong\r\n$0\r\n\r\n")
+
+	publisher.send("PUBLISH", "news", "hello world")
+	publisher.expect(":2\r\n")
+	subscriber.expect("*3\r\n$7\r\nmessage\r\n$4\r\nnews\r\n$11\r\nhello world\r\n")
+	subscriber.expect("*4\r\n$8\r\npmessage\r\n$2\r\nn*\r\n$4\r\nnews\r\n$11\r\nhello world\r\n")
+
+	publisher.send("PUBSUB", "CHANNELS")
+	publisher.expect("*2\r\n$4\r\nnews\r\n$5\r\nsport\r\n")
+	publisher.send("PUBSUB", "NUMSUB", "news", "none")
+	publisher.expect("*4\r\n$4\r\nnews\r\n:1\r\n$4\r\nnone\r\n:0\r\n")
+	publisher.send("PUBSUB", "NUMPAT")
+	publisher.expect(":1\r\n")
+
+	// Channel names are user data and may hold any byte.
+	other := connect(t, h)
+	other.send("SUBSCRIBE", "a\r\nb")
+	other.expect("*3\r\n$9\r\nsubscribe\r\n$4\r\na\r\nb\r\n:1\r\n")
+	publisher.send("PUBSUB", "CHANNELS", "a*")
+	publisher.expect("*1\r\n$4\r\na\r\nb\r\n")
+	publisher.send("PUBSUB", "NUMSUB", "a\r\nb")
+	publisher.expect("*2\r\n$4\r\na\r\nb\r\n:1\r\n")
+	other.send("UNSUBSCRIBE")
+	other.expect("*3\r\n$11\r\nunsubscribe\r\n$4\r\na\r\nb\r\n:0\r\n")
+
+	subscriber.send("UNSUBSCRIBE")
+	subscriber.expect("*3\r\n$11\r\nunsubscribe\r\n$4\r\nnews\r\n:2\r\n*3\r\n$11\r\nunsubscribe\r\n$5\r\nsport\r\n:1\r\n")
+	subscriber.send("PUNSUBSCRIBE", "n*")
+	subscriber.expect("*3\r\n$12\r\npunsubscribe\r\n$2\r\nn*\r\n:0\r\n")
+
+	// Leaving every subscription ends subscriber mode.
+	subscriber.send("PING")
+	subscriber.expect("+PONG\r\n")
+	publisher.send("PUBLISH", "news", "again")
+	publisher.expect(":0\r\n")
+}
+
+func TestPubSubDisconnectUnsubscribes(t *testing.T) {
+	h := NewClientHandler(config.NewConfig())
+	subscriber := connect(t, h)
+	publisher := connect(t, h)
+
+	subscriber.send("SUBSCRIBE", "news")
+	subscriber.expect("*3\r\n$9\r\nsubscribe\r\n$4\r\nnews\r\n:1\r\n")
+	subscriber.send("QUIT")
+	subscriber.expect("+OK\r\n")
+
+	deadline := time.Now().Add(time.Second)
+	for h.pubsub.NumSub("news") != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected QUIT to drop the subscription")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	publisher.send("PUBLISH", "news", "hello")
+	publisher.expect(":0\r\n")
+}
+
+func TestShardedPubSub(t *testing.T) {
+	h := NewClientHandler(config.NewConfig())
+	subscriber := connect(t, h)
+	publisher := connect(t, h)
+
+	subscriber.send("SSUBSCRIBE", "{user1}.a", "{user1}.b")
+	subscriber.expect("*3\r\n$10\r\nssubscribe\r\n$9\r\n{user1}.a\r\n:1\r\n*3\r\n$10\r\nssubscribe\r\n$9\r\n{user1}.b\r\n:2\r\n")
+	subscriber.send("SSUBSCRIBE", "foo", "bar")
+	subscriber.expect("-CROSSSLOT Keys in request don't hash to the same slot\r\n")
+	subscriber.send("SUBSCRIBE", "news")
+	subscriber.expect("*3\r\n$9\r\nsubscribe\r\n$4\r\nnews\r\n:1\r\n")
+	subscriber.send("GET", "x")
+	subscriber.expect("-ERR Can't execute 'get': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context\r\n")
+
+	publisher.send("SPUBLISH", "{user1}.a", "hi")
+	publisher.expect(":1\r\n")
+	subscriber.expect("*3\r\n$8\r\nsmessage\r\n$9\r\n{user1}.a\r\n$2\r\nhi\r\n")
+	publisher.send("PUBLISH", "{user1}.a", "hi")
+	publisher.expect(":0\r\n")
+
+	publisher.send("PUBSUB", "SHARDCHANNELS")
+	publisher.expect("*2\r\n$9\r\n{user1}.a\r\n$9\r\n{user1}.b\r\n")
+	publisher.send("PUBSUB", "SHARDNUMSUB", "{user1}.a", "news")
+	publisher.expect("*4\r\n$9\r\n{user1}.a\r\n:1\r\n$4\r\nnews\r\n:0\r\n")
+	publisher.send("PUBSUB", "CHANNELS")
+	publisher.expect("*1\r\n$4\r\nnews\r\n")
+
+	subscriber.send("SUNSUBSCRIBE")
+	subscriber.expect("*3\r\n$12\r\nsunsubscribe\r\n$9\r\n{user1}.a\r\n:1\r\n*3\r\n$12\r\nsunsubscribe\r\n$9\r\n{user1}.b\r\n:0\r\n")
+	subscriber.send("RESET")
+	subscriber.expect("+RESET\r\n")
+	subscriber.send("PING")
+	subscriber.expect("+PONG\r\n")
+}
@@ -0,0 +1,216 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"redis-go-clone/internal/logging"
+	"redis-go-clone/internal/memory"
+	"redis-go-clone/pkg/lua"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// redisLib returns the redis table scripts use to call commands.
+func (h *ClientHandler) redisLib() *lua.Table {
+	lib := lua.NewLib("redis", map[string]func(*lua.State, []lua.Value) []lua.Value{
+		"call": func(l *lua.State, args []lua.Value) []lua.Value {
+			reply := h.scriptCallCommand(args)
+			if isErrorTable(reply) {
+				l.Raise(reply)
+			}
+			return []lua.Value{reply}
+		},
+		"pcall": func(l *lua.State, args []lua.Value) []lua.Value {
+			return []lua.Value{h.scriptCallCommand(args)}
+		},
+		"error_reply": func(l *lua.State, args []lua.Value) []lua.Value {
+			return []lua.Value{replyTable("err", lua.CheckString(l, args, 1, "error_reply"))}
+		},
+		"status_reply": func(l *lua.State, args []lua.Value) []lua.Value {
+			return []lua.Value{replyTable("ok", lua.CheckString(l, args, 1, "status_reply"))}
+		},
+		"sha1hex": func(l *lua.State, args []lua.Value) []lua.Value {
+			return []lua.Value{sha1Hex(lua.CheckString(l, args, 1, "sha1hex"))}
+		},
+		"log": func(l *lua.State, args []lua.Value) []lua.Value {
+			level := lua.CheckInt(l, args, 1, "log")
+			if level < 0 || level >= len(logging.Levels) {
+				l.RaiseError("Invalid debug level.")
+			}
+			parts := make([]string, 0, len(args)-1)
+			for i := 2; i <= len(args); i++ {
+				parts = append(parts, lua.CheckString(l, args, i, "log"))
+			}
+			logger := slog.Default()
+			if running := h.scripting.running; running != nil && running.c != nil {
+				logger = running.c.log
+			}
+			logger.Log(context.Background(), logging.Levels[level], strings.Join(parts, " "), "source", "script")
+			return nil
+		},
+		"replicate_commands": func(*lua.State, []lua.Value) []lua.Value {
+			return []lua.Value{true}
+		},
+		"register_function": func(l *lua.State, args []lua.Value) []lua.Value {
+			h.scripting.registerFunction(l, args)
+			return nil
+		},
+	})
+	for i, level := range []string{"LOG_DEBUG", "LOG_VERBOSE", "LOG_NOTICE", "LOG_WARNING"} {
+		lib.Set(level, float64(i))
+	}
+	return lib
+}
+
+func replyTable(field, msg string) *lua.Table {
+	t := lua.NewTable()
+	t.Set(field, msg)
+	return t
+}
+
+func isErrorTable(v lua.Value) bool {
+	t, ok := v.(*lua.Table)
+	if !ok {
+		return false
+	}
+	_, ok = t.Get("err").(string)
+	return ok
+}
+
+// scriptCallCommand runs a command for redis.call and redis.pcall and
+// returns its reply as a Lua value; errors are error tables. The script
+// already holds the store lock.
+func (h *ClientHandler) scriptCallCommand(args []lua.Value) lua.Value {
+	if len(args) == 0 {
+		return replyTable("err", "ERR Please specify at least one argument for this redis lib call")
+	}
+	cmdArray := make([]any, len(args))
+	for i, arg := range args {
+		var s string
+		switch arg := arg.(type) {
+		case string:
+			s = arg
+		case float64:
+			s = lua.FormatNumber(arg)
+		default:
+			return replyTable("err", "ERR Lua redis lib command arguments must be strings or integers")
+		}
+		// Store commands expect what the RESP parser produces, which turns
+		// canonical integers into ints.
+		if n, err := strconv.Atoi(s); err == nil && strconv.Itoa(n) == s {
+			cmdArray[i] = n
+		} else {
+			cmdArray[i] = s
+		}
+	}
+
+	cmdName := strings.ToUpper(argString(cmdArray[0]))
+	arity, found := commandArity(cmdName)
+	switch {
+	case !found:
+		return replyTable("err", "ERR Unknown Redis command called from script")
+	case noScriptCommands[cmdName]:
+		return replyTable("err", "ERR This Redis command is not allowed from script")
+	case (arity > 0 && len(cmdArray) != arity) || len(cmdArray) < -arity:
+		return replyTable("err", "ERR Wrong number of args calling Redis command from script")
+	}
+
+	run := h.scripting.running
+	if run == nil {
+		// A library is being loaded.
+		return replyTable("err", "ERR redis.call can not be called while loading a library")
+	}
+	if writeCommands[cmdName] {
+		if run.readOnly {
+			return replyTable("err", "ERR Write commands are not allowed from read-only scripts.")
+		}
+		if !run.allowOOM && memory.OverLimit(h.config.DB) {
+			return replyTable("err", oomError)
+		}
+		h.scripting.wrote()
+	}
+	return replyToLua(h.runCommand(run.c, cmdName, cmdArray, &sync.RWMutex{}, callScript))
+}
+
+// replyToLua converts a command reply the way Redis does: integers become
+// numbers, bulk strings strings, arrays tables and nulls false, while status
+// and error replies become tables with an ok or err field.
+func replyToLua(reply string) lua.Value {
+	v, _ := parseScriptReply(reply, true)
+	return v
+}
+
+func parseScriptReply(reply string, top bool) (lua.Value, string) {
+	end := strings.Index(reply, "\r\n")
+	if end < 1 {
+		return false, ""
+	}
+	line, rest := reply[1:end], reply[end+2:]
+	switch reply[0] {
+	case '+':
+		// Array members come back as simple strings, so only a top-level
+		// one is a status reply.
+		if top {
+			return replyTable("ok", line), rest
+		}
+		return line, rest
+	case '-':
+		return replyTable("err", line), rest
+	case ':':
+		n, _ := strconv.ParseInt(line, 10, 64)
+		return float64(n), rest
+	case '$':
+		n, _ := strconv.Atoi(line)
+		if n < 0 || len(rest) < n+2 {
+			return false, rest
+		}
+		return rest[:n], rest[n+2:]
+	case '*':
+		n, _ := strconv.Atoi(line)
+		if n < 0 {
+			return false, rest
+		}
+		t := lua.NewTable()
+		for i := 0; i < n; i++ {
+			var v lua.Value
+			v, rest = parseScriptReply(rest, false)
+			t.Append(v)
+		}
+		return t, rest
+	default:
+		return false, rest
+	}
+}
+
+// luaToReply converts the value a script returns to a reply: strings become
+// bulk strings, numbers integers, true 1, false and nil null, and tables
+// arrays up to their first nil, unless they have an ok or err field.
+func luaToReply(v lua.Value) string {
+	switch v := v.(type) {
+	case string:
+		return "$" + strconv.Itoa(len(v)) + "\r\n" + v + "\r\n"
+	case float64:
+		return ":" + strconv.FormatInt(int64(v), 10) + "\r\n"
+	case bool:
+		if v {
+			return ":1\r\n"
+		}
+		return "$-1\r\n"
+	case *lua.Table:
+		if msg, ok := v.Get("err").(string); ok {
+			return "-" + oneLine(msg) + "\r\n"
+		}
+		if msg, ok := v.Get("ok").(string); ok {
+			return "+" + oneLine(msg) + "\r\n"
+		}
+		var elements strings.Builder
+		n := 0
+		for ; v.Get(float64(n+1)) != nil; n++ {
+			elements.WriteString(luaToReply(v.Get(float64(n + 1))))
+		}
+		return "*" + strconv.Itoa(n) + "\r\n" + elements.String()
+	default:
+		return "$-1\r\n"
+	}
+}
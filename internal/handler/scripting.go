@@ -0,0 +1,379 @@
+package handler
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"redis-go-clone/pkg/lua"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// scriptTimeLimit is how long, in milliseconds, a script runs before other
+// clients get BUSY replies and SCRIPT KILL may stop it.
+var scriptTimeLimit atomic.Int64
+
+func init() {
+	scriptTimeLimit.Store(5000)
+}
+
+func getScriptTimeLimit() string {
+	return strconv.FormatInt(scriptTimeLimit.Load(), 10)
+}
+
+func setScriptTimeLimit(value string) error {
+	ms, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || ms < 0 {
+		return errors.New("argument must be a non-negative integer")
+	}
+	scriptTimeLimit.Store(ms)
+	return nil
+}
+
+var errScriptKilled = errors.New("Script killed by user with SCRIPT KILL...")
+
+// scripting holds the script cache, the function libraries and the
+// interpreter. Scripts and functions run one at a time with the store
+// locked, so the interpreter is only used under the store lock; mu guards
+// the cache, the libraries and the state of the running script, which other
+// clients read to reply BUSY and to kill it.
+type scripting struct {
+	mu        sync.Mutex
+	scripts   map[string]*lua.Function
+	libraries map[string]*library
+	functions map[string]*libraryFunction
+	// scriptBytes is the size of the cached script bodies.
+	scriptBytes int
+	// running is only changed by the goroutine running the script, which
+	// may read it without mu.
+	running *runningScript
+
+	lua *lua.State
+	// loading is the library being loaded, which redis.register_function
+	// adds to, since loadStart.
+	loading   *library
+	loadStart time.Time
+}
+
+// scriptRun describes a script or function call.
+type scriptRun struct {
+	c *client
+	// name is the SHA1 of a script or the name of a function.
+	name     string
+	function bool
+	readOnly bool
+	allowOOM bool
+	command  []string
+}
+
+type runningScript struct {
+	scriptRun
+	start  time.Time
+	wrote  bool
+	killed bool
+}
+
+func newScripting(h *ClientHandler) *scripting {
+	s := &scripting{
+		scripts:   make(map[string]*lua.Function),
+		libraries: make(map[string]*library),
+		functions: make(map[string]*libraryFunction),
+	}
+	s.lua = lua.NewState()
+	s.lua.Globals.Set("redis", h.redisLib())
+	s.lua.StrictGlobals = true
+	s.lua.Interrupt = s.interrupt
+	return s
+}
+
+func sha1Hex(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// load compiles a script and caches it by the SHA1 of its body.
+func (s *scripting) load(body string) (string, *lua.Function, error) {
+	sha := sha1Hex(body)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if fn, found := s.scripts[sha]; found {
+		return sha, fn, nil
+	}
+	fn, err := s.lua.Load(body, "user_script")
+	if err != nil {
+		return "", nil, err
+	}
+	s.scripts[sha] = fn
+	s.scriptBytes += len(body)
+	return sha, fn, nil
+}
+
+func (s *scripting) lookup(sha string) (*lua.Function, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn, found := s.scripts[strings.ToLower(sha)]
+	return fn, found
+}
+
+// cacheSizes returns the bytes of code kept for scripts and for function
+// libraries.
+func (s *scripting) cacheSizes() (scripts, functions int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, lib := range s.libraries {
+		functions += len(lib.code)
+	}
+	return s.scriptBytes, functions
+}
+
+// cacheCounts returns how many scripts, functions and libraries are loaded.
+func (s *scripting) cacheCounts() (scripts, functions, libraries int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.scripts), len(s.functions), len(s.libraries)
+}
+
+// busyReply returns the BUSY error other commands get while a script has
+// been running for longer than the time limit, or "" if no script is.
+func (s *scripting) busyReply() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running == nil || time.Since(s.running.start) < time.Duration(scriptTimeLimit.Load())*time.Millisecond {
+		return ""
+	}
+	if s.running.function {
+		return "-BUSY Redis is busy running a function. You can only call FUNCTION KILL or SHUTDOWN NOSAVE.\r\n"
+	}
+	return "-BUSY Redis is busy running a script. You can only call SCRIPT KILL or SHUTDOWN NOSAVE.\r\n"
+}
+
+func (s *scripting) interrupt() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running != nil && s.running.killed {
+		return errScriptKilled
+	}
+	if s.loading != nil && time.Since(s.loadStart) > libraryLoadTimeout {
+		return errLoadTimeout
+	}
+	return nil
+}
+
+// run calls a script with the store already locked.
+func (s *scripting) run(run scriptRun, fn lua.Value, args ...lua.Value) ([]lua.Value, error) {
+	s.mu.Lock()
+	s.running = &runningScript{scriptRun: run, start: time.Now()}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.running = nil
+		s.mu.Unlock()
+	}()
+	return s.lua.Call(fn, args...)
+}
+
+// wrote records that the running script modified the store, which makes it
+// unkillable.
+func (s *scripting) wrote() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.running.wrote = true
+}
+
+// kill asks the running script, or function, to stop. A script that
+// already wrote cannot be killed without breaking atomicity.
+func (s *scripting) kill(function bool) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case s.running == nil:
+		return "-NOTBUSY No scripts in execution right now.\r\n"
+	case s.running.function && !function:
+		return "-BUSY Redis is busy running a function. You can only call FUNCTION KILL or SHUTDOWN NOSAVE.\r\n"
+	case !s.running.function && function:
+		return "-BUSY Redis is busy running a script. You can only call SCRIPT KILL or SHUTDOWN NOSAVE.\r\n"
+	case s.running.wrote:
+		return "-UNKILLABLE Sorry the script already executed write commands against the dataset. You can either wait the script termination or kill the server in a hard way using the SHUTDOWN NOSAVE command.\r\n"
+	default:
+		s.running.killed = true
+		return "+OK\r\n"
+	}
+}
+
+// allowedWhileBusy reports whether a command runs even though a script is
+// busy: the ones that stop it, and FUNCTION STATS.
+func allowedWhileBusy(cmdName string, cmdArray []any) bool {
+	if len(cmdArray) != 2 {
+		return false
+	}
+	subcommand := strings.ToUpper(argString(cmdArray[1]))
+	return (cmdName == "SCRIPT" && subcommand == "KILL") ||
+		(cmdName == "FUNCTION" && (subcommand == "KILL" || subcommand == "STATS")) ||
+		(cmdName == "SHUTDOWN" && subcommand == "NOSAVE")
+}
+
+// eval implements EVAL script numkeys [key ...] [arg ...].
+func (h *ClientHandler) eval(c *client, cmdArray []any, mu *sync.RWMutex) string {
+	return h.evalScript(c, cmdArray, mu, false)
+}
+
+// evalRO is EVAL for scripts that only read.
+func (h *ClientHandler) evalRO(c *client, cmdArray []any, mu *sync.RWMutex) string {
+	return h.evalScript(c, cmdArray, mu, true)
+}
+
+func (h *ClientHandler) evalScript(c *client, cmdArray []any, mu *sync.RWMutex, readOnly bool) string {
+	if len(cmdArray) < 3 {
+		return "-ERR wrong number of arguments for " + strings.ToUpper(argString(cmdArray[0])) + "\r\n"
+	}
+	sha, fn, err := h.scripting.load(argString(cmdArray[1]))
+	if err != nil {
+		return "-ERR Error compiling script (new function): " + oneLine(err.Error()) + "\r\n"
+	}
+	return h.runScript(c, scriptRun{c: c, name: sha, readOnly: readOnly, command: argStrings(cmdArray)}, fn, cmdArray[2:], mu)
+}
+
+// evalSha implements EVALSHA sha1 numkeys [key ...] [arg ...].
+func (h *ClientHandler) evalSha(c *client, cmdArray []any, mu *sync.RWMutex) string {
+	return h.evalShaScript(c, cmdArray, mu, false)
+}
+
+func (h *ClientHandler) evalShaRO(c *client, cmdArray []any, mu *sync.RWMutex) string {
+	return h.evalShaScript(c, cmdArray, mu, true)
+}
+
+func (h *ClientHandler) evalShaScript(c *client, cmdArray []any, mu *sync.RWMutex, readOnly bool) string {
+	if len(cmdArray) < 3 {
+		return "-ERR wrong number of arguments for " + strings.ToUpper(argString(cmdArray[0])) + "\r\n"
+	}
+	sha := strings.ToLower(argString(cmdArray[1]))
+	fn, found := h.scripting.lookup(sha)
+	if !found {
+		return "-NOSCRIPT No matching script. Please use EVAL.\r\n"
+	}
+	return h.runScript(c, scriptRun{c: c, name: sha, readOnly: readOnly, command: argStrings(cmdArray)}, fn, cmdArray[2:], mu)
+}
+
+// runScript runs a script or function with the keys and arguments taken
+// from "numkeys key ... arg ...": a script finds them in KEYS and ARGV, a
+// function gets them as its two parameters. The store stays locked for the
+// whole call, which makes it atomic.
+func (h *ClientHandler) runScript(c *client, run scriptRun, fn lua.Value, args []any, mu *sync.RWMutex) string {
+	keys, argv, reply := scriptKeysAndArgs(args)
+	if reply != "" {
+		return reply
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	var results []lua.Value
+	var err error
+	if run.function {
+		results, err = h.scripting.run(run, fn, stringTable(keys), stringTable(argv))
+	} else {
+		l := h.scripting.lua
+		l.Globals.Set("KEYS", stringTable(keys))
+		l.Globals.Set("ARGV", stringTable(argv))
+		results, err = h.scripting.run(run, fn)
+	}
+	if err != nil {
+		return scriptErrorReply(err, run)
+	}
+	var result lua.Value
+	if len(results) > 0 {
+		result = results[0]
+	}
+	return luaToReply(result)
+}
+
+// scriptKeysAndArgs splits "numkeys key ... arg ..." or returns an error
+// reply.
+func scriptKeysAndArgs(args []any) ([]string, []string, string) {
+	numKeys, err := strconv.Atoi(argString(args[0]))
+	switch {
+	case err != nil:
+		return nil, nil, "-ERR value is not an integer or out of range\r\n"
+	case numKeys < 0:
+		return nil, nil, "-ERR Number of keys can't be negative\r\n"
+	case numKeys > len(args)-1:
+		return nil, nil, "-ERR Number of keys can't be greater than number of args\r\n"
+	}
+	rest := argStrings(args[1:])
+	return rest[:numKeys], rest[numKeys:], ""
+}
+
+func stringTable(values []string) *lua.Table {
+	t := lua.NewTable()
+	for _, v := range values {
+		t.Append(v)
+	}
+	return t
+}
+
+// scriptErrorReply turns the error a script failed with into an error
+// reply. Errors raised as error tables, such as the ones redis.call raises,
+// are replied as they are.
+func scriptErrorReply(err error, run scriptRun) string {
+	var luaErr *lua.Error
+	if !errors.As(err, &luaErr) {
+		return "-ERR " + err.Error() + "\r\n"
+	}
+	if t, ok := luaErr.Value.(*lua.Table); ok {
+		if msg, ok := t.Get("err").(string); ok {
+			return "-" + oneLine(msg) + "\r\n"
+		}
+	}
+	return "-ERR " + oneLine(luaErr.Error()) + " script: " + run.name + "\r\n"
+}
+
+// oneLine makes a message safe to send as an error or status reply.
+func oneLine(s string) string {
+	return strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ").Replace(s)
+}
+
+// scriptCommand implements SCRIPT LOAD, EXISTS, FLUSH and KILL.
+func (h *ClientHandler) scriptCommand(c *client, cmdArray []any, _ *sync.RWMutex) string {
+	if len(cmdArray) < 2 {
+		return "-ERR wrong number of arguments for SCRIPT\r\n"
+	}
+	subcommand := strings.ToUpper(argString(cmdArray[1]))
+	switch {
+	case subcommand == "LOAD" && len(cmdArray) == 3:
+		sha, _, err := h.scripting.load(argString(cmdArray[2]))
+		if err != nil {
+			return "-ERR Error compiling script (new function): " + oneLine(err.Error()) + "\r\n"
+		}
+		return "$40\r\n" + sha + "\r\n"
+	case subcommand == "EXISTS" && len(cmdArray) >= 3:
+		reply := "*" + strconv.Itoa(len(cmdArray)-2) + "\r\n"
+		for _, sha := range argStrings(cmdArray[2:]) {
+			if _, found := h.scripting.lookup(sha); found {
+				reply += ":1\r\n"
+			} else {
+				reply += ":0\r\n"
+			}
+		}
+		return reply
+	case subcommand == "FLUSH" && len(cmdArray) <= 3:
+		if len(cmdArray) == 3 {
+			if mode := strings.ToUpper(argString(cmdArray[2])); mode != "ASYNC" && mode != "SYNC" {
+				return "-ERR syntax error\r\n"
+			}
+		}
+		h.scripting.mu.Lock()
+		clear(h.scripting.scripts)
+		h.scripting.scriptBytes = 0
+		h.scripting.mu.Unlock()
+		return "+OK\r\n"
+	case subcommand == "KILL" && len(cmdArray) == 2:
+		return h.scripting.kill(false)
+	case subcommand == "LOAD" || subcommand == "EXISTS" || subcommand == "FLUSH" || subcommand == "KILL":
+		return "-ERR wrong number of arguments for SCRIPT " + subcommand + "\r\n"
+	default:
+		return "-ERR unknown subcommand '" + argString(cmdArray[1]) + "'. Try SCRIPT HELP.\r\n"
+	}
+}
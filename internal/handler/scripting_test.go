@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"redis-go-clone/cmd/config"
+	"testing"
+	"time"
+)
+
+func TestEval(t *testing.T) {
+	h := NewClientHandler(config.NewConfig())
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"Bulk string", []string{"return 'hello'", "0"}, "$5\r\nhello\r\n"},
+		{"Integer", []string{"return 3.99", "0"}, ":3\r\n"},
+		{"Booleans", []string{"return {true, false, 'x'}", "0"}, "*3\r\n:1\r\n$-1\r\n$1\r\nx\r\n"},
+		{"Nil", []string{"return nil", "0"}, "$-1\r\n"},
+		{"Keys and args", []string{"return {KEYS[1], KEYS[2], ARGV[1], #ARGV}", "2", "k1", "k2", "a1", "a2"}, "*4\r\n$2\r\nk1\r\n$2\r\nk2\r\n$2\r\na1\r\n:2\r\n"},
+		{"Status reply", []string{"return redis.status_reply('FINE')", "0"}, "+FINE\r\n"},
+		{"Error reply", []string{"return redis.error_reply('MY error')", "0"}, "-MY error\r\n"},
+		{"Set and get", []string{"redis.call('SET', KEYS[1], ARGV[1]) return redis.call('GET', KEYS[1])", "1", "key", "value"}, "$5\r\nvalue\r\n"},
+		{"Status from call", []string{"return redis.call('SET', 'k', 'v')", "0"}, "+OK\r\n"},
+		{"Status as table", []string{"return redis.call('SET', 'k', 'v').ok", "0"}, "$2\r\nOK\r\n"},
+		{"Integer from call", []string{"return redis.call('SADD', 'ints', 'a', 'b') + 1", "0"}, ":3\r\n"},
+		{"Array from call", []string{"redis.call('RPUSH', 'l', 'a') redis.call('SADD', 's', 'x', 'y') return #redis.call('SMEMBERS', 's')", "0"}, ":2\r\n"},
+		{"Null from call", []string{"return redis.call('GET', 'missing') == false", "0"}, ":1\r\n"},
+		{"Number arguments", []string{"redis.call('SET', 'num', 42) return redis.call('GET', 'num')", "0"}, ":42\r\n"},
+		{"Call raises errors", []string{"return redis.call('SADD', 'k', 'x')", "0"}, "-ERR value is not type of set\r\n"},
+		{"Pcall returns errors", []string{"local r = redis.pcall('SADD', 'k', 'x') return r.err", "0"}, "$28\r\nERR value is not type of set\r\n"},
+		{"Unknown command", []string{"return redis.pcall('NOPE').err", "0"}, "$44\r\nERR Unknown Redis command called from script\r\n"},
+		{"Command not allowed", []string{"return redis.call('MULTI')", "0"}, "-ERR This Redis command is not allowed from script\r\n"},
+		{"Wrong number of args", []string{"return redis.call('GET')", "0"}, "-ERR Wrong number of args calling Redis command from script\r\n"},
+		{"Cjson", []string{"return cjson.encode(cjson.decode(ARGV[1]))", "0", `{"a":[1,null]}`}, "$14\r\n{\"a\":[1,null]}\r\n"},
+		{"Cjson null", []string{"return cjson.decode('null')", "0"}, "$-1\r\n"},
+		{"Bit", []string{"return bit.bxor(bit.lshift(1, 4), 3)", "0"}, ":19\r\n"},
+		{"Sha1hex", []string{"return redis.sha1hex('')", "0"}, "$40\r\nda39a3ee5e6b4b0d3255bfef95601890afd80709\r\n"},
+		{"Runtime error", []string{"return nil + 1", "0"}, "-ERR user_script:1: attempt to perform arithmetic on a nil value script: d13bc48d895ba57f297c4db70c1d256548a54e2d\r\n"},
+		{"Globals are protected", []string{"x = 1", "0"}, "-ERR user_script:1: Attempt to modify a readonly table script: 34bce5f775de97f557a34088509c8bfe1ea17e52\r\n"},
+		{"Compile error", []string{"return (", "0"}, "-ERR Error compiling script (new function): user_script:1: unexpected symbol near '<eof>'\r\n"},
+		{"Negative numkeys", []string{"return 1", "-1"}, "-ERR Number of keys can't be negative\r\n"},
+		{"Too many keys", []string{"return 1", "2", "k"}, "-ERR Number of keys can't be greater than number of args\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := connect(t, h)
+			client.send(append([]string{"EVAL"}, tt.args...)...)
+			client.expect(tt.want)
+		})
+	}
+}
+
+func TestEvalShaAndScript(t *testing.T) {
+	h := NewClientHandler(config.NewConfig())
+	client := connect(t, h)
+
+	const sha = "e0e1f9fabfc9d4800c877a703b823ac0578ff8db"
+	client.send("EVALSHA", sha, "0")
+	client.expect("-NOSCRIPT No matching script. Please use EVAL.\r\n")
+	client.send("SCRIPT", "LOAD", "return 1")
+	client.expect("$40\r\n" + sha + "\r\n")
+	client.send("SCRIPT", "EXISTS", sha, "ffff")
+	client.expect("*2\r\n:1\r\n:0\r\n")
+	client.send("EVALSHA", "E0E1F9FABFC9D4800C877A703B823AC0578FF8DB", "0")
+	client.expect(":1\r\n")
+
+	client.send("SCRIPT", "FLUSH")
+	client.expect("+OK\r\n")
+	client.send("EVALSHA", sha, "0")
+	client.expect("-NOSCRIPT No matching script. Please use EVAL.\r\n")
+
+	// EVAL caches the script too.
+	client.send("EVAL", "return 1", "0")
+	client.expect(":1\r\n")
+	client.send("EVALSHA", sha, "0")
+	client.expect(":1\r\n")
+
+	client.send("SCRIPT", "KILL")
+	client.expect("-NOTBUSY No scripts in execution right now.\r\n")
+	client.send("SCRIPT", "NOPE")
+	client.expect("-ERR unknown subcommand 'NOPE'. Try SCRIPT HELP.\r\n")
+}
+
+func TestEvalReadOnly(t *testing.T) {
+	h := NewClientHandler(config.NewConfig())
+	client := connect(t, h)
+
+	client.send("SET", "k", "v")
+	client.expect("+OK\r\n")
+	client.send("EVAL_RO", "return redis.call('GET', KEYS[1])", "1", "k")
+	client.expect("$1\r\nv\r\n")
+	client.send("EVAL_RO", "return redis.call('DEL', KEYS[1])", "1", "k")
+	client.expect("-ERR Write commands are not allowed from read-only scripts.\r\n")
+	client.send("GET", "k")
+	client.expect("$1\r\nv\r\n")
+}
+
+func TestEvalInsideMulti(t *testing.T) {
+	h := NewClientHandler(config.NewConfig())
+	client := connect(t, h)
+
+	client.send("MULTI")
+	client.expect("+OK\r\n")
+	client.send("EVAL", "return redis.call('SET', KEYS[1], 'v')", "1", "k")
+	client.expect("+QUEUED\r\n")
+	client.send("GET", "k")
+	client.expect("+QUEUED\r\n")
+	client.send("EXEC")
+	client.expect("*2\r\n+OK\r\n$1\r\nv\r\n")
+}
+
+func TestScriptBusyAndKill(t *testing.T) {
+	h := NewClientHandler(config.NewConfig())
+	runner := connect(t, h)
+	other := connect(t, h)
+	t.Cleanup(func() { setScriptTimeLimit("5000") })
+
+	other.send("CONFIG", "SET", "busy-reply-threshold", "50")
+	other.expect("+OK\r\n")
+
+	runner.send("EVAL", "while true do end", "0")
+	time.Sleep(100 * time.Millisecond)
+	other.send("GET", "k")
+	other.expect("-BUSY Redis is busy running a script. You can only call SCRIPT KILL or SHUTDOWN NOSAVE.\r\n")
+	other.send("SCRIPT", "KILL")
+	other.expect("+OK\r\n")
+	runner.expect("-ERR Script killed by user with SCRIPT KILL...\r\n")
+
+	other.send("GET", "k")
+	other.expect("$-1\r\n")
+}
+
+func TestScriptKillAfterWrite(t *testing.T) {
+	h := NewClientHandler(config.NewConfig())
+	h.scripting.running = &runningScript{start: time.Now()}
+	h.scripting.wrote()
+	if got := h.scripting.kill(false); got[:12] != "-UNKILLABLE " {
+		t.Errorf("expected an UNKILLABLE error, got %q", got)
+	}
+}
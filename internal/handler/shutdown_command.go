@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"redis-go-clone/internal/redis_command"
+	"strings"
+	"sync"
+)
+
+// shutdown implements SHUTDOWN [NOSAVE|SAVE]. The dataset is saved first
+// unless NOSAVE is given, which is also the only way to stop a server stuck
+// in a script that wrote and can no longer be killed.
+func (h *ClientHandler) shutdown(c *client, cmdArray []any, mu *sync.RWMutex) string {
+	save := true
+	for _, arg := range argStrings(cmdArray[1:]) {
+		switch strings.ToUpper(arg) {
+		case "NOSAVE":
+			save = false
+		case "SAVE":
+			save = true
+		default:
+			return "-ERR syntax error\r\n"
+		}
+	}
+
+	if save {
+		if reply := redis_command.Save([]any{"SAVE"}, h.config.DB, mu); reply != "+OK\r\n" {
+			c.log.Warn("Error trying to save the DB, can't exit")
+			return "-ERR Errors trying to SHUTDOWN. Check logs.\r\n"
+		}
+	}
+	c.log.Warn("User requested shutdown...")
+	h.exit(0)
+	return ""
+}
@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"os"
+	"redis-go-clone/cmd/config"
+	"testing"
+	"time"
+)
+
+// stubExit records the exit codes of h instead of ending the test.
+func stubExit(h *ClientHandler) <-chan int {
+	exited := make(chan int, 1)
+	h.exit = func(code int) { exited <- code }
+	return exited
+}
+
+func expectExit(t *testing.T, exited <-chan int) {
+	t.Helper()
+	select {
+	case code := <-exited:
+		if code != 0 {
+			t.Errorf("expected exit code 0, got %d", code)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the server to exit")
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	h := NewClientHandler(config.NewConfig())
+	exited := stubExit(h)
+	client := connect(t, h)
+	t.Cleanup(func() {
+		os.Remove("data.json")
+		os.Remove("functions.json")
+	})
+
+	client.send("SHUTDOWN", "NOW")
+	client.expect("-ERR syntax error\r\n")
+
+	client.send("SET", "k", "v")
+	client.expect("+OK\r\n")
+	client.send("SHUTDOWN")
+	expectExit(t, exited)
+	if _, err := os.Stat("data.json"); err != nil {
+		t.Errorf("expected SHUTDOWN to save: %v", err)
+	}
+}
+
+func TestShutdownNoSaveStopsUnkillableScript(t *testing.T) {
+	h := NewClientHandler(config.NewConfig())
+	exited := stubExit(h)
+	runner := connect(t, h)
+	other := connect(t, h)
+	t.Cleanup(func() { setScriptTimeLimit("5000") })
+
+	other.send("CONFIG", "SET", "busy-reply-threshold", "50")
+	other.expect("+OK\r\n")
+
+	runner.send("EVAL", "redis.call('SET', 'k', 'v') while true do end", "0")
+	time.Sleep(100 * time.Millisecond)
+	other.send("SCRIPT", "KILL")
+	other.expect("-UNKILLABLE Sorry the script already executed write commands against the dataset. You can either wait the script termination or kill the server in a hard way using the SHUTDOWN NOSAVE command.\r\n")
+	other.send("SHUTDOWN")
+	other.expect("-BUSY Redis is busy running a script. You can only call SCRIPT KILL or SHUTDOWN NOSAVE.\r\n")
+	other.send("SHUTDOWN", "NOSAVE")
+	expectExit(t, exited)
+
+	// The process is still here: stop the script the way exiting would.
+	h.scripting.mu.Lock()
+	h.scripting.running.killed = true
+	h.scripting.mu.Unlock()
+	runner.expect("-ERR Script killed by user with SCRIPT KILL...\r\n")
+}
@@ -0,0 +1,194 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The slow log keeps the slowest commands: slowlogLogSlowerThan is the
+// duration, in microseconds, above which a command is logged (0 logs every
+// command and a negative value none), and slowlogMaxLen how many entries
+// are kept.
+var slowlogLogSlowerThan, slowlogMaxLen atomic.Int64
+
+func init() {
+	slowlogLogSlowerThan.Store(10000)
+	slowlogMaxLen.Store(128)
+}
+
+func getSlowlogLogSlowerThan() string {
+	return strconv.FormatInt(slowlogLogSlowerThan.Load(), 10)
+}
+
+func setSlowlogLogSlowerThan(value string) error {
+	usec, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return errors.New("argument couldn't be parsed into an integer")
+	}
+	slowlogLogSlowerThan.Store(usec)
+	return nil
+}
+
+func getSlowlogMaxLen() string {
+	return strconv.FormatInt(slowlogMaxLen.Load(), 10)
+}
+
+func setSlowlogMaxLen(value string) error {
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 || n > 1<<31-1 {
+		return errors.New("argument must be between 0 and 2147483647 inclusive")
+	}
+	slowlogMaxLen.Store(n)
+	return nil
+}
+
+// Like in Redis, a logged command keeps at most slowlogMaxArgs arguments of
+// at most slowlogMaxArgLen bytes each.
+const (
+	slowlogMaxArgs   = 32
+	slowlogMaxArgLen = 128
+)
+
+type slowlogEntry struct {
+	id         int64
+	time       time.Time
+	duration   time.Duration
+	args       []string
+	clientAddr string
+	clientName string
+}
+
+// slowlog is a ring buffer of the last slowlogMaxLen slow commands.
+type slowlog struct {
+	mu sync.Mutex
+	// entries holds the oldest entry at start once the buffer is full.
+	entries []slowlogEntry
+	start   int
+	nextID  int64
+}
+
+// record logs a command that started at start and ran for d, if it was
+// slow enough.
+func (s *slowlog) record(c *client, cmdArray []any, start time.Time, d time.Duration) {
+	threshold := slowlogLogSlowerThan.Load()
+	if threshold < 0 || d.Microseconds() < threshold {
+		return
+	}
+	entry := slowlogEntry{
+		time:       start,
+		duration:   d,
+		args:       slowlogArgs(argStrings(cmdArray)),
+		clientAddr: c.conn.RemoteAddr().String(),
+		clientName: c.name,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry.id = s.nextID
+	s.nextID++
+	s.push(entry, int(slowlogMaxLen.Load()))
+}
+
+func (s *slowlog) push(entry slowlogEntry, maxLen int) {
+	switch {
+	case maxLen > 0 && len(s.entries) == maxLen:
+		// Full: the new entry replaces the oldest one.
+		s.entries[s.start] = entry
+		s.start = (s.start + 1) % len(s.entries)
+	case s.start == 0 && len(s.entries) < maxLen:
+		s.entries = append(s.entries, entry)
+	default:
+		// slowlog-max-len changed: start over from the entries in order,
+		// keeping the newest ones.
+		ordered := append(s.ordered(), entry)
+		s.entries, s.start = ordered[max(len(ordered)-maxLen, 0):], 0
+	}
+}
+
+// ordered returns the entries from the oldest to the newest.
+func (s *slowlog) ordered() []slowlogEntry {
+	return append(append([]slowlogEntry(nil), s.entries[s.start:]...), s.entries[:s.start]...)
+}
+
+// slowlogArgs truncates the arguments of a command the way the slow log
+// keeps them.
+func slowlogArgs(args []string) []string {
+	kept := make([]string, 0, min(len(args), slowlogMaxArgs))
+	for i, arg := range args {
+		if i == slowlogMaxArgs-1 && len(args) > slowlogMaxArgs {
+			kept = append(kept, "... ("+strconv.Itoa(len(args)-i)+" more arguments)")
+			break
+		}
+		if len(arg) > slowlogMaxArgLen {
+			arg = arg[:slowlogMaxArgLen] + "... (" + strconv.Itoa(len(arg)-slowlogMaxArgLen) + " more bytes)"
+		}
+		kept = append(kept, arg)
+	}
+	return kept
+}
+
+// slowlogCommand implements SLOWLOG GET [count], SLOWLOG LEN and SLOWLOG
+// RESET.
+func (h *ClientHandler) slowlogCommand(_ *client, cmdArray []any, _ *sync.RWMutex) string {
+	if len(cmdArray) < 2 {
+		return "-ERR wrong number of arguments for SLOWLOG\r\n"
+	}
+	args := argStrings(cmdArray[2:])
+	switch subcommand := strings.ToUpper(argString(cmdArray[1])); {
+	case subcommand == "GET" && len(args) <= 1:
+		count := 10
+		if len(args) == 1 {
+			n, err := strconv.Atoi(args[0])
+			if err != nil || n < -1 {
+				return "-ERR count should be greater than or equal to -1\r\n"
+			}
+			count = n
+		}
+		return h.slowlog.get(count)
+	case subcommand == "LEN" && len(args) == 0:
+		h.slowlog.mu.Lock()
+		defer h.slowlog.mu.Unlock()
+		return ":" + strconv.Itoa(len(h.slowlog.entries)) + "\r\n"
+	case subcommand == "RESET" && len(args) == 0:
+		h.slowlog.mu.Lock()
+		defer h.slowlog.mu.Unlock()
+		h.slowlog.entries, h.slowlog.start = nil, 0
+		return "+OK\r\n"
+	case subcommand == "GET" || subcommand == "LEN" || subcommand == "RESET":
+		return "-ERR wrong number of arguments for SLOWLOG " + subcommand + "\r\n"
+	default:
+		return "-ERR unknown subcommand '" + argString(cmdArray[1]) + "'. Try SLOWLOG HELP.\r\n"
+	}
+}
+
+// get replies with the count newest entries, all of them when count is -1.
+func (s *slowlog) get(count int) string {
+	s.mu.Lock()
+	entries := s.ordered()
+	s.mu.Unlock()
+
+	if count == -1 || count > len(entries) {
+		count = len(entries)
+	}
+	reply := make([]any, 0, count)
+	for i := len(entries) - 1; i >= len(entries)-count; i-- {
+		entry := entries[i]
+		args := make([]any, len(entry.args))
+		for j, arg := range entry.args {
+			args[j] = arg
+		}
+		reply = append(reply, []any{
+			int(entry.id),
+			int(entry.time.Unix()),
+			int(entry.duration.Microseconds()),
+			args,
+			entry.clientAddr,
+			entry.clientName,
+		})
+	}
+	return bulkReply(reply)
+}
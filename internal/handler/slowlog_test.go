@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"redis-go-clone/cmd/config"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func restoreSlowlogConfig(t *testing.T) {
+	t.Cleanup(func() {
+		setSlowlogLogSlowerThan("10000")
+		setSlowlogMaxLen("128")
+	})
+}
+
+func TestSlowlogCommand(t *testing.T) {
+	restoreSlowlogConfig(t)
+	h := NewClientHandler(config.NewConfig())
+	client := connect(t, h)
+
+	client.send("CONFIG", "SET", "slowlog-log-slower-than", "0")
+	client.expect("+OK\r\n")
+	client.send("SET", "k", "v")
+	client.expect("+OK\r\n")
+	client.send("SLOWLOG", "LEN")
+	client.expect(":2\r\n")
+
+	entries := h.slowlog.ordered()
+	if got := entries[1]; got.id != 1 || !slices.Equal(got.args, []string{"SET", "k", "v"}) || got.clientAddr != "pipe" {
+		t.Errorf("got entry %+v, want SET k v from pipe", got)
+	}
+
+	client.send("SLOWLOG", "RESET")
+	client.expect("+OK\r\n")
+	client.send("SLOWLOG", "LEN")
+	client.expect(":1\r\n")
+	client.send("SLOWLOG", "GET", "-2")
+	client.expect("-ERR count should be greater than or equal to -1\r\n")
+	client.send("SLOWLOG", "NOPE")
+	client.expect("-ERR unknown subcommand 'NOPE'. Try SLOWLOG HELP.\r\n")
+
+	client.send("CONFIG", "SET", "slowlog-log-slower-than", "-1")
+	client.expect("+OK\r\n")
+	client.send("SLOWLOG", "RESET")
+	client.expect("+OK\r\n")
+	client.send("GET", "k")
+	client.expect("$1\r\nv\r\n")
+	client.send("SLOWLOG", "LEN")
+	client.expect(":0\r\n")
+}
+
+func TestSlowlogSkipsExec(t *testing.T) {
+	restoreSlowlogConfig(t)
+	h := NewClientHandler(config.NewConfig())
+	client := connect(t, h)
+
+	setSlowlogLogSlowerThan("0")
+	client.send("MULTI")
+	client.expect("+OK\r\n")
+	client.send("SET", "k", "v")
+	client.expect("+QUEUED\r\n")
+	client.send("EXEC")
+	client.expect("*1\r\n+OK\r\n")
+
+	var logged []string
+	for _, entry := range h.slowlog.ordered() {
+		logged = append(logged, entry.args[0])
+	}
+	if want := []string{"MULTI", "SET"}; !slices.Equal(logged, want) {
+		t.Errorf("logged %v, want %v", logged, want)
+	}
+}
+
+func TestSlowlogGet(t *testing.T) {
+	s := &slowlog{}
+	start := time.Unix(1700000000, 0)
+	for i, args := range [][]string{{"GET", "a"}, {"GET", "b"}, {"GET", "c"}} {
+		s.push(slowlogEntry{id: int64(i), time: start, duration: 15 * time.Millisecond, args: args, clientAddr: "127.0.0.1:5000", clientName: "app"}, 10)
+	}
+
+	entry := func(id, key string) string {
+		return "*6\r\n:" + id + "\r\n:1700000000\r\n:15000\r\n*2\r\n$3\r\nGET\r\n$1\r\n" + key + "\r\n$14\r\n127.0.0.1:5000\r\n$3\r\napp\r\n"
+	}
+	tests := []struct {
+		count int
+		want  string
+	}{
+		{1, "*1\r\n" + entry("2", "c")},
+		{2, "*2\r\n" + entry("2", "c") + entry("1", "b")},
+		{-1, "*3\r\n" + entry("2", "c") + entry("1", "b") + entry("0", "a")},
+		{10, "*3\r\n" + entry("2", "c") + entry("1", "b") + entry("0", "a")},
+		{0, "*0\r\n"},
+	}
+	for _, tt := range tests {
+		if got := s.get(tt.count); got != tt.want {
+			t.Errorf("get(%d) = %q, want %q", tt.count, got, tt.want)
+		}
+	}
+}
+
+func TestSlowlogRing(t *testing.T) {
+	s := &slowlog{}
+	ids := func() []int64 {
+		var ids []int64
+		for _, entry := range s.ordered() {
+			ids = append(ids, entry.id)
+		}
+		return ids
+	}
+
+	tests := []struct {
+		maxLen int
+		push   []int64
+		want   []int64
+	}{
+		{3, []int64{0, 1, 2, 3, 4}, []int64{2, 3, 4}},
+		{2, []int64{5}, []int64{4, 5}},
+		{4, []int64{6, 7, 8}, []int64{5, 6, 7, 8}},
+		{0, []int64{9}, nil},
+	}
+	for _, tt := range tests {
+		for _, id := range tt.push {
+			s.push(slowlogEntry{id: id}, tt.maxLen)
+		}
+		if got := ids(); !slices.Equal(got, tt.want) {
+			t.Errorf("with slowlog-max-len %d kept %v, want %v", tt.maxLen, got, tt.want)
+		}
+	}
+}
+
+func TestSlowlogArgs(t *testing.T) {
+	long := strings.Repeat("x", 130)
+	if got := slowlogArgs([]string{"SET", "k", long}); got[2] != strings.Repeat("x", 128)+"... (2 more bytes)" {
+		t.Errorf("long argument kept as %q", got[2])
+	}
+
+	many := make([]string, 40)
+	got := slowlogArgs(many)
+	if len(got) != 32 || got[31] != "... (9 more arguments)" {
+		t.Errorf("40 arguments kept as %d, last %q", len(got), got[len(got)-1])
+	}
+}
@@ -0,0 +1,174 @@
+// Package latency implements the latency monitor: the server times events
+// such as expiry cycles, saves and evictions, and every one that takes at
+// least latency-monitor-threshold milliseconds is kept in a short history
+// per event for LATENCY to report.
+package latency
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// historyLen is how many samples are kept per event, like in Redis.
+const historyLen = 160
+
+// Events the server samples.
+const (
+	Command       = "command"
+	ExpireCycle   = "expire-cycle"
+	EvictionCycle = "eviction-cycle"
+	EvictionDel   = "eviction-del"
+	Save          = "save"
+	Fsync         = "fsync"
+)
+
+// threshold is latency-monitor-threshold in milliseconds, 0 disabling the
+// monitor. It is read for every sample, so it does not take mu.
+var threshold atomic.Int64
+
+// Sample is the latency of an event at a time, in milliseconds.
+type Sample struct {
+	Time    time.Time
+	Latency int64
+}
+
+// series is the history of an event: a ring of its last samples and the
+// highest latency it ever had.
+type series struct {
+	samples []Sample
+	next    int
+	max     int64
+}
+
+func (s *series) add(sample Sample) {
+	if s.max < sample.Latency {
+		s.max = sample.Latency
+	}
+	// Like in Redis, there is a sample per second at most: the highest.
+	if len(s.samples) > 0 {
+		last := &s.samples[(s.next+len(s.samples)-1)%len(s.samples)]
+		if last.Time.Unix() == sample.Time.Unix() {
+			last.Latency = max(last.Latency, sample.Latency)
+			return
+		}
+	}
+	if len(s.samples) < historyLen {
+		s.samples = append(s.samples, sample)
+		return
+	}
+	s.samples[s.next] = sample
+	s.next = (s.next + 1) % historyLen
+}
+
+// history returns the samples from the oldest to the newest.
+func (s *series) history() []Sample {
+	if len(s.samples) < historyLen {
+		return slices.Clone(s.samples)
+	}
+	return append(slices.Clone(s.samples[s.next:]), s.samples[:s.next]...)
+}
+
+var (
+	mu     sync.Mutex
+	events = make(map[string]*series)
+)
+
+// Threshold returns the latency-monitor-threshold parameter.
+func Threshold() string {
+	return strconv.FormatInt(threshold.Load(), 10)
+}
+
+func SetThreshold(value string) error {
+	ms, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || ms < 0 {
+		return errors.New("argument must be a non-negative integer")
+	}
+	threshold.Store(ms)
+	return nil
+}
+
+// Enabled reports whether the monitor is on.
+func Enabled() bool {
+	return threshold.Load() > 0
+}
+
+// Add records that event took d, if the monitor is on and d reaches the
+// threshold.
+func Add(event string, d time.Duration) {
+	limit := threshold.Load()
+	if limit == 0 || d.Milliseconds() < limit {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	s, found := events[event]
+	if !found {
+		s = &series{}
+		events[event] = s
+	}
+	s.add(Sample{Time: time.Now(), Latency: d.Milliseconds()})
+}
+
+// Latest is the last sample of an event, with the highest latency it ever
+// had.
+type Latest struct {
+	Event string
+	Sample
+	Max int64
+}
+
+// GetLatest returns the last sample of every event, by event name.
+func GetLatest() []Latest {
+	mu.Lock()
+	defer mu.Unlock()
+	latest := make([]Latest, 0, len(events))
+	for _, event := range sortedEvents() {
+		s := events[event]
+		history := s.history()
+		latest = append(latest, Latest{Event: event, Sample: history[len(history)-1], Max: s.max})
+	}
+	return latest
+}
+
+// History returns the samples of event, from the oldest.
+func History(event string) []Sample {
+	mu.Lock()
+	defer mu.Unlock()
+	if s, found := events[event]; found {
+		return s.history()
+	}
+	return nil
+}
+
+// Reset forgets the given events, or all of them when none is given, and
+// returns how many it forgot.
+func Reset(names ...string) int {
+	mu.Lock()
+	defer mu.Unlock()
+	if len(names) == 0 {
+		n := len(events)
+		clear(events)
+		return n
+	}
+	n := 0
+	for _, name := range names {
+		if _, found := events[name]; found {
+			delete(events, name)
+			n++
+		}
+	}
+	return n
+}
+
+func sortedEvents() []string {
+	names := make([]string, 0, len(events))
+	for name := range events {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
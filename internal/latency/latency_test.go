@@ -0,0 +1,129 @@
+package latency
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func restoreThreshold(t *testing.T) {
+	t.Cleanup(func() {
+		SetThreshold("0")
+		Reset()
+	})
+}
+
+func TestAddThreshold(t *testing.T) {
+	restoreThreshold(t)
+
+	Add(Command, time.Second)
+	if len(GetLatest()) != 0 {
+		t.Fatal("samples were kept with the monitor disabled")
+	}
+
+	if err := SetThreshold("100"); err != nil {
+		t.Fatal(err)
+	}
+	Add(Command, 99*time.Millisecond)
+	Add(Save, 250*time.Millisecond)
+	latest := GetLatest()
+	if len(latest) != 1 || latest[0].Event != Save || latest[0].Latency != 250 || latest[0].Max != 250 {
+		t.Errorf("GetLatest() = %+v, want one save sample of 250ms", latest)
+	}
+
+	if err := SetThreshold("-1"); err == nil {
+		t.Error("a negative threshold should be refused")
+	}
+}
+
+func TestSeries(t *testing.T) {
+	s := &series{}
+	start := time.Unix(1700000000, 0)
+	s.add(Sample{start, 10})
+	s.add(Sample{start.Add(100 * time.Millisecond), 30})
+	s.add(Sample{start.Add(200 * time.Millisecond), 20})
+	if history := s.history(); len(history) != 1 || history[0].Latency != 30 {
+		t.Errorf("samples of the same second should keep the highest, got %+v", history)
+	}
+
+	for i := 1; i <= historyLen+5; i++ {
+		s.add(Sample{start.Add(time.Duration(i) * time.Second), int64(i)})
+	}
+	history := s.history()
+	if len(history) != historyLen || history[0].Latency != 6 || history[historyLen-1].Latency != historyLen+5 {
+		t.Errorf("history kept %d samples from %d to %d", len(history), history[0].Latency, history[len(history)-1].Latency)
+	}
+	if s.max != historyLen+5 {
+		t.Errorf("max = %d, want %d", s.max, historyLen+5)
+	}
+}
+
+func TestReset(t *testing.T) {
+	restoreThreshold(t)
+	SetThreshold("1")
+	Add(Command, time.Second)
+	Add(Save, time.Second)
+	Add(Fsync, time.Second)
+
+	if n := Reset(Save, "nope"); n != 1 {
+		t.Errorf("Reset(save, nope) = %d, want 1", n)
+	}
+	if History(Save) != nil {
+		t.Error("save was not reset")
+	}
+	if n := Reset(); n != 2 {
+		t.Errorf("Reset() = %d, want 2", n)
+	}
+}
+
+func TestGraph(t *testing.T) {
+	restoreThreshold(t)
+	if _, found := Graph(Command); found {
+		t.Error("graph of an event without samples")
+	}
+
+	now := time.Now()
+	mu.Lock()
+	events[Command] = &series{}
+	for i, ms := range []int64{100, 200, 300, 400} {
+		events[Command].add(Sample{now.Add(time.Duration(i-3) * time.Minute), ms})
+	}
+	mu.Unlock()
+
+	graph, found := Graph(Command)
+	want := "command - high 400 ms, low 100 ms (all time high 400 ms)\n" +
+		strings.Repeat("-", 80) + "\n" +
+		"   #\n" +
+		"  #|\n" +
+		" o||\n" +
+		"_|||\n" +
+		"\n" +
+		"3210\n" +
+		"mmms\n"
+	if !found || graph != want {
+		t.Errorf("Graph() =\n%s\nwant\n%s", graph, want)
+	}
+}
+
+func TestDoctor(t *testing.T) {
+	restoreThreshold(t)
+	if got := Doctor(); !strings.HasPrefix(got, "I'm sorry, Dave, I can't do that.") {
+		t.Errorf("Doctor() with the monitor disabled = %q", got)
+	}
+
+	SetThreshold("10")
+	if got := Doctor(); !strings.HasPrefix(got, "Dave, no latency spike was observed") {
+		t.Errorf("Doctor() without samples = %q", got)
+	}
+
+	Add(ExpireCycle, 50*time.Millisecond)
+	got := Doctor()
+	for _, want := range []string{
+		"1. expire-cycle: 1 latency spikes (average 50ms, mean deviation 0ms, period 0.00 sec). Worst all time event 50ms.",
+		"- " + advice[ExpireCycle],
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Doctor() = %q, want it to contain %q", got, want)
+		}
+	}
+}
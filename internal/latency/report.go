@@ -0,0 +1,159 @@
+package latency
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The graph of LATENCY GRAPH has a column per sample and graphRows rows,
+// each split in as many steps as graphCharset has characters.
+const (
+	graphRows    = 4
+	graphColumns = 80
+	graphCharset = "_o#"
+)
+
+// Graph draws the history of event as an ASCII chart, the newest samples
+// on the right, labelled with how long ago they were taken. It returns
+// false when the event has no samples.
+func Graph(event string) (string, bool) {
+	mu.Lock()
+	s, found := events[event]
+	var history []Sample
+	var allTimeHigh int64
+	if found {
+		history, allTimeHigh = s.history(), s.max
+	}
+	mu.Unlock()
+	if len(history) == 0 {
+		return "", false
+	}
+	history = history[max(len(history)-graphColumns, 0):]
+
+	low, high := history[0].Latency, history[0].Latency
+	for _, sample := range history {
+		low, high = min(low, sample.Latency), max(high, sample.Latency)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s - high %d ms, low %d ms (all time high %d ms)\n", event, high, low, allTimeHigh)
+	b.WriteString(strings.Repeat("-", graphColumns) + "\n")
+
+	steps := graphRows * len(graphCharset)
+	line := make([]byte, len(history))
+	for row := 0; row < graphRows; row++ {
+		for i, sample := range history {
+			step := 0
+			if high > low {
+				step = min(int((sample.Latency-low)*int64(steps)/(high-low)), steps-1)
+			}
+			switch char := step - (graphRows-row-1)*len(graphCharset); {
+			case char >= len(graphCharset):
+				line[i] = '|'
+			case char >= 0:
+				line[i] = graphCharset[char]
+			default:
+				line[i] = ' '
+			}
+		}
+		b.Write(line)
+		b.WriteByte('\n')
+	}
+
+	now := time.Now()
+	labels := make([]string, len(history))
+	longest := 0
+	for i, sample := range history {
+		labels[i] = age(now.Sub(sample.Time))
+		longest = max(longest, len(labels[i]))
+	}
+	b.WriteByte('\n')
+	for row := 0; row < longest; row++ {
+		for i, label := range labels {
+			line[i] = ' '
+			if row < len(label) {
+				line[i] = label[row]
+			}
+		}
+		b.Write(line)
+		b.WriteByte('\n')
+	}
+	return b.String(), true
+}
+
+// age formats how long ago a sample was taken, such as 12s or 3m.
+func age(d time.Duration) string {
+	switch seconds := int64(d.Seconds()); {
+	case seconds < 60:
+		return strconv.FormatInt(seconds, 10) + "s"
+	case seconds < 3600:
+		return strconv.FormatInt(seconds/60, 10) + "m"
+	case seconds < 86400:
+		return strconv.FormatInt(seconds/3600, 10) + "h"
+	default:
+		return strconv.FormatInt(seconds/86400, 10) + "d"
+	}
+}
+
+// advice is what the doctor suggests for each event.
+var advice = map[string]string{
+	Command:       "Check your Slow Log to understand what are the commands you are running which are too slow to execute. SLOWLOG GET shows them with their arguments.",
+	ExpireCycle:   "Deleting expired keys is taking long. Many keys may be expiring at the same time; consider spreading their TTLs.",
+	EvictionCycle: "Evicting keys to stay under maxmemory is taking long. Consider raising maxmemory, or a policy that finds victims faster such as allkeys-random.",
+	EvictionDel:   "Deleting evicted keys is taking long, which happens with big values. Consider splitting them into smaller keys.",
+	Save:          "SAVE blocks the server while it writes the whole dataset. Call it less often, or when the server is less busy.",
+	Fsync:         "Flushing saved files to disk is slow. Check the disk, and whether other processes are using it heavily.",
+}
+
+// Doctor analyses the samples of every event and suggests what to do about
+// the spikes.
+func Doctor() string {
+	if !Enabled() {
+		return "I'm sorry, Dave, I can't do that. Latency monitoring is disabled in this Redis instance. " +
+			"You may use \"CONFIG SET latency-monitor-threshold <milliseconds>.\" in order to enable it.\n"
+	}
+
+	mu.Lock()
+	names := sortedEvents()
+	histories := make(map[string][]Sample, len(names))
+	allTimeHighs := make(map[string]int64, len(names))
+	for _, name := range names {
+		histories[name], allTimeHighs[name] = events[name].history(), events[name].max
+	}
+	mu.Unlock()
+
+	if len(names) == 0 {
+		return "Dave, no latency spike was observed during the lifetime of this Redis instance, not in the slightest bit. " +
+			"I honestly think you ought to sleep a little bit more.\n"
+	}
+
+	var b strings.Builder
+	b.WriteString("Dave, I have observed latency spikes in this Redis instance. You don't mind talking about it, do you Dave?\n\n")
+	for i, name := range names {
+		history := histories[name]
+		var sum int64
+		for _, sample := range history {
+			sum += sample.Latency
+		}
+		avg := sum / int64(len(history))
+		var deviation int64
+		for _, sample := range history {
+			deviation += max(sample.Latency-avg, avg-sample.Latency)
+		}
+		period := 0.0
+		if len(history) > 1 {
+			period = history[len(history)-1].Time.Sub(history[0].Time).Seconds() / float64(len(history)-1)
+		}
+		fmt.Fprintf(&b, "%d. %s: %d latency spikes (average %dms, mean deviation %dms, period %.2f sec). Worst all time event %dms.\n",
+			i+1, name, len(history), avg, deviation/int64(len(history)), period, allTimeHighs[name])
+	}
+
+	b.WriteString("\nI have a few advices for you:\n\n")
+	for _, name := range names {
+		if tip, found := advice[name]; found {
+			b.WriteString("- " + tip + "\n")
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,188 @@
+// Package logging sets up the server log on log/slog: the Redis log levels,
+// the loglevel parameter that can be changed at runtime, text or JSON
+// output, and a log file that is reopened on SIGHUP so it can be rotated.
+package logging
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// The Redis log levels. Notice is the default, and what the standard log
+// package logs at once Setup made slog the default logger.
+const (
+	LevelDebug   = slog.LevelDebug
+	LevelVerbose = slog.Level(-2)
+	LevelNotice  = slog.LevelInfo
+	LevelWarning = slog.LevelWarn
+)
+
+// Levels are the levels in the order of redis.LOG_DEBUG to
+// redis.LOG_WARNING.
+var Levels = []slog.Level{LevelDebug, LevelVerbose, LevelNotice, LevelWarning}
+
+var levelNames = map[slog.Level]string{
+	LevelDebug:   "debug",
+	LevelVerbose: "verbose",
+	LevelNotice:  "notice",
+	LevelWarning: "warning",
+}
+
+var level slog.LevelVar
+
+var (
+	mu     sync.Mutex
+	path   string
+	format = "text"
+	file   *os.File
+)
+
+func init() {
+	level.Set(LevelNotice)
+}
+
+// Level returns the loglevel parameter.
+func Level() string {
+	return levelName(level.Level())
+}
+
+// SetLevel sets the loglevel parameter, the least severe level logged.
+func SetLevel(value string) error {
+	for l, name := range levelNames {
+		if strings.EqualFold(value, name) {
+			level.Set(l)
+			return nil
+		}
+	}
+	return errors.New("argument(s) must be one of the following: debug, verbose, notice, warning")
+}
+
+// levelName names l after the Redis level it is at or above.
+func levelName(l slog.Level) string {
+	switch {
+	case l >= LevelWarning:
+		return "warning"
+	case l >= LevelNotice:
+		return "notice"
+	case l >= LevelVerbose:
+		return "verbose"
+	default:
+		return "debug"
+	}
+}
+
+// File returns the logfile parameter, empty when logging to the standard
+// output.
+func File() string {
+	mu.Lock()
+	defer mu.Unlock()
+	return path
+}
+
+// Format returns the log-format parameter, text or json.
+func Format() string {
+	mu.Lock()
+	defer mu.Unlock()
+	return format
+}
+
+// Setup makes slog log to the file at logfile, or to the standard output
+// when it is empty, in the text or json format, and installs it as the
+// default logger.
+func Setup(logfile, logFormat string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	newHandler := map[string]func(io.Writer, *slog.HandlerOptions) slog.Handler{
+		"text": func(w io.Writer, opts *slog.HandlerOptions) slog.Handler { return slog.NewTextHandler(w, opts) },
+		"json": func(w io.Writer, opts *slog.HandlerOptions) slog.Handler { return slog.NewJSONHandler(w, opts) },
+	}[strings.ToLower(logFormat)]
+	if newHandler == nil {
+		return errors.New("log format must be text or json")
+	}
+	if err := open(logfile); err != nil {
+		return err
+	}
+	path, format = logfile, strings.ToLower(logFormat)
+
+	opts := &slog.HandlerOptions{Level: &level, ReplaceAttr: replaceLevel}
+	slog.SetDefault(slog.New(newHandler(output{}, opts)))
+	return nil
+}
+
+// replaceLevel shows levels by their Redis name.
+func replaceLevel(groups []string, a slog.Attr) slog.Attr {
+	if a.Key == slog.LevelKey && len(groups) == 0 {
+		if l, ok := a.Value.Any().(slog.Level); ok {
+			a.Value = slog.StringValue(levelName(l))
+		}
+	}
+	return a
+}
+
+// open replaces the log file with the one at name. mu must be held.
+func open(name string) error {
+	var f *os.File
+	if name != "" {
+		var err error
+		f, err = os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+	}
+	if file != nil {
+		file.Close()
+	}
+	file = f
+	return nil
+}
+
+// Reopen closes the log file and opens it again, so that lines go to a new
+// file once the old one was moved away.
+func Reopen() error {
+	mu.Lock()
+	defer mu.Unlock()
+	if path == "" {
+		return nil
+	}
+	return open(path)
+}
+
+// ReopenOnSIGHUP reopens the log file every time the process gets SIGHUP,
+// which is how logrotate tells it the file was rotated.
+func ReopenOnSIGHUP() {
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	go func() {
+		for range hangups {
+			if err := Reopen(); err != nil {
+				slog.Warn("Failed to reopen the log file", "error", err)
+			} else {
+				Verbose(slog.Default(), "Reopened the log file")
+			}
+		}
+	}()
+}
+
+// output writes to the current log file, which Reopen may replace.
+type output struct{}
+
+func (output) Write(p []byte) (int, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if file == nil {
+		return os.Stdout.Write(p)
+	}
+	return file.Write(p)
+}
+
+// Verbose logs at the verbose level, which slog has no function for.
+func Verbose(logger *slog.Logger, msg string, args ...any) {
+	logger.Log(context.Background(), LevelVerbose, msg, args...)
+}
@@ -0,0 +1,103 @@
+package logging
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// setupFile logs to a file in a temporary directory for the duration of the
+// test and returns its path.
+func setupFile(t *testing.T, format string) string {
+	t.Helper()
+	name := filepath.Join(t.TempDir(), "redis.log")
+	if err := Setup(name, format); err != nil {
+		t.Fatalf("Setup() failed: %v", err)
+	}
+	t.Cleanup(func() {
+		Setup("", "text")
+		level.Set(LevelNotice)
+	})
+	return name
+}
+
+func readLines(t *testing.T, name string) []string {
+	t.Helper()
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatalf("reading the log failed: %v", err)
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+func TestSetLevel(t *testing.T) {
+	t.Cleanup(func() { level.Set(LevelNotice) })
+	if Level() != "notice" {
+		t.Errorf("default Level() = %q, want notice", Level())
+	}
+	for _, name := range []string{"debug", "VERBOSE", "notice", "warning"} {
+		if err := SetLevel(name); err != nil {
+			t.Errorf("SetLevel(%q) failed: %v", name, err)
+		} else if Level() != strings.ToLower(name) {
+			t.Errorf("Level() = %q after SetLevel(%q)", Level(), name)
+		}
+	}
+	if err := SetLevel("error"); err == nil {
+		t.Errorf("SetLevel(error) succeeded")
+	}
+}
+
+func TestSetup(t *testing.T) {
+	if err := Setup("", "xml"); err == nil {
+		t.Errorf("Setup with the xml format succeeded")
+	}
+
+	name := setupFile(t, "JSON")
+	if File() != name || Format() != "json" {
+		t.Errorf("File() = %q and Format() = %q", File(), Format())
+	}
+	Verbose(slog.Default(), "hidden")
+	slog.Warn("shown", "client_id", 7)
+	SetLevel("verbose")
+	Verbose(slog.Default(), "shown too")
+
+	lines := readLines(t, name)
+	if len(lines) != 2 {
+		t.Fatalf("logged %q, want 2 lines", lines)
+	}
+	var line struct {
+		Level    string `json:"level"`
+		Msg      string `json:"msg"`
+		ClientID int    `json:"client_id"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &line); err != nil || line.Level != "warning" || line.Msg != "shown" || line.ClientID != 7 {
+		t.Errorf("first line is %q", lines[0])
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &line); err != nil || line.Level != "verbose" {
+		t.Errorf("second line is %q", lines[1])
+	}
+}
+
+func TestReopen(t *testing.T) {
+	name := setupFile(t, "text")
+	slog.Info("before")
+	if err := os.Rename(name, name+".1"); err != nil {
+		t.Fatal(err)
+	}
+	slog.Info("still in the rotated file")
+	if err := Reopen(); err != nil {
+		t.Fatalf("Reopen() failed: %v", err)
+	}
+	slog.Info("after")
+
+	if lines := readLines(t, name+".1"); len(lines) != 2 {
+		t.Errorf("rotated file has %q", lines)
+	}
+	lines := readLines(t, name)
+	if len(lines) != 1 || !strings.Contains(lines[0], "level=notice msg=after") {
+		t.Errorf("new file has %q", lines)
+	}
+}
@@ -35,6 +35,18 @@ func TestStartBackgroundExpiryManager(t *testing.T) {
 			wait:     200 * time.Millisecond,
 			want:     2,
 		},
+		{
+			name: "Removes hash once all fields expire",
+			data: map[string]model.StoredData{
+				"hash": {Value: &model.Hash{
+					Fields:      map[string]string{"f1": "v1"},
+					FieldExpiry: map[string]int64{"f1": time.Now().Add(50 * time.Millisecond).UnixMilli()},
+				}},
+			},
+			interval: 50 * time.Millisecond,
+			wait:     200 * time.Millisecond,
+			want:     0,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -53,3 +65,24 @@ func TestStartBackgroundExpiryManager(t *testing.T) {
 		})
 	}
 }
+
+func TestBackgroundExpiryManagerRemovesHashFields(t *testing.T) {
+	mu := &sync.RWMutex{}
+	hash := &model.Hash{
+		Fields:      map[string]string{"short": "v1", "long": "v2"},
+		FieldExpiry: map[string]int64{"short": time.Now().Add(50 * time.Millisecond).UnixMilli()},
+	}
+	storedData := map[string]model.StoredData{"hash": {Value: hash}}
+
+	StartBackgroundExpiryManager(storedData, mu, 50*time.Millisecond)
+	time.Sleep(200 * time.Millisecond)
+
+	mu.RLock()
+	defer mu.RUnlock()
+	if _, found := hash.Fields["short"]; found {
+		t.Error("expected expired field to be removed")
+	}
+	if _, found := hash.Fields["long"]; !found {
+		t.Error("expected field without TTL to be kept")
+	}
+}
@@ -1,7 +1,9 @@
 package manager
 
 import (
+	"redis-go-clone/internal/latency"
 	"redis-go-clone/internal/model"
+	"redis-go-clone/internal/redis_command"
 	"sync"
 	"time"
 )
@@ -11,12 +13,19 @@ func StartBackgroundExpiryManager(storedData map[string]model.StoredData, mu *sy
 		for {
 			time.Sleep(interval)
 			mu.Lock()
-			now := time.Now().Unix()
+			now := time.Now()
 			for key, value := range storedData {
-				if value.ExpiryDate > 0 && value.ExpiryDate <= now {
-					delete(storedData, key)
+				if value.ExpiryDate > 0 && value.ExpiryDate <= now.Unix() {
+					redis_command.ExpireKey(storedData, key)
+					continue
+				}
+
+				// Hash fields can carry their own TTLs.
+				if _, ok := value.Value.(*model.Hash); ok {
+					redis_command.ExpireHashFields(storedData, key)
 				}
 			}
+			latency.Add(latency.ExpireCycle, time.Since(now))
 			mu.Unlock()
 		}
 	}()
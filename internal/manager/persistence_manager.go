@@ -2,22 +2,26 @@ package manager
 
 import (
 	"encoding/json"
-	"log"
+	"log/slog"
 	"os"
 	"redis-go-clone/internal/model"
 	"sync"
 )
 
-const saveFile = "data.json"
+const (
+	saveFile      = "data.json"
+	functionsFile = "functions.json"
+)
 
 func LoadData(storedData map[string]model.StoredData, mu *sync.RWMutex) {
 	file, err := os.Open(saveFile)
 	if err != nil {
 		if os.IsNotExist(err) {
-			log.Println("No existing data file found. Starting with empty database.")
+			slog.Info("No existing data file found. Starting with empty database.")
 			return
 		}
-		log.Fatalf("Failed to open data file: %v", err)
+		slog.Error("Failed to open data file", "error", err)
+		os.Exit(1)
 	}
 	defer file.Close()
 
@@ -26,8 +30,30 @@ func LoadData(storedData map[string]model.StoredData, mu *sync.RWMutex) {
 	defer mu.Unlock()
 	err = decoder.Decode(&storedData)
 	if err != nil {
-		log.Fatalf("Failed to decode data file: %v", err)
+		slog.Error("Failed to decode data file", "error", err)
+		os.Exit(1)
+	}
+
+	slog.Info("Database loaded successfully from disk.")
+}
+
+// LoadFunctions reads the function libraries saved by SAVE and hands them to
+// restore.
+func LoadFunctions(restore func(payload []byte) error) {
+	payload, err := os.ReadFile(functionsFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			slog.Info("No existing functions file found. Starting without function libraries.")
+			return
+		}
+		slog.Error("Failed to open functions file", "error", err)
+		os.Exit(1)
+	}
+
+	if err := restore(payload); err != nil {
+		slog.Error("Failed to load function libraries", "error", err)
+		os.Exit(1)
 	}
 
-	log.Println("Database loaded successfully from disk.")
+	slog.Info("Function libraries loaded successfully from disk.")
 }
@@ -0,0 +1,43 @@
+package memory
+
+import (
+	"runtime"
+	"sync/atomic"
+)
+
+// Allocator is what the Go runtime reports about the memory of the
+// process, which plays the part of the allocator statistics of Redis.
+type Allocator struct {
+	runtime.MemStats
+	// Peak is the largest HeapAlloc read so far and Startup the one when
+	// the server started.
+	Peak    uint64
+	Startup uint64
+}
+
+var startupAllocated, peakAllocated atomic.Uint64
+
+func init() {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	startupAllocated.Store(stats.HeapAlloc)
+	peakAllocated.Store(stats.HeapAlloc)
+}
+
+// ReadAllocator reads the runtime statistics and updates the peak.
+func ReadAllocator() Allocator {
+	a := Allocator{Startup: startupAllocated.Load()}
+	runtime.ReadMemStats(&a.MemStats)
+	for {
+		peak := peakAllocated.Load()
+		if a.HeapAlloc <= peak {
+			a.Peak = peak
+			break
+		}
+		if peakAllocated.CompareAndSwap(peak, a.HeapAlloc) {
+			a.Peak = a.HeapAlloc
+			break
+		}
+	}
+	return a
+}
@@ -0,0 +1,224 @@
+package memory
+
+import (
+	"cmp"
+	"math"
+	"math/rand/v2"
+	"redis-go-clone/internal/latency"
+	"redis-go-clone/internal/model"
+	"redis-go-clone/internal/notify"
+	"redis-go-clone/internal/stats"
+	"redis-go-clone/internal/watch"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LFU counter parameters, the defaults of lfu-log-factor and lfu-decay-time
+// in Redis: a new key starts at lfuInitVal, the counter grows
+// logarithmically and drops by one for every minute the key is not used.
+const (
+	lfuInitVal   = 5
+	lfuLogFactor = 10
+	lfuDecayTime = time.Minute
+)
+
+// evictionPoolSize is how many of the best candidates found by sampling
+// are remembered between evictions.
+const evictionPoolSize = 16
+
+// poolEntry is an eviction candidate; the higher idle, the better.
+type poolEntry struct {
+	key  string
+	idle uint64
+}
+
+// pool holds candidates sorted by increasing idle.
+var pool []poolEntry
+
+// pendingAccess is when a key was last used and how many times since its
+// clocks were last updated.
+type pendingAccess struct {
+	last  time.Time
+	count int
+}
+
+// accessed holds the accesses not applied to the clocks yet. Read commands
+// only hold the store lock for reading, which is not enough to update the
+// clocks kept in the store, so accesses are recorded here under accessMu and
+// applied the next time the store lock is held for writing.
+var (
+	accessMu sync.Mutex
+	accessed = make(map[string]pendingAccess)
+)
+
+// Access records that a command used key. It does not touch the store: the
+// clocks are updated by ApplyAccesses.
+func Access(key string) {
+	accessMu.Lock()
+	defer accessMu.Unlock()
+	accessed[key] = pendingAccess{last: time.Now(), count: accessed[key].count + 1}
+}
+
+// ApplyAccesses updates the access clocks of the keys used since it last
+// ran. The store lock must be held for writing.
+func ApplyAccesses(store map[string]model.StoredData) {
+	accessMu.Lock()
+	pending := accessed
+	accessed = make(map[string]pendingAccess)
+	accessMu.Unlock()
+
+	for key, access := range pending {
+		data, found := store[key]
+		if !found {
+			continue
+		}
+		counter := uint8(lfuInitVal)
+		if data.AccessTime > 0 {
+			counter = lfuDecrement(data, access.last)
+		}
+		for range access.count {
+			counter = lfuIncrement(counter)
+		}
+		data.AccessCount = counter
+		data.AccessTime = access.last.UnixMilli()
+		store[key] = data
+	}
+}
+
+func lfuIncrement(counter uint8) uint8 {
+	if counter == math.MaxUint8 {
+		return counter
+	}
+	base := max(float64(counter)-lfuInitVal, 0)
+	if rand.Float64() < 1/(base*lfuLogFactor+1) {
+		counter++
+	}
+	return counter
+}
+
+// lfuDecrement returns the counter of data decremented for the time it
+// was not used. Keys never used since they were loaded count as 0.
+func lfuDecrement(data model.StoredData, now time.Time) uint8 {
+	if data.AccessTime == 0 {
+		return 0
+	}
+	periods := now.Sub(time.UnixMilli(data.AccessTime)) / lfuDecayTime
+	if periods >= time.Duration(data.AccessCount) {
+		return 0
+	}
+	return data.AccessCount - uint8(periods)
+}
+
+// Evict removes keys following the eviction policy until the store uses
+// no more than maxmemory. It returns false when it cannot, because the
+// policy is noeviction or no key qualifies. The store lock must be held
+// for writing.
+func Evict(store map[string]model.StoredData) bool {
+	limit := maxMemory.Load()
+	if limit == 0 {
+		return true
+	}
+
+	start := time.Now()
+	defer func() { latency.Add(latency.EvictionCycle, time.Since(start)) }()
+
+	ApplyAccesses(store)
+	mu.Lock()
+	defer mu.Unlock()
+	count(store)
+	trackExceeded(counted.Bytes > limit)
+	for counted.Bytes > limit {
+		key, found := victim(store)
+		if !found {
+			return false
+		}
+		deleteStart := time.Now()
+		delete(store, key)
+		counted.add(sizes[key], -1)
+		delete(sizes, key)
+		evictedKeys++
+		watch.Touch(key)
+		stats.Changed()
+		notify.KeyspaceEvent(notify.Evicted, "evicted", key)
+		latency.Add(latency.EvictionDel, time.Since(deleteStart))
+	}
+	trackExceeded(false)
+	return true
+}
+
+// victim picks the next key to evict.
+func victim(store map[string]model.StoredData) (string, bool) {
+	if policy == NoEviction {
+		return "", false
+	}
+	volatile := strings.HasPrefix(policy, "volatile-")
+	if strings.HasSuffix(policy, "-random") {
+		for key, data := range store {
+			if !volatile || data.ExpiryDate > 0 {
+				return key, true
+			}
+		}
+		return "", false
+	}
+
+	populatePool(store, volatile)
+	for len(pool) > 0 {
+		entry := pool[len(pool)-1]
+		pool = pool[:len(pool)-1]
+		// The pool may hold keys deleted or made persistent since.
+		if data, found := store[entry.key]; found && (!volatile || data.ExpiryDate > 0) {
+			return entry.key, true
+		}
+	}
+	return "", false
+}
+
+// populatePool samples keys and adds them to the pool when they are
+// better candidates than the ones it has. Ranging over a map starts at a
+// random position, which makes the first keys visited a random sample.
+func populatePool(store map[string]model.StoredData, volatile bool) {
+	now := time.Now()
+	sampled := 0
+	for key, data := range store {
+		if volatile && data.ExpiryDate == 0 {
+			continue
+		}
+		addToPool(key, idleScore(data, now))
+		if sampled++; sampled == samples {
+			break
+		}
+	}
+}
+
+// idleScore ranks a key for the policy: the longer since it was used, the
+// less often it is used, or the sooner it expires, the higher the score.
+func idleScore(data model.StoredData, now time.Time) uint64 {
+	switch policy {
+	case AllKeysLFU, VolatileLFU:
+		return math.MaxUint8 - uint64(lfuDecrement(data, now))
+	case VolatileTTL:
+		return math.MaxUint64 - uint64(data.ExpiryDate)
+	default:
+		return uint64(now.UnixMilli() - data.AccessTime)
+	}
+}
+
+func addToPool(key string, idle uint64) {
+	if i := slices.IndexFunc(pool, func(e poolEntry) bool { return e.key == key }); i >= 0 {
+		pool = slices.Delete(pool, i, i+1)
+	}
+	i, _ := slices.BinarySearchFunc(pool, idle, func(e poolEntry, idle uint64) int {
+		return cmp.Compare(e.idle, idle)
+	})
+	if len(pool) == evictionPoolSize {
+		if i == 0 {
+			return
+		}
+		// Make room by dropping the worst candidate.
+		pool = slices.Delete(pool, 0, 1)
+		i--
+	}
+	pool = slices.Insert(pool, i, poolEntry{key, idle})
+}
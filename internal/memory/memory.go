@@ -0,0 +1,295 @@
+// Package memory accounts for the memory the store uses and enforces
+// maxmemory by evicting keys with one of the Redis eviction policies. The
+// size of every key is estimated once and recounted only when the key
+// changes, so knowing the memory used does not mean walking the store.
+package memory
+
+import (
+	"errors"
+	"redis-go-clone/internal/model"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Eviction policies, named like the maxmemory-policy values.
+const (
+	NoEviction     = "noeviction"
+	AllKeysLRU     = "allkeys-lru"
+	AllKeysLFU     = "allkeys-lfu"
+	AllKeysRandom  = "allkeys-random"
+	VolatileLRU    = "volatile-lru"
+	VolatileLFU    = "volatile-lfu"
+	VolatileRandom = "volatile-random"
+	VolatileTTL    = "volatile-ttl"
+)
+
+var policies = []string{
+	NoEviction, AllKeysLRU, AllKeysLFU, AllKeysRandom,
+	VolatileLRU, VolatileLFU, VolatileRandom, VolatileTTL,
+}
+
+// countSamples is how many elements of an aggregate value are looked at to
+// estimate its size.
+const countSamples = 16
+
+// maxMemory is read before every command, so it does not take mu.
+var maxMemory atomic.Int64
+
+var (
+	mu      sync.Mutex
+	policy  = NoEviction
+	samples = 5
+
+	// Keys are only counted once something needs the memory used; from
+	// then on sizes holds the estimated size of every key and changed the
+	// keys to recount.
+	counting bool
+	sizes    = make(map[string]keySize)
+	changed  = make(map[string]struct{})
+	counted  Usage
+
+	evictedKeys int64
+	// exceededSince is when the store went over maxmemory, zero while it
+	// is not, and exceededTime the time it spent over it before.
+	exceededSince time.Time
+	exceededTime  time.Duration
+)
+
+// MaxMemory returns the maxmemory parameter in bytes, 0 meaning no limit.
+func MaxMemory() string {
+	return strconv.FormatInt(maxMemory.Load(), 10)
+}
+
+// Limited reports whether maxmemory is set.
+func Limited() bool {
+	return maxMemory.Load() > 0
+}
+
+// SetMaxMemory sets maxmemory from a number of bytes, optionally followed
+// by a unit such as "kb", "mb" or "gb".
+func SetMaxMemory(value string) error {
+	bytes, err := parseBytes(value)
+	if err != nil {
+		return err
+	}
+	maxMemory.Store(bytes)
+	if bytes == 0 {
+		mu.Lock()
+		trackExceeded(false)
+		mu.Unlock()
+	}
+	return nil
+}
+
+// parseBytes parses a memory amount the way redis.conf does: k, m and g
+// are powers of 1000, kb, mb and gb powers of 1024.
+func parseBytes(value string) (int64, error) {
+	lower := strings.ToLower(value)
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix     string
+		multiplier int64
+	}{
+		{"gb", 1 << 30}, {"mb", 1 << 20}, {"kb", 1 << 10},
+		{"g", 1000 * 1000 * 1000}, {"m", 1000 * 1000}, {"k", 1000}, {"b", 1},
+	} {
+		if strings.HasSuffix(lower, unit.suffix) {
+			lower, multiplier = strings.TrimSuffix(lower, unit.suffix), unit.multiplier
+			break
+		}
+	}
+	n, err := strconv.ParseInt(lower, 10, 64)
+	if err != nil || n < 0 {
+		return 0, errors.New("argument must be a memory value")
+	}
+	return n * multiplier, nil
+}
+
+func Policy() string {
+	mu.Lock()
+	defer mu.Unlock()
+	return policy
+}
+
+func SetPolicy(value string) error {
+	value = strings.ToLower(value)
+	if !slices.Contains(policies, value) {
+		return errors.New("argument(s) must be one of the following: " + strings.Join(policies, ", "))
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	policy = value
+	pool = pool[:0]
+	return nil
+}
+
+// Samples returns maxmemory-samples, the number of keys looked at to pick
+// each key to evict.
+func Samples() string {
+	mu.Lock()
+	defer mu.Unlock()
+	return strconv.Itoa(samples)
+}
+
+func SetSamples(value string) error {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 || n > 64 {
+		return errors.New("argument must be between 1 and 64 inclusive")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	samples = n
+	return nil
+}
+
+// Reset forgets the keys counted so far, for a store that was replaced.
+func Reset() {
+	mu.Lock()
+	defer mu.Unlock()
+	counting = false
+	clear(sizes)
+	clear(changed)
+	counted = Usage{}
+	pool = pool[:0]
+
+	accessMu.Lock()
+	clear(accessed)
+	accessMu.Unlock()
+}
+
+// KeyChanged records that key was modified, created or deleted, so that
+// its size gets counted again.
+func KeyChanged(key string) {
+	mu.Lock()
+	defer mu.Unlock()
+	if counting {
+		changed[key] = struct{}{}
+	}
+}
+
+// keySize is what a key was counted to take, in total and in the main and
+// expires dicts.
+type keySize struct {
+	bytes, main, expires int64
+}
+
+// Usage is the memory counted for the keys of the store.
+type Usage struct {
+	Keys    int64
+	Expires int64
+	// Bytes is the total, of which MainOverhead and ExpiresOverhead are
+	// spent on the keyspace dicts rather than the values.
+	Bytes           int64
+	MainOverhead    int64
+	ExpiresOverhead int64
+}
+
+func (u *Usage) add(size keySize, sign int64) {
+	u.Keys += sign
+	if size.expires > 0 {
+		u.Expires += sign
+	}
+	u.Bytes += sign * size.bytes
+	u.MainOverhead += sign * size.main
+	u.ExpiresOverhead += sign * size.expires
+}
+
+// Used returns the estimated number of bytes the keys of store take. The
+// store lock must be held.
+func Used(store map[string]model.StoredData) int64 {
+	return Dataset(store).Bytes
+}
+
+// Dataset returns the memory counted for the keys of store. The store lock
+// must be held.
+func Dataset(store map[string]model.StoredData) Usage {
+	mu.Lock()
+	defer mu.Unlock()
+	count(store)
+	return counted
+}
+
+// OverLimit reports whether the store uses more than maxmemory. The store
+// lock must be held.
+func OverLimit(store map[string]model.StoredData) bool {
+	limit := maxMemory.Load()
+	over := limit > 0 && Used(store) > limit
+	mu.Lock()
+	defer mu.Unlock()
+	trackExceeded(over)
+	return over
+}
+
+// trackExceeded accounts for the time the store spends over maxmemory,
+// given whether it is now. mu must be held.
+func trackExceeded(over bool) {
+	switch {
+	case over && exceededSince.IsZero():
+		exceededSince = time.Now()
+	case !over && !exceededSince.IsZero():
+		exceededTime += time.Since(exceededSince)
+		exceededSince = time.Time{}
+	}
+}
+
+func sizeOf(key string, data model.StoredData) keySize {
+	main, expires := data.KeyspaceOverhead(key)
+	return keySize{
+		bytes:   int64(main + expires + data.ValueUsage(countSamples)),
+		main:    int64(main),
+		expires: int64(expires),
+	}
+}
+
+// count brings the counted usage up to date, counting all of store the
+// first time.
+func count(store map[string]model.StoredData) {
+	if !counting {
+		counting = true
+		clear(changed)
+		counted = Usage{}
+		for key, data := range store {
+			sizes[key] = sizeOf(key, data)
+			counted.add(sizes[key], 1)
+		}
+		return
+	}
+	for key := range changed {
+		if size, found := sizes[key]; found {
+			counted.add(size, -1)
+			delete(sizes, key)
+		}
+		if data, found := store[key]; found {
+			sizes[key] = sizeOf(key, data)
+			counted.add(sizes[key], 1)
+		}
+	}
+	clear(changed)
+}
+
+// Stats are the eviction counters reported by INFO.
+type Stats struct {
+	EvictedKeys int64
+	// ExceededTime is how long the store used more than maxmemory in all,
+	// and CurrentExceededTime how long it has been over it this time.
+	ExceededTime        time.Duration
+	CurrentExceededTime time.Duration
+}
+
+func GetStats() Stats {
+	mu.Lock()
+	defer mu.Unlock()
+	var current time.Duration
+	if !exceededSince.IsZero() {
+		current = time.Since(exceededSince)
+	}
+	return Stats{
+		EvictedKeys:         evictedKeys,
+		ExceededTime:        exceededTime + current,
+		CurrentExceededTime: current,
+	}
+}
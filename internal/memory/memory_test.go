@@ -0,0 +1,221 @@
+package memory
+
+import (
+	"redis-go-clone/internal/model"
+	"redis-go-clone/internal/stats"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func restoreConfig(t *testing.T) {
+	t.Cleanup(func() {
+		SetMaxMemory("0")
+		SetPolicy(NoEviction)
+		SetSamples("5")
+		Reset()
+	})
+}
+
+func TestSetMaxMemory(t *testing.T) {
+	restoreConfig(t)
+
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"0", "0", false},
+		{"1024", "1024", false},
+		{"100b", "100", false},
+		{"2k", "2000", false},
+		{"2kb", "2048", false},
+		{"3MB", "3145728", false},
+		{"1g", "1000000000", false},
+		{"1gb", "1073741824", false},
+		{"-1", "", true},
+		{"lots", "", true},
+		{"12tb", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			err := SetMaxMemory(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SetMaxMemory(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if !tt.wantErr && MaxMemory() != tt.want {
+				t.Errorf("MaxMemory() = %q, want %q", MaxMemory(), tt.want)
+			}
+		})
+	}
+
+	if err := SetPolicy("ALLKEYS-LRU"); err != nil || Policy() != AllKeysLRU {
+		t.Errorf("SetPolicy(ALLKEYS-LRU) = %v, policy %q", err, Policy())
+	}
+	if err := SetPolicy("lru"); err == nil {
+		t.Error("expected an error for an unknown policy")
+	}
+	if err := SetSamples("0"); err == nil {
+		t.Error("expected an error for 0 samples")
+	}
+}
+
+func TestUsed(t *testing.T) {
+	restoreConfig(t)
+	Reset()
+
+	store := map[string]model.StoredData{"a": {Value: "value"}}
+	initial := Used(store)
+	if want := int64(store["a"].MemoryUsage("a", countSamples)); initial != want {
+		t.Fatalf("Used() = %d, want %d", initial, want)
+	}
+
+	set := model.NewSet()
+	set.Add("member")
+	store["b"] = model.StoredData{Value: set}
+	KeyChanged("b")
+	if grown := Used(store); grown <= initial {
+		t.Errorf("Used() = %d after adding a key, want more than %d", grown, initial)
+	}
+
+	delete(store, "b")
+	KeyChanged("b")
+	if got := Used(store); got != initial {
+		t.Errorf("Used() = %d after deleting the key, want %d", got, initial)
+	}
+}
+
+func TestEvict(t *testing.T) {
+	restoreConfig(t)
+	now := time.Now()
+	ago := func(d time.Duration) int64 { return now.Add(-d).UnixMilli() }
+
+	tests := []struct {
+		name    string
+		policy  string
+		store   map[string]model.StoredData
+		want    bool
+		evicted string
+	}{
+		{
+			name:   "No eviction",
+			policy: NoEviction,
+			store:  map[string]model.StoredData{"a": {Value: "1"}, "b": {Value: "2"}},
+			want:   false,
+		},
+		{
+			name:   "All keys LRU",
+			policy: AllKeysLRU,
+			store: map[string]model.StoredData{
+				"recent": {Value: "1", AccessTime: ago(time.Second)},
+				"old":    {Value: "2", AccessTime: ago(time.Hour)},
+				"newer":  {Value: "3", AccessTime: ago(time.Minute)},
+			},
+			want:    true,
+			evicted: "old",
+		},
+		{
+			name:   "All keys LFU",
+			policy: AllKeysLFU,
+			store: map[string]model.StoredData{
+				"hot":  {Value: "1", AccessTime: ago(time.Second), AccessCount: 50},
+				"cold": {Value: "2", AccessTime: ago(time.Second), AccessCount: 6},
+				"warm": {Value: "3", AccessTime: ago(time.Second), AccessCount: 20},
+			},
+			want:    true,
+			evicted: "cold",
+		},
+		{
+			name:   "LFU counters decay",
+			policy: AllKeysLFU,
+			store: map[string]model.StoredData{
+				"stale": {Value: "1", AccessTime: ago(time.Hour), AccessCount: 50},
+				"warm":  {Value: "2", AccessTime: ago(time.Second), AccessCount: 20},
+			},
+			want:    true,
+			evicted: "stale",
+		},
+		{
+			name:   "Volatile LRU only evicts keys with a TTL",
+			policy: VolatileLRU,
+			store: map[string]model.StoredData{
+				"persistent": {Value: "1", AccessTime: ago(time.Hour)},
+				"volatile":   {Value: "2", AccessTime: ago(time.Second), ExpiryDate: now.Unix() + 100},
+			},
+			want:    true,
+			evicted: "volatile",
+		},
+		{
+			name:   "Volatile TTL",
+			policy: VolatileTTL,
+			store: map[string]model.StoredData{
+				"later":      {Value: "1", ExpiryDate: now.Unix() + 1000},
+				"soon":       {Value: "2", ExpiryDate: now.Unix() + 10},
+				"persistent": {Value: "3"},
+			},
+			want:    true,
+			evicted: "soon",
+		},
+		{
+			name:   "Volatile random without keys with a TTL",
+			policy: VolatileRandom,
+			store:  map[string]model.StoredData{"a": {Value: "1"}, "b": {Value: "2"}},
+			want:   false,
+		},
+		{
+			name:   "All keys random",
+			policy: AllKeysRandom,
+			store:  map[string]model.StoredData{"a": {Value: "1"}, "b": {Value: "2"}},
+			want:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			Reset()
+			SetPolicy(tt.policy)
+			SetSamples("64")
+			// One key has to go to get under the limit.
+			keys := len(tt.store)
+			SetMaxMemory(strconv.FormatInt(Used(tt.store)-1, 10))
+
+			before, changes := GetStats().EvictedKeys, stats.Get().Dirty
+			if got := Evict(tt.store); got != tt.want {
+				t.Fatalf("Evict() = %v, want %v", got, tt.want)
+			}
+			if !tt.want {
+				return
+			}
+			if len(tt.store) != keys-1 {
+				t.Errorf("expected one key to be evicted, %d keys left of %d", len(tt.store), keys)
+			}
+			if _, found := tt.store[tt.evicted]; tt.evicted != "" && found {
+				t.Errorf("expected %q to be evicted, store is %v", tt.evicted, tt.store)
+			}
+			if evicted := GetStats().EvictedKeys - before; evicted != 1 {
+				t.Errorf("evicted keys stat grew by %d, want 1", evicted)
+			}
+			if changed := stats.Get().Dirty - changes; changed != 1 {
+				t.Errorf("changes since the last save grew by %d, want 1", changed)
+			}
+		})
+	}
+}
+
+func TestAccess(t *testing.T) {
+	store := map[string]model.StoredData{"k": {Value: "v"}}
+	Access("k")
+	if data := store["k"]; data.AccessTime != 0 {
+		t.Errorf("expected Access not to write to the store, got %+v", data)
+	}
+	ApplyAccesses(store)
+	data := store["k"]
+	if data.AccessTime == 0 || data.AccessCount < lfuInitVal {
+		t.Errorf("unexpected access clocks %+v", data)
+	}
+
+	Access("missing")
+	ApplyAccesses(store)
+	if _, found := store["missing"]; found {
+		t.Error("ApplyAccesses created a key")
+	}
+}
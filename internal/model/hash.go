@@ -0,0 +1,82 @@
+package model
+
+import "encoding/json"
+
+// Hash is the value stored under a hash key. FieldExpiry holds the expiry
+// time of individual fields in Unix milliseconds; fields without a TTL have
+// no entry in it.
+type Hash struct {
+	Fields      map[string]string
+	FieldExpiry map[string]int64 `json:",omitempty"`
+}
+
+func NewHash() *Hash {
+	return &Hash{
+		Fields:      make(map[string]string),
+		FieldExpiry: make(map[string]int64),
+	}
+}
+
+// DeleteField removes a field together with its TTL.
+func (h *Hash) DeleteField(field string) {
+	delete(h.Fields, field)
+	delete(h.FieldExpiry, field)
+}
+
+// RemoveExpiredFields deletes every field whose TTL is at or before nowMs and
+// returns how many fields were removed.
+func (h *Hash) RemoveExpiredFields(nowMs int64) int {
+	removed := 0
+	for field, expiry := range h.FieldExpiry {
+		if expiry <= nowMs {
+			h.DeleteField(field)
+			removed++
+		}
+	}
+	return removed
+}
+
+type hashFieldJSON struct {
+	Field  binaryString
+	Value  binaryString
+	Expiry int64 `json:",omitempty"`
+}
+
+// hashJSON is the on-disk form of a hash. Entries keeps fields as a list,
+// since JSON object keys cannot hold binary data. Fields and FieldExpiry
+// are how snapshots stored hashes before, and still load.
+type hashJSON struct {
+	Entries     []hashFieldJSON
+	Fields      map[string]string `json:",omitempty"`
+	FieldExpiry map[string]int64  `json:",omitempty"`
+}
+
+func (h *Hash) MarshalJSON() ([]byte, error) {
+	out := hashJSON{Entries: make([]hashFieldJSON, 0, len(h.Fields))}
+	for field, value := range h.Fields {
+		out.Entries = append(out.Entries, hashFieldJSON{binaryString(field), binaryString(value), h.FieldExpiry[field]})
+	}
+	return json.Marshal(out)
+}
+
+func (h *Hash) UnmarshalJSON(data []byte) error {
+	var in hashJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+
+	*h = *NewHash()
+	for field, value := range in.Fields {
+		h.Fields[field] = value
+	}
+	for field, expiry := range in.FieldExpiry {
+		h.FieldExpiry[field] = expiry
+	}
+	for _, entry := range in.Entries {
+		h.Fields[string(entry.Field)] = string(entry.Value)
+		if entry.Expiry != 0 {
+			h.FieldExpiry[string(entry.Field)] = entry.Expiry
+		}
+	}
+	return nil
+}
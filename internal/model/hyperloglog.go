@@ -0,0 +1,413 @@
+package model
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// HyperLogLog parameters and encodings, identical to hyperloglog.c in Redis
+// so that values can be exchanged with a real server through GET/SET.
+const (
+	HLLP         = 14
+	HLLQ         = 64 - HLLP
+	HLLRegisters = 1 << HLLP
+	hllBits      = 6
+	hllRegMax    = 1<<hllBits - 1
+	hllHdrSize   = 16
+	hllDenseSize = hllHdrSize + (HLLRegisters*hllBits+7)/8
+	hllAlphaInf  = 0.721347520444481703680
+
+	hllEncodingDense  = 0
+	hllEncodingSparse = 1
+
+	hllSparseXZeroBit      = 0x40
+	hllSparseValBit        = 0x80
+	hllSparseValMaxValue   = 32
+	hllSparseValMaxLen     = 4
+	hllSparseZeroMaxLen    = 64
+	hllSparseXZeroMaxLen   = 16384
+	hllHashSeed            = 0xadc83b19
+	hllCardCacheInvalidBit = 1 << 7
+)
+
+// HLLSparseMaxBytes is the size above which a sparse HyperLogLog is
+// converted to the dense encoding (hll-sparse-max-bytes in Redis).
+var HLLSparseMaxBytes = 3000
+
+var (
+	ErrNotHyperLogLog     = errors.New("not a valid HyperLogLog string value")
+	ErrCorruptHyperLogLog = errors.New("corrupted HLL object detected")
+)
+
+// HyperLogLog is the decoded form of a HyperLogLog string. The value stored
+// in the database stays the Redis byte representation produced by Bytes.
+type HyperLogLog struct {
+	Registers [HLLRegisters]uint8
+	Dense     bool
+
+	cardinality uint64
+	cacheValid  bool
+}
+
+// NewHyperLogLog returns an empty sparse HyperLogLog with a valid cached
+// cardinality of zero, like a freshly created key in Redis.
+func NewHyperLogLog() *HyperLogLog {
+	return &HyperLogLog{cacheValid: true}
+}
+
+// IsHyperLogLog performs the same header checks as isHLLObjectOrReply.
+func IsHyperLogLog(s string) bool {
+	if len(s) < hllHdrSize || s[:4] != "HYLL" {
+		return false
+	}
+	switch s[4] {
+	case hllEncodingDense:
+		return len(s) == hllDenseSize
+	case hllEncodingSparse:
+		return true
+	default:
+		return false
+	}
+}
+
+// ParseHyperLogLog decodes a HyperLogLog string in either encoding.
+func ParseHyperLogLog(s string) (*HyperLogLog, error) {
+	if !IsHyperLogLog(s) {
+		return nil, ErrNotHyperLogLog
+	}
+
+	h := &HyperLogLog{Dense: s[4] == hllEncodingDense}
+	card := []byte(s[8:16])
+	h.cacheValid = card[7]&hllCardCacheInvalidBit == 0
+	h.cardinality = binary.LittleEndian.Uint64(card)
+
+	registers := s[hllHdrSize:]
+	if h.Dense {
+		for i := range h.Registers {
+			h.Registers[i] = denseGetRegister(registers, i)
+		}
+		return h, nil
+	}
+
+	idx := 0
+	err := walkSparse(registers, func(value uint8, runLen int) {
+		for j := 0; j < runLen && idx < HLLRegisters; j++ {
+			h.Registers[idx] = value
+			idx++
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// walkSparse calls fn for every opcode of a sparse representation and
+// checks that the runs cover exactly HLLRegisters registers.
+func walkSparse(data string, fn func(value uint8, runLen int)) error {
+	total := 0
+	for i := 0; i < len(data); {
+		op := data[i]
+		switch {
+		case op&0xc0 == 0: // ZERO: 00xxxxxx
+			runLen := int(op&0x3f) + 1
+			fn(0, runLen)
+			total += runLen
+			i++
+		case op&0xc0 == hllSparseXZeroBit: // XZERO: 01xxxxxx yyyyyyyy
+			if i+1 >= len(data) {
+				return ErrCorruptHyperLogLog
+			}
+			runLen := (int(op&0x3f)<<8 | int(data[i+1])) + 1
+			fn(0, runLen)
+			total += runLen
+			i += 2
+		default: // VAL: 1vvvvvxx
+			runLen := int(op&0x3) + 1
+			fn((op>>2)&0x1f+1, runLen)
+			total += runLen
+			i++
+		}
+	}
+	if total != HLLRegisters {
+		return ErrCorruptHyperLogLog
+	}
+	return nil
+}
+
+// DecodeSparse renders the opcodes of a sparse HyperLogLog the way
+// PFDEBUG DECODE does.
+func DecodeSparse(s string) (string, error) {
+	if !IsHyperLogLog(s) {
+		return "", ErrNotHyperLogLog
+	}
+	if s[4] != hllEncodingSparse {
+		return "", errors.New("HLL encoding is not sparse")
+	}
+
+	var parts []string
+	i := 0
+	data := s[hllHdrSize:]
+	err := walkSparse(data, func(value uint8, runLen int) {
+		switch {
+		case value != 0:
+			parts = append(parts, "v:"+strconv.Itoa(int(value))+","+strconv.Itoa(runLen))
+			i++
+		case data[i]&0xc0 == hllSparseXZeroBit:
+			parts = append(parts, "Z:"+strconv.Itoa(runLen))
+			i += 2
+		default:
+			parts = append(parts, "z:"+strconv.Itoa(runLen))
+			i++
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(parts, " "), nil
+}
+
+// Add hashes element into its register and reports whether the register
+// changed, which invalidates the cached cardinality.
+func (h *HyperLogLog) Add(element string) bool {
+	index, count := hllPatLen(element)
+	return h.SetRegister(index, count)
+}
+
+// SetRegister raises register index to value if it is lower.
+func (h *HyperLogLog) SetRegister(index int, value uint8) bool {
+	if h.Registers[index] >= value {
+		return false
+	}
+	h.Registers[index] = value
+	h.cacheValid = false
+	return true
+}
+
+// Merge keeps the maximum of each register of h and other.
+func (h *HyperLogLog) Merge(other *HyperLogLog) {
+	for i, value := range other.Registers {
+		h.SetRegister(i, value)
+	}
+	if other.Dense {
+		h.Dense = true
+	}
+}
+
+// InvalidateCache forces the next Count to recompute the estimate.
+func (h *HyperLogLog) InvalidateCache() {
+	h.cacheValid = false
+}
+
+// Count returns the estimated cardinality, using and refreshing the cache.
+func (h *HyperLogLog) Count() uint64 {
+	if !h.cacheValid {
+		h.cardinality = h.estimate()
+		h.cacheValid = true
+	}
+	return h.cardinality
+}
+
+// estimate implements the improved estimator from Otmar Ertl's paper that
+// Redis has used since 5.0.
+func (h *HyperLogLog) estimate() uint64 {
+	var histogram [HLLQ + 2]int
+	for _, value := range h.Registers {
+		histogram[value]++
+	}
+
+	m := float64(HLLRegisters)
+	z := m * hllTau((m-float64(histogram[HLLQ+1]))/m)
+	for j := HLLQ; j >= 1; j-- {
+		z += float64(histogram[j])
+		z *= 0.5
+	}
+	z += m * hllSigma(float64(histogram[0])/m)
+	return uint64(math.Round(hllAlphaInf * m * m / z))
+}
+
+func hllSigma(x float64) float64 {
+	if x == 1 {
+		return math.Inf(1)
+	}
+	y := 1.0
+	z := x
+	for {
+		x *= x
+		zPrime := z
+		z += x * y
+		y += y
+		if zPrime == z {
+			return z
+		}
+	}
+}
+
+func hllTau(x float64) float64 {
+	if x == 0 || x == 1 {
+		return 0
+	}
+	y := 1.0
+	z := 1 - x
+	for {
+		x = math.Sqrt(x)
+		zPrime := z
+		y *= 0.5
+		z -= math.Pow(1-x, 2) * y
+		if zPrime == z {
+			return z / 3
+		}
+	}
+}
+
+// Bytes encodes h in the Redis representation. A sparse HyperLogLog is
+// promoted to dense once a register exceeds what the sparse encoding can
+// hold or the encoding grows past HLLSparseMaxBytes.
+func (h *HyperLogLog) Bytes() string {
+	if !h.Dense {
+		if sparse, ok := h.encodeSparse(); ok {
+			return h.header(hllEncodingSparse) + sparse
+		}
+		h.Dense = true
+	}
+
+	registers := make([]byte, hllDenseSize-hllHdrSize)
+	for i, value := range h.Registers {
+		denseSetRegister(registers, i, value)
+	}
+	return h.header(hllEncodingDense) + string(registers)
+}
+
+func (h *HyperLogLog) header(encoding byte) string {
+	hdr := make([]byte, hllHdrSize)
+	copy(hdr, "HYLL")
+	hdr[4] = encoding
+	binary.LittleEndian.PutUint64(hdr[8:], h.cardinality)
+	if !h.cacheValid {
+		hdr[15] |= hllCardCacheInvalidBit
+	}
+	return string(hdr)
+}
+
+func (h *HyperLogLog) encodeSparse() (string, bool) {
+	var out []byte
+	for i := 0; i < HLLRegisters; {
+		value := h.Registers[i]
+		runLen := 1
+		for i+runLen < HLLRegisters && h.Registers[i+runLen] == value {
+			runLen++
+		}
+		i += runLen
+
+		if value > hllSparseValMaxValue {
+			return "", false
+		}
+		for runLen > 0 {
+			switch {
+			case value != 0:
+				n := min(runLen, hllSparseValMaxLen)
+				out = append(out, hllSparseValBit|(value-1)<<2|byte(n-1))
+				runLen -= n
+			case runLen > hllSparseZeroMaxLen:
+				n := min(runLen, hllSparseXZeroMaxLen)
+				out = append(out, hllSparseXZeroBit|byte((n-1)>>8), byte((n-1)&0xff))
+				runLen -= n
+			default:
+				out = append(out, byte(runLen-1))
+				runLen = 0
+			}
+		}
+	}
+
+	if hllHdrSize+len(out) > HLLSparseMaxBytes {
+		return "", false
+	}
+	return string(out), true
+}
+
+func denseGetRegister(registers string, index int) uint8 {
+	byteIdx := index * hllBits / 8
+	fb := uint(index * hllBits & 7)
+	b0 := registers[byteIdx]
+	var b1 byte
+	if byteIdx+1 < len(registers) {
+		b1 = registers[byteIdx+1]
+	}
+	return (b0>>fb | b1<<(8-fb)) & hllRegMax
+}
+
+func denseSetRegister(registers []byte, index int, value uint8) {
+	byteIdx := index * hllBits / 8
+	fb := uint(index * hllBits & 7)
+	registers[byteIdx] &^= hllRegMax << fb
+	registers[byteIdx] |= value << fb
+	if byteIdx+1 < len(registers) {
+		registers[byteIdx+1] &^= hllRegMax >> (8 - fb)
+		registers[byteIdx+1] |= value >> (8 - fb)
+	}
+}
+
+// hllPatLen returns the register index for element and the length of the
+// 000..1 pattern of the remaining hash bits.
+func hllPatLen(element string) (int, uint8) {
+	hash := murmurHash64A([]byte(element), hllHashSeed)
+	index := int(hash & (HLLRegisters - 1))
+	hash >>= HLLP
+	hash |= 1 << HLLQ
+
+	count := uint8(1)
+	for bit := uint64(1); hash&bit == 0; bit <<= 1 {
+		count++
+	}
+	return index, count
+}
+
+// murmurHash64A is the 64-bit MurmurHash2 variant used by Redis.
+func murmurHash64A(key []byte, seed uint64) uint64 {
+	const m = 0xc6a4a7935bd1e995
+	const r = 47
+
+	h := seed ^ uint64(len(key))*m
+	blocks := len(key) / 8
+	for i := 0; i < blocks; i++ {
+		k := binary.LittleEndian.Uint64(key[i*8:])
+		k *= m
+		k ^= k >> r
+		k *= m
+		h ^= k
+		h *= m
+	}
+
+	tail := key[blocks*8:]
+	switch len(tail) {
+	case 7:
+		h ^= uint64(tail[6]) << 48
+		fallthrough
+	case 6:
+		h ^= uint64(tail[5]) << 40
+		fallthrough
+	case 5:
+		h ^= uint64(tail[4]) << 32
+		fallthrough
+	case 4:
+		h ^= uint64(tail[3]) << 24
+		fallthrough
+	case 3:
+		h ^= uint64(tail[2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint64(tail[1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint64(tail[0])
+		h *= m
+	}
+
+	h ^= h >> r
+	h *= m
+	h ^= h >> r
+	return h
+}
@@ -0,0 +1,151 @@
+package model
+
+// Approximate sizes, in bytes, of the structures Redis keeps values in on a
+// 64-bit build. They are used to estimate the memory a key takes.
+const (
+	robjOverhead           = 16
+	sdsOverhead            = 9
+	dictOverhead           = 56
+	dictEntryOverhead      = 24
+	dictBucketOverhead     = 8
+	listEntryOverhead      = 11
+	skiplistNodeOverhead   = 40
+	streamOverhead         = 80
+	streamNodeOverhead     = 48
+	streamGroupOverhead    = 96
+	streamNACKOverhead     = 56
+	streamConsumerOverhead = 64
+)
+
+// MemoryUsage estimates the bytes key takes with its value: the keyspace
+// entry, the key name, the value and its expires entry. Aggregate values
+// only look at samples of their elements and extrapolate; samples <= 0
+// looks at all of them.
+func (s StoredData) MemoryUsage(key string, samples int) int {
+	main, expires := s.KeyspaceOverhead(key)
+	return main + expires + s.ValueUsage(samples)
+}
+
+// KeyspaceOverhead returns the bytes spent on key outside of its value:
+// its entry in the main dict with the key name, and its entry in the
+// expires dict if it has a TTL.
+func (s StoredData) KeyspaceOverhead(key string) (main, expires int) {
+	main = dictEntryOverhead + dictBucketOverhead + robjOverhead + len(key) + sdsOverhead
+	if s.ExpiryDate > 0 {
+		expires = dictEntryOverhead + dictBucketOverhead
+	}
+	return main, expires
+}
+
+// ValueUsage estimates the bytes the value takes, from samples of its
+// elements like MemoryUsage.
+func (s StoredData) ValueUsage(samples int) int {
+	size := 0
+	switch v := s.Value.(type) {
+	case string:
+		size += len(v) + sdsOverhead
+	case []any:
+		size += dictOverhead + sampled(len(v), samples, func(visit func(int) bool) {
+			for _, element := range v {
+				if !visit(listEntryOverhead + elementSize(element)) {
+					return
+				}
+			}
+		})
+	case *Hash:
+		size += v.MemoryUsage(samples)
+	case *Set:
+		size += v.MemoryUsage(samples)
+	case *SortedSet:
+		size += v.MemoryUsage(samples)
+	case *Stream:
+		size += v.MemoryUsage(samples)
+	default:
+		// Integers and floats are stored in the object itself.
+		size += 8
+	}
+	return size
+}
+
+func elementSize(element any) int {
+	if s, ok := element.(string); ok {
+		return len(s)
+	}
+	return 8
+}
+
+// sampled extrapolates the size of n elements from the ones each calls
+// visit with, up to samples of them.
+func sampled(n, samples int, each func(visit func(size int) bool)) int {
+	if n == 0 {
+		return 0
+	}
+	total, seen := 0, 0
+	each(func(size int) bool {
+		total += size
+		seen++
+		return samples <= 0 || seen < samples
+	})
+	if seen == 0 {
+		return 0
+	}
+	return total * n / seen
+}
+
+func (h *Hash) MemoryUsage(samples int) int {
+	size := dictOverhead + sampled(len(h.Fields), samples, func(visit func(int) bool) {
+		for field, value := range h.Fields {
+			if !visit(dictEntryOverhead + dictBucketOverhead + len(field) + len(value) + 2*sdsOverhead) {
+				return
+			}
+		}
+	})
+	if len(h.FieldExpiry) > 0 {
+		size += dictOverhead + len(h.FieldExpiry)*(dictEntryOverhead+dictBucketOverhead+8)
+	}
+	return size
+}
+
+func (s *Set) MemoryUsage(samples int) int {
+	if s.members == nil {
+		return 8 + 8*len(s.intset)
+	}
+	return dictOverhead + sampled(len(s.members), samples, func(visit func(int) bool) {
+		for member := range s.members {
+			if !visit(dictEntryOverhead + dictBucketOverhead + len(member) + sdsOverhead) {
+				return
+			}
+		}
+	})
+}
+
+func (z *SortedSet) MemoryUsage(samples int) int {
+	return dictOverhead + skiplistNodeOverhead + sampled(len(z.dict), samples, func(visit func(int) bool) {
+		for member := range z.dict {
+			if !visit(dictEntryOverhead + dictBucketOverhead + skiplistNodeOverhead + len(member) + sdsOverhead) {
+				return
+			}
+		}
+	})
+}
+
+func (s *Stream) MemoryUsage(samples int) int {
+	size := streamOverhead + sampled(len(s.nodes), samples, func(visit func(int) bool) {
+		for _, node := range s.nodes {
+			nodeSize := streamNodeOverhead + cap(node.data)
+			for _, field := range node.masterFields {
+				nodeSize += len(field) + listEntryOverhead
+			}
+			if !visit(nodeSize) {
+				return
+			}
+		}
+	})
+	for _, group := range s.groups {
+		size += streamGroupOverhead + len(group.Name) + len(group.pending)*streamNACKOverhead
+		for name := range group.Consumers {
+			size += streamConsumerOverhead + len(name) + sdsOverhead
+		}
+	}
+	return size
+}
@@ -0,0 +1,151 @@
+package model
+
+import (
+	"encoding/json"
+	"slices"
+	"strconv"
+)
+
+// SetMaxIntsetEntries is the largest number of members a set may hold while
+// still using the compact intset encoding.
+const SetMaxIntsetEntries = 512
+
+// Set is the value stored under a set key. Small sets whose members are all
+// integers are kept in a sorted []int64 (the "intset" encoding); once a
+// non-integer member is added or the set grows past SetMaxIntsetEntries it is
+// converted to a hash table.
+type Set struct {
+	intset  []int64
+	members map[string]struct{}
+}
+
+func NewSet() *Set {
+	return &Set{intset: []int64{}}
+}
+
+// Encoding returns "intset" or "hashtable", matching OBJECT ENCODING in Redis.
+func (s *Set) Encoding() string {
+	if s.members == nil {
+		return "intset"
+	}
+	return "hashtable"
+}
+
+func (s *Set) Len() int {
+	if s.members == nil {
+		return len(s.intset)
+	}
+	return len(s.members)
+}
+
+// Add inserts member and reports whether it was not already present.
+func (s *Set) Add(member string) bool {
+	if s.members == nil {
+		if n, ok := parseIntsetMember(member); ok {
+			idx, found := slices.BinarySearch(s.intset, n)
+			if found {
+				return false
+			}
+			if len(s.intset) < SetMaxIntsetEntries {
+				s.intset = slices.Insert(s.intset, idx, n)
+				return true
+			}
+		}
+		s.convertToHashtable()
+	}
+
+	if _, found := s.members[member]; found {
+		return false
+	}
+	s.members[member] = struct{}{}
+	return true
+}
+
+// Remove deletes member and reports whether it was present.
+func (s *Set) Remove(member string) bool {
+	if s.members == nil {
+		n, ok := parseIntsetMember(member)
+		if !ok {
+			return false
+		}
+		idx, found := slices.BinarySearch(s.intset, n)
+		if !found {
+			return false
+		}
+		s.intset = slices.Delete(s.intset, idx, idx+1)
+		return true
+	}
+
+	if _, found := s.members[member]; !found {
+		return false
+	}
+	delete(s.members, member)
+	return true
+}
+
+func (s *Set) Contains(member string) bool {
+	if s.members == nil {
+		n, ok := parseIntsetMember(member)
+		if !ok {
+			return false
+		}
+		_, found := slices.BinarySearch(s.intset, n)
+		return found
+	}
+
+	_, found := s.members[member]
+	return found
+}
+
+// Members returns every member. Intset members come back in ascending order;
+// hash table members come back in map order.
+func (s *Set) Members() []string {
+	result := make([]string, 0, s.Len())
+	if s.members == nil {
+		for _, n := range s.intset {
+			result = append(result, strconv.FormatInt(n, 10))
+		}
+		return result
+	}
+
+	for member := range s.members {
+		result = append(result, member)
+	}
+	return result
+}
+
+func (s *Set) convertToHashtable() {
+	s.members = make(map[string]struct{}, len(s.intset))
+	for _, n := range s.intset {
+		s.members[strconv.FormatInt(n, 10)] = struct{}{}
+	}
+	s.intset = nil
+}
+
+// MarshalJSON stores a set as a plain list of members.
+func (s *Set) MarshalJSON() ([]byte, error) {
+	return json.Marshal(toBinaryStrings(s.Members()))
+}
+
+func (s *Set) UnmarshalJSON(data []byte) error {
+	var members []binaryString
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+
+	*s = *NewSet()
+	for _, member := range members {
+		s.Add(string(member))
+	}
+	return nil
+}
+
+// parseIntsetMember only accepts the canonical decimal form, so "007" or
+// "+1" stay distinct members as they are in Redis.
+func parseIntsetMember(member string) (int64, bool) {
+	n, err := strconv.ParseInt(member, 10, 64)
+	if err != nil || strconv.FormatInt(n, 10) != member {
+		return 0, false
+	}
+	return n, true
+}
@@ -0,0 +1,524 @@
+package model
+
+import (
+	"encoding/json"
+	"math/rand/v2"
+	"strconv"
+)
+
+const (
+	skiplistMaxLevel = 32
+	skiplistP        = 0.25
+)
+
+// ZMember is a member of a sorted set together with its score.
+type ZMember struct {
+	Member string
+	Score  float64
+}
+
+// ScoreRange describes an interval of scores such as "(1 5" where either end
+// may be exclusive.
+type ScoreRange struct {
+	Min, Max     float64
+	MinEx, MaxEx bool
+}
+
+func (r ScoreRange) gteMin(score float64) bool {
+	if r.MinEx {
+		return score > r.Min
+	}
+	return score >= r.Min
+}
+
+func (r ScoreRange) lteMax(score float64) bool {
+	if r.MaxEx {
+		return score < r.Max
+	}
+	return score <= r.Max
+}
+
+func (r ScoreRange) isEmpty() bool {
+	return r.Min > r.Max || (r.Min == r.Max && (r.MinEx || r.MaxEx))
+}
+
+// LexRange describes an interval of members for the BYLEX commands. The
+// MinInf/MaxInf flags stand for the "-" and "+" bounds.
+type LexRange struct {
+	Min, Max       string
+	MinEx, MaxEx   bool
+	MinInf, MaxInf bool
+}
+
+func (r LexRange) gteMin(member string) bool {
+	switch {
+	case r.MinInf:
+		return true
+	case r.MinEx:
+		return member > r.Min
+	default:
+		return member >= r.Min
+	}
+}
+
+func (r LexRange) lteMax(member string) bool {
+	switch {
+	case r.MaxInf:
+		return true
+	case r.MaxEx:
+		return member < r.Max
+	default:
+		return member <= r.Max
+	}
+}
+
+func (r LexRange) isEmpty() bool {
+	if r.MinInf || r.MaxInf {
+		return false
+	}
+	return r.Min > r.Max || (r.Min == r.Max && (r.MinEx || r.MaxEx))
+}
+
+type skiplistLevel struct {
+	forward *skiplistNode
+	span    int
+}
+
+type skiplistNode struct {
+	member   string
+	score    float64
+	backward *skiplistNode
+	level    []skiplistLevel
+}
+
+// skiplist orders members by score and then lexicographically by member,
+// like zskiplist in Redis. Spans make rank lookups O(log N).
+type skiplist struct {
+	header *skiplistNode
+	tail   *skiplistNode
+	length int
+	level  int
+}
+
+func newSkiplist() *skiplist {
+	return &skiplist{
+		header: &skiplistNode{level: make([]skiplistLevel, skiplistMaxLevel)},
+		level:  1,
+	}
+}
+
+func randomLevel() int {
+	level := 1
+	for level < skiplistMaxLevel && rand.Float64() < skiplistP {
+		level++
+	}
+	return level
+}
+
+func nodeLess(node *skiplistNode, score float64, member string) bool {
+	return node.score < score || (node.score == score && node.member < member)
+}
+
+func (zsl *skiplist) insert(score float64, member string) {
+	var update [skiplistMaxLevel]*skiplistNode
+	var rank [skiplistMaxLevel]int
+
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		if i == zsl.level-1 {
+			rank[i] = 0
+		} else {
+			rank[i] = rank[i+1]
+		}
+		for x.level[i].forward != nil && nodeLess(x.level[i].forward, score, member) {
+			rank[i] += x.level[i].span
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+
+	level := randomLevel()
+	if level > zsl.level {
+		for i := zsl.level; i < level; i++ {
+			rank[i] = 0
+			update[i] = zsl.header
+			update[i].level[i].span = zsl.length
+		}
+		zsl.level = level
+	}
+
+	x = &skiplistNode{member: member, score: score, level: make([]skiplistLevel, level)}
+	for i := 0; i < level; i++ {
+		x.level[i].forward = update[i].level[i].forward
+		update[i].level[i].forward = x
+		x.level[i].span = update[i].level[i].span - (rank[0] - rank[i])
+		update[i].level[i].span = rank[0] - rank[i] + 1
+	}
+	for i := level; i < zsl.level; i++ {
+		update[i].level[i].span++
+	}
+
+	if update[0] != zsl.header {
+		x.backward = update[0]
+	}
+	if x.level[0].forward != nil {
+		x.level[0].forward.backward = x
+	} else {
+		zsl.tail = x
+	}
+	zsl.length++
+}
+
+func (zsl *skiplist) delete(score float64, member string) bool {
+	var update [skiplistMaxLevel]*skiplistNode
+
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && nodeLess(x.level[i].forward, score, member) {
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+
+	x = x.level[0].forward
+	if x == nil || x.score != score || x.member != member {
+		return false
+	}
+	zsl.deleteNode(x, update[:])
+	return true
+}
+
+func (zsl *skiplist) deleteNode(x *skiplistNode, update []*skiplistNode) {
+	for i := 0; i < zsl.level; i++ {
+		if update[i].level[i].forward == x {
+			update[i].level[i].span += x.level[i].span - 1
+			update[i].level[i].forward = x.level[i].forward
+		} else {
+			update[i].level[i].span--
+		}
+	}
+	if x.level[0].forward != nil {
+		x.level[0].forward.backward = x.backward
+	} else {
+		zsl.tail = x.backward
+	}
+	for zsl.level > 1 && zsl.header.level[zsl.level-1].forward == nil {
+		zsl.level--
+	}
+	zsl.length--
+}
+
+// rank returns the 1-based rank of the element, or 0 if it is not found.
+func (zsl *skiplist) rank(score float64, member string) int {
+	rank := 0
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil &&
+			(nodeLess(x.level[i].forward, score, member) ||
+				(x.level[i].forward.score == score && x.level[i].forward.member == member)) {
+			rank += x.level[i].span
+			x = x.level[i].forward
+		}
+		if x != zsl.header && x.member == member {
+			return rank
+		}
+	}
+	return 0
+}
+
+// byRank returns the node at the given 1-based rank.
+func (zsl *skiplist) byRank(rank int) *skiplistNode {
+	traversed := 0
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && traversed+x.level[i].span <= rank {
+			traversed += x.level[i].span
+			x = x.level[i].forward
+		}
+		if traversed == rank {
+			return x
+		}
+	}
+	return nil
+}
+
+func (zsl *skiplist) firstInScoreRange(r ScoreRange) *skiplistNode {
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && !r.gteMin(x.level[i].forward.score) {
+			x = x.level[i].forward
+		}
+	}
+	x = x.level[0].forward
+	if x == nil || !r.lteMax(x.score) {
+		return nil
+	}
+	return x
+}
+
+func (zsl *skiplist) lastInScoreRange(r ScoreRange) *skiplistNode {
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && r.lteMax(x.level[i].forward.score) {
+			x = x.level[i].forward
+		}
+	}
+	if x == zsl.header || !r.gteMin(x.score) {
+		return nil
+	}
+	return x
+}
+
+func (zsl *skiplist) firstInLexRange(r LexRange) *skiplistNode {
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && !r.gteMin(x.level[i].forward.member) {
+			x = x.level[i].forward
+		}
+	}
+	x = x.level[0].forward
+	if x == nil || !r.lteMax(x.member) {
+		return nil
+	}
+	return x
+}
+
+func (zsl *skiplist) lastInLexRange(r LexRange) *skiplistNode {
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && r.lteMax(x.level[i].forward.member) {
+			x = x.level[i].forward
+		}
+	}
+	if x == zsl.header || !r.gteMin(x.member) {
+		return nil
+	}
+	return x
+}
+
+// SortedSet is the value stored under a sorted set key. The dict gives O(1)
+// score lookups and the skiplist keeps members ordered for range queries.
+type SortedSet struct {
+	dict map[string]float64
+	zsl  *skiplist
+}
+
+func NewSortedSet() *SortedSet {
+	return &SortedSet{
+		dict: make(map[string]float64),
+		zsl:  newSkiplist(),
+	}
+}
+
+func (z *SortedSet) Len() int {
+	return len(z.dict)
+}
+
+func (z *SortedSet) Score(member string) (float64, bool) {
+	score, found := z.dict[member]
+	return score, found
+}
+
+// Add sets the score of member, inserting it if needed. It reports whether
+// the member is new.
+func (z *SortedSet) Add(member string, score float64) bool {
+	current, found := z.dict[member]
+	if found {
+		if current != score {
+			z.zsl.delete(current, member)
+			z.zsl.insert(score, member)
+			z.dict[member] = score
+		}
+		return false
+	}
+
+	z.zsl.insert(score, member)
+	z.dict[member] = score
+	return true
+}
+
+// Remove deletes member and reports whether it was present.
+func (z *SortedSet) Remove(member string) bool {
+	score, found := z.dict[member]
+	if !found {
+		return false
+	}
+	z.zsl.delete(score, member)
+	delete(z.dict, member)
+	return true
+}
+
+// Rank returns the 0-based rank of member in ascending order, or in
+// descending order when reverse is set.
+func (z *SortedSet) Rank(member string, reverse bool) (int, bool) {
+	score, found := z.dict[member]
+	if !found {
+		return 0, false
+	}
+	rank := z.zsl.rank(score, member)
+	if reverse {
+		return z.zsl.length - rank, true
+	}
+	return rank - 1, true
+}
+
+// RangeByRank returns the members between the 0-based ranks start and end,
+// both inclusive and already clamped to the set size.
+func (z *SortedSet) RangeByRank(start, end int, reverse bool) []ZMember {
+	if start > end || start >= z.zsl.length {
+		return nil
+	}
+
+	result := make([]ZMember, 0, end-start+1)
+	var x *skiplistNode
+	if reverse {
+		x = z.zsl.byRank(z.zsl.length - start)
+	} else {
+		x = z.zsl.byRank(start + 1)
+	}
+	for i := start; i <= end && x != nil; i++ {
+		result = append(result, ZMember{Member: x.member, Score: x.score})
+		if reverse {
+			x = x.backward
+		} else {
+			x = x.level[0].forward
+		}
+	}
+	return result
+}
+
+// RangeByScore returns the members inside r, skipping offset matches and
+// returning at most limit of them (a negative limit means no limit).
+func (z *SortedSet) RangeByScore(r ScoreRange, reverse bool, offset, limit int) []ZMember {
+	if r.isEmpty() {
+		return nil
+	}
+
+	var x *skiplistNode
+	if reverse {
+		x = z.zsl.lastInScoreRange(r)
+	} else {
+		x = z.zsl.firstInScoreRange(r)
+	}
+
+	var result []ZMember
+	for x != nil && limit != 0 {
+		if reverse && !r.gteMin(x.score) || !reverse && !r.lteMax(x.score) {
+			break
+		}
+		if offset > 0 {
+			offset--
+		} else {
+			result = append(result, ZMember{Member: x.member, Score: x.score})
+			limit--
+		}
+		if reverse {
+			x = x.backward
+		} else {
+			x = x.level[0].forward
+		}
+	}
+	return result
+}
+
+// RangeByLex works like RangeByScore for lexicographical ranges. It is only
+// meaningful when every member has the same score.
+func (z *SortedSet) RangeByLex(r LexRange, reverse bool, offset, limit int) []ZMember {
+	if r.isEmpty() {
+		return nil
+	}
+
+	var x *skiplistNode
+	if reverse {
+		x = z.zsl.lastInLexRange(r)
+	} else {
+		x = z.zsl.firstInLexRange(r)
+	}
+
+	var result []ZMember
+	for x != nil && limit != 0 {
+		if reverse && !r.gteMin(x.member) || !reverse && !r.lteMax(x.member) {
+			break
+		}
+		if offset > 0 {
+			offset--
+		} else {
+			result = append(result, ZMember{Member: x.member, Score: x.score})
+			limit--
+		}
+		if reverse {
+			x = x.backward
+		} else {
+			x = x.level[0].forward
+		}
+	}
+	return result
+}
+
+// CountInScoreRange counts members inside r using ranks, without walking
+// the range.
+func (z *SortedSet) CountInScoreRange(r ScoreRange) int {
+	if r.isEmpty() {
+		return 0
+	}
+	first := z.zsl.firstInScoreRange(r)
+	if first == nil {
+		return 0
+	}
+	last := z.zsl.lastInScoreRange(r)
+	return z.zsl.rank(last.score, last.member) - z.zsl.rank(first.score, first.member) + 1
+}
+
+func (z *SortedSet) CountInLexRange(r LexRange) int {
+	if r.isEmpty() {
+		return 0
+	}
+	first := z.zsl.firstInLexRange(r)
+	if first == nil {
+		return 0
+	}
+	last := z.zsl.lastInLexRange(r)
+	return z.zsl.rank(last.score, last.member) - z.zsl.rank(first.score, first.member) + 1
+}
+
+// Members returns every member in ascending order.
+func (z *SortedSet) Members() []ZMember {
+	if z.zsl.length == 0 {
+		return nil
+	}
+	return z.RangeByRank(0, z.zsl.length-1, false)
+}
+
+// MarshalJSON stores a sorted set as an ordered list of members. Scores are
+// written as strings so that infinities survive the round trip.
+func (z *SortedSet) MarshalJSON() ([]byte, error) {
+	type entry struct {
+		Member binaryString
+		Score  string
+	}
+	entries := make([]entry, 0, z.Len())
+	for _, m := range z.Members() {
+		entries = append(entries, entry{Member: binaryString(m.Member), Score: strconv.FormatFloat(m.Score, 'g', -1, 64)})
+	}
+	return json.Marshal(entries)
+}
+
+func (z *SortedSet) UnmarshalJSON(data []byte) error {
+	var entries []struct {
+		Member binaryString
+		Score  string
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+
+	*z = *NewSortedSet()
+	for _, e := range entries {
+		score, err := strconv.ParseFloat(e.Score, 64)
+		if err != nil {
+			return err
+		}
+		z.Add(string(e.Member), score)
+	}
+	return nil
+}
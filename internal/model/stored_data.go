@@ -1,6 +1,157 @@
 package model
 
+import (
+	"encoding/json"
+	"unicode/utf8"
+)
+
 type StoredData struct {
 	Value      any
 	ExpiryDate int64
+	// AccessTime is when the key was last used, in Unix milliseconds, and
+	// AccessCount how often, on a logarithmic scale. They drive the LRU and
+	// LFU eviction policies and are not saved in snapshots.
+	AccessTime  int64
+	AccessCount uint8
+}
+
+// storedDataJSON is the on-disk form of StoredData. Type is only set for
+// value types that cannot be recovered from plain JSON, so snapshots written
+// before it existed still load.
+type storedDataJSON struct {
+	Type       string `json:",omitempty"`
+	Value      json.RawMessage
+	ExpiryDate int64
+}
+
+// binaryString is a string nested in a value, such as a set member, that
+// may hold any bytes. It is written as a JSON string when it is valid UTF-8
+// and base64 encoded otherwise, since encoding/json would replace invalid
+// bytes. Plain JSON strings still load, so older snapshots do too.
+type binaryString string
+
+type binaryStringJSON struct {
+	Binary []byte
+}
+
+func (b binaryString) MarshalJSON() ([]byte, error) {
+	if utf8.ValidString(string(b)) {
+		return json.Marshal(string(b))
+	}
+	return json.Marshal(binaryStringJSON{Binary: []byte(b)})
+}
+
+func (b *binaryString) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '{' {
+		var raw binaryStringJSON
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return err
+		}
+		*b = binaryString(raw.Binary)
+		return nil
+	}
+
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	*b = binaryString(value)
+	return nil
+}
+
+func toBinaryStrings(values []string) []binaryString {
+	out := make([]binaryString, len(values))
+	for i, value := range values {
+		out[i] = binaryString(value)
+	}
+	return out
+}
+
+func fromBinaryStrings(values []binaryString) []string {
+	out := make([]string, len(values))
+	for i, value := range values {
+		out[i] = string(value)
+	}
+	return out
+}
+
+func (s StoredData) MarshalJSON() ([]byte, error) {
+	var typeName string
+	value := s.Value
+	switch v := s.Value.(type) {
+	case string:
+		// encoding/json would replace invalid UTF-8, so binary strings such
+		// as bitmaps are stored base64 encoded instead.
+		if !utf8.ValidString(v) {
+			typeName = "binary"
+			value = []byte(v)
+		}
+	case *Hash:
+		typeName = "hash"
+	case *Set:
+		typeName = "set"
+	case *SortedSet:
+		typeName = "zset"
+	case *Stream:
+		typeName = "stream"
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(storedDataJSON{Type: typeName, Value: encoded, ExpiryDate: s.ExpiryDate})
+}
+
+func (s *StoredData) UnmarshalJSON(data []byte) error {
+	var raw storedDataJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	s.ExpiryDate = raw.ExpiryDate
+
+	switch raw.Type {
+	case "binary":
+		var value []byte
+		if err := json.Unmarshal(raw.Value, &value); err != nil {
+			return err
+		}
+		s.Value = string(value)
+	case "hash":
+		hash := NewHash()
+		if err := json.Unmarshal(raw.Value, hash); err != nil {
+			return err
+		}
+		s.Value = hash
+	case "set":
+		set := NewSet()
+		if err := json.Unmarshal(raw.Value, set); err != nil {
+			return err
+		}
+		s.Value = set
+	case "zset":
+		zset := NewSortedSet()
+		if err := json.Unmarshal(raw.Value, zset); err != nil {
+			return err
+		}
+		s.Value = zset
+	case "stream":
+		stream := NewStream()
+		if err := json.Unmarshal(raw.Value, stream); err != nil {
+			return err
+		}
+		s.Value = stream
+	default:
+		var value any
+		if len(raw.Value) > 0 {
+			if err := json.Unmarshal(raw.Value, &value); err != nil {
+				return err
+			}
+		}
+		s.Value = value
+	}
+
+	return nil
 }
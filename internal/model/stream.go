@@ -0,0 +1,474 @@
+package model
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Limits of a single stream node, like stream-node-max-entries and
+// stream-node-max-bytes in Redis.
+var (
+	StreamNodeMaxEntries = 100
+	StreamNodeMaxBytes   = 4096
+)
+
+// StreamID is the <milliseconds>-<sequence> identifier of a stream entry.
+type StreamID struct {
+	Ms, Seq uint64
+}
+
+// MaxStreamID is the largest possible ID.
+var MaxStreamID = StreamID{math.MaxUint64, math.MaxUint64}
+
+var errInvalidStreamID = errors.New("invalid stream ID")
+
+func (id StreamID) String() string {
+	return strconv.FormatUint(id.Ms, 10) + "-" + strconv.FormatUint(id.Seq, 10)
+}
+
+// Compare returns -1, 0 or 1 depending on whether id is smaller than, equal
+// to or greater than other.
+func (id StreamID) Compare(other StreamID) int {
+	switch {
+	case id.Ms < other.Ms || id.Ms == other.Ms && id.Seq < other.Seq:
+		return -1
+	case id == other:
+		return 0
+	default:
+		return 1
+	}
+}
+
+// Next returns the smallest ID greater than id and false on overflow.
+func (id StreamID) Next() (StreamID, bool) {
+	switch {
+	case id.Seq < math.MaxUint64:
+		return StreamID{id.Ms, id.Seq + 1}, true
+	case id.Ms < math.MaxUint64:
+		return StreamID{id.Ms + 1, 0}, true
+	default:
+		return id, false
+	}
+}
+
+// Prev returns the greatest ID smaller than id and false on underflow.
+func (id StreamID) Prev() (StreamID, bool) {
+	switch {
+	case id.Seq > 0:
+		return StreamID{id.Ms, id.Seq - 1}, true
+	case id.Ms > 0:
+		return StreamID{id.Ms - 1, math.MaxUint64}, true
+	default:
+		return id, false
+	}
+}
+
+// ParseStreamID parses "<ms>-<seq>" or "<ms>", in which case the sequence
+// is missingSeq.
+func ParseStreamID(s string, missingSeq uint64) (StreamID, error) {
+	msPart, seqPart, hasSeq := strings.Cut(s, "-")
+	ms, err := strconv.ParseUint(msPart, 10, 64)
+	if err != nil {
+		return StreamID{}, errInvalidStreamID
+	}
+	if !hasSeq {
+		return StreamID{ms, missingSeq}, nil
+	}
+	seq, err := strconv.ParseUint(seqPart, 10, 64)
+	if err != nil {
+		return StreamID{}, errInvalidStreamID
+	}
+	return StreamID{ms, seq}, nil
+}
+
+// StreamEntry is an entry with its field-value pairs flattened.
+type StreamEntry struct {
+	ID     StreamID
+	Fields []string
+}
+
+const (
+	streamEntryDeleted    = 1 << 0
+	streamEntrySameFields = 1 << 1
+)
+
+// streamNode packs consecutive entries into one byte slice, in the spirit
+// of the Redis listpack nodes. IDs are stored as deltas from the master ID
+// and entries with the same field names as the master entry only store
+// their values.
+type streamNode struct {
+	master       StreamID
+	masterFields []string
+	data         []byte
+	entries      int
+	deleted      int
+}
+
+// streamNodeEntry is a decoded entry together with its offset in data.
+type streamNodeEntry struct {
+	StreamEntry
+	offset  int
+	deleted bool
+}
+
+func (n *streamNode) live() int {
+	return n.entries - n.deleted
+}
+
+func (n *streamNode) append(id StreamID, fields []string) {
+	var flags byte
+	if sameFieldNames(n.masterFields, fields) {
+		flags |= streamEntrySameFields
+	}
+
+	n.data = append(n.data, flags)
+	n.data = binary.AppendUvarint(n.data, id.Ms-n.master.Ms)
+	n.data = binary.AppendVarint(n.data, int64(id.Seq-n.master.Seq))
+	if flags&streamEntrySameFields != 0 {
+		for i := 1; i < len(fields); i += 2 {
+			n.data = appendString(n.data, fields[i])
+		}
+	} else {
+		n.data = binary.AppendUvarint(n.data, uint64(len(fields)/2))
+		for _, s := range fields {
+			n.data = appendString(n.data, s)
+		}
+	}
+	n.entries++
+}
+
+// decode returns every entry of the node, including deleted ones.
+func (n *streamNode) decode() []streamNodeEntry {
+	result := make([]streamNodeEntry, 0, n.entries)
+	for pos := 0; pos < len(n.data); {
+		entry := streamNodeEntry{offset: pos}
+		flags := n.data[pos]
+		pos++
+		entry.deleted = flags&streamEntryDeleted != 0
+
+		msDelta, size := binary.Uvarint(n.data[pos:])
+		pos += size
+		seqDelta, size := binary.Varint(n.data[pos:])
+		pos += size
+		entry.ID = StreamID{n.master.Ms + msDelta, n.master.Seq + uint64(seqDelta)}
+
+		if flags&streamEntrySameFields != 0 {
+			entry.Fields = make([]string, len(n.masterFields))
+			for i := 0; i < len(n.masterFields); i += 2 {
+				entry.Fields[i] = n.masterFields[i]
+				entry.Fields[i+1], pos = readString(n.data, pos)
+			}
+		} else {
+			count, size := binary.Uvarint(n.data[pos:])
+			pos += size
+			entry.Fields = make([]string, 2*count)
+			for i := range entry.Fields {
+				entry.Fields[i], pos = readString(n.data, pos)
+			}
+		}
+		result = append(result, entry)
+	}
+	return result
+}
+
+func (n *streamNode) markDeleted(offset int) {
+	n.data[offset] |= streamEntryDeleted
+	n.deleted++
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+func readString(buf []byte, pos int) (string, int) {
+	length, size := binary.Uvarint(buf[pos:])
+	pos += size
+	return string(buf[pos : pos+int(length)]), pos + int(length)
+}
+
+func sameFieldNames(master, fields []string) bool {
+	if len(master) != len(fields) {
+		return false
+	}
+	for i := 0; i < len(fields); i += 2 {
+		if master[i] != fields[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Stream is an append-only log of entries. Redis indexes its nodes with a
+// radix tree keyed by the master ID; since IDs only ever grow, a slice
+// sorted by master ID gives the same lookups with plain binary search.
+type Stream struct {
+	nodes        []*streamNode
+	length       int
+	LastID       StreamID
+	MaxDeletedID StreamID
+	EntriesAdded uint64
+	groups       []*StreamGroup
+}
+
+func NewStream() *Stream {
+	return &Stream{}
+}
+
+func (s *Stream) Len() int {
+	return s.length
+}
+
+// NodeCount returns the number of packed nodes backing the stream.
+func (s *Stream) NodeCount() int {
+	return len(s.nodes)
+}
+
+// Add appends an entry whose ID must be greater than LastID.
+func (s *Stream) Add(id StreamID, fields []string) {
+	var last *streamNode
+	if len(s.nodes) > 0 {
+		last = s.nodes[len(s.nodes)-1]
+	}
+	if last == nil || last.entries >= StreamNodeMaxEntries || len(last.data) >= StreamNodeMaxBytes {
+		last = &streamNode{master: id, masterFields: fieldNames(fields)}
+		s.nodes = append(s.nodes, last)
+	}
+
+	last.append(id, fields)
+	s.length++
+	s.LastID = id
+	s.EntriesAdded++
+}
+
+func fieldNames(fields []string) []string {
+	names := make([]string, len(fields))
+	for i := 0; i < len(fields); i += 2 {
+		names[i] = fields[i]
+	}
+	return names
+}
+
+// Delete removes the entry with the given ID and reports whether it existed.
+func (s *Stream) Delete(id StreamID) bool {
+	idx := s.nodeFor(id)
+	if idx < 0 {
+		return false
+	}
+
+	node := s.nodes[idx]
+	for _, entry := range node.decode() {
+		if entry.ID != id || entry.deleted {
+			continue
+		}
+		node.markDeleted(entry.offset)
+		s.length--
+		if node.live() == 0 {
+			s.nodes = append(s.nodes[:idx], s.nodes[idx+1:]...)
+		}
+		if id.Compare(s.MaxDeletedID) > 0 {
+			s.MaxDeletedID = id
+		}
+		return true
+	}
+	return false
+}
+
+// nodeFor returns the index of the last node whose master ID is not
+// greater than id, or -1.
+func (s *Stream) nodeFor(id StreamID) int {
+	return sort.Search(len(s.nodes), func(i int) bool {
+		return s.nodes[i].master.Compare(id) > 0
+	}) - 1
+}
+
+// Range returns up to count entries (all if count <= 0) with IDs between
+// start and end inclusive, in reverse order if reverse is set.
+func (s *Stream) Range(start, end StreamID, reverse bool, count int) []StreamEntry {
+	if start.Compare(end) > 0 {
+		return nil
+	}
+
+	var result []StreamEntry
+	full := func() bool { return count > 0 && len(result) >= count }
+
+	if !reverse {
+		for idx := max(s.nodeFor(start), 0); idx < len(s.nodes) && !full(); idx++ {
+			for _, entry := range s.nodes[idx].decode() {
+				if entry.ID.Compare(end) > 0 {
+					return result
+				}
+				if entry.deleted || entry.ID.Compare(start) < 0 {
+					continue
+				}
+				result = append(result, entry.StreamEntry)
+				if full() {
+					break
+				}
+			}
+		}
+		return result
+	}
+
+	for idx := s.nodeFor(end); idx >= 0 && !full(); idx-- {
+		entries := s.nodes[idx].decode()
+		for i := len(entries) - 1; i >= 0; i-- {
+			entry := entries[i]
+			if entry.ID.Compare(start) < 0 {
+				return result
+			}
+			if entry.deleted || entry.ID.Compare(end) > 0 {
+				continue
+			}
+			result = append(result, entry.StreamEntry)
+			if full() {
+				break
+			}
+		}
+	}
+	return result
+}
+
+// First returns the first live entry.
+func (s *Stream) First() (StreamEntry, bool) {
+	entries := s.Range(StreamID{}, MaxStreamID, false, 1)
+	if len(entries) == 0 {
+		return StreamEntry{}, false
+	}
+	return entries[0], true
+}
+
+// Last returns the last live entry.
+func (s *Stream) Last() (StreamEntry, bool) {
+	entries := s.Range(StreamID{}, MaxStreamID, true, 1)
+	if len(entries) == 0 {
+		return StreamEntry{}, false
+	}
+	return entries[0], true
+}
+
+// TrimMaxLen evicts the oldest entries until at most maxLen remain.
+func (s *Stream) TrimMaxLen(maxLen int, approx bool, limit int) int {
+	return s.trim(approx, limit,
+		func() bool { return s.length <= maxLen },
+		func(node *streamNode, _ []streamNodeEntry) bool { return s.length-node.live() >= maxLen },
+		func(StreamEntry) bool { return s.length > maxLen })
+}
+
+// TrimMinID evicts the entries with an ID smaller than minID.
+func (s *Stream) TrimMinID(minID StreamID, approx bool, limit int) int {
+	return s.trim(approx, limit,
+		func() bool { return false },
+		func(_ *streamNode, entries []streamNodeEntry) bool {
+			return entries[len(entries)-1].ID.Compare(minID) < 0
+		},
+		func(entry StreamEntry) bool { return entry.ID.Compare(minID) < 0 })
+}
+
+// trim follows streamTrim in Redis: whole nodes are dropped from the head
+// while removeNode allows it. With approx trimming stops at the first node
+// that cannot be dropped entirely; otherwise the entries of that node are
+// marked deleted while evict holds. A non-zero limit caps the number of
+// entries evicted.
+func (s *Stream) trim(approx bool, limit int, done func() bool, removeNode func(*streamNode, []streamNodeEntry) bool, evict func(StreamEntry) bool) int {
+	deleted := 0
+	for len(s.nodes) > 0 && !done() {
+		node := s.nodes[0]
+		if limit > 0 && deleted+node.live() > limit {
+			break
+		}
+
+		entries := node.decode()
+		if removeNode(node, entries) {
+			s.nodes = s.nodes[1:]
+			s.length -= node.live()
+			deleted += node.live()
+			continue
+		}
+		if approx {
+			break
+		}
+
+		for _, entry := range entries {
+			if entry.deleted {
+				continue
+			}
+			if !evict(entry.StreamEntry) {
+				break
+			}
+			node.markDeleted(entry.offset)
+			s.length--
+			deleted++
+		}
+		break
+	}
+	return deleted
+}
+
+type streamEntryJSON struct {
+	ID     string
+	Fields []binaryString
+}
+
+type streamJSON struct {
+	Entries      []streamEntryJSON
+	LastID       string
+	MaxDeletedID string
+	EntriesAdded uint64
+	Groups       []streamGroupJSON `json:",omitempty"`
+}
+
+func (s *Stream) MarshalJSON() ([]byte, error) {
+	out := streamJSON{
+		Entries:      make([]streamEntryJSON, 0, s.length),
+		LastID:       s.LastID.String(),
+		MaxDeletedID: s.MaxDeletedID.String(),
+		EntriesAdded: s.EntriesAdded,
+	}
+	for _, entry := range s.Range(StreamID{}, MaxStreamID, false, 0) {
+		out.Entries = append(out.Entries, streamEntryJSON{ID: entry.ID.String(), Fields: toBinaryStrings(entry.Fields)})
+	}
+	for _, group := range s.groups {
+		out.Groups = append(out.Groups, group.toJSON())
+	}
+	return json.Marshal(out)
+}
+
+func (s *Stream) UnmarshalJSON(data []byte) error {
+	var in streamJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+
+	*s = Stream{}
+	for _, entry := range in.Entries {
+		id, err := ParseStreamID(entry.ID, 0)
+		if err != nil {
+			return err
+		}
+		s.Add(id, fromBinaryStrings(entry.Fields))
+	}
+
+	var err error
+	if s.LastID, err = ParseStreamID(in.LastID, 0); err != nil {
+		return err
+	}
+	if s.MaxDeletedID, err = ParseStreamID(in.MaxDeletedID, 0); err != nil {
+		return err
+	}
+	s.EntriesAdded = in.EntriesAdded
+
+	for _, groupJSON := range in.Groups {
+		group, err := streamGroupFromJSON(groupJSON)
+		if err != nil {
+			return err
+		}
+		s.groups = append(s.groups, group)
+	}
+	return nil
+}
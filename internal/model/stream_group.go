@@ -0,0 +1,334 @@
+package model
+
+import (
+	"sort"
+)
+
+// EntriesReadUnknown marks a group whose logical read counter cannot be
+// derived, e.g. after entries were deleted ahead of its last delivered ID.
+const EntriesReadUnknown = -1
+
+// StreamPendingEntry is an entry delivered to a consumer but not yet
+// acknowledged.
+type StreamPendingEntry struct {
+	ID            StreamID
+	Consumer      string
+	DeliveryTime  int64
+	DeliveryCount int64
+}
+
+type StreamConsumer struct {
+	Name string
+	// SeenTime is the last time the consumer interacted with the group and
+	// ActiveTime the last time it read or claimed entries (-1 if never).
+	SeenTime   int64
+	ActiveTime int64
+	Pending    int
+}
+
+// StreamGroup is a consumer group with its pending entries list (PEL),
+// kept sorted by ID.
+type StreamGroup struct {
+	Name        string
+	LastID      StreamID
+	EntriesRead int64
+	Consumers   map[string]*StreamConsumer
+	pending     []*StreamPendingEntry
+}
+
+func newStreamGroup(name string, lastID StreamID, entriesRead int64) *StreamGroup {
+	return &StreamGroup{
+		Name:        name,
+		LastID:      lastID,
+		EntriesRead: entriesRead,
+		Consumers:   make(map[string]*StreamConsumer),
+	}
+}
+
+// Group returns the consumer group called name.
+func (s *Stream) Group(name string) *StreamGroup {
+	for _, group := range s.groups {
+		if group.Name == name {
+			return group
+		}
+	}
+	return nil
+}
+
+// Groups returns the consumer groups sorted by name.
+func (s *Stream) Groups() []*StreamGroup {
+	return s.groups
+}
+
+// CreateGroup adds a group and reports false if it already exists.
+func (s *Stream) CreateGroup(name string, lastID StreamID, entriesRead int64) bool {
+	idx := sort.Search(len(s.groups), func(i int) bool { return s.groups[i].Name >= name })
+	if idx < len(s.groups) && s.groups[idx].Name == name {
+		return false
+	}
+	s.groups = append(s.groups, nil)
+	copy(s.groups[idx+1:], s.groups[idx:])
+	s.groups[idx] = newStreamGroup(name, lastID, entriesRead)
+	return true
+}
+
+// DestroyGroup removes a group and reports whether it existed.
+func (s *Stream) DestroyGroup(name string) bool {
+	for i, group := range s.groups {
+		if group.Name == name {
+			s.groups = append(s.groups[:i], s.groups[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// SortedConsumers returns the consumers sorted by name.
+func (g *StreamGroup) SortedConsumers() []*StreamConsumer {
+	consumers := make([]*StreamConsumer, 0, len(g.Consumers))
+	for _, consumer := range g.Consumers {
+		consumers = append(consumers, consumer)
+	}
+	sort.Slice(consumers, func(i, j int) bool { return consumers[i].Name < consumers[j].Name })
+	return consumers
+}
+
+// Consumer returns the named consumer, creating it if create is set. The
+// second result reports whether it was created.
+func (g *StreamGroup) Consumer(name string, create bool, nowMs int64) (*StreamConsumer, bool) {
+	consumer, found := g.Consumers[name]
+	if !found && create {
+		consumer = &StreamConsumer{Name: name, SeenTime: nowMs, ActiveTime: -1}
+		g.Consumers[name] = consumer
+	}
+	return consumer, !found && consumer != nil
+}
+
+// DeleteConsumer removes a consumer with its pending entries and returns
+// how many entries it had pending.
+func (g *StreamGroup) DeleteConsumer(name string) (int, bool) {
+	consumer, found := g.Consumers[name]
+	if !found {
+		return 0, false
+	}
+
+	g.pending = removeFunc(g.pending, func(p *StreamPendingEntry) bool { return p.Consumer == name })
+	delete(g.Consumers, name)
+	return consumer.Pending, true
+}
+
+func removeFunc(pending []*StreamPendingEntry, del func(*StreamPendingEntry) bool) []*StreamPendingEntry {
+	kept := pending[:0]
+	for _, p := range pending {
+		if !del(p) {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+// PendingLen returns the size of the group PEL.
+func (g *StreamGroup) PendingLen() int {
+	return len(g.pending)
+}
+
+func (g *StreamGroup) pendingIndex(id StreamID) int {
+	return sort.Search(len(g.pending), func(i int) bool {
+		return g.pending[i].ID.Compare(id) >= 0
+	})
+}
+
+// PendingEntry returns the PEL entry for id.
+func (g *StreamGroup) PendingEntry(id StreamID) *StreamPendingEntry {
+	idx := g.pendingIndex(id)
+	if idx < len(g.pending) && g.pending[idx].ID == id {
+		return g.pending[idx]
+	}
+	return nil
+}
+
+// Deliver records that id was delivered to consumer, moving the entry to
+// it if it was already pending for another consumer.
+func (g *StreamGroup) Deliver(id StreamID, consumer *StreamConsumer, nowMs int64) {
+	idx := g.pendingIndex(id)
+	if idx < len(g.pending) && g.pending[idx].ID == id {
+		entry := g.pending[idx]
+		g.setOwner(entry, consumer)
+		entry.DeliveryTime = nowMs
+		entry.DeliveryCount = 1
+		return
+	}
+
+	entry := &StreamPendingEntry{ID: id, Consumer: consumer.Name, DeliveryTime: nowMs, DeliveryCount: 1}
+	g.pending = append(g.pending, nil)
+	copy(g.pending[idx+1:], g.pending[idx:])
+	g.pending[idx] = entry
+	consumer.Pending++
+}
+
+// Claim transfers a pending entry to consumer.
+func (g *StreamGroup) Claim(entry *StreamPendingEntry, consumer *StreamConsumer) {
+	g.setOwner(entry, consumer)
+}
+
+func (g *StreamGroup) setOwner(entry *StreamPendingEntry, consumer *StreamConsumer) {
+	if entry.Consumer == consumer.Name {
+		return
+	}
+	if previous, found := g.Consumers[entry.Consumer]; found {
+		previous.Pending--
+	}
+	entry.Consumer = consumer.Name
+	consumer.Pending++
+}
+
+// Ack removes id from the PEL and reports whether it was pending.
+func (g *StreamGroup) Ack(id StreamID) bool {
+	idx := g.pendingIndex(id)
+	if idx >= len(g.pending) || g.pending[idx].ID != id {
+		return false
+	}
+
+	if consumer, found := g.Consumers[g.pending[idx].Consumer]; found {
+		consumer.Pending--
+	}
+	g.pending = append(g.pending[:idx], g.pending[idx+1:]...)
+	return true
+}
+
+// PendingRange returns up to count PEL entries between start and end,
+// optionally restricted to one consumer (count <= 0 means no limit).
+func (g *StreamGroup) PendingRange(start, end StreamID, consumer string, count int) []*StreamPendingEntry {
+	var result []*StreamPendingEntry
+	for idx := g.pendingIndex(start); idx < len(g.pending); idx++ {
+		entry := g.pending[idx]
+		if entry.ID.Compare(end) > 0 || count > 0 && len(result) >= count {
+			break
+		}
+		if consumer == "" || entry.Consumer == consumer {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+// HasTombstonesAfter reports whether entries with an ID of at least start
+// may have been deleted, which makes logical read counters unreliable.
+func (s *Stream) HasTombstonesAfter(start StreamID) bool {
+	if s.length == 0 || s.MaxDeletedID == (StreamID{}) {
+		return false
+	}
+	return start.Compare(s.MaxDeletedID) <= 0
+}
+
+// EstimateEntriesRead returns the logical position of id in the stream,
+// counted from the first entry ever added, or EntriesReadUnknown.
+func (s *Stream) EstimateEntriesRead(id StreamID) int64 {
+	added := int64(s.EntriesAdded)
+	if added == 0 {
+		return 0
+	}
+	if s.length == 0 && id.Compare(s.LastID) < 1 {
+		return added
+	}
+
+	switch id.Compare(s.LastID) {
+	case 0:
+		return added
+	case 1:
+		return EntriesReadUnknown
+	}
+
+	first, _ := s.First()
+	if s.MaxDeletedID == (StreamID{}) || s.MaxDeletedID.Compare(first.ID) < 0 {
+		// No fragmentation ahead.
+		switch id.Compare(first.ID) {
+		case -1:
+			return added - int64(s.length)
+		case 0:
+			return added - int64(s.length) + 1
+		}
+	}
+	return EntriesReadUnknown
+}
+
+// Lag returns the number of entries the group has yet to read, and false
+// if it cannot be computed.
+func (s *Stream) Lag(g *StreamGroup) (int64, bool) {
+	added := int64(s.EntriesAdded)
+	if added == 0 {
+		return 0, true
+	}
+	if g.EntriesRead != EntriesReadUnknown && !s.HasTombstonesAfter(g.LastID) {
+		return added - g.EntriesRead, true
+	}
+	if read := s.EstimateEntriesRead(g.LastID); read != EntriesReadUnknown {
+		return added - read, true
+	}
+	return 0, false
+}
+
+// AdvanceGroup moves the group last delivered ID to id, keeping its read
+// counter up to date.
+func (s *Stream) AdvanceGroup(g *StreamGroup, id StreamID) {
+	if id.Compare(g.LastID) <= 0 {
+		return
+	}
+	if g.EntriesRead != EntriesReadUnknown && !s.HasTombstonesAfter(id) {
+		g.EntriesRead++
+	} else if s.EntriesAdded > 0 {
+		g.EntriesRead = s.EstimateEntriesRead(id)
+	}
+	g.LastID = id
+}
+
+// Contains reports whether a live entry with the given ID exists.
+func (s *Stream) Contains(id StreamID) bool {
+	return len(s.Range(id, id, false, 1)) == 1
+}
+
+type streamPendingJSON struct {
+	ID            string
+	Consumer      string
+	DeliveryTime  int64
+	DeliveryCount int64
+}
+
+type streamGroupJSON struct {
+	Name        string
+	LastID      string
+	EntriesRead int64
+	Consumers   []StreamConsumer
+	Pending     []streamPendingJSON
+}
+
+func (g *StreamGroup) toJSON() streamGroupJSON {
+	out := streamGroupJSON{Name: g.Name, LastID: g.LastID.String(), EntriesRead: g.EntriesRead}
+	for _, consumer := range g.SortedConsumers() {
+		out.Consumers = append(out.Consumers, *consumer)
+	}
+	for _, p := range g.pending {
+		out.Pending = append(out.Pending, streamPendingJSON{p.ID.String(), p.Consumer, p.DeliveryTime, p.DeliveryCount})
+	}
+	return out
+}
+
+func streamGroupFromJSON(in streamGroupJSON) (*StreamGroup, error) {
+	lastID, err := ParseStreamID(in.LastID, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	g := newStreamGroup(in.Name, lastID, in.EntriesRead)
+	for _, consumer := range in.Consumers {
+		g.Consumers[consumer.Name] = &consumer
+	}
+	for _, p := range in.Pending {
+		id, err := ParseStreamID(p.ID, 0)
+		if err != nil {
+			return nil, err
+		}
+		g.pending = append(g.pending, &StreamPendingEntry{id, p.Consumer, p.DeliveryTime, p.DeliveryCount})
+	}
+	return g, nil
+}
@@ -0,0 +1,146 @@
+// Package notify emits keyspace notifications: Pub/Sub messages published
+// on __keyspace@<db>__:<key> and __keyevent@<db>__:<event> whenever a key
+// changes, filtered by the notify-keyspace-events flags.
+package notify
+
+import (
+	"errors"
+	"strings"
+	"sync"
+)
+
+// Event classes, named after their notify-keyspace-events flag characters.
+const (
+	Keyspace = 1 << iota // K
+	Keyevent             // E
+	Generic              // g
+	String               // $
+	List                 // l
+	Set                  // s
+	Hash                 // h
+	Zset                 // z
+	Expired              // x
+	Evicted              // e
+	Stream               // t
+	KeyMiss              // m
+	New                  // n
+
+	// All is the "A" alias. Like in Redis it leaves out m and n.
+	All = Generic | String | List | Set | Hash | Zset | Expired | Evicted | Stream
+)
+
+// db is the only database this server has.
+const db = "0"
+
+var (
+	mu        sync.RWMutex
+	flags     int
+	publisher func(channel, message string) int
+)
+
+var flagChars = []struct {
+	char  byte
+	class int
+}{
+	{'g', Generic}, {'$', String}, {'l', List}, {'s', Set}, {'h', Hash},
+	{'z', Zset}, {'x', Expired}, {'e', Evicted}, {'t', Stream},
+}
+
+// ParseFlags parses a notify-keyspace-events string such as "KEA" or "Kx".
+func ParseFlags(s string) (int, error) {
+	f := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case 'A':
+			f |= All
+		case 'K':
+			f |= Keyspace
+		case 'E':
+			f |= Keyevent
+		case 'm':
+			f |= KeyMiss
+		case 'n':
+			f |= New
+		default:
+			class := 0
+			for _, fc := range flagChars {
+				if fc.char == c {
+					class = fc.class
+				}
+			}
+			if class == 0 {
+				return 0, errors.New("invalid notify-keyspace-events flag '" + string(c) + "'")
+			}
+			f |= class
+		}
+	}
+	return f, nil
+}
+
+// FormatFlags is the inverse of ParseFlags, in the canonical order Redis
+// reports through CONFIG GET.
+func FormatFlags(f int) string {
+	var b strings.Builder
+	if f&All == All {
+		b.WriteByte('A')
+	} else {
+		for _, fc := range flagChars {
+			if f&fc.class != 0 {
+				b.WriteByte(fc.char)
+			}
+		}
+	}
+	for _, fc := range []struct {
+		char  byte
+		class int
+	}{{'K', Keyspace}, {'E', Keyevent}, {'m', KeyMiss}, {'n', New}} {
+		if f&fc.class != 0 {
+			b.WriteByte(fc.char)
+		}
+	}
+	return b.String()
+}
+
+// SetFlags replaces the notify-keyspace-events setting.
+func SetFlags(s string) error {
+	f, err := ParseFlags(s)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	flags = f
+	mu.Unlock()
+	return nil
+}
+
+// Flags returns the notify-keyspace-events setting.
+func Flags() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return FormatFlags(flags)
+}
+
+// SetPublisher registers the function used to publish notifications. It is
+// called while the store lock may be held, so it must not take that lock.
+func SetPublisher(publish func(channel, message string) int) {
+	mu.Lock()
+	publisher = publish
+	mu.Unlock()
+}
+
+// KeyspaceEvent notifies that event of the given class happened to key.
+func KeyspaceEvent(class int, event, key string) {
+	mu.RLock()
+	f, publish := flags, publisher
+	mu.RUnlock()
+
+	if f&class == 0 || publish == nil {
+		return
+	}
+	if f&Keyspace != 0 {
+		publish("__keyspace@"+db+"__:"+key, event)
+	}
+	if f&Keyevent != 0 {
+		publish("__keyevent@"+db+"__:"+event, key)
+	}
+}
@@ -0,0 +1,73 @@
+package notify
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseFlags(t *testing.T) {
+	tests := []struct {
+		flags string
+		want  string
+	}{
+		{"", ""},
+		{"KEA", "AKE"},
+		{"AKEmn", "AKEmn"},
+		{"Ex", "xE"},
+		{"g$lshzxetK", "AK"},
+		{"Kl$", "$lK"},
+		{"nK", "Kn"},
+	}
+
+	for _, tt := range tests {
+		f, err := ParseFlags(tt.flags)
+		if err != nil {
+			t.Fatalf("ParseFlags(%q): %v", tt.flags, err)
+		}
+		if got := FormatFlags(f); got != tt.want {
+			t.Errorf("FormatFlags(ParseFlags(%q)): expected %q, got %q", tt.flags, tt.want, got)
+		}
+	}
+
+	if _, err := ParseFlags("KEQ"); err == nil {
+		t.Error("expected an error for an unknown flag")
+	}
+}
+
+func TestKeyspaceEvent(t *testing.T) {
+	var published [][2]string
+	SetPublisher(func(channel, message string) int {
+		published = append(published, [2]string{channel, message})
+		return 0
+	})
+	t.Cleanup(func() {
+		SetPublisher(nil)
+		SetFlags("")
+	})
+
+	tests := []struct {
+		flags string
+		class int
+		want  [][2]string
+	}{
+		{"", Generic, nil},
+		{"KEA", Generic, [][2]string{{"__keyspace@0__:k", "del"}, {"__keyevent@0__:del", "k"}}},
+		{"Kg", Generic, [][2]string{{"__keyspace@0__:k", "del"}}},
+		{"Eg", Generic, [][2]string{{"__keyevent@0__:del", "k"}}},
+		{"KE$", Generic, nil},
+		{"g", Generic, nil},
+		{"KEA", New, nil},
+		{"KEn", New, [][2]string{{"__keyspace@0__:k", "del"}, {"__keyevent@0__:del", "k"}}},
+	}
+
+	for _, tt := range tests {
+		if err := SetFlags(tt.flags); err != nil {
+			t.Fatal(err)
+		}
+		published = nil
+		KeyspaceEvent(tt.class, "del", "k")
+		if !slices.Equal(published, tt.want) {
+			t.Errorf("flags %q: expected %v, got %v", tt.flags, tt.want, published)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package pubsub
+
+import (
+	"redis-go-clone/pkg/glob"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Subscriber receives the messages published to its channels and patterns.
+// Push is called with the broker lock held, so it must not block.
+type Subscriber interface {
+	Push(reply string)
+}
+
+// PubSub routes published messages to the subscribers of a channel and to
+// the subscribers of every pattern matching it. Shard channels are kept
+// apart: they are only reached by SPUBLISH and never match patterns.
+type PubSub struct {
+	mu            sync.RWMutex
+	channels      map[string]map[Subscriber]struct{}
+	patterns      map[string]map[Subscriber]struct{}
+	shardChannels map[string]map[Subscriber]struct{}
+}
+
+func New() *PubSub {
+	return &PubSub{
+		channels:      make(map[string]map[Subscriber]struct{}),
+		patterns:      make(map[string]map[Subscriber]struct{}),
+		shardChannels: make(map[string]map[Subscriber]struct{}),
+	}
+}
+
+func (p *PubSub) Subscribe(s Subscriber, channel string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	add(p.channels, channel, s)
+}
+
+func (p *PubSub) Unsubscribe(s Subscriber, channel string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	remove(p.channels, channel, s)
+}
+
+func (p *PubSub) PSubscribe(s Subscriber, pattern string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	add(p.patterns, pattern, s)
+}
+
+func (p *PubSub) PUnsubscribe(s Subscriber, pattern string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	remove(p.patterns, pattern, s)
+}
+
+func (p *PubSub) SSubscribe(s Subscriber, channel string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	add(p.shardChannels, channel, s)
+}
+
+func (p *PubSub) SUnsubscribe(s Subscriber, channel string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	remove(p.shardChannels, channel, s)
+}
+
+func add(subscriptions map[string]map[Subscriber]struct{}, name string, s Subscriber) {
+	subscribers, found := subscriptions[name]
+	if !found {
+		subscribers = make(map[Subscriber]struct{})
+		subscriptions[name] = subscribers
+	}
+	subscribers[s] = struct{}{}
+}
+
+func remove(subscriptions map[string]map[Subscriber]struct{}, name string, s Subscriber) {
+	subscribers := subscriptions[name]
+	delete(subscribers, s)
+	if len(subscribers) == 0 {
+		delete(subscriptions, name)
+	}
+}
+
+// Publish sends message to channel and returns the number of receivers. A
+// client subscribed both to the channel and to matching patterns receives
+// the message once per subscription.
+func (p *PubSub) Publish(channel, message string) int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	receivers := 0
+	if subscribers, found := p.channels[channel]; found {
+		reply := Reply("message", channel, message)
+		for s := range subscribers {
+			s.Push(reply)
+			receivers++
+		}
+	}
+	for pattern, subscribers := range p.patterns {
+		if !glob.Match(pattern, channel) {
+			continue
+		}
+		reply := Reply("pmessage", pattern, channel, message)
+		for s := range subscribers {
+			s.Push(reply)
+			receivers++
+		}
+	}
+	return receivers
+}
+
+// SPublish sends message to the subscribers of a shard channel and returns
+// their number.
+func (p *PubSub) SPublish(channel, message string) int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	subscribers := p.shardChannels[channel]
+	if len(subscribers) == 0 {
+		return 0
+	}
+	reply := Reply("smessage", channel, message)
+	for s := range subscribers {
+		s.Push(reply)
+	}
+	return len(subscribers)
+}
+
+// Channels returns the channels with at least one subscriber, optionally
+// filtered by a glob pattern.
+func (p *PubSub) Channels(pattern string) []string {
+	return p.active(p.channels, pattern)
+}
+
+// ShardChannels is Channels for shard channels.
+func (p *PubSub) ShardChannels(pattern string) []string {
+	return p.active(p.shardChannels, pattern)
+}
+
+func (p *PubSub) active(subscriptions map[string]map[Subscriber]struct{}, pattern string) []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	var channels []string
+	for channel := range subscriptions {
+		if pattern == "" || glob.Match(pattern, channel) {
+			channels = append(channels, channel)
+		}
+	}
+	sort.Strings(channels)
+	return channels
+}
+
+// NumSub returns the number of subscribers of channel, not counting
+// pattern subscriptions.
+func (p *PubSub) NumSub(channel string) int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return len(p.channels[channel])
+}
+
+// ShardNumSub returns the number of subscribers of a shard channel.
+func (p *PubSub) ShardNumSub(channel string) int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return len(p.shardChannels[channel])
+}
+
+// NumPat returns the number of unique patterns subscribed to.
+func (p *PubSub) NumPat() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return len(p.patterns)
+}
+
+// NumClients returns how many subscribers have at least one subscription.
+func (p *PubSub) NumClients() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	seen := make(map[Subscriber]struct{})
+	for _, subscriptions := range []map[string]map[Subscriber]struct{}{p.channels, p.patterns, p.shardChannels} {
+		for _, subscribers := range subscriptions {
+			for s := range subscribers {
+				seen[s] = struct{}{}
+			}
+		}
+	}
+	return len(seen)
+}
+
+// Reply encodes a Pub/Sub protocol message. Unlike other array replies its
+// strings are bulk strings, since payloads and channel names may contain
+// any byte; nil encodes as a null bulk string.
+func Reply(items ...any) string {
+	var b strings.Builder
+	b.WriteString("*" + strconv.Itoa(len(items)) + "\r\n")
+	for _, item := range items {
+		switch v := item.(type) {
+		case string:
+			b.WriteString("$" + strconv.Itoa(len(v)) + "\r\n" + v + "\r\n")
+		case int:
+			b.WriteString(":" + strconv.Itoa(v) + "\r\n")
+		default:
+			b.WriteString("$-1\r\n")
+		}
+	}
+	return b.String()
+}
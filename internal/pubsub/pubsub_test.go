@@ -0,0 +1,118 @@
+package pubsub
+
+import (
+	"reflect"
+	"testing"
+)
+
+type recorder struct {
+	messages []string
+}
+
+func (r *recorder) Push(reply string) {
+	r.messages = append(r.messages, reply)
+}
+
+func TestPublish(t *testing.T) {
+	p := New()
+	news, all := &recorder{}, &recorder{}
+	p.Subscribe(news, "news.tech")
+	p.PSubscribe(all, "news.*")
+	p.Subscribe(all, "news.tech")
+
+	if receivers := p.Publish("news.tech", "hello"); receivers != 3 {
+		t.Errorf("expected 3 receivers, got %d", receivers)
+	}
+	if receivers := p.Publish("news.art", "x"); receivers != 1 {
+		t.Errorf("expected 1 receiver, got %d", receivers)
+	}
+	if receivers := p.Publish("sport", "x"); receivers != 0 {
+		t.Errorf("expected no receivers, got %d", receivers)
+	}
+
+	message := "*3\r\n$7\r\nmessage\r\n$9\r\nnews.tech\r\n$5\r\nhello\r\n"
+	if !reflect.DeepEqual(news.messages, []string{message}) {
+		t.Errorf("unexpected channel messages %q", news.messages)
+	}
+	want := []string{
+		message,
+		"*4\r\n$8\r\npmessage\r\n$6\r\nnews.*\r\n$9\r\nnews.tech\r\n$5\r\nhello\r\n",
+		"*4\r\n$8\r\npmessage\r\n$6\r\nnews.*\r\n$8\r\nnews.art\r\n$1\r\nx\r\n",
+	}
+	if !reflect.DeepEqual(all.messages, want) {
+		t.Errorf("expected the channel message before the pattern messages, got %q", all.messages)
+	}
+
+	p.Unsubscribe(all, "news.tech")
+	p.PUnsubscribe(all, "news.*")
+	if receivers := p.Publish("news.tech", "bye"); receivers != 1 {
+		t.Errorf("expected 1 receiver after unsubscribing, got %d", receivers)
+	}
+}
+
+func TestIntrospection(t *testing.T) {
+	p := New()
+	a, b := &recorder{}, &recorder{}
+	p.Subscribe(a, "news")
+	p.Subscribe(b, "news")
+	p.Subscribe(a, "sport")
+	p.PSubscribe(a, "n*")
+	p.PSubscribe(b, "n*")
+
+	if channels := p.Channels(""); !reflect.DeepEqual(channels, []string{"news", "sport"}) {
+		t.Errorf("unexpected channels %v", channels)
+	}
+	if channels := p.Channels("s*"); !reflect.DeepEqual(channels, []string{"sport"}) {
+		t.Errorf("unexpected filtered channels %v", channels)
+	}
+	if n := p.NumSub("news"); n != 2 {
+		t.Errorf("expected 2 subscribers, got %d", n)
+	}
+	if n := p.NumPat(); n != 1 {
+		t.Errorf("expected 1 pattern, got %d", n)
+	}
+
+	p.Unsubscribe(a, "sport")
+	if channels := p.Channels(""); !reflect.DeepEqual(channels, []string{"news"}) {
+		t.Errorf("expected channels without subscribers to disappear, got %v", channels)
+	}
+}
+
+func TestReply(t *testing.T) {
+	if reply := Reply("unsubscribe", nil, 0); reply != "*3\r\n$11\r\nunsubscribe\r\n$-1\r\n:0\r\n" {
+		t.Errorf("unexpected reply %q", reply)
+	}
+}
+
+func TestShardChannels(t *testing.T) {
+	p := New()
+	sharded, global := &recorder{}, &recorder{}
+	p.SSubscribe(sharded, "orders")
+	p.Subscribe(global, "orders")
+	p.PSubscribe(global, "*")
+
+	if receivers := p.SPublish("orders", "x"); receivers != 1 {
+		t.Errorf("expected only the shard subscriber, got %d receivers", receivers)
+	}
+	if !reflect.DeepEqual(sharded.messages, []string{"*3\r\n$8\r\nsmessage\r\n$6\r\norders\r\n$1\r\nx\r\n"}) {
+		t.Errorf("unexpected shard messages %q", sharded.messages)
+	}
+	if receivers := p.Publish("orders", "y"); receivers != 2 {
+		t.Errorf("expected PUBLISH to skip shard subscribers, got %d receivers", receivers)
+	}
+	if len(sharded.messages) != 1 {
+		t.Errorf("expected no global message for the shard subscriber, got %q", sharded.messages)
+	}
+
+	if channels := p.ShardChannels("ord*"); !reflect.DeepEqual(channels, []string{"orders"}) {
+		t.Errorf("unexpected shard channels %v", channels)
+	}
+	if n := p.ShardNumSub("orders"); n != 1 {
+		t.Errorf("expected 1 shard subscriber, got %d", n)
+	}
+
+	p.SUnsubscribe(sharded, "orders")
+	if channels := p.ShardChannels(""); len(channels) != 0 {
+		t.Errorf("expected no shard channels, got %v", channels)
+	}
+}
@@ -2,6 +2,7 @@ package redis_command
 
 import (
 	"redis-go-clone/internal/model"
+	"redis-go-clone/internal/notify"
 	"sync"
 )
 
@@ -33,6 +34,10 @@ func LPush(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMu
 	}
 
 	storedData[key] = model.StoredData{Value: list}
+	if !found {
+		keyspaceEvent(notify.New, "new", key)
+	}
+	keyspaceEvent(notify.List, "lpush", key)
 	return "+OK\r\n"
 }
 
@@ -64,5 +69,9 @@ func RPush(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMu
 	}
 
 	storedData[key] = model.StoredData{Value: list}
+	if !found {
+		keyspaceEvent(notify.New, "new", key)
+	}
+	keyspaceEvent(notify.List, "rpush", key)
 	return "+OK\r\n"
 }
@@ -0,0 +1,660 @@
+package redis_command
+
+import (
+	"math/big"
+	"math/bits"
+	"redis-go-clone/internal/model"
+	"redis-go-clone/internal/notify"
+	"redis-go-clone/pkg/resp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxBitOffset matches the 512MB string limit of Redis.
+const maxBitOffset = 1<<32 - 1
+
+func SetBit(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) != 4 {
+		return "-ERR wrong number of arguments for SETBIT\r\n"
+	}
+
+	key, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR invalid argument for SETBIT\r\n"
+	}
+	offset, ok := parseBitOffset(cmdArray[2], false, 0)
+	if !ok {
+		return "-ERR bit offset is not an integer or out of range\r\n"
+	}
+	bit, ok := argToInt64(cmdArray[3])
+	if !ok || (bit != 0 && bit != 1) {
+		return "-ERR bit is not an integer or out of range\r\n"
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	value, found, errReply := lookupString(storedData, key)
+	if errReply != "" {
+		return errReply
+	}
+
+	buf := growBitmap([]byte(value), offset+1)
+	byteIdx, shift := offset>>3, 7-offset&7
+	old := int(buf[byteIdx]>>shift) & 1
+	if bit == 1 {
+		buf[byteIdx] |= 1 << shift
+	} else {
+		buf[byteIdx] &^= 1 << shift
+	}
+
+	storedData[key] = model.StoredData{Value: string(buf), ExpiryDate: storedData[key].ExpiryDate}
+	if !found {
+		keyspaceEvent(notify.New, "new", key)
+	}
+	keyspaceEvent(notify.String, "setbit", key)
+	return ":" + strconv.Itoa(old) + "\r\n"
+}
+
+func GetBit(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) != 3 {
+		return "-ERR wrong number of arguments for GETBIT\r\n"
+	}
+
+	key, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR invalid argument for GETBIT\r\n"
+	}
+	offset, ok := parseBitOffset(cmdArray[2], false, 0)
+	if !ok {
+		return "-ERR bit offset is not an integer or out of range\r\n"
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+
+	value, _, errReply := lookupString(storedData, key)
+	if errReply != "" {
+		return errReply
+	}
+
+	byteIdx := offset >> 3
+	if byteIdx >= uint64(len(value)) {
+		return ":0\r\n"
+	}
+	return ":" + strconv.Itoa(int(value[byteIdx]>>(7-offset&7))&1) + "\r\n"
+}
+
+func BitCount(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) != 2 && len(cmdArray) != 4 && len(cmdArray) != 5 {
+		return "-ERR syntax error\r\n"
+	}
+
+	key, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR invalid argument for BITCOUNT\r\n"
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+
+	value, _, errReply := lookupString(storedData, key)
+	if errReply != "" {
+		return errReply
+	}
+
+	startBit, endBit := int64(0), int64(len(value))*8-1
+	if len(cmdArray) > 2 {
+		var errReply string
+		startBit, endBit, errReply = parseBitRange(cmdArray[2], cmdArray[3], cmdArray[4:], len(value))
+		if errReply != "" {
+			return errReply
+		}
+	}
+
+	count := 0
+	for pos := startBit; pos <= endBit; {
+		// Count whole bytes at once when the range allows it.
+		if pos&7 == 0 && pos+7 <= endBit {
+			count += bits.OnesCount8(value[pos>>3])
+			pos += 8
+			continue
+		}
+		count += int(value[pos>>3]>>(7-pos&7)) & 1
+		pos++
+	}
+	return ":" + strconv.Itoa(count) + "\r\n"
+}
+
+func BitPos(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) < 3 || len(cmdArray) > 6 {
+		return "-ERR wrong number of arguments for BITPOS\r\n"
+	}
+
+	key, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR invalid argument for BITPOS\r\n"
+	}
+	bit, ok := argToInt64(cmdArray[2])
+	if !ok || (bit != 0 && bit != 1) {
+		return "-ERR The bit argument must be 1 or 0.\r\n"
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+
+	value, found, errReply := lookupString(storedData, key)
+	if errReply != "" {
+		return errReply
+	}
+	if !found {
+		if bit == 1 {
+			return ":-1\r\n"
+		}
+		return ":0\r\n"
+	}
+
+	endGiven := len(cmdArray) > 4
+	startBit, endBit := int64(0), int64(len(value))*8-1
+	switch len(cmdArray) {
+	case 3:
+	case 4:
+		startBit, endBit, errReply = parseBitRange(cmdArray[3], -1, nil, len(value))
+	default:
+		startBit, endBit, errReply = parseBitRange(cmdArray[3], cmdArray[4], cmdArray[5:], len(value))
+	}
+	if errReply != "" {
+		return errReply
+	}
+	if startBit > endBit {
+		return ":-1\r\n"
+	}
+
+	// skip is a byte made only of the bit we are not looking for.
+	skip := byte(0)
+	if bit == 0 {
+		skip = 0xff
+	}
+	for pos := startBit; pos <= endBit; {
+		if pos&7 == 0 && pos+7 <= endBit && value[pos>>3] == skip {
+			pos += 8
+			continue
+		}
+		if int64(value[pos>>3]>>(7-pos&7))&1 == bit {
+			return ":" + strconv.FormatInt(pos, 10) + "\r\n"
+		}
+		pos++
+	}
+
+	// Without an explicit end the string is considered padded with zeros
+	// on the right, so the first clear bit is the one after the string.
+	if bit == 0 && !endGiven {
+		return ":" + strconv.FormatInt(endBit+1, 10) + "\r\n"
+	}
+	return ":-1\r\n"
+}
+
+func BitOp(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) < 4 {
+		return "-ERR wrong number of arguments for BITOP\r\n"
+	}
+
+	op, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR syntax error\r\n"
+	}
+	op = strings.ToUpper(op)
+
+	destination, ok := cmdArray[2].(string)
+	if !ok {
+		return "-ERR invalid argument for BITOP\r\n"
+	}
+	keys, ok := keyArgs(cmdArray[3:])
+	if !ok {
+		return "-ERR invalid argument for BITOP\r\n"
+	}
+
+	switch op {
+	case "AND", "OR", "XOR", "ONE":
+	case "NOT":
+		if len(keys) != 1 {
+			return "-ERR BITOP NOT must be called with a single source key.\r\n"
+		}
+	case "DIFF", "DIFF1", "ANDOR":
+		if len(keys) < 2 {
+			return "-ERR BITOP " + op + " must be called with at least two source keys.\r\n"
+		}
+	default:
+		return "-ERR syntax error\r\n"
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	sources := make([]string, 0, len(keys))
+	maxLen := 0
+	for _, key := range keys {
+		value, _, errReply := lookupString(storedData, key)
+		if errReply != "" {
+			return errReply
+		}
+		sources = append(sources, value)
+		maxLen = max(maxLen, len(value))
+	}
+
+	_, existed := storedData[destination]
+	if maxLen == 0 {
+		delete(storedData, destination)
+		if existed {
+			keyspaceEvent(notify.Generic, "del", destination)
+		}
+		return ":0\r\n"
+	}
+
+	result := make([]byte, maxLen)
+	for i := range result {
+		result[i] = bitopByte(op, sources, i)
+	}
+
+	storedData[destination] = model.StoredData{Value: string(result)}
+	if !existed {
+		keyspaceEvent(notify.New, "new", destination)
+	}
+	keyspaceEvent(notify.String, "set", destination)
+	return ":" + strconv.Itoa(maxLen) + "\r\n"
+}
+
+// bitopByte computes byte i of a BITOP result. Shorter sources are treated
+// as zero padded.
+func bitopByte(op string, sources []string, i int) byte {
+	at := func(s string) byte {
+		if i < len(s) {
+			return s[i]
+		}
+		return 0
+	}
+
+	// others is the OR of every source after the first one.
+	others := byte(0)
+	for _, s := range sources[1:] {
+		others |= at(s)
+	}
+
+	first := at(sources[0])
+	switch op {
+	case "AND":
+		result := first
+		for _, s := range sources[1:] {
+			result &= at(s)
+		}
+		return result
+	case "OR":
+		return first | others
+	case "XOR":
+		result := first
+		for _, s := range sources[1:] {
+			result ^= at(s)
+		}
+		return result
+	case "NOT":
+		return ^first
+	case "DIFF":
+		return first &^ others
+	case "DIFF1":
+		return ^first & others
+	case "ANDOR":
+		return first & others
+	case "ONE":
+		// once holds bits seen an odd number of times, multi bits seen at
+		// least twice.
+		once, multi := byte(0), byte(0)
+		for _, s := range sources {
+			b := at(s)
+			multi |= once & b
+			once ^= b
+		}
+		return once &^ multi
+	}
+	return 0
+}
+
+// bitfieldOp is a single GET, SET or INCRBY subcommand of BITFIELD.
+type bitfieldOp struct {
+	kind     string
+	signed   bool
+	bits     uint
+	offset   uint64
+	value    int64
+	overflow string
+}
+
+func BitField(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	return bitfield("BITFIELD", cmdArray, storedData, mu, false)
+}
+
+func BitFieldRO(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	return bitfield("BITFIELD_RO", cmdArray, storedData, mu, true)
+}
+
+func bitfield(name string, cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex, readOnly bool) string {
+	if len(cmdArray) < 2 {
+		return "-ERR wrong number of arguments for " + name + "\r\n"
+	}
+
+	key, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR invalid argument for " + name + "\r\n"
+	}
+
+	ops, errReply := parseBitfieldOps(cmdArray[2:])
+	if errReply != "" {
+		return errReply
+	}
+
+	writes := false
+	for _, op := range ops {
+		if op.kind != "GET" {
+			writes = true
+		}
+	}
+	if writes && readOnly {
+		return "-ERR BITFIELD_RO only supports the GET subcommand\r\n"
+	}
+
+	if writes {
+		mu.Lock()
+		defer mu.Unlock()
+	} else {
+		mu.RLock()
+		defer mu.RUnlock()
+	}
+
+	value, _, errReply := lookupString(storedData, key)
+	if errReply != "" {
+		return errReply
+	}
+	buf := []byte(value)
+
+	result := make([]any, 0, len(ops))
+	changed := false
+	for _, op := range ops {
+		if op.kind == "GET" {
+			result = append(result, int(readBitfield(buf, op.offset, op.bits, op.signed)))
+			continue
+		}
+
+		old := readBitfield(buf, op.offset, op.bits, op.signed)
+		target := big.NewInt(op.value)
+		if op.kind == "INCRBY" {
+			target.Add(target, big.NewInt(old))
+		}
+
+		newValue, ok := applyBitfieldOverflow(target, op.bits, op.signed, op.overflow)
+		if !ok {
+			result = append(result, nil)
+			continue
+		}
+
+		buf = growBitmap(buf, op.offset+uint64(op.bits))
+		writeBitfield(buf, op.offset, op.bits, newValue)
+		changed = true
+
+		if op.kind == "SET" {
+			result = append(result, int(old))
+		} else {
+			result = append(result, int(newValue))
+		}
+	}
+
+	if changed {
+		_, existed := storedData[key]
+		storedData[key] = model.StoredData{Value: string(buf), ExpiryDate: storedData[key].ExpiryDate}
+		if !existed {
+			keyspaceEvent(notify.New, "new", key)
+		}
+		keyspaceEvent(notify.String, "setbit", key)
+	}
+	return resp.SerializeRESP(result, false)
+}
+
+func parseBitfieldOps(args []any) ([]bitfieldOp, string) {
+	var ops []bitfieldOp
+	overflow := "WRAP"
+
+	for i := 0; i < len(args); {
+		sub, ok := args[i].(string)
+		if !ok {
+			return nil, "-ERR syntax error\r\n"
+		}
+		sub = strings.ToUpper(sub)
+
+		if sub == "OVERFLOW" {
+			if i+1 >= len(args) {
+				return nil, "-ERR syntax error\r\n"
+			}
+			mode, _ := args[i+1].(string)
+			switch strings.ToUpper(mode) {
+			case "WRAP", "SAT", "FAIL":
+				overflow = strings.ToUpper(mode)
+			default:
+				return nil, "-ERR Invalid OVERFLOW type specified\r\n"
+			}
+			i += 2
+			continue
+		}
+
+		argCount := 3
+		switch sub {
+		case "GET":
+		case "SET", "INCRBY":
+			argCount = 4
+		default:
+			return nil, "-ERR syntax error\r\n"
+		}
+		if i+argCount > len(args) {
+			return nil, "-ERR syntax error\r\n"
+		}
+
+		typeName, _ := args[i+1].(string)
+		signed, width, ok := parseBitfieldType(typeName)
+		if !ok {
+			return nil, "-ERR Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is.\r\n"
+		}
+		offset, ok := parseBitOffset(args[i+2], true, width)
+		if !ok {
+			return nil, "-ERR bit offset is not an integer or out of range\r\n"
+		}
+
+		op := bitfieldOp{kind: sub, signed: signed, bits: width, offset: offset, overflow: overflow}
+		if argCount == 4 {
+			op.value, ok = argToInt64(args[i+3])
+			if !ok {
+				return nil, "-ERR value is not an integer or out of range\r\n"
+			}
+		}
+		ops = append(ops, op)
+		i += argCount
+	}
+	return ops, ""
+}
+
+// parseBitfieldType parses types such as "i8" or "u16".
+func parseBitfieldType(typeName string) (bool, uint, bool) {
+	if len(typeName) < 2 {
+		return false, 0, false
+	}
+
+	signed := false
+	switch typeName[0] {
+	case 'i', 'I':
+		signed = true
+	case 'u', 'U':
+	default:
+		return false, 0, false
+	}
+
+	width, err := strconv.Atoi(typeName[1:])
+	if err != nil || width < 1 || (signed && width > 64) || (!signed && width > 63) {
+		return false, 0, false
+	}
+	return signed, uint(width), true
+}
+
+// parseBitOffset parses a bit offset. When hashAllowed is set, "#N" means
+// the N-th field of the given width.
+func parseBitOffset(arg any, hashAllowed bool, width uint) (uint64, bool) {
+	multiplier := int64(1)
+	if s, ok := arg.(string); ok && hashAllowed && strings.HasPrefix(s, "#") {
+		arg = s[1:]
+		multiplier = int64(width)
+	}
+
+	offset, ok := argToInt64(arg)
+	if !ok || offset < 0 || offset > maxBitOffset/multiplier {
+		return 0, false
+	}
+	offset *= multiplier
+	if offset > maxBitOffset {
+		return 0, false
+	}
+	return uint64(offset), true
+}
+
+// parseBitRange converts the start/end arguments of BITCOUNT and BITPOS,
+// given in bytes or, with the BIT option, in bits, to an inclusive range of
+// bit positions. An empty range is returned as start > end.
+func parseBitRange(startArg, endArg any, unitArgs []any, length int) (int64, int64, string) {
+	start, ok1 := argToInt64(startArg)
+	end, ok2 := argToInt64(endArg)
+	if !ok1 || !ok2 {
+		return 0, 0, "-ERR value is not an integer or out of range\r\n"
+	}
+
+	isBit := false
+	if len(unitArgs) > 0 {
+		unit, _ := unitArgs[0].(string)
+		switch strings.ToUpper(unit) {
+		case "BYTE":
+		case "BIT":
+			isBit = true
+		default:
+			return 0, 0, "-ERR syntax error\r\n"
+		}
+	}
+
+	total := int64(length)
+	if isBit {
+		total *= 8
+	}
+	if start < 0 {
+		start += total
+	}
+	if end < 0 {
+		end += total
+	}
+	start, end = max(start, 0), max(end, 0)
+	end = min(end, total-1)
+	if start > end {
+		return 1, 0, ""
+	}
+
+	if isBit {
+		return start, end, ""
+	}
+	return start * 8, end*8 + 7, ""
+}
+
+// readBitfield reads width bits starting at offset, MSB first. Bits past the
+// end of the string read as zero.
+func readBitfield(buf []byte, offset uint64, width uint, signed bool) int64 {
+	var value uint64
+	for i := uint64(0); i < uint64(width); i++ {
+		pos := offset + i
+		bit := uint64(0)
+		if pos>>3 < uint64(len(buf)) {
+			bit = uint64(buf[pos>>3]>>(7-pos&7)) & 1
+		}
+		value = value<<1 | bit
+	}
+
+	if signed && width < 64 && value&(1<<(width-1)) != 0 {
+		return int64(value) - 1<<width
+	}
+	return int64(value)
+}
+
+func writeBitfield(buf []byte, offset uint64, width uint, value int64) {
+	v := uint64(value)
+	for i := uint64(0); i < uint64(width); i++ {
+		pos := offset + i
+		shift := 7 - pos&7
+		if v>>(uint64(width)-1-i)&1 == 1 {
+			buf[pos>>3] |= 1 << shift
+		} else {
+			buf[pos>>3] &^= 1 << shift
+		}
+	}
+}
+
+// applyBitfieldOverflow fits value into the field type according to the
+// overflow mode. It reports false when FAIL rejects the operation.
+func applyBitfieldOverflow(value *big.Int, width uint, signed bool, overflow string) (int64, bool) {
+	lower, upper := new(big.Int), new(big.Int)
+	if signed {
+		upper.Lsh(big.NewInt(1), width-1)
+		lower.Neg(upper)
+		upper.Sub(upper, big.NewInt(1))
+	} else {
+		upper.Lsh(big.NewInt(1), width)
+		upper.Sub(upper, big.NewInt(1))
+	}
+
+	if value.Cmp(lower) >= 0 && value.Cmp(upper) <= 0 {
+		return value.Int64(), true
+	}
+
+	switch overflow {
+	case "SAT":
+		if value.Cmp(lower) < 0 {
+			return lower.Int64(), true
+		}
+		return upper.Int64(), true
+	case "FAIL":
+		return 0, false
+	default:
+		modulus := new(big.Int).Lsh(big.NewInt(1), width)
+		wrapped := new(big.Int).Mod(value, modulus)
+		if signed && wrapped.Cmp(upper) > 0 {
+			wrapped.Sub(wrapped, modulus)
+		}
+		return wrapped.Int64(), true
+	}
+}
+
+// growBitmap zero pads buf so that it holds at least bitLen bits.
+func growBitmap(buf []byte, bitLen uint64) []byte {
+	needed := int((bitLen + 7) / 8)
+	if needed > len(buf) {
+		buf = append(buf, make([]byte, needed-len(buf))...)
+	}
+	return buf
+}
+
+// lookupString returns the string stored at key and whether the key exists.
+// Integers stored by SET are treated as their decimal representation.
+func lookupString(storedData map[string]model.StoredData, key string) (string, bool, string) {
+	value, found := storedData[key]
+	if !found {
+		return "", false, ""
+	}
+
+	switch v := value.Value.(type) {
+	case string:
+		return v, true, ""
+	case int:
+		return strconv.Itoa(v), true, ""
+	default:
+		return "", false, "-ERR value is not type of string\r\n"
+	}
+}
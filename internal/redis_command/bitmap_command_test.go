@@ -0,0 +1,151 @@
+package redis_command
+
+import (
+	"redis-go-clone/internal/model"
+	"sync"
+	"testing"
+)
+
+func TestSetBitGetBit(t *testing.T) {
+	storedData := map[string]model.StoredData{
+		"list": {Value: []any{"a"}},
+	}
+
+	runCommands(t, storedData, []commandCase{
+		{"invalid bit", []any{"SETBIT", "bm", 1, 2}, "-ERR bit is not an integer or out of range\r\n", SetBit},
+		{"negative offset", []any{"SETBIT", "bm", -1, 1}, "-ERR bit offset is not an integer or out of range\r\n", SetBit},
+		{"offset too large", []any{"SETBIT", "bm", 4294967296, 1}, "-ERR bit offset is not an integer or out of range\r\n", SetBit},
+		{"wrong type", []any{"SETBIT", "list", 1, 1}, "-ERR value is not type of string\r\n", SetBit},
+		{"set new bit", []any{"SETBIT", "bm", 7, 1}, ":0\r\n", SetBit},
+		{"set existing bit", []any{"SETBIT", "bm", 7, 0}, ":1\r\n", SetBit},
+		{"set far bit", []any{"SETBIT", "bm", 100, 1}, ":0\r\n", SetBit},
+		{"get set bit", []any{"GETBIT", "bm", 100}, ":1\r\n", GetBit},
+		{"get clear bit", []any{"GETBIT", "bm", 7}, ":0\r\n", GetBit},
+		{"get past end", []any{"GETBIT", "bm", 1000}, ":0\r\n", GetBit},
+		{"get missing key", []any{"GETBIT", "missing", 0}, ":0\r\n", GetBit},
+	})
+
+	if value := storedData["bm"].Value.(string); len(value) != 13 {
+		t.Errorf("expected the bitmap to grow to 13 bytes, got %d", len(value))
+	}
+}
+
+func TestSetBitOnIntegerValue(t *testing.T) {
+	// "1" is 0x31 = 00110001; setting bit 6 gives 00110011 = "3".
+	storedData := map[string]model.StoredData{"num": {Value: 1}}
+
+	var mu sync.RWMutex
+	if result := SetBit([]any{"SETBIT", "num", 6, 1}, storedData, &mu); result != ":0\r\n" {
+		t.Fatalf("expected :0, got %q", result)
+	}
+	if value := storedData["num"].Value; value != "3" {
+		t.Errorf("expected \"3\", got %v", value)
+	}
+}
+
+func TestBitCount(t *testing.T) {
+	storedData := map[string]model.StoredData{
+		"s": {Value: "foobar"},
+	}
+
+	runCommands(t, storedData, []commandCase{
+		{"whole string", []any{"BITCOUNT", "s"}, ":26\r\n", BitCount},
+		{"first byte", []any{"BITCOUNT", "s", 0, 0}, ":4\r\n", BitCount},
+		{"second byte", []any{"BITCOUNT", "s", 1, 1}, ":6\r\n", BitCount},
+		{"negative byte range", []any{"BITCOUNT", "s", -2, -1}, ":7\r\n", BitCount},
+		{"bit range", []any{"BITCOUNT", "s", 5, 30, "BIT"}, ":17\r\n", BitCount},
+		{"byte unit", []any{"BITCOUNT", "s", 1, 1, "BYTE"}, ":6\r\n", BitCount},
+		{"empty range", []any{"BITCOUNT", "s", 4, 2}, ":0\r\n", BitCount},
+		{"missing key", []any{"BITCOUNT", "missing"}, ":0\r\n", BitCount},
+		{"bad unit", []any{"BITCOUNT", "s", 0, 1, "NIBBLE"}, "-ERR syntax error\r\n", BitCount},
+		{"start only", []any{"BITCOUNT", "s", 0}, "-ERR syntax error\r\n", BitCount},
+	})
+}
+
+func TestBitPos(t *testing.T) {
+	storedData := map[string]model.StoredData{
+		"ones":  {Value: "\xff\xf0\x00"},
+		"full":  {Value: "\xff\xff\xff"},
+		"zeros": {Value: "\x00\x00\x00"},
+	}
+
+	runCommands(t, storedData, []commandCase{
+		{"first clear bit", []any{"BITPOS", "ones", 0}, ":12\r\n", BitPos},
+		{"first set bit from byte", []any{"BITPOS", "ones", 1, 2}, ":-1\r\n", BitPos},
+		{"first set bit", []any{"BITPOS", "zeros", 1}, ":-1\r\n", BitPos},
+		{"clear bit padding", []any{"BITPOS", "full", 0}, ":24\r\n", BitPos},
+		{"clear bit with end", []any{"BITPOS", "full", 0, 0, -1}, ":-1\r\n", BitPos},
+		{"bit range", []any{"BITPOS", "ones", 1, 7, 15, "BIT"}, ":7\r\n", BitPos},
+		{"clear bit in bit range", []any{"BITPOS", "ones", 0, 2, 13, "BIT"}, ":12\r\n", BitPos},
+		{"missing key clear", []any{"BITPOS", "missing", 0}, ":0\r\n", BitPos},
+		{"missing key set", []any{"BITPOS", "missing", 1}, ":-1\r\n", BitPos},
+		{"invalid bit", []any{"BITPOS", "ones", 2}, "-ERR The bit argument must be 1 or 0.\r\n", BitPos},
+	})
+}
+
+func TestBitOp(t *testing.T) {
+	storedData := map[string]model.StoredData{
+		"a": {Value: "\xf0\x0f"},
+		"b": {Value: "\xcc"},
+		"c": {Value: "\xaa\xaa"},
+	}
+
+	tests := []struct {
+		cmdArray []any
+		expected string
+		result   string
+	}{
+		{[]any{"BITOP", "AND", "dst", "a", "b"}, ":2\r\n", "\xc0\x00"},
+		{[]any{"BITOP", "OR", "dst", "a", "b"}, ":2\r\n", "\xfc\x0f"},
+		{[]any{"BITOP", "XOR", "dst", "a", "b"}, ":2\r\n", "\x3c\x0f"},
+		{[]any{"BITOP", "NOT", "dst", "a"}, ":2\r\n", "\x0f\xf0"},
+		{[]any{"BITOP", "DIFF", "dst", "a", "b", "c"}, ":2\r\n", "\x10\x05"},
+		{[]any{"BITOP", "DIFF1", "dst", "a", "b", "c"}, ":2\r\n", "\x0e\xa0"},
+		{[]any{"BITOP", "ANDOR", "dst", "a", "b", "c"}, ":2\r\n", "\xe0\x0a"},
+		{[]any{"BITOP", "ONE", "dst", "a", "b", "c"}, ":2\r\n", "\x16\xa5"},
+	}
+
+	var mu sync.RWMutex
+	for _, tt := range tests {
+		if result := BitOp(tt.cmdArray, storedData, &mu); result != tt.expected {
+			t.Errorf("%v: expected %q, got %q", tt.cmdArray[1], tt.expected, result)
+			continue
+		}
+		if value := storedData["dst"].Value.(string); value != tt.result {
+			t.Errorf("%v: expected %q, got %q", tt.cmdArray[1], tt.result, value)
+		}
+	}
+
+	runCommands(t, storedData, []commandCase{
+		{"NOT with two keys", []any{"BITOP", "NOT", "dst", "a", "b"}, "-ERR BITOP NOT must be called with a single source key.\r\n", BitOp},
+		{"DIFF with one key", []any{"BITOP", "DIFF", "dst", "a"}, "-ERR BITOP DIFF must be called with at least two source keys.\r\n", BitOp},
+		{"unknown op", []any{"BITOP", "NAND", "dst", "a"}, "-ERR syntax error\r\n", BitOp},
+		{"only missing keys", []any{"BITOP", "OR", "dst", "x", "y"}, ":0\r\n", BitOp},
+	})
+
+	if _, found := storedData["dst"]; found {
+		t.Error("expected an empty result to delete the destination")
+	}
+}
+
+func TestBitField(t *testing.T) {
+	storedData := map[string]model.StoredData{}
+
+	runCommands(t, storedData, []commandCase{
+		{"get on missing key", []any{"BITFIELD", "bf", "GET", "u8", 0}, "*1\r\n:0\r\n", BitField},
+		{"set returns old value", []any{"BITFIELD", "bf", "SET", "u8", 0, 200, "GET", "u8", 0}, "*2\r\n:0\r\n:200\r\n", BitField},
+		{"signed read", []any{"BITFIELD", "bf", "GET", "i8", 0}, "*1\r\n:-56\r\n", BitField},
+		{"incr wraps", []any{"BITFIELD", "bf", "INCRBY", "u8", 0, 100}, "*1\r\n:44\r\n", BitField},
+		{"incr saturates", []any{"BITFIELD", "bf", "OVERFLOW", "SAT", "INCRBY", "u8", 0, 1000}, "*1\r\n:255\r\n", BitField},
+		{"incr fails", []any{"BITFIELD", "bf", "OVERFLOW", "FAIL", "INCRBY", "u8", 0, 1, "GET", "u8", 0}, "*2\r\n$-1\r\n:255\r\n", BitField},
+		{"signed wrap", []any{"BITFIELD", "sf", "SET", "i8", 0, 127, "INCRBY", "i8", 0, 1}, "*2\r\n:0\r\n:-128\r\n", BitField},
+		{"signed saturate low", []any{"BITFIELD", "sf", "OVERFLOW", "SAT", "INCRBY", "i8", 0, -10}, "*1\r\n:-128\r\n", BitField},
+		{"hash offset", []any{"BITFIELD", "hf", "SET", "u4", "#1", 15, "GET", "u8", 0}, "*2\r\n:0\r\n:15\r\n", BitField},
+		{"unaligned i5", []any{"BITFIELD", "uf", "SET", "i5", 3, -3, "GET", "i5", 3, "GET", "u8", 0}, "*3\r\n:0\r\n:-3\r\n:29\r\n", BitField},
+		{"i64 max", []any{"BITFIELD", "big", "SET", "i64", 0, "9223372036854775807", "OVERFLOW", "SAT", "INCRBY", "i64", 0, 1}, "*2\r\n:0\r\n:9223372036854775807\r\n", BitField},
+		{"u64 rejected", []any{"BITFIELD", "bf", "GET", "u64", 0}, "-ERR Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is.\r\n", BitField},
+		{"bad overflow", []any{"BITFIELD", "bf", "OVERFLOW", "CLAMP"}, "-ERR Invalid OVERFLOW type specified\r\n", BitField},
+		{"read only get", []any{"BITFIELD_RO", "bf", "GET", "u8", 0}, "*1\r\n:255\r\n", BitFieldRO},
+		{"read only rejects set", []any{"BITFIELD_RO", "bf", "SET", "u8", 0, 1}, "-ERR BITFIELD_RO only supports the GET subcommand\r\n", BitFieldRO},
+	})
+}
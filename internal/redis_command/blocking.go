@@ -0,0 +1,58 @@
+package redis_command
+
+import (
+	"math"
+	"redis-go-clone/internal/model"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// BlockingRequest is returned by a blocking command that could not be served
+// right away. The caller parks the client until one of Keys is signalled as
+// ready and then calls Retry, or replies with TimeoutReply once Timeout has
+// passed. A zero Timeout blocks forever.
+type BlockingRequest struct {
+	Keys         []string
+	Timeout      time.Duration
+	Retry        func(storedData map[string]model.StoredData, mu *sync.RWMutex) (string, bool)
+	TimeoutReply string
+}
+
+var keyReadyHook func(key string)
+
+// SetKeyReadyHook registers the function called whenever a key may have
+// become able to serve a blocked client, e.g. after ZADD. The hook runs
+// while the store lock is held, so it must not take that lock itself.
+func SetKeyReadyHook(hook func(key string)) {
+	keyReadyHook = hook
+}
+
+func signalKeyAsReady(key string) {
+	if keyReadyHook != nil {
+		keyReadyHook(key)
+	}
+}
+
+// parseBlockingTimeout parses a timeout given in (possibly fractional)
+// seconds.
+func parseBlockingTimeout(arg any) (time.Duration, string) {
+	var seconds float64
+	switch v := arg.(type) {
+	case int:
+		seconds = float64(v)
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return 0, "-ERR timeout is not a float or out of range\r\n"
+		}
+		seconds = f
+	default:
+		return 0, "-ERR timeout is not a float or out of range\r\n"
+	}
+
+	if seconds < 0 {
+		return 0, "-ERR timeout is negative\r\n"
+	}
+	return time.Duration(seconds * float64(time.Second)), ""
+}
@@ -0,0 +1,93 @@
+package redis_command
+
+import (
+	"math"
+	"strconv"
+)
+
+// argToString accepts both string and integer arguments, since the RESP
+// parser turns numeric bulk strings into ints.
+func argToString(arg any) (string, bool) {
+	switch v := arg.(type) {
+	case string:
+		return v, true
+	case int:
+		return strconv.Itoa(v), true
+	default:
+		return "", false
+	}
+}
+
+func argToInt64(arg any) (int64, bool) {
+	switch v := arg.(type) {
+	case int:
+		return int64(v), true
+	case string:
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		return n, true
+	default:
+		return 0, false
+	}
+}
+
+// stringArgs converts every argument with argToString.
+func stringArgs(args []any) ([]string, bool) {
+	result := make([]string, 0, len(args))
+	for _, arg := range args {
+		s, ok := argToString(arg)
+		if !ok {
+			return nil, false
+		}
+		result = append(result, s)
+	}
+	return result, true
+}
+
+// keyArgs converts a list of key arguments, which must be strings like every
+// other key argument.
+func keyArgs(args []any) ([]string, bool) {
+	result := make([]string, 0, len(args))
+	for _, arg := range args {
+		key, ok := arg.(string)
+		if !ok {
+			return nil, false
+		}
+		result = append(result, key)
+	}
+	return result, true
+}
+
+// argToFloat parses a score-like argument. NaN is rejected, infinities are
+// accepted as "inf", "+inf" and "-inf".
+func argToFloat(arg any) (float64, bool) {
+	switch v := arg.(type) {
+	case int:
+		return float64(v), true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || math.IsNaN(f) {
+			return 0, false
+		}
+		return f, true
+	default:
+		return 0, false
+	}
+}
+
+// formatFloat renders a float the way Redis replies with scores: integral
+// values without a fractional part and infinities as "inf"/"-inf".
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case f == math.Trunc(f) && math.Abs(f) < 1e17:
+		return strconv.FormatInt(int64(f), 10)
+	default:
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+}
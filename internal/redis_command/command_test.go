@@ -0,0 +1,29 @@
+package redis_command
+
+import (
+	"redis-go-clone/internal/model"
+	"sync"
+	"testing"
+)
+
+type commandFunc func([]any, map[string]model.StoredData, *sync.RWMutex) string
+
+// commandCase is a command run by runCommands and the reply it expects.
+type commandCase struct {
+	name     string
+	cmdArray []any
+	expected string
+	fn       commandFunc
+}
+
+// runCommands runs tests in order against storedData, so that a case sees
+// the changes made by the ones before it.
+func runCommands(t *testing.T, storedData map[string]model.StoredData, tests []commandCase) {
+	t.Helper()
+	var mu sync.RWMutex
+	for _, tt := range tests {
+		if result := tt.fn(tt.cmdArray, storedData, &mu); result != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.expected, result)
+		}
+	}
+}
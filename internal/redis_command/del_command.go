@@ -1,8 +1,13 @@
 package redis_command
 
 import (
+	"redis-go-clone/internal/memory"
 	"redis-go-clone/internal/model"
+	"redis-go-clone/internal/notify"
+	"redis-go-clone/internal/stats"
+	"redis-go-clone/internal/watch"
 	"strconv"
+	"strings"
 	"sync"
 )
 
@@ -22,6 +27,7 @@ func Del(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMute
 		}
 		if _, exists := storedData[key]; exists {
 			delete(storedData, key)
+			keyspaceEvent(notify.Generic, "del", key)
 			deletedCount++
 		}
 	}
@@ -29,3 +35,28 @@ func Del(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMute
 
 	return ":" + strconv.Itoa(deletedCount) + "\r\n"
 }
+
+// FlushAll removes every key. The single database makes FLUSHDB an alias.
+// The ASYNC and SYNC modes are accepted, but flushing is always synchronous.
+func FlushAll(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) > 2 {
+		return "-ERR syntax error\r\n"
+	}
+	if len(cmdArray) == 2 {
+		mode, _ := argToString(cmdArray[1])
+		if !strings.EqualFold(mode, "ASYNC") && !strings.EqualFold(mode, "SYNC") {
+			return "-ERR syntax error\r\n"
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	for key := range storedData {
+		watch.Touch(key)
+		memory.KeyChanged(key)
+		stats.Changed()
+	}
+	clear(storedData)
+	return "+OK\r\n"
+}
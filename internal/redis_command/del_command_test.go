@@ -2,6 +2,7 @@ package redis_command
 
 import (
 	"redis-go-clone/internal/model"
+	"redis-go-clone/internal/stats"
 	"sync"
 	"testing"
 )
@@ -61,3 +62,34 @@ func TestDel(t *testing.T) {
 		})
 	}
 }
+
+func TestFlushAll(t *testing.T) {
+	tests := []struct {
+		name     string
+		cmdArray []any
+		expected string
+		keysLeft int
+	}{
+		{"bad mode", []any{"FLUSHALL", "LATER"}, "-ERR syntax error\r\n", 2},
+		{"too many arguments", []any{"FLUSHALL", "SYNC", "ASYNC"}, "-ERR syntax error\r\n", 2},
+		{"flush", []any{"FLUSHALL"}, "+OK\r\n", 0},
+		{"flush async", []any{"FLUSHDB", "async"}, "+OK\r\n", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storedData := map[string]model.StoredData{"k1": {Value: "v1"}, "k2": {Value: "v2"}}
+			var mu sync.RWMutex
+			changes := stats.Get().Dirty
+			if result := FlushAll(tt.cmdArray, storedData, &mu); result != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, result)
+			}
+			if len(storedData) != tt.keysLeft {
+				t.Errorf("expected %d keys left, got %d", tt.keysLeft, len(storedData))
+			}
+			if changed := stats.Get().Dirty - changes; changed != int64(2-tt.keysLeft) {
+				t.Errorf("changes since the last save grew by %d, want %d", changed, 2-tt.keysLeft)
+			}
+		})
+	}
+}
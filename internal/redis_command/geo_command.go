@@ -0,0 +1,531 @@
+package redis_command
+
+import (
+	"fmt"
+	"math"
+	"redis-go-clone/internal/model"
+	"redis-go-clone/pkg/geohash"
+	"redis-go-clone/pkg/resp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Distance units accepted by the GEO commands, in meters.
+var geoUnits = map[string]float64{
+	"m":  1,
+	"km": 1000,
+	"ft": 0.3048,
+	"mi": 1609.34,
+}
+
+// GeoAdd translates the coordinates into 52-bit geohash scores and adds them
+// to a sorted set with ZADD.
+func GeoAdd(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) < 5 {
+		return "-ERR wrong number of arguments for GEOADD\r\n"
+	}
+
+	key, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR invalid argument for GEOADD\r\n"
+	}
+
+	var nx, xx bool
+	zaddArray := []any{"ZADD", key}
+	idx := 2
+flags:
+	for ; idx < len(cmdArray); idx++ {
+		opt, ok := cmdArray[idx].(string)
+		if !ok {
+			break
+		}
+		switch strings.ToUpper(opt) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "CH":
+		default:
+			break flags
+		}
+		zaddArray = append(zaddArray, opt)
+	}
+
+	triples := cmdArray[idx:]
+	if len(triples) == 0 || len(triples)%3 != 0 || (nx && xx) {
+		return "-ERR syntax error\r\n"
+	}
+
+	for i := 0; i < len(triples); i += 3 {
+		longitude, latitude, errReply := parseLonLat(triples[i], triples[i+1])
+		if errReply != "" {
+			return errReply
+		}
+		score, _ := geohash.EncodeWGS84(longitude, latitude)
+		zaddArray = append(zaddArray, strconv.FormatUint(score, 10), triples[i+2])
+	}
+
+	return ZAdd(zaddArray, storedData, mu)
+}
+
+func GeoPos(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) < 2 {
+		return "-ERR wrong number of arguments for GEOPOS\r\n"
+	}
+
+	key, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR invalid argument for GEOPOS\r\n"
+	}
+	members, ok := stringArgs(cmdArray[2:])
+	if !ok {
+		return "-ERR invalid argument for GEOPOS\r\n"
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+
+	zset, errReply := lookupZSet(storedData, key)
+	if errReply != "" {
+		return errReply
+	}
+
+	result := make([]any, 0, len(members))
+	for _, member := range members {
+		longitude, latitude, found := geoMemberPosition(zset, member)
+		if !found {
+			result = append(result, nil)
+			continue
+		}
+		result = append(result, []any{formatCoordinate(longitude), formatCoordinate(latitude)})
+	}
+	return resp.SerializeBulk(result)
+}
+
+func GeoDist(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) != 4 && len(cmdArray) != 5 {
+		return "-ERR wrong number of arguments for GEODIST\r\n"
+	}
+
+	key, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR invalid argument for GEODIST\r\n"
+	}
+	members, ok := stringArgs(cmdArray[2:4])
+	if !ok {
+		return "-ERR invalid argument for GEODIST\r\n"
+	}
+	conversion := 1.0
+	if len(cmdArray) == 5 {
+		if conversion, ok = parseGeoUnit(cmdArray[4]); !ok {
+			return "-ERR unsupported unit provided. please use M, KM, FT, MI\r\n"
+		}
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+
+	zset, errReply := lookupZSet(storedData, key)
+	if errReply != "" {
+		return errReply
+	}
+
+	lon1, lat1, found1 := geoMemberPosition(zset, members[0])
+	lon2, lat2, found2 := geoMemberPosition(zset, members[1])
+	if !found1 || !found2 {
+		return "$-1\r\n"
+	}
+	return resp.SerializeRESP(formatDistance(geohash.Distance(lon1, lat1, lon2, lat2)/conversion), true)
+}
+
+func GeoHash(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) < 2 {
+		return "-ERR wrong number of arguments for GEOHASH\r\n"
+	}
+
+	key, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR invalid argument for GEOHASH\r\n"
+	}
+	members, ok := stringArgs(cmdArray[2:])
+	if !ok {
+		return "-ERR invalid argument for GEOHASH\r\n"
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+
+	zset, errReply := lookupZSet(storedData, key)
+	if errReply != "" {
+		return errReply
+	}
+
+	result := make([]any, 0, len(members))
+	for _, member := range members {
+		var score float64
+		found := false
+		if zset != nil {
+			score, found = zset.Score(member)
+		}
+		if !found {
+			result = append(result, nil)
+			continue
+		}
+		result = append(result, geohash.String(uint64(score)))
+	}
+	return resp.SerializeBulk(result)
+}
+
+func GeoSearch(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) < 7 {
+		return "-ERR wrong number of arguments for GEOSEARCH\r\n"
+	}
+
+	key, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR invalid argument for GEOSEARCH\r\n"
+	}
+
+	query, errReply := parseGeoSearch("GEOSEARCH", cmdArray[2:], false)
+	if errReply != "" {
+		return errReply
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+
+	points, errReply := geoSearch(storedData, key, query)
+	if errReply != "" {
+		return errReply
+	}
+
+	result := make([]any, 0, len(points))
+	for _, p := range points {
+		if !query.withDist && !query.withHash && !query.withCoord {
+			result = append(result, p.member)
+			continue
+		}
+		item := []any{p.member}
+		if query.withDist {
+			item = append(item, formatDistance(p.dist/query.conversion))
+		}
+		if query.withHash {
+			item = append(item, int(p.score))
+		}
+		if query.withCoord {
+			item = append(item, []any{formatCoordinate(p.longitude), formatCoordinate(p.latitude)})
+		}
+		result = append(result, item)
+	}
+	return resp.SerializeBulk(result)
+}
+
+// GeoSearchStore stores the matches in destination, scored by geohash or,
+// with STOREDIST, by their distance in the requested unit.
+func GeoSearchStore(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) < 8 {
+		return "-ERR wrong number of arguments for GEOSEARCHSTORE\r\n"
+	}
+
+	keys, ok := keyArgs(cmdArray[1:3])
+	if !ok {
+		return "-ERR invalid argument for GEOSEARCHSTORE\r\n"
+	}
+	destination, source := keys[0], keys[1]
+
+	query, errReply := parseGeoSearch("GEOSEARCHSTORE", cmdArray[3:], true)
+	if errReply != "" {
+		return errReply
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	points, errReply := geoSearch(storedData, source, query)
+	if errReply != "" {
+		return errReply
+	}
+
+	members := make([]model.ZMember, 0, len(points))
+	for _, p := range points {
+		score := p.score
+		if query.storeDist {
+			score = p.dist / query.conversion
+		}
+		members = append(members, model.ZMember{Member: p.member, Score: score})
+	}
+	return storeZMembers(storedData, destination, members, "geosearchstore")
+}
+
+type geoQuery struct {
+	fromMember string
+	hasMember  bool
+	longitude  float64
+	latitude   float64
+	hasLonLat  bool
+
+	radius     float64
+	byRadius   bool
+	width      float64
+	height     float64
+	byBox      bool
+	conversion float64
+
+	sort      int // 0 unsorted, 1 ascending, -1 descending
+	count     int
+	any       bool
+	withDist  bool
+	withHash  bool
+	withCoord bool
+	storeDist bool
+}
+
+type geoPoint struct {
+	member    string
+	score     float64
+	longitude float64
+	latitude  float64
+	dist      float64
+}
+
+func parseGeoSearch(name string, args []any, store bool) (geoQuery, string) {
+	query := geoQuery{conversion: 1}
+
+	for i := 0; i < len(args); i++ {
+		opt, ok := args[i].(string)
+		if !ok {
+			return query, "-ERR syntax error\r\n"
+		}
+		remaining := len(args) - i - 1
+
+		switch strings.ToUpper(opt) {
+		case "FROMMEMBER":
+			if remaining < 1 {
+				return query, "-ERR syntax error\r\n"
+			}
+			if query.fromMember, ok = argToString(args[i+1]); !ok {
+				return query, "-ERR syntax error\r\n"
+			}
+			query.hasMember = true
+			i++
+		case "FROMLONLAT":
+			if remaining < 2 {
+				return query, "-ERR syntax error\r\n"
+			}
+			longitude, latitude, errReply := parseLonLat(args[i+1], args[i+2])
+			if errReply != "" {
+				return query, errReply
+			}
+			query.longitude, query.latitude, query.hasLonLat = longitude, latitude, true
+			i += 2
+		case "BYRADIUS":
+			if remaining < 2 {
+				return query, "-ERR syntax error\r\n"
+			}
+			radius, ok := argToFloat(args[i+1])
+			if !ok {
+				return query, "-ERR need numeric radius\r\n"
+			}
+			if radius < 0 {
+				return query, "-ERR radius cannot be negative\r\n"
+			}
+			conversion, ok := parseGeoUnit(args[i+2])
+			if !ok {
+				return query, "-ERR unsupported unit provided. please use M, KM, FT, MI\r\n"
+			}
+			query.radius, query.conversion, query.byRadius = radius, conversion, true
+			i += 2
+		case "BYBOX":
+			if remaining < 3 {
+				return query, "-ERR syntax error\r\n"
+			}
+			width, ok1 := argToFloat(args[i+1])
+			height, ok2 := argToFloat(args[i+2])
+			if !ok1 || !ok2 {
+				return query, "-ERR need numeric width and height\r\n"
+			}
+			if width < 0 || height < 0 {
+				return query, "-ERR height or width cannot be negative\r\n"
+			}
+			conversion, ok := parseGeoUnit(args[i+3])
+			if !ok {
+				return query, "-ERR unsupported unit provided. please use M, KM, FT, MI\r\n"
+			}
+			query.width, query.height, query.conversion, query.byBox = width, height, conversion, true
+			i += 3
+		case "ASC":
+			query.sort = 1
+		case "DESC":
+			query.sort = -1
+		case "COUNT":
+			if remaining < 1 {
+				return query, "-ERR syntax error\r\n"
+			}
+			count, ok := argToInt64(args[i+1])
+			if !ok || count <= 0 {
+				return query, "-ERR COUNT must be > 0\r\n"
+			}
+			query.count = int(count)
+			i++
+			if remaining > 1 {
+				if next, ok := args[i+1].(string); ok && strings.EqualFold(next, "ANY") {
+					query.any = true
+					i++
+				}
+			}
+		case "WITHDIST":
+			query.withDist = true
+		case "WITHHASH":
+			query.withHash = true
+		case "WITHCOORD":
+			query.withCoord = true
+		case "STOREDIST":
+			if !store {
+				return query, "-ERR syntax error\r\n"
+			}
+			query.storeDist = true
+		default:
+			return query, "-ERR syntax error\r\n"
+		}
+	}
+
+	if query.hasMember == query.hasLonLat {
+		return query, "-ERR exactly one of FROMMEMBER or FROMLONLAT can be specified for " + strings.ToLower(name) + "\r\n"
+	}
+	if query.byRadius == query.byBox {
+		return query, "-ERR exactly one of BYRADIUS and BYBOX can be specified for " + strings.ToLower(name) + "\r\n"
+	}
+	if query.any && query.count == 0 {
+		return query, "-ERR the ANY argument requires COUNT argument\r\n"
+	}
+	if store && (query.withDist || query.withHash || query.withCoord) {
+		return query, "-ERR STORE option in " + name + " is not compatible with WITHDIST, WITHHASH and WITHCOORD options\r\n"
+	}
+
+	// COUNT without ANY returns the closest matches.
+	if query.count > 0 && !query.any && query.sort == 0 {
+		query.sort = 1
+	}
+	return query, ""
+}
+
+// geoSearch scans the cells around the search center, as Redis does, and
+// keeps the points that fall inside the requested shape.
+func geoSearch(storedData map[string]model.StoredData, key string, query geoQuery) ([]geoPoint, string) {
+	zset, errReply := lookupZSet(storedData, key)
+	if errReply != "" || zset == nil {
+		return nil, errReply
+	}
+
+	if query.hasMember {
+		var found bool
+		query.longitude, query.latitude, found = geoMemberPosition(zset, query.fromMember)
+		if !found {
+			return nil, "-ERR could not decode requested zset member\r\n"
+		}
+	}
+
+	var radiusM, halfWidthM, halfHeightM float64
+	if query.byRadius {
+		radiusM = query.radius * query.conversion
+		halfWidthM, halfHeightM = radiusM, radiusM
+	} else {
+		halfWidthM = query.width / 2 * query.conversion
+		halfHeightM = query.height / 2 * query.conversion
+		radiusM = math.Sqrt(halfWidthM*halfWidthM + halfHeightM*halfHeightM)
+	}
+
+	limit := 0
+	if query.any {
+		limit = query.count
+	}
+
+	var points []geoPoint
+	area := geohash.AreasByShape(query.longitude, query.latitude, radiusM, halfWidthM, halfHeightM)
+	for _, cell := range area.Cells() {
+		if limit > 0 && len(points) >= limit {
+			break
+		}
+
+		min, max := geohash.ScoreRange(cell)
+		r := model.ScoreRange{Min: float64(min), Max: float64(max), MaxEx: true}
+		for _, m := range zset.RangeByScore(r, false, 0, -1) {
+			if limit > 0 && len(points) >= limit {
+				break
+			}
+
+			longitude, latitude := geohash.DecodeWGS84(uint64(m.Score))
+			var dist float64
+			var inside bool
+			if query.byRadius {
+				dist = geohash.Distance(query.longitude, query.latitude, longitude, latitude)
+				inside = dist <= radiusM
+			} else {
+				dist, inside = geohash.DistanceIfInRectangle(halfWidthM*2, halfHeightM*2,
+					query.longitude, query.latitude, longitude, latitude)
+			}
+			if inside {
+				points = append(points, geoPoint{m.Member, m.Score, longitude, latitude, dist})
+			}
+		}
+	}
+
+	switch query.sort {
+	case 1:
+		sort.SliceStable(points, func(i, j int) bool { return points[i].dist < points[j].dist })
+	case -1:
+		sort.SliceStable(points, func(i, j int) bool { return points[i].dist > points[j].dist })
+	}
+	if query.count > 0 && len(points) > query.count {
+		points = points[:query.count]
+	}
+	return points, ""
+}
+
+func geoMemberPosition(zset *model.SortedSet, member string) (float64, float64, bool) {
+	if zset == nil {
+		return 0, 0, false
+	}
+	score, found := zset.Score(member)
+	if !found {
+		return 0, 0, false
+	}
+	longitude, latitude := geohash.DecodeWGS84(uint64(score))
+	return longitude, latitude, true
+}
+
+func parseLonLat(lonArg, latArg any) (float64, float64, string) {
+	longitude, ok1 := argToFloat(lonArg)
+	latitude, ok2 := argToFloat(latArg)
+	if !ok1 || !ok2 {
+		return 0, 0, "-ERR value is not a valid float\r\n"
+	}
+	if !geohash.ValidCoordinates(longitude, latitude) {
+		return 0, 0, fmt.Sprintf("-ERR invalid longitude,latitude pair %f,%f\r\n", longitude, latitude)
+	}
+	return longitude, latitude, ""
+}
+
+func parseGeoUnit(arg any) (float64, bool) {
+	unit, ok := arg.(string)
+	if !ok {
+		return 0, false
+	}
+	conversion, ok := geoUnits[strings.ToLower(unit)]
+	return conversion, ok
+}
+
+// formatDistance uses the fixed four decimals of GEODIST and WITHDIST.
+func formatDistance(dist float64) string {
+	return strconv.FormatFloat(dist, 'f', 4, 64)
+}
+
+// formatCoordinate prints 17 decimals with trailing zeros removed, like
+// addReplyHumanLongDouble.
+func formatCoordinate(value float64) string {
+	s := strconv.FormatFloat(value, 'f', 17, 64)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
@@ -0,0 +1,151 @@
+package redis_command
+
+import (
+	"redis-go-clone/internal/model"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func newSicily(t *testing.T) (map[string]model.StoredData, *sync.RWMutex) {
+	t.Helper()
+	storedData := make(map[string]model.StoredData)
+	mu := &sync.RWMutex{}
+	reply := GeoAdd([]any{"GEOADD", "Sicily", "13.361389", "38.115556", "Palermo", "15.087269", "37.502669", "Catania"}, storedData, mu)
+	if reply != ":2\r\n" {
+		t.Fatalf("expected :2, got %q", reply)
+	}
+	return storedData, mu
+}
+
+func TestGeoAdd(t *testing.T) {
+	storedData, mu := newSicily(t)
+
+	tests := []struct {
+		name     string
+		cmdArray []any
+		expected string
+	}{
+		{"existing member", []any{"GEOADD", "Sicily", "13.361389", "38.115556", "Palermo"}, ":0\r\n"},
+		{"CH counts moves", []any{"GEOADD", "Sicily", "CH", "13.5", "38.1", "Palermo"}, ":1\r\n"},
+		{"XX skips new members", []any{"GEOADD", "Sicily", "XX", "13", "38", "Trapani"}, ":0\r\n"},
+		{"NX skips existing members", []any{"GEOADD", "Sicily", "NX", "CH", "13.361389", "38.115556", "Palermo"}, ":0\r\n"},
+		{"NX and XX", []any{"GEOADD", "Sicily", "NX", "XX", "13", "38", "Trapani"}, "-ERR syntax error\r\n"},
+		{"incomplete triple", []any{"GEOADD", "Sicily", "13", "38"}, "-ERR wrong number of arguments for GEOADD\r\n"},
+		{"invalid latitude", []any{"GEOADD", "Sicily", "13", "86", "North"}, "-ERR invalid longitude,latitude pair 13.000000,86.000000\r\n"},
+	}
+
+	for _, tt := range tests {
+		if result := GeoAdd(tt.cmdArray, storedData, mu); result != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.expected, result)
+		}
+	}
+}
+
+func TestGeoQueries(t *testing.T) {
+	storedData, mu := newSicily(t)
+
+	tests := []struct {
+		name     string
+		cmdArray []any
+		expected string
+		fn       func([]any, map[string]model.StoredData, *sync.RWMutex) string
+	}{
+		{"score is the geohash", []any{"ZSCORE", "Sicily", "Palermo"}, "$16\r\n3479099956230698\r\n", ZScore},
+		{"distance in meters", []any{"GEODIST", "Sicily", "Palermo", "Catania"}, "$11\r\n166274.1516\r\n", GeoDist},
+		{"distance in km", []any{"GEODIST", "Sicily", "Palermo", "Catania", "km"}, "$8\r\n166.2742\r\n", GeoDist},
+		{"distance in miles", []any{"GEODIST", "Sicily", "Palermo", "Catania", "mi"}, "$8\r\n103.3182\r\n", GeoDist},
+		{"distance to missing member", []any{"GEODIST", "Sicily", "Palermo", "Foo"}, "$-1\r\n", GeoDist},
+		{"bad unit", []any{"GEODIST", "Sicily", "Palermo", "Catania", "yd"}, "-ERR unsupported unit provided. please use M, KM, FT, MI\r\n", GeoDist},
+		{"geohash", []any{"GEOHASH", "Sicily", "Palermo", "Catania", "Foo"}, "*3\r\n$11\r\nsqc8b49rny0\r\n$11\r\nsqdtr74hyu0\r\n$-1\r\n", GeoHash},
+		{
+			"positions",
+			[]any{"GEOPOS", "Sicily", "Palermo", "Foo"},
+			"*2\r\n*2\r\n$20\r\n13.36138933897018433\r\n$20\r\n38.11555639549629859\r\n$-1\r\n",
+			GeoPos,
+		},
+		{"wrong type", []any{"GEOPOS", "str", "a"}, "-ERR value is not type of zset\r\n", GeoPos},
+	}
+
+	storedData["str"] = model.StoredData{Value: "x"}
+	for _, tt := range tests {
+		if result := tt.fn(tt.cmdArray, storedData, mu); result != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.expected, result)
+		}
+	}
+}
+
+func TestGeoSearch(t *testing.T) {
+	storedData, mu := newSicily(t)
+	GeoAdd([]any{"GEOADD", "Sicily", "12.758489", "38.788135", "edge1", "17.241510", "38.788135", "edge2"}, storedData, mu)
+
+	tests := []struct {
+		name     string
+		cmdArray []any
+		expected string
+	}{
+		{
+			"radius with distance and coordinates",
+			[]any{"GEOSEARCH", "Sicily", "FROMLONLAT", 15, 37, "BYRADIUS", 200, "km", "ASC", "WITHCOORD", "WITHDIST"},
+			"*2\r\n" +
+				"*3\r\n$7\r\nCatania\r\n$7\r\n56.4413\r\n*2\r\n$20\r\n15.08726745843887329\r\n$20\r\n37.50266842333162032\r\n" +
+				"*3\r\n$7\r\nPalermo\r\n$8\r\n190.4424\r\n*2\r\n$20\r\n13.36138933897018433\r\n$20\r\n38.11555639549629859\r\n",
+		},
+		{
+			"box includes the edges",
+			[]any{"GEOSEARCH", "Sicily", "FROMLONLAT", 15, 37, "BYBOX", 400, 400, "km", "ASC", "WITHDIST"},
+			"*4\r\n*2\r\n$7\r\nCatania\r\n$7\r\n56.4413\r\n*2\r\n$7\r\nPalermo\r\n$8\r\n190.4424\r\n" +
+				"*2\r\n$5\r\nedge2\r\n$8\r\n279.7403\r\n*2\r\n$5\r\nedge1\r\n$8\r\n279.7405\r\n",
+		},
+		{"descending", []any{"GEOSEARCH", "Sicily", "FROMLONLAT", 15, 37, "BYRADIUS", 200, "km", "DESC"}, "*2\r\n$7\r\nPalermo\r\n$7\r\nCatania\r\n"},
+		{"count implies ascending", []any{"GEOSEARCH", "Sicily", "FROMMEMBER", "Palermo", "BYRADIUS", 500, "km", "COUNT", 2}, "*2\r\n$7\r\nPalermo\r\n$5\r\nedge1\r\n"},
+		{"with hash", []any{"GEOSEARCH", "Sicily", "FROMMEMBER", "Catania", "BYRADIUS", 1, "m", "WITHHASH"}, "*1\r\n*2\r\n$7\r\nCatania\r\n:3479447370796909\r\n"},
+		{"missing key", []any{"GEOSEARCH", "missing", "FROMLONLAT", 15, 37, "BYRADIUS", 1, "km"}, "*0\r\n"},
+		{"missing member", []any{"GEOSEARCH", "Sicily", "FROMMEMBER", "Foo", "BYRADIUS", 1, "km"}, "-ERR could not decode requested zset member\r\n"},
+		{"two centers", []any{"GEOSEARCH", "Sicily", "FROMMEMBER", "Palermo", "FROMLONLAT", 15, 37, "BYRADIUS", 1, "km"}, "-ERR exactly one of FROMMEMBER or FROMLONLAT can be specified for geosearch\r\n"},
+		{"no shape", []any{"GEOSEARCH", "Sicily", "FROMMEMBER", "Palermo", "ASC", "WITHDIST", "WITHHASH"}, "-ERR exactly one of BYRADIUS and BYBOX can be specified for geosearch\r\n"},
+		{"any without count", []any{"GEOSEARCH", "Sicily", "FROMMEMBER", "Palermo", "BYRADIUS", 1, "km", "ANY"}, "-ERR syntax error\r\n"},
+		{"zero count", []any{"GEOSEARCH", "Sicily", "FROMMEMBER", "Palermo", "BYRADIUS", 1, "km", "COUNT", 0}, "-ERR COUNT must be > 0\r\n"},
+		{"negative radius", []any{"GEOSEARCH", "Sicily", "FROMMEMBER", "Palermo", "BYRADIUS", -1, "km"}, "-ERR radius cannot be negative\r\n"},
+	}
+
+	for _, tt := range tests {
+		if result := GeoSearch(tt.cmdArray, storedData, mu); result != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.expected, result)
+		}
+	}
+
+	// With ANY the first match found is returned, whichever it is.
+	result := GeoSearch([]any{"GEOSEARCH", "Sicily", "FROMLONLAT", 15, 37, "BYRADIUS", 500, "km", "COUNT", 1, "ANY"}, storedData, mu)
+	if !strings.HasPrefix(result, "*1\r\n") {
+		t.Errorf("expected a single match with COUNT 1 ANY, got %q", result)
+	}
+}
+
+func TestGeoSearchStore(t *testing.T) {
+	storedData, mu := newSicily(t)
+
+	tests := []struct {
+		name     string
+		cmdArray []any
+		expected string
+		fn       func([]any, map[string]model.StoredData, *sync.RWMutex) string
+	}{
+		{"store geohashes", []any{"GEOSEARCHSTORE", "dst", "Sicily", "FROMLONLAT", 15, 37, "BYBOX", 400, 400, "km", "ASC", "COUNT", 3}, ":2\r\n", GeoSearchStore},
+		{"stored scores are geohashes", []any{"GEOHASH", "dst", "Catania"}, "*1\r\n$11\r\nsqdtr74hyu0\r\n", GeoHash},
+		{"store distances", []any{"GEOSEARCHSTORE", "dst", "Sicily", "FROMLONLAT", 15, 37, "BYRADIUS", 200, "km", "STOREDIST"}, ":2\r\n", GeoSearchStore},
+		{"stored distance", []any{"ZSCORE", "dst", "Catania"}, "$16\r\n56.4412578701582\r\n", ZScore},
+		{"with options rejected", []any{"GEOSEARCHSTORE", "dst", "Sicily", "FROMLONLAT", 15, 37, "BYRADIUS", 200, "km", "WITHDIST"}, "-ERR STORE option in GEOSEARCHSTORE is not compatible with WITHDIST, WITHHASH and WITHCOORD options\r\n", GeoSearchStore},
+		{"empty result deletes destination", []any{"GEOSEARCHSTORE", "dst", "Sicily", "FROMLONLAT", 0, 0, "BYRADIUS", 1, "km"}, ":0\r\n", GeoSearchStore},
+	}
+
+	for _, tt := range tests {
+		if result := tt.fn(tt.cmdArray, storedData, mu); result != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.expected, result)
+		}
+	}
+
+	if _, found := storedData["dst"]; found {
+		t.Error("expected an empty search to delete the destination")
+	}
+}
@@ -30,7 +30,7 @@ func Get(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMute
 		if time.Now().After(expiryTime) {
 			// Key expired; delete it
 			mu.Lock()
-			delete(storedData, key)
+			ExpireKey(storedData, key)
 			mu.Unlock()
 			return "$-1\r\n"
 		}
@@ -0,0 +1,461 @@
+package redis_command
+
+import (
+	"math"
+	"redis-go-clone/internal/model"
+	"redis-go-clone/internal/notify"
+	"redis-go-clone/pkg/resp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Reply codes shared by the per-field expiry commands.
+const (
+	fieldNotFound      = -2
+	fieldNoTTL         = -1
+	fieldConditionFail = 0
+	fieldUpdated       = 1
+	fieldDeleted       = 2
+)
+
+func HSet(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) < 4 || len(cmdArray)%2 != 0 {
+		return "-ERR wrong number of arguments for HSET\r\n"
+	}
+
+	key, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR invalid argument for HSET\r\n"
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	hash, errReply := lookupHash(storedData, key)
+	if errReply != "" {
+		return errReply
+	}
+	created := hash == nil
+	if created {
+		hash = model.NewHash()
+	}
+
+	added := 0
+	for i := 2; i < len(cmdArray); i += 2 {
+		field, ok := argToString(cmdArray[i])
+		if !ok {
+			return "-ERR invalid argument for HSET\r\n"
+		}
+		value, ok := argToString(cmdArray[i+1])
+		if !ok {
+			return "-ERR invalid argument for HSET\r\n"
+		}
+		if _, exists := hash.Fields[field]; !exists {
+			added++
+		}
+		// Overwriting a field clears its TTL, as in Redis.
+		hash.DeleteField(field)
+		hash.Fields[field] = value
+	}
+
+	storedData[key] = model.StoredData{Value: hash, ExpiryDate: storedData[key].ExpiryDate}
+	if created {
+		keyspaceEvent(notify.New, "new", key)
+	}
+	keyspaceEvent(notify.Hash, "hset", key)
+	return ":" + strconv.Itoa(added) + "\r\n"
+}
+
+func HGet(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) != 3 {
+		return "-ERR wrong number of arguments for HGET\r\n"
+	}
+
+	key, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR invalid argument for HGET\r\n"
+	}
+	field, ok := argToString(cmdArray[2])
+	if !ok {
+		return "-ERR invalid argument for HGET\r\n"
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	hash, errReply := lookupHash(storedData, key)
+	if errReply != "" {
+		return errReply
+	}
+	if hash == nil {
+		return "$-1\r\n"
+	}
+
+	value, found := hash.Fields[field]
+	if !found {
+		return "$-1\r\n"
+	}
+	return resp.SerializeRESP(value, true)
+}
+
+func HDel(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) < 3 {
+		return "-ERR wrong number of arguments for HDEL\r\n"
+	}
+
+	key, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR invalid argument for HDEL\r\n"
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	hash, errReply := lookupHash(storedData, key)
+	if errReply != "" {
+		return errReply
+	}
+	if hash == nil {
+		return ":0\r\n"
+	}
+
+	deleted := 0
+	for _, arg := range cmdArray[2:] {
+		field, ok := argToString(arg)
+		if !ok {
+			return "-ERR invalid argument for HDEL\r\n"
+		}
+		if _, exists := hash.Fields[field]; exists {
+			hash.DeleteField(field)
+			deleted++
+		}
+	}
+
+	if deleted > 0 {
+		keyspaceEvent(notify.Hash, "hdel", key)
+	}
+	if len(hash.Fields) == 0 {
+		delete(storedData, key)
+		keyspaceEvent(notify.Generic, "del", key)
+	}
+	return ":" + strconv.Itoa(deleted) + "\r\n"
+}
+
+func HGetAll(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) != 2 {
+		return "-ERR wrong number of arguments for HGETALL\r\n"
+	}
+
+	key, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR invalid argument for HGETALL\r\n"
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	hash, errReply := lookupHash(storedData, key)
+	if errReply != "" {
+		return errReply
+	}
+	if hash == nil {
+		return "*0\r\n"
+	}
+
+	result := make([]any, 0, len(hash.Fields)*2)
+	for field, value := range hash.Fields {
+		result = append(result, field, value)
+	}
+	return resp.SerializeBulk(result)
+}
+
+func HExpire(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	return hashExpire("HEXPIRE", cmdArray, storedData, mu, func(v int64) (int64, bool) {
+		now := time.Now().UnixMilli()
+		if v > (math.MaxInt64-now)/1000 {
+			return 0, false
+		}
+		return now + v*1000, true
+	})
+}
+
+func HPExpire(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	return hashExpire("HPEXPIRE", cmdArray, storedData, mu, func(v int64) (int64, bool) {
+		now := time.Now().UnixMilli()
+		if v > math.MaxInt64-now {
+			return 0, false
+		}
+		return now + v, true
+	})
+}
+
+func HExpireAt(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	return hashExpire("HEXPIREAT", cmdArray, storedData, mu, func(v int64) (int64, bool) {
+		if v > math.MaxInt64/1000 {
+			return 0, false
+		}
+		return v * 1000, true
+	})
+}
+
+func HPExpireAt(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	return hashExpire("HPEXPIREAT", cmdArray, storedData, mu, func(v int64) (int64, bool) {
+		return v, true
+	})
+}
+
+func HTTL(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	return hashTTL("HTTL", cmdArray, storedData, mu, 1000)
+}
+
+func HPTTL(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	return hashTTL("HPTTL", cmdArray, storedData, mu, 1)
+}
+
+func HPersist(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) < 5 {
+		return "-ERR wrong number of arguments for HPERSIST\r\n"
+	}
+
+	key, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR invalid argument for HPERSIST\r\n"
+	}
+
+	fields, err := parseHashFields(cmdArray[2:])
+	if err != "" {
+		return "-ERR " + err + " for HPERSIST\r\n"
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	hash, errReply := lookupHash(storedData, key)
+	if errReply != "" {
+		return errReply
+	}
+
+	result := make([]any, 0, len(fields))
+	persisted := false
+	for _, field := range fields {
+		switch {
+		case hash == nil || !hasField(hash, field):
+			result = append(result, fieldNotFound)
+		case !hasFieldTTL(hash, field):
+			result = append(result, fieldNoTTL)
+		default:
+			delete(hash.FieldExpiry, field)
+			result = append(result, fieldUpdated)
+			persisted = true
+		}
+	}
+	if persisted {
+		keyspaceEvent(notify.Hash, "hpersist", key)
+	}
+	return resp.SerializeRESP(result, false)
+}
+
+// hashExpire implements HEXPIRE and its variants. toUnixMs converts the time
+// argument into an absolute expiry in Unix milliseconds, and reports false
+// when that does not fit in an int64.
+func hashExpire(name string, cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex, toUnixMs func(int64) (int64, bool)) string {
+	if len(cmdArray) < 6 {
+		return "-ERR wrong number of arguments for " + name + "\r\n"
+	}
+
+	key, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR invalid argument for " + name + "\r\n"
+	}
+
+	ttl, ok := argToInt64(cmdArray[2])
+	var expiry int64
+	if ok && ttl >= 0 {
+		expiry, ok = toUnixMs(ttl)
+	}
+	if !ok || ttl < 0 {
+		return "-ERR invalid expire time in '" + strings.ToLower(name) + "' command\r\n"
+	}
+
+	rest := cmdArray[3:]
+	condition := ""
+	if opt, ok := rest[0].(string); ok {
+		switch upper := strings.ToUpper(opt); upper {
+		case "NX", "XX", "GT", "LT":
+			condition = upper
+			rest = rest[1:]
+		}
+	}
+
+	fields, err := parseHashFields(rest)
+	if err != "" {
+		return "-ERR " + err + " for " + name + "\r\n"
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	hash, errReply := lookupHash(storedData, key)
+	if errReply != "" {
+		return errReply
+	}
+
+	now := time.Now().UnixMilli()
+	result := make([]any, 0, len(fields))
+	updated, deleted := false, false
+	for _, field := range fields {
+		if hash == nil || !hasField(hash, field) {
+			result = append(result, fieldNotFound)
+			continue
+		}
+
+		current, hasTTL := hash.FieldExpiry[field]
+		met := true
+		switch condition {
+		case "NX":
+			met = !hasTTL
+		case "XX":
+			met = hasTTL
+		case "GT":
+			met = hasTTL && expiry > current
+		case "LT":
+			met = !hasTTL || expiry < current
+		}
+		if !met {
+			result = append(result, fieldConditionFail)
+			continue
+		}
+
+		if expiry <= now {
+			hash.DeleteField(field)
+			result = append(result, fieldDeleted)
+			deleted = true
+			continue
+		}
+
+		hash.FieldExpiry[field] = expiry
+		result = append(result, fieldUpdated)
+		updated = true
+	}
+
+	if updated {
+		keyspaceEvent(notify.Hash, "hexpire", key)
+	}
+	if deleted {
+		keyspaceEvent(notify.Hash, "hdel", key)
+	}
+	if hash != nil && len(hash.Fields) == 0 {
+		delete(storedData, key)
+		keyspaceEvent(notify.Generic, "del", key)
+	}
+	return resp.SerializeRESP(result, false)
+}
+
+// hashTTL implements HTTL and HPTTL; unit is the number of milliseconds per
+// reported unit.
+func hashTTL(name string, cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex, unit int64) string {
+	if len(cmdArray) < 5 {
+		return "-ERR wrong number of arguments for " + name + "\r\n"
+	}
+
+	key, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR invalid argument for " + name + "\r\n"
+	}
+
+	fields, err := parseHashFields(cmdArray[2:])
+	if err != "" {
+		return "-ERR " + err + " for " + name + "\r\n"
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	hash, errReply := lookupHash(storedData, key)
+	if errReply != "" {
+		return errReply
+	}
+
+	now := time.Now().UnixMilli()
+	result := make([]any, 0, len(fields))
+	for _, field := range fields {
+		switch {
+		case hash == nil || !hasField(hash, field):
+			result = append(result, fieldNotFound)
+		case !hasFieldTTL(hash, field):
+			result = append(result, fieldNoTTL)
+		default:
+			remaining := hash.FieldExpiry[field] - now
+			result = append(result, int((remaining+unit-1)/unit))
+		}
+	}
+	return resp.SerializeRESP(result, false)
+}
+
+// parseHashFields parses the trailing "FIELDS numfields field [field ...]"
+// block shared by the per-field expiry commands.
+func parseHashFields(args []any) ([]string, string) {
+	if len(args) < 3 {
+		return nil, "wrong number of arguments"
+	}
+
+	keyword, ok := args[0].(string)
+	if !ok || strings.ToUpper(keyword) != "FIELDS" {
+		return nil, "mandatory argument FIELDS is missing or not at the right position"
+	}
+
+	numFields, ok := argToInt64(args[1])
+	if !ok || numFields <= 0 {
+		return nil, "numfields should be greater than 0"
+	}
+	if int(numFields) != len(args)-2 {
+		return nil, "numfields does not match the number of arguments"
+	}
+
+	fields := make([]string, 0, numFields)
+	for _, arg := range args[2:] {
+		field, ok := argToString(arg)
+		if !ok {
+			return nil, "invalid argument"
+		}
+		fields = append(fields, field)
+	}
+	return fields, ""
+}
+
+// lookupHash returns the hash stored at key with its expired fields already
+// removed, or nil if the key does not exist. The caller must hold the write
+// lock.
+func lookupHash(storedData map[string]model.StoredData, key string) (*model.Hash, string) {
+	value, found := storedData[key]
+	if !found {
+		return nil, ""
+	}
+
+	hash, ok := value.Value.(*model.Hash)
+	if !ok {
+		return nil, "-ERR value is not type of hash\r\n"
+	}
+
+	if hash.RemoveExpiredFields(time.Now().UnixMilli()) > 0 {
+		keyspaceEvent(notify.Hash, "hexpired", key)
+	}
+	if len(hash.Fields) == 0 {
+		delete(storedData, key)
+		keyspaceEvent(notify.Generic, "del", key)
+		return nil, ""
+	}
+	return hash, ""
+}
+
+func hasField(hash *model.Hash, field string) bool {
+	_, found := hash.Fields[field]
+	return found
+}
+
+func hasFieldTTL(hash *model.Hash, field string) bool {
+	_, found := hash.FieldExpiry[field]
+	return found
+}
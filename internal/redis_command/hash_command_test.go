@@ -0,0 +1,305 @@
+package redis_command
+
+import (
+	"math"
+	"redis-go-clone/internal/model"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestHash(fields map[string]string, expiry map[string]int64) *model.Hash {
+	hash := model.NewHash()
+	for k, v := range fields {
+		hash.Fields[k] = v
+	}
+	for k, v := range expiry {
+		hash.FieldExpiry[k] = v
+	}
+	return hash
+}
+
+func TestHSet(t *testing.T) {
+	tests := []struct {
+		name     string
+		cmdArray []any
+		expected string
+	}{
+		{
+			name:     "missing argument",
+			cmdArray: []any{"HSET", "hash", "field"},
+			expected: "-ERR wrong number of arguments for HSET\r\n",
+		},
+		{
+			name:     "invalid key",
+			cmdArray: []any{"HSET", 123, "field", "value"},
+			expected: "-ERR invalid argument for HSET\r\n",
+		},
+		{
+			name:     "set new fields",
+			cmdArray: []any{"HSET", "hash", "f1", "v1", "f2", 2},
+			expected: ":2\r\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storedData := make(map[string]model.StoredData)
+			mu := &sync.RWMutex{}
+			if result := HSet(tt.cmdArray, storedData, mu); result != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestHSetClearsFieldTTL(t *testing.T) {
+	mu := &sync.RWMutex{}
+	future := time.Now().Add(time.Hour).UnixMilli()
+	storedData := map[string]model.StoredData{
+		"hash": {Value: newTestHash(map[string]string{"f1": "v1"}, map[string]int64{"f1": future})},
+	}
+
+	if result := HSet([]any{"HSET", "hash", "f1", "v2"}, storedData, mu); result != ":0\r\n" {
+		t.Fatalf("expected :0, got %q", result)
+	}
+
+	hash := storedData["hash"].Value.(*model.Hash)
+	if _, found := hash.FieldExpiry["f1"]; found {
+		t.Error("expected HSET to clear the field TTL")
+	}
+}
+
+func TestHGet(t *testing.T) {
+	past := time.Now().Add(-time.Second).UnixMilli()
+
+	tests := []struct {
+		name       string
+		cmdArray   []any
+		storedData map[string]model.StoredData
+		expected   string
+	}{
+		{
+			name:     "missing field",
+			cmdArray: []any{"HGET", "hash", "nope"},
+			storedData: map[string]model.StoredData{
+				"hash": {Value: newTestHash(map[string]string{"f1": "v1"}, nil)},
+			},
+			expected: "$-1\r\n",
+		},
+		{
+			name:     "existing field",
+			cmdArray: []any{"HGET", "hash", "f1"},
+			storedData: map[string]model.StoredData{
+				"hash": {Value: newTestHash(map[string]string{"f1": "v1"}, nil)},
+			},
+			expected: "$2\r\nv1\r\n",
+		},
+		{
+			name:     "expired field",
+			cmdArray: []any{"HGET", "hash", "f1"},
+			storedData: map[string]model.StoredData{
+				"hash": {Value: newTestHash(map[string]string{"f1": "v1", "f2": "v2"}, map[string]int64{"f1": past})},
+			},
+			expected: "$-1\r\n",
+		},
+		{
+			name:     "wrong type",
+			cmdArray: []any{"HGET", "str", "f1"},
+			storedData: map[string]model.StoredData{
+				"str": {Value: "value"},
+			},
+			expected: "-ERR value is not type of hash\r\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mu := &sync.RWMutex{}
+			if result := HGet(tt.cmdArray, tt.storedData, mu); result != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestHGetAllBulkStrings(t *testing.T) {
+	mu := &sync.RWMutex{}
+	storedData := map[string]model.StoredData{
+		"hash": {Value: newTestHash(map[string]string{"f\r\n:1": ""}, nil)},
+	}
+
+	expected := "*2\r\n$5\r\nf\r\n:1\r\n$0\r\n\r\n"
+	if result := HGetAll([]any{"HGETALL", "hash"}, storedData, mu); result != expected {
+		t.Errorf("expected %q, got %q", expected, result)
+	}
+}
+
+func TestHDel(t *testing.T) {
+	mu := &sync.RWMutex{}
+	storedData := map[string]model.StoredData{
+		"hash": {Value: newTestHash(map[string]string{"f1": "v1", "f2": "v2"}, nil)},
+	}
+
+	if result := HDel([]any{"HDEL", "hash", "f1", "missing"}, storedData, mu); result != ":1\r\n" {
+		t.Errorf("expected :1, got %q", result)
+	}
+	if result := HDel([]any{"HDEL", "hash", "f2"}, storedData, mu); result != ":1\r\n" {
+		t.Errorf("expected :1, got %q", result)
+	}
+	if _, found := storedData["hash"]; found {
+		t.Error("expected empty hash to be deleted")
+	}
+}
+
+func TestHExpire(t *testing.T) {
+	future := time.Now().Add(time.Hour).UnixMilli()
+
+	tests := []struct {
+		name     string
+		cmdArray []any
+		expected string
+	}{
+		{
+			name:     "missing FIELDS",
+			cmdArray: []any{"HEXPIRE", "hash", 10, "f1", "f2", "f3"},
+			expected: "-ERR mandatory argument FIELDS is missing or not at the right position for HEXPIRE\r\n",
+		},
+		{
+			name:     "numfields mismatch",
+			cmdArray: []any{"HEXPIRE", "hash", 10, "FIELDS", 2, "f1"},
+			expected: "-ERR numfields does not match the number of arguments for HEXPIRE\r\n",
+		},
+		{
+			name:     "negative ttl",
+			cmdArray: []any{"HEXPIRE", "hash", -1, "FIELDS", 1, "f1"},
+			expected: "-ERR invalid expire time in 'hexpire' command\r\n",
+		},
+		{
+			name:     "set ttl on existing and missing fields",
+			cmdArray: []any{"HEXPIRE", "hash", 10, "FIELDS", 2, "f1", "missing"},
+			expected: "*2\r\n:1\r\n:-2\r\n",
+		},
+		{
+			name:     "NX skips fields with a ttl",
+			cmdArray: []any{"HEXPIRE", "hash", 10, "NX", "FIELDS", 2, "f1", "f2"},
+			expected: "*2\r\n:1\r\n:0\r\n",
+		},
+		{
+			name:     "XX skips fields without a ttl",
+			cmdArray: []any{"HEXPIRE", "hash", 10, "XX", "FIELDS", 2, "f1", "f2"},
+			expected: "*2\r\n:0\r\n:1\r\n",
+		},
+		{
+			name:     "GT only extends",
+			cmdArray: []any{"HEXPIRE", "hash", 10, "GT", "FIELDS", 2, "f1", "f2"},
+			expected: "*2\r\n:0\r\n:0\r\n",
+		},
+		{
+			name:     "LT only shortens",
+			cmdArray: []any{"HEXPIRE", "hash", 10, "LT", "FIELDS", 2, "f1", "f2"},
+			expected: "*2\r\n:1\r\n:1\r\n",
+		},
+		{
+			name:     "zero ttl deletes the field",
+			cmdArray: []any{"HEXPIRE", "hash", 0, "FIELDS", 1, "f1"},
+			expected: "*1\r\n:2\r\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mu := &sync.RWMutex{}
+			storedData := map[string]model.StoredData{
+				"hash": {Value: newTestHash(map[string]string{"f1": "v1", "f2": "v2"}, map[string]int64{"f2": future})},
+			}
+			if result := HExpire(tt.cmdArray, storedData, mu); result != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestHashExpireOverflow(t *testing.T) {
+	tests := []struct {
+		name     string
+		run      commandFunc
+		cmdArray []any
+	}{
+		{"HEXPIRE", HExpire, []any{"HEXPIRE", "hash", math.MaxInt64 / 1000, "FIELDS", 1, "f1"}},
+		{"HEXPIRE max", HExpire, []any{"HEXPIRE", "hash", math.MaxInt64, "FIELDS", 1, "f1"}},
+		{"HPEXPIRE", HPExpire, []any{"HPEXPIRE", "hash", math.MaxInt64, "FIELDS", 1, "f1"}},
+		{"HEXPIREAT", HExpireAt, []any{"HEXPIREAT", "hash", math.MaxInt64/1000 + 1, "FIELDS", 1, "f1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mu := &sync.RWMutex{}
+			storedData := map[string]model.StoredData{
+				"hash": {Value: newTestHash(map[string]string{"f1": "v1"}, nil)},
+			}
+			want := "-ERR invalid expire time in '" + strings.ToLower(tt.cmdArray[0].(string)) + "' command\r\n"
+			if result := tt.run(tt.cmdArray, storedData, mu); result != want {
+				t.Errorf("expected %q, got %q", want, result)
+			}
+			if result := HGet([]any{"HGET", "hash", "f1"}, storedData, mu); result != "$2\r\nv1\r\n" {
+				t.Errorf("expected the field to be kept, got %q", result)
+			}
+		})
+	}
+}
+
+func TestHExpireAtPastDeletesKey(t *testing.T) {
+	mu := &sync.RWMutex{}
+	storedData := map[string]model.StoredData{
+		"hash": {Value: newTestHash(map[string]string{"f1": "v1"}, nil)},
+	}
+
+	past := strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)
+	if result := HExpireAt([]any{"HEXPIREAT", "hash", past, "FIELDS", 1, "f1"}, storedData, mu); result != "*1\r\n:2\r\n" {
+		t.Fatalf("expected *1 :2, got %q", result)
+	}
+	if _, found := storedData["hash"]; found {
+		t.Error("expected hash without fields to be deleted")
+	}
+}
+
+func TestHTTL(t *testing.T) {
+	mu := &sync.RWMutex{}
+	storedData := map[string]model.StoredData{
+		"hash": {Value: newTestHash(
+			map[string]string{"f1": "v1", "f2": "v2"},
+			map[string]int64{"f2": time.Now().Add(100 * time.Second).UnixMilli()},
+		)},
+	}
+
+	expected := "*3\r\n:-1\r\n:100\r\n:-2\r\n"
+	if result := HTTL([]any{"HTTL", "hash", "FIELDS", 3, "f1", "f2", "f3"}, storedData, mu); result != expected {
+		t.Errorf("expected %q, got %q", expected, result)
+	}
+
+	expected = "*1\r\n:-2\r\n"
+	if result := HPTTL([]any{"HPTTL", "missing", "FIELDS", 1, "f1"}, storedData, mu); result != expected {
+		t.Errorf("expected %q, got %q", expected, result)
+	}
+}
+
+func TestHPersist(t *testing.T) {
+	mu := &sync.RWMutex{}
+	storedData := map[string]model.StoredData{
+		"hash": {Value: newTestHash(
+			map[string]string{"f1": "v1", "f2": "v2"},
+			map[string]int64{"f2": time.Now().Add(time.Hour).UnixMilli()},
+		)},
+	}
+
+	expected := "*3\r\n:-1\r\n:1\r\n:-2\r\n"
+	if result := HPersist([]any{"HPERSIST", "hash", "FIELDS", 3, "f1", "f2", "f3"}, storedData, mu); result != expected {
+		t.Errorf("expected %q, got %q", expected, result)
+	}
+	if hasFieldTTL(storedData["hash"].Value.(*model.Hash), "f2") {
+		t.Error("expected HPERSIST to remove the field TTL")
+	}
+}
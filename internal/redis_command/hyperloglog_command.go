@@ -0,0 +1,218 @@
+package redis_command
+
+import (
+	"redis-go-clone/internal/model"
+	"redis-go-clone/internal/notify"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+func PFAdd(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) < 2 {
+		return "-ERR wrong number of arguments for PFADD\r\n"
+	}
+
+	key, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR invalid argument for PFADD\r\n"
+	}
+	elements, ok := stringArgs(cmdArray[2:])
+	if !ok {
+		return "-ERR invalid argument for PFADD\r\n"
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	hll, found, errReply := lookupHyperLogLog(storedData, key)
+	if errReply != "" {
+		return errReply
+	}
+
+	updated := !found
+	if !found {
+		hll = model.NewHyperLogLog()
+	}
+	for _, element := range elements {
+		if hll.Add(element) {
+			updated = true
+		}
+	}
+
+	if updated {
+		storeHyperLogLog(storedData, key, hll)
+		if !found {
+			keyspaceEvent(notify.New, "new", key)
+		}
+		keyspaceEvent(notify.String, "pfadd", key)
+		return ":1\r\n"
+	}
+	return ":0\r\n"
+}
+
+// PFCount returns the cached cardinality of a single key, refreshing the
+// cache when needed, or the cardinality of the union of several keys.
+func PFCount(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) < 2 {
+		return "-ERR wrong number of arguments for PFCOUNT\r\n"
+	}
+
+	keys, ok := keyArgs(cmdArray[1:])
+	if !ok {
+		return "-ERR invalid argument for PFCOUNT\r\n"
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(keys) == 1 {
+		hll, found, errReply := lookupHyperLogLog(storedData, keys[0])
+		if errReply != "" {
+			return errReply
+		}
+		if !found {
+			return ":0\r\n"
+		}
+		card := hll.Count()
+		storeHyperLogLog(storedData, keys[0], hll)
+		return ":" + strconv.FormatUint(card, 10) + "\r\n"
+	}
+
+	union, errReply := mergeHyperLogLogs(storedData, keys)
+	if errReply != "" {
+		return errReply
+	}
+	return ":" + strconv.FormatUint(union.Count(), 10) + "\r\n"
+}
+
+func PFMerge(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) < 2 {
+		return "-ERR wrong number of arguments for PFMERGE\r\n"
+	}
+
+	keys, ok := keyArgs(cmdArray[1:])
+	if !ok {
+		return "-ERR invalid argument for PFMERGE\r\n"
+	}
+	destination := keys[0]
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	merged, errReply := mergeHyperLogLogs(storedData, keys)
+	if errReply != "" {
+		return errReply
+	}
+
+	hll, found, _ := lookupHyperLogLog(storedData, destination)
+	if !found {
+		hll = model.NewHyperLogLog()
+	}
+	hll.Merge(merged)
+	// Redis invalidates the cached cardinality even if no register changed.
+	hll.InvalidateCache()
+	storeHyperLogLog(storedData, destination, hll)
+	if !found {
+		keyspaceEvent(notify.New, "new", destination)
+	}
+	keyspaceEvent(notify.String, "pfadd", destination)
+	return "+OK\r\n"
+}
+
+// PFDebug implements the GETREG, DECODE, ENCODING and TODENSE subcommands.
+func PFDebug(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) != 3 {
+		return "-ERR wrong number of arguments for PFDEBUG\r\n"
+	}
+
+	subcommand, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR invalid argument for PFDEBUG\r\n"
+	}
+	key, ok := cmdArray[2].(string)
+	if !ok {
+		return "-ERR invalid argument for PFDEBUG\r\n"
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	hll, found, errReply := lookupHyperLogLog(storedData, key)
+	if errReply != "" {
+		return errReply
+	}
+	if !found {
+		return "-ERR The specified key does not exist\r\n"
+	}
+
+	switch strings.ToUpper(subcommand) {
+	case "GETREG":
+		if !hll.Dense {
+			hll.Dense = true
+			storeHyperLogLog(storedData, key, hll)
+		}
+		var builder strings.Builder
+		builder.WriteString("*" + strconv.Itoa(model.HLLRegisters) + "\r\n")
+		for _, value := range hll.Registers {
+			builder.WriteString(":" + strconv.Itoa(int(value)) + "\r\n")
+		}
+		return builder.String()
+	case "DECODE":
+		decoded, err := model.DecodeSparse(storedData[key].Value.(string))
+		if err != nil {
+			return "-ERR " + err.Error() + "\r\n"
+		}
+		return "+" + decoded + "\r\n"
+	case "ENCODING":
+		if hll.Dense {
+			return "+dense\r\n"
+		}
+		return "+sparse\r\n"
+	case "TODENSE":
+		if hll.Dense {
+			return ":0\r\n"
+		}
+		hll.Dense = true
+		storeHyperLogLog(storedData, key, hll)
+		return ":1\r\n"
+	default:
+		return "-ERR Unknown PFDEBUG subcommand '" + subcommand + "'\r\n"
+	}
+}
+
+// mergeHyperLogLogs returns the register-wise maximum of every existing key.
+func mergeHyperLogLogs(storedData map[string]model.StoredData, keys []string) (*model.HyperLogLog, string) {
+	merged := model.NewHyperLogLog()
+	for _, key := range keys {
+		hll, found, errReply := lookupHyperLogLog(storedData, key)
+		if errReply != "" {
+			return nil, errReply
+		}
+		if found {
+			merged.Merge(hll)
+		}
+	}
+	return merged, ""
+}
+
+func lookupHyperLogLog(storedData map[string]model.StoredData, key string) (*model.HyperLogLog, bool, string) {
+	value, found, errReply := lookupString(storedData, key)
+	if errReply != "" || !found {
+		return nil, false, errReply
+	}
+
+	hll, err := model.ParseHyperLogLog(value)
+	switch err {
+	case nil:
+		return hll, true, ""
+	case model.ErrCorruptHyperLogLog:
+		return nil, false, "-INVALIDOBJ Corrupted HLL object detected\r\n"
+	default:
+		return nil, false, "-WRONGTYPE Key is not a valid HyperLogLog string value.\r\n"
+	}
+}
+
+func storeHyperLogLog(storedData map[string]model.StoredData, key string, hll *model.HyperLogLog) {
+	storedData[key] = model.StoredData{Value: hll.Bytes(), ExpiryDate: storedData[key].ExpiryDate}
+}
@@ -0,0 +1,125 @@
+package redis_command
+
+import (
+	"encoding/json"
+	"math"
+	"redis-go-clone/internal/model"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestPFAddPFCount(t *testing.T) {
+	storedData := map[string]model.StoredData{
+		"str": {Value: "hello"},
+	}
+	mu := &sync.RWMutex{}
+
+	tests := []struct {
+		name     string
+		cmdArray []any
+		expected string
+		fn       func([]any, map[string]model.StoredData, *sync.RWMutex) string
+	}{
+		{"create without elements", []any{"PFADD", "empty"}, ":1\r\n", PFAdd},
+		{"count empty", []any{"PFCOUNT", "empty"}, ":0\r\n", PFCount},
+		{"add elements", []any{"PFADD", "hll", "a", "b", "c", "d", "e", "f", "g"}, ":1\r\n", PFAdd},
+		{"add existing elements", []any{"PFADD", "hll", "a", "b"}, ":0\r\n", PFAdd},
+		{"count", []any{"PFCOUNT", "hll"}, ":7\r\n", PFCount},
+		{"count missing key", []any{"PFCOUNT", "missing"}, ":0\r\n", PFCount},
+		{"add to second key", []any{"PFADD", "hll2", "f", "g", "h", "i"}, ":1\r\n", PFAdd},
+		{"count union", []any{"PFCOUNT", "hll", "hll2", "missing"}, ":9\r\n", PFCount},
+		{"wrong type", []any{"PFADD", "str", "a"}, "-WRONGTYPE Key is not a valid HyperLogLog string value.\r\n", PFAdd},
+		{"wrong type in union", []any{"PFCOUNT", "hll", "str"}, "-WRONGTYPE Key is not a valid HyperLogLog string value.\r\n", PFCount},
+		{"merge", []any{"PFMERGE", "dst", "hll", "hll2"}, "+OK\r\n", PFMerge},
+		{"count merged", []any{"PFCOUNT", "dst"}, ":9\r\n", PFCount},
+		{"encoding", []any{"PFDEBUG", "ENCODING", "dst"}, "+sparse\r\n", PFDebug},
+		{"decode empty", []any{"PFDEBUG", "DECODE", "empty"}, "+Z:16384\r\n", PFDebug},
+		{"todense", []any{"PFDEBUG", "TODENSE", "dst"}, ":1\r\n", PFDebug},
+		{"todense again", []any{"PFDEBUG", "TODENSE", "dst"}, ":0\r\n", PFDebug},
+		{"dense encoding", []any{"PFDEBUG", "ENCODING", "dst"}, "+dense\r\n", PFDebug},
+		{"count dense", []any{"PFCOUNT", "dst"}, ":9\r\n", PFCount},
+		{"decode dense", []any{"PFDEBUG", "DECODE", "dst"}, "-ERR HLL encoding is not sparse\r\n", PFDebug},
+		{"debug missing key", []any{"PFDEBUG", "ENCODING", "missing"}, "-ERR The specified key does not exist\r\n", PFDebug},
+	}
+
+	for _, tt := range tests {
+		if result := tt.fn(tt.cmdArray, storedData, mu); result != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.expected, result)
+		}
+	}
+}
+
+func TestHyperLogLogEncoding(t *testing.T) {
+	storedData := make(map[string]model.StoredData)
+	mu := &sync.RWMutex{}
+
+	PFAdd([]any{"PFADD", "hll"}, storedData, mu)
+	value := storedData["hll"].Value.(string)
+	// Header, sparse encoding, zero cached cardinality and one XZERO opcode.
+	expected := "HYLL\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x7f\xff"
+	if value != expected {
+		t.Fatalf("expected %q, got %q", expected, value)
+	}
+
+	PFAdd([]any{"PFADD", "hll", "a"}, storedData, mu)
+	if value := storedData["hll"].Value.(string); value[15]&0x80 == 0 {
+		t.Error("expected PFADD to invalidate the cached cardinality")
+	}
+	PFCount([]any{"PFCOUNT", "hll"}, storedData, mu)
+	if value := storedData["hll"].Value.(string); value[8] != 1 || value[15] != 0 {
+		t.Errorf("expected PFCOUNT to cache a cardinality of 1, got header %q", value[:16])
+	}
+}
+
+func TestHyperLogLogPromotesToDense(t *testing.T) {
+	storedData := make(map[string]model.StoredData)
+	mu := &sync.RWMutex{}
+
+	const n = 20000
+	for i := 0; i < n; i += 100 {
+		cmdArray := []any{"PFADD", "hll"}
+		for j := i; j < i+100; j++ {
+			cmdArray = append(cmdArray, "element:"+strconv.Itoa(j))
+		}
+		PFAdd(cmdArray, storedData, mu)
+	}
+
+	if result := PFDebug([]any{"PFDEBUG", "ENCODING", "hll"}, storedData, mu); result != "+dense\r\n" {
+		t.Fatalf("expected dense encoding, got %q", result)
+	}
+	if value := storedData["hll"].Value.(string); len(value) != 12304 {
+		t.Errorf("expected a dense value of 12304 bytes, got %d", len(value))
+	}
+
+	result := PFCount([]any{"PFCOUNT", "hll"}, storedData, mu)
+	count, err := strconv.Atoi(result[1 : len(result)-2])
+	if err != nil {
+		t.Fatalf("unexpected reply %q", result)
+	}
+	if math.Abs(float64(count-n))/n > 0.02 {
+		t.Errorf("estimate %d is more than 2%% off from %d", count, n)
+	}
+}
+
+func TestHyperLogLogSurvivesSnapshot(t *testing.T) {
+	storedData := make(map[string]model.StoredData)
+	mu := &sync.RWMutex{}
+	PFAdd([]any{"PFADD", "hll", "a", "b", "c"}, storedData, mu)
+
+	data, err := json.Marshal(storedData)
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	loaded := make(map[string]model.StoredData)
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+
+	if loaded["hll"].Value != storedData["hll"].Value {
+		t.Fatal("expected the HyperLogLog bytes to round-trip")
+	}
+	if result := PFCount([]any{"PFCOUNT", "hll"}, loaded, mu); result != ":3\r\n" {
+		t.Errorf("expected :3 after reload, got %q", result)
+	}
+}
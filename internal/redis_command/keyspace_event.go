@@ -0,0 +1,36 @@
+package redis_command
+
+import (
+	"redis-go-clone/internal/memory"
+	"redis-go-clone/internal/model"
+	"redis-go-clone/internal/notify"
+	"redis-go-clone/internal/stats"
+	"redis-go-clone/internal/watch"
+)
+
+// keyspaceEvent reports that event changed key: clients WATCHing the key
+// get their transaction invalidated, its memory usage is counted again, the
+// change counts towards the next save and the event is sent as a keyspace
+// notification. Every mutation of the store goes through here.
+func keyspaceEvent(class int, event, key string) {
+	watch.Touch(key)
+	memory.KeyChanged(key)
+	stats.Changed()
+	notify.KeyspaceEvent(class, event, key)
+}
+
+// ExpireKey deletes key once its TTL has passed and reports it as expired.
+// Lazy expiry on access and the background expiry cycle both go through
+// here. The caller must hold the write lock.
+func ExpireKey(storedData map[string]model.StoredData, key string) {
+	delete(storedData, key)
+	keyspaceEvent(notify.Expired, "expired", key)
+	stats.KeyExpired()
+}
+
+// ExpireHashFields removes the expired fields of the hash stored at key,
+// deleting the key when none are left, the same way commands do before
+// reading a hash. The caller must hold the write lock.
+func ExpireHashFields(storedData map[string]model.StoredData, key string) {
+	lookupHash(storedData, key)
+}
@@ -2,6 +2,7 @@ package redis_command
 
 import (
 	"redis-go-clone/internal/model"
+	"redis-go-clone/internal/notify"
 	"sync"
 )
 
@@ -26,6 +27,7 @@ func Incr(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMut
 	if v, ok := value.Value.(int); ok {
 		v++
 		storedData[key] = model.StoredData{Value: v}
+		keyspaceEvent(notify.String, "incrby", key)
 		return "+OK\r\n"
 	} else {
 		return "-ERR value is not type of int\r\n"
@@ -53,6 +55,7 @@ func Decr(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMut
 	if v, ok := value.Value.(int); ok {
 		v--
 		storedData[key] = model.StoredData{Value: v}
+		keyspaceEvent(notify.String, "decrby", key)
 		return "+OK\r\n"
 	} else {
 		return "-ERR value is not type of int\r\n"
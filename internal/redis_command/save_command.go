@@ -3,11 +3,25 @@ package redis_command
 import (
 	"encoding/json"
 	"os"
+	"redis-go-clone/internal/latency"
 	"redis-go-clone/internal/model"
+	"redis-go-clone/internal/stats"
 	"sync"
+	"time"
 )
 
-const saveFile = "data.json"
+const (
+	saveFile      = "data.json"
+	functionsFile = "functions.json"
+)
+
+var functionsDumpHook func() []byte
+
+// SetFunctionsDumpHook registers the function returning the function
+// libraries, which SAVE writes to their own file next to the data.
+func SetFunctionsDumpHook(hook func() []byte) {
+	functionsDumpHook = hook
+}
 
 func Save(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
 	if len(cmdArray) != 1 {
@@ -17,15 +31,53 @@ func Save(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMut
 	mu.RLock()
 	defer mu.RUnlock()
 
+	start := time.Now()
+	defer func() { latency.Add(latency.Save, time.Since(start)) }()
+
+	if err := save(storedData); err != nil {
+		stats.SaveFailed()
+		return "-ERR error saving data\r\n"
+	}
+
+	stats.Saved(time.Now())
+	return "+OK\r\n"
+}
+
+func save(storedData map[string]model.StoredData) error {
 	data, err := json.MarshalIndent(storedData, "", "  ")
 	if err != nil {
-		return "-ERR error saving data\r\n"
+		return err
 	}
 
-	err = os.WriteFile(saveFile, data, 0644)
+	err = writeFile(saveFile, data)
 	if err != nil {
-		return "-ERR error saving data\r\n"
+		return err
 	}
 
-	return "+OK\r\n"
+	if functionsDumpHook != nil {
+		return writeFile(functionsFile, functionsDumpHook())
+	}
+	return nil
+}
+
+// writeFile writes data to the file name and flushes it to disk, so that a
+// save is not lost if the machine goes down right after it.
+func writeFile(name string, data []byte) error {
+	file, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+
+	start := time.Now()
+	err = file.Sync()
+	latency.Add(latency.Fsync, time.Since(start))
+	if err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
 }
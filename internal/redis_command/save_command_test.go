@@ -1,6 +1,7 @@
 package redis_command
 
 import (
+	"encoding/json"
 	"os"
 	"redis-go-clone/internal/model"
 	"sync"
@@ -26,3 +27,75 @@ func TestSave(t *testing.T) {
 
 	os.Remove("data.json")
 }
+
+func TestSaveWritesFunctions(t *testing.T) {
+	SetFunctionsDumpHook(func() []byte { return []byte(`["#!lua name=lib"]`) })
+	defer SetFunctionsDumpHook(nil)
+	defer os.Remove("data.json")
+	defer os.Remove("functions.json")
+
+	result := Save([]any{"SAVE"}, map[string]model.StoredData{}, &sync.RWMutex{})
+	if result != "+OK\r\n" {
+		t.Errorf("expected +OK\r\n, got %v", result)
+	}
+
+	data, err := os.ReadFile("functions.json")
+	if err != nil {
+		t.Fatalf("functions.json was not created: %v", err)
+	}
+	if string(data) != `["#!lua name=lib"]` {
+		t.Errorf("unexpected functions.json content %q", data)
+	}
+}
+
+func TestSnapshotKeepsBinaryData(t *testing.T) {
+	const binary = "\xff\xfe\x00bin"
+	storedData := make(map[string]model.StoredData)
+	mu := &sync.RWMutex{}
+	HSet([]any{"HSET", "h", binary, binary, "plain", "value"}, storedData, mu)
+	HExpire([]any{"HEXPIRE", "h", 100, "FIELDS", 1, binary}, storedData, mu)
+	SAdd([]any{"SADD", "s", binary, "plain"}, storedData, mu)
+	ZAdd([]any{"ZADD", "z", 1, binary}, storedData, mu)
+	XAdd([]any{"XADD", "x", "1-0", "f", binary}, storedData, mu)
+
+	data, err := json.Marshal(storedData)
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	loaded := make(map[string]model.StoredData)
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+
+	replies := []struct {
+		cmd  []any
+		want string
+	}{
+		{[]any{"HGET", "h", binary}, "$6\r\n" + binary + "\r\n"},
+		{[]any{"HGET", "h", "plain"}, "$5\r\nvalue\r\n"},
+		{[]any{"HTTL", "h", "FIELDS", 1, binary}, "*1\r\n:100\r\n"},
+		{[]any{"SISMEMBER", "s", binary}, ":1\r\n"},
+		{[]any{"SCARD", "s"}, ":2\r\n"},
+		{[]any{"ZSCORE", "z", binary}, "$1\r\n1\r\n"},
+		{[]any{"XRANGE", "x", "-", "+"}, "*1\r\n*2\r\n$3\r\n1-0\r\n*2\r\n$1\r\nf\r\n$6\r\n" + binary + "\r\n"},
+	}
+	commands := map[string]commandFunc{"HGET": HGet, "HTTL": HTTL, "SISMEMBER": SIsMember, "SCARD": SCard, "ZSCORE": ZScore, "XRANGE": XRange}
+	for _, tt := range replies {
+		if got := commands[tt.cmd[0].(string)](tt.cmd, loaded, mu); got != tt.want {
+			t.Errorf("%v after reload = %q, want %q", tt.cmd, got, tt.want)
+		}
+	}
+}
+
+func TestSnapshotLoadsHashFieldsMap(t *testing.T) {
+	// Snapshots stored hash fields as a JSON object before.
+	data := `{"h":{"Type":"hash","Value":{"Fields":{"f":"v","g":"w"},"FieldExpiry":{"g":4102444800000}},"ExpiryDate":0}}`
+	loaded := make(map[string]model.StoredData)
+	if err := json.Unmarshal([]byte(data), &loaded); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	hash := loaded["h"].Value.(*model.Hash)
+	if hash.Fields["f"] != "v" || hash.Fields["g"] != "w" || hash.FieldExpiry["g"] != 4102444800000 || len(hash.FieldExpiry) != 1 {
+		t.Errorf("unexpected hash after reload: %+v", hash)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"redis-go-clone/internal/model"
+	"redis-go-clone/internal/notify"
 	"strconv"
 	"strings"
 	"sync"
@@ -34,7 +35,15 @@ func Set(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMute
 
 	mu.Lock()
 	defer mu.Unlock()
+	_, found := storedData[key]
 	storedData[key] = model.StoredData{Value: value, ExpiryDate: expiryTimestamp}
+	if !found {
+		keyspaceEvent(notify.New, "new", key)
+	}
+	keyspaceEvent(notify.String, "set", key)
+	if expiryTimestamp > 0 {
+		keyspaceEvent(notify.Generic, "expire", key)
+	}
 
 	return "+OK\r\n"
 }
@@ -0,0 +1,736 @@
+package redis_command
+
+import (
+	"cmp"
+	"container/heap"
+	"hash/fnv"
+	"math/rand/v2"
+	"redis-go-clone/internal/model"
+	"redis-go-clone/internal/notify"
+	"redis-go-clone/pkg/glob"
+	"redis-go-clone/pkg/resp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+func SAdd(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) < 3 {
+		return "-ERR missing argument for SADD\r\n"
+	}
+
+	key, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR invalid argument for SADD\r\n"
+	}
+
+	members, ok := stringArgs(cmdArray[2:])
+	if !ok {
+		return "-ERR invalid argument for SADD\r\n"
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	set, errReply := lookupSet(storedData, key)
+	if errReply != "" {
+		return errReply
+	}
+	if set == nil {
+		set = model.NewSet()
+		storedData[key] = model.StoredData{Value: set}
+		keyspaceEvent(notify.New, "new", key)
+	}
+
+	added := 0
+	for _, member := range members {
+		if set.Add(member) {
+			added++
+		}
+	}
+	if added > 0 {
+		keyspaceEvent(notify.Set, "sadd", key)
+	}
+	return ":" + strconv.Itoa(added) + "\r\n"
+}
+
+func SRem(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) < 3 {
+		return "-ERR missing argument for SREM\r\n"
+	}
+
+	key, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR invalid argument for SREM\r\n"
+	}
+
+	members, ok := stringArgs(cmdArray[2:])
+	if !ok {
+		return "-ERR invalid argument for SREM\r\n"
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	set, errReply := lookupSet(storedData, key)
+	if errReply != "" {
+		return errReply
+	}
+	if set == nil {
+		return ":0\r\n"
+	}
+
+	removed := 0
+	for _, member := range members {
+		if set.Remove(member) {
+			removed++
+		}
+	}
+	if removed > 0 {
+		keyspaceEvent(notify.Set, "srem", key)
+	}
+	if set.Len() == 0 {
+		delete(storedData, key)
+		keyspaceEvent(notify.Generic, "del", key)
+	}
+	return ":" + strconv.Itoa(removed) + "\r\n"
+}
+
+func SIsMember(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) != 3 {
+		return "-ERR wrong number of arguments for SISMEMBER\r\n"
+	}
+
+	key, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR invalid argument for SISMEMBER\r\n"
+	}
+	member, ok := argToString(cmdArray[2])
+	if !ok {
+		return "-ERR invalid argument for SISMEMBER\r\n"
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+
+	set, errReply := lookupSet(storedData, key)
+	if errReply != "" {
+		return errReply
+	}
+	if set != nil && set.Contains(member) {
+		return ":1\r\n"
+	}
+	return ":0\r\n"
+}
+
+func SMIsMember(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) < 3 {
+		return "-ERR missing argument for SMISMEMBER\r\n"
+	}
+
+	key, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR invalid argument for SMISMEMBER\r\n"
+	}
+	members, ok := stringArgs(cmdArray[2:])
+	if !ok {
+		return "-ERR invalid argument for SMISMEMBER\r\n"
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+
+	set, errReply := lookupSet(storedData, key)
+	if errReply != "" {
+		return errReply
+	}
+
+	result := make([]any, 0, len(members))
+	for _, member := range members {
+		if set != nil && set.Contains(member) {
+			result = append(result, 1)
+		} else {
+			result = append(result, 0)
+		}
+	}
+	return resp.SerializeRESP(result, false)
+}
+
+func SMembers(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) != 2 {
+		return "-ERR wrong number of arguments for SMEMBERS\r\n"
+	}
+
+	key, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR invalid argument for SMEMBERS\r\n"
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+
+	set, errReply := lookupSet(storedData, key)
+	if errReply != "" {
+		return errReply
+	}
+	if set == nil {
+		return "*0\r\n"
+	}
+	return serializeMembers(set.Members())
+}
+
+func SCard(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) != 2 {
+		return "-ERR wrong number of arguments for SCARD\r\n"
+	}
+
+	key, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR invalid argument for SCARD\r\n"
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+
+	set, errReply := lookupSet(storedData, key)
+	if errReply != "" {
+		return errReply
+	}
+	if set == nil {
+		return ":0\r\n"
+	}
+	return ":" + strconv.Itoa(set.Len()) + "\r\n"
+}
+
+func SPop(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) < 2 || len(cmdArray) > 3 {
+		return "-ERR wrong number of arguments for SPOP\r\n"
+	}
+
+	key, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR invalid argument for SPOP\r\n"
+	}
+
+	count := int64(1)
+	if len(cmdArray) == 3 {
+		count, ok = argToInt64(cmdArray[2])
+		if !ok || count < 0 {
+			return "-ERR value is out of range, must be positive\r\n"
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	set, errReply := lookupSet(storedData, key)
+	if errReply != "" {
+		return errReply
+	}
+	if set == nil {
+		if len(cmdArray) == 3 {
+			return "*0\r\n"
+		}
+		return "$-1\r\n"
+	}
+
+	members := set.Members()
+	rand.Shuffle(len(members), func(i, j int) { members[i], members[j] = members[j], members[i] })
+	if int64(len(members)) > count {
+		members = members[:count]
+	}
+	for _, member := range members {
+		set.Remove(member)
+	}
+	if len(members) > 0 {
+		keyspaceEvent(notify.Set, "spop", key)
+	}
+	if set.Len() == 0 {
+		delete(storedData, key)
+		keyspaceEvent(notify.Generic, "del", key)
+	}
+
+	if len(cmdArray) == 2 {
+		return resp.SerializeBulk(members[0])
+	}
+	return serializeMembers(members)
+}
+
+func SRandMember(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) < 2 || len(cmdArray) > 3 {
+		return "-ERR wrong number of arguments for SRANDMEMBER\r\n"
+	}
+
+	key, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR invalid argument for SRANDMEMBER\r\n"
+	}
+
+	var count int64
+	if len(cmdArray) == 3 {
+		count, ok = argToInt64(cmdArray[2])
+		if !ok {
+			return "-ERR value is not an integer or out of range\r\n"
+		}
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+
+	set, errReply := lookupSet(storedData, key)
+	if errReply != "" {
+		return errReply
+	}
+	if set == nil {
+		if len(cmdArray) == 3 {
+			return "*0\r\n"
+		}
+		return "$-1\r\n"
+	}
+
+	members := set.Members()
+	if len(cmdArray) == 2 {
+		return resp.SerializeBulk(members[rand.IntN(len(members))])
+	}
+
+	// A negative count allows the same member to be returned several times.
+	if count < 0 {
+		result := make([]string, 0, -count)
+		for i := int64(0); i < -count; i++ {
+			result = append(result, members[rand.IntN(len(members))])
+		}
+		return serializeMembers(result)
+	}
+
+	rand.Shuffle(len(members), func(i, j int) { members[i], members[j] = members[j], members[i] })
+	if int64(len(members)) > count {
+		members = members[:count]
+	}
+	return serializeMembers(members)
+}
+
+func SMove(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) != 4 {
+		return "-ERR wrong number of arguments for SMOVE\r\n"
+	}
+
+	source, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR invalid argument for SMOVE\r\n"
+	}
+	destination, ok := cmdArray[2].(string)
+	if !ok {
+		return "-ERR invalid argument for SMOVE\r\n"
+	}
+	member, ok := argToString(cmdArray[3])
+	if !ok {
+		return "-ERR invalid argument for SMOVE\r\n"
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	srcSet, errReply := lookupSet(storedData, source)
+	if errReply != "" {
+		return errReply
+	}
+	dstSet, errReply := lookupSet(storedData, destination)
+	if errReply != "" {
+		return errReply
+	}
+
+	if srcSet == nil || !srcSet.Contains(member) {
+		return ":0\r\n"
+	}
+	if source == destination {
+		return ":1\r\n"
+	}
+
+	srcSet.Remove(member)
+	keyspaceEvent(notify.Set, "srem", source)
+	if srcSet.Len() == 0 {
+		delete(storedData, source)
+		keyspaceEvent(notify.Generic, "del", source)
+	}
+	if dstSet == nil {
+		dstSet = model.NewSet()
+		storedData[destination] = model.StoredData{Value: dstSet}
+		keyspaceEvent(notify.New, "new", destination)
+	}
+	if dstSet.Add(member) {
+		keyspaceEvent(notify.Set, "sadd", destination)
+	}
+	return ":1\r\n"
+}
+
+func SInter(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	return setOperation("SINTER", cmdArray, storedData, mu, setInter)
+}
+
+func SUnion(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	return setOperation("SUNION", cmdArray, storedData, mu, setUnion)
+}
+
+func SDiff(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	return setOperation("SDIFF", cmdArray, storedData, mu, setDiff)
+}
+
+func SInterStore(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	return setOperationStore("SINTERSTORE", cmdArray, storedData, mu, setInter)
+}
+
+func SUnionStore(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	return setOperationStore("SUNIONSTORE", cmdArray, storedData, mu, setUnion)
+}
+
+func SDiffStore(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	return setOperationStore("SDIFFSTORE", cmdArray, storedData, mu, setDiff)
+}
+
+func SInterCard(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) < 3 {
+		return "-ERR wrong number of arguments for SINTERCARD\r\n"
+	}
+
+	numKeys, ok := argToInt64(cmdArray[1])
+	if !ok || numKeys <= 0 {
+		return "-ERR numkeys should be greater than 0\r\n"
+	}
+	if int64(len(cmdArray)-2) < numKeys {
+		return "-ERR Number of keys can't be greater than number of args\r\n"
+	}
+
+	keys, ok := keyArgs(cmdArray[2 : 2+numKeys])
+	if !ok {
+		return "-ERR invalid argument for SINTERCARD\r\n"
+	}
+
+	limit := int64(0)
+	rest := cmdArray[2+numKeys:]
+	if len(rest) > 0 {
+		opt, _ := rest[0].(string)
+		if len(rest) != 2 || strings.ToUpper(opt) != "LIMIT" {
+			return "-ERR syntax error\r\n"
+		}
+		limit, ok = argToInt64(rest[1])
+		if !ok || limit < 0 {
+			return "-ERR LIMIT can't be negative\r\n"
+		}
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+
+	sets, errReply := lookupSets(storedData, keys)
+	if errReply != "" {
+		return errReply
+	}
+
+	count := len(setInter(sets))
+	if limit > 0 && int64(count) > limit {
+		count = int(limit)
+	}
+	return ":" + strconv.Itoa(count) + "\r\n"
+}
+
+func SScan(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) < 3 {
+		return "-ERR wrong number of arguments for SSCAN\r\n"
+	}
+
+	key, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR invalid argument for SSCAN\r\n"
+	}
+
+	cursor, ok := argToInt64(cmdArray[2])
+	if !ok || cursor < 0 {
+		return "-ERR invalid cursor\r\n"
+	}
+
+	pattern, count, errReply := parseScanOptions(cmdArray[3:])
+	if errReply != "" {
+		return errReply
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+
+	set, errReply := lookupSet(storedData, key)
+	if errReply != "" {
+		return errReply
+	}
+
+	var members []string
+	if set != nil {
+		members = set.Members()
+	}
+	scanned, nextCursor := scanMembers(members, cursor, count)
+
+	page := make([]any, 0, len(scanned))
+	for _, member := range scanned {
+		if pattern == "" || glob.Match(pattern, member) {
+			page = append(page, member)
+		}
+	}
+	return resp.SerializeBulk([]any{strconv.FormatInt(nextCursor, 10), page})
+}
+
+// scanPosition places member in the order SSCAN and ZSCAN walk a collection
+// in. It depends on nothing but the member, so that members added or removed
+// during a scan do not make it skip or repeat the others.
+func scanPosition(member string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(member))
+	return int64(h.Sum64() >> 2)
+}
+
+// scanMembers returns the members a scan from cursor visits next, the count
+// ones with the lowest positions at or past cursor, and the cursor to resume
+// from, 0 once none are left. Members sharing a position are always visited
+// together.
+func scanMembers(members []string, cursor int64, count int) ([]string, int64) {
+	// Keep the lowest positions in a max-heap of count entries, so that a
+	// page takes O(N log count) instead of sorting the whole collection.
+	lowest := make(positionHeap, 0, count)
+	for _, member := range members {
+		pos := scanPosition(member)
+		switch {
+		case pos < cursor:
+		case len(lowest) < count:
+			heap.Push(&lowest, pos)
+		case pos < lowest[0]:
+			lowest[0] = pos
+			heap.Fix(&lowest, 0)
+		}
+	}
+	if len(lowest) == 0 {
+		return nil, 0
+	}
+
+	last := lowest[0]
+	var page []string
+	var nextCursor int64
+	for _, member := range members {
+		pos := scanPosition(member)
+		switch {
+		case pos < cursor:
+		case pos <= last:
+			page = append(page, member)
+		default:
+			nextCursor = last + 1
+		}
+	}
+	slices.SortFunc(page, func(a, b string) int {
+		return cmp.Compare(scanPosition(a), scanPosition(b))
+	})
+	return page, nextCursor
+}
+
+// positionHeap is a max-heap of scan positions.
+type positionHeap []int64
+
+func (h positionHeap) Len() int           { return len(h) }
+func (h positionHeap) Less(i, j int) bool { return h[i] > h[j] }
+func (h positionHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *positionHeap) Push(x any)        { *h = append(*h, x.(int64)) }
+
+func (h *positionHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// parseScanOptions parses the MATCH and COUNT options shared by the SCAN
+// family.
+func parseScanOptions(args []any) (string, int, string) {
+	pattern := ""
+	count := 10
+
+	for i := 0; i < len(args); i += 2 {
+		opt, ok := args[i].(string)
+		if !ok || i+1 >= len(args) {
+			return "", 0, "-ERR syntax error\r\n"
+		}
+
+		switch strings.ToUpper(opt) {
+		case "MATCH":
+			pattern, ok = argToString(args[i+1])
+			if !ok {
+				return "", 0, "-ERR syntax error\r\n"
+			}
+		case "COUNT":
+			n, ok := argToInt64(args[i+1])
+			if !ok || n < 1 {
+				return "", 0, "-ERR value is out of range, must be positive\r\n"
+			}
+			count = int(n)
+		default:
+			return "", 0, "-ERR syntax error\r\n"
+		}
+	}
+	return pattern, count, ""
+}
+
+type setOperationFunc func(sets []*model.Set) []string
+
+func setOperation(name string, cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex, op setOperationFunc) string {
+	if len(cmdArray) < 2 {
+		return "-ERR missing argument for " + name + "\r\n"
+	}
+
+	keys, ok := keyArgs(cmdArray[1:])
+	if !ok {
+		return "-ERR invalid argument for " + name + "\r\n"
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+
+	sets, errReply := lookupSets(storedData, keys)
+	if errReply != "" {
+		return errReply
+	}
+	return serializeMembers(op(sets))
+}
+
+func setOperationStore(name string, cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex, op setOperationFunc) string {
+	if len(cmdArray) < 3 {
+		return "-ERR missing argument for " + name + "\r\n"
+	}
+
+	keys, ok := keyArgs(cmdArray[1:])
+	if !ok {
+		return "-ERR invalid argument for " + name + "\r\n"
+	}
+	destination := keys[0]
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	sets, errReply := lookupSets(storedData, keys[1:])
+	if errReply != "" {
+		return errReply
+	}
+
+	members := op(sets)
+	_, existed := storedData[destination]
+	if len(members) == 0 {
+		if existed {
+			delete(storedData, destination)
+			keyspaceEvent(notify.Generic, "del", destination)
+		}
+		return ":0\r\n"
+	}
+
+	result := model.NewSet()
+	for _, member := range members {
+		result.Add(member)
+	}
+	storedData[destination] = model.StoredData{Value: result}
+	if !existed {
+		keyspaceEvent(notify.New, "new", destination)
+	}
+	keyspaceEvent(notify.Set, strings.ToLower(name), destination)
+	return ":" + strconv.Itoa(result.Len()) + "\r\n"
+}
+
+// setInter treats a missing key (nil set) as an empty set.
+func setInter(sets []*model.Set) []string {
+	for _, set := range sets {
+		if set == nil {
+			return nil
+		}
+	}
+
+	// Iterate the smallest set and probe the others.
+	smallest := slices.MinFunc(sets, func(a, b *model.Set) int { return a.Len() - b.Len() })
+	var result []string
+	for _, member := range smallest.Members() {
+		inAll := true
+		for _, set := range sets {
+			if !set.Contains(member) {
+				inAll = false
+				break
+			}
+		}
+		if inAll {
+			result = append(result, member)
+		}
+	}
+	return result
+}
+
+func setUnion(sets []*model.Set) []string {
+	seen := make(map[string]struct{})
+	var result []string
+	for _, set := range sets {
+		if set == nil {
+			continue
+		}
+		for _, member := range set.Members() {
+			if _, found := seen[member]; !found {
+				seen[member] = struct{}{}
+				result = append(result, member)
+			}
+		}
+	}
+	return result
+}
+
+func setDiff(sets []*model.Set) []string {
+	if sets[0] == nil {
+		return nil
+	}
+
+	var result []string
+	for _, member := range sets[0].Members() {
+		inOther := false
+		for _, set := range sets[1:] {
+			if set != nil && set.Contains(member) {
+				inOther = true
+				break
+			}
+		}
+		if !inOther {
+			result = append(result, member)
+		}
+	}
+	return result
+}
+
+// lookupSet returns the set stored at key, or nil if the key does not exist.
+func lookupSet(storedData map[string]model.StoredData, key string) (*model.Set, string) {
+	value, found := storedData[key]
+	if !found {
+		return nil, ""
+	}
+
+	set, ok := value.Value.(*model.Set)
+	if !ok {
+		return nil, "-ERR value is not type of set\r\n"
+	}
+	return set, ""
+}
+
+func lookupSets(storedData map[string]model.StoredData, keys []string) ([]*model.Set, string) {
+	sets := make([]*model.Set, 0, len(keys))
+	for _, key := range keys {
+		set, errReply := lookupSet(storedData, key)
+		if errReply != "" {
+			return nil, errReply
+		}
+		sets = append(sets, set)
+	}
+	return sets, ""
+}
+
+func serializeMembers(members []string) string {
+	result := make([]any, 0, len(members))
+	for _, member := range members {
+		result = append(result, member)
+	}
+	return resp.SerializeBulk(result)
+}
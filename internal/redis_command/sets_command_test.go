@@ -0,0 +1,319 @@
+package redis_command
+
+import (
+	"fmt"
+	"redis-go-clone/internal/model"
+	"redis-go-clone/pkg/resp"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func newTestSet(members ...string) *model.Set {
+	set := model.NewSet()
+	for _, member := range members {
+		set.Add(member)
+	}
+	return set
+}
+
+func TestSAdd(t *testing.T) {
+	var mu sync.RWMutex
+	storedData := make(map[string]model.StoredData)
+
+	tests := []struct {
+		cmdArray []any
+		expected string
+		encoding string
+	}{
+		{[]any{"SADD", "myset"}, "-ERR missing argument for SADD\r\n", ""},
+		{[]any{"SADD", 123, "a"}, "-ERR invalid argument for SADD\r\n", ""},
+		{[]any{"SADD", "myset", 1, 2, 2}, ":2\r\n", "intset"},
+		{[]any{"SADD", "myset", "3", 1}, ":1\r\n", "intset"},
+		{[]any{"SADD", "myset", "hello"}, ":1\r\n", "hashtable"},
+	}
+
+	for _, tt := range tests {
+		result := SAdd(tt.cmdArray, storedData, &mu)
+		if result != tt.expected {
+			t.Errorf("expected %q, got %q", tt.expected, result)
+		}
+		if tt.encoding != "" {
+			if encoding := storedData["myset"].Value.(*model.Set).Encoding(); encoding != tt.encoding {
+				t.Errorf("expected encoding %s, got %s", tt.encoding, encoding)
+			}
+		}
+	}
+
+	if card := SCard([]any{"SCARD", "myset"}, storedData, &mu); card != ":4\r\n" {
+		t.Errorf("expected :4, got %q", card)
+	}
+}
+
+func TestSetIntsetConvertsWhenLarge(t *testing.T) {
+	set := model.NewSet()
+	for i := 0; i < model.SetMaxIntsetEntries; i++ {
+		set.Add(strconv.Itoa(i))
+	}
+	if set.Encoding() != "intset" {
+		t.Fatalf("expected intset, got %s", set.Encoding())
+	}
+
+	set.Add(strconv.Itoa(model.SetMaxIntsetEntries))
+	if set.Encoding() != "hashtable" {
+		t.Errorf("expected hashtable, got %s", set.Encoding())
+	}
+	if set.Len() != model.SetMaxIntsetEntries+1 || !set.Contains("0") {
+		t.Error("expected members to survive conversion")
+	}
+}
+
+func TestSRem(t *testing.T) {
+	var mu sync.RWMutex
+	storedData := map[string]model.StoredData{
+		"myset": {Value: newTestSet("a", "b")},
+		"str":   {Value: "value"},
+	}
+
+	tests := []struct {
+		cmdArray []any
+		expected string
+	}{
+		{[]any{"SREM", "myset", "a", "missing"}, ":1\r\n"},
+		{[]any{"SREM", "str", "a"}, "-ERR value is not type of set\r\n"},
+		{[]any{"SREM", "myset", "b"}, ":1\r\n"},
+		{[]any{"SREM", "myset", "b"}, ":0\r\n"},
+	}
+
+	for _, tt := range tests {
+		if result := SRem(tt.cmdArray, storedData, &mu); result != tt.expected {
+			t.Errorf("expected %q, got %q", tt.expected, result)
+		}
+	}
+	if _, found := storedData["myset"]; found {
+		t.Error("expected empty set to be deleted")
+	}
+}
+
+func TestSIsMember(t *testing.T) {
+	var mu sync.RWMutex
+	storedData := map[string]model.StoredData{
+		"myset": {Value: newTestSet("a", "1")},
+	}
+
+	tests := []struct {
+		name     string
+		cmdArray []any
+		expected string
+		fn       func([]any, map[string]model.StoredData, *sync.RWMutex) string
+	}{
+		{"member", []any{"SISMEMBER", "myset", "a"}, ":1\r\n", SIsMember},
+		{"integer member", []any{"SISMEMBER", "myset", 1}, ":1\r\n", SIsMember},
+		{"not a member", []any{"SISMEMBER", "myset", "b"}, ":0\r\n", SIsMember},
+		{"missing key", []any{"SISMEMBER", "nope", "a"}, ":0\r\n", SIsMember},
+		{"multiple members", []any{"SMISMEMBER", "myset", "a", "b", 1}, "*3\r\n:1\r\n:0\r\n:1\r\n", SMIsMember},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := tt.fn(tt.cmdArray, storedData, &mu); result != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestSPop(t *testing.T) {
+	var mu sync.RWMutex
+	storedData := map[string]model.StoredData{
+		"myset": {Value: newTestSet("a", "b", "c")},
+	}
+
+	result := SPop([]any{"SPOP", "myset", 2}, storedData, &mu)
+	if result[:4] != "*2\r\n" {
+		t.Errorf("expected two members, got %q", result)
+	}
+	if card := SCard([]any{"SCARD", "myset"}, storedData, &mu); card != ":1\r\n" {
+		t.Errorf("expected one member left, got %q", card)
+	}
+
+	SPop([]any{"SPOP", "myset"}, storedData, &mu)
+	if _, found := storedData["myset"]; found {
+		t.Error("expected empty set to be deleted")
+	}
+	if result := SPop([]any{"SPOP", "myset"}, storedData, &mu); result != "$-1\r\n" {
+		t.Errorf("expected nil, got %q", result)
+	}
+}
+
+func TestSRandMember(t *testing.T) {
+	var mu sync.RWMutex
+	storedData := map[string]model.StoredData{
+		"myset": {Value: newTestSet("a")},
+	}
+
+	tests := []struct {
+		cmdArray []any
+		expected string
+	}{
+		{[]any{"SRANDMEMBER", "myset"}, "$1\r\na\r\n"},
+		{[]any{"SRANDMEMBER", "myset", 5}, "*1\r\n$1\r\na\r\n"},
+		{[]any{"SRANDMEMBER", "myset", -3}, "*3\r\n$1\r\na\r\n$1\r\na\r\n$1\r\na\r\n"},
+		{[]any{"SRANDMEMBER", "missing"}, "$-1\r\n"},
+	}
+
+	for _, tt := range tests {
+		if result := SRandMember(tt.cmdArray, storedData, &mu); result != tt.expected {
+			t.Errorf("expected %q, got %q", tt.expected, result)
+		}
+	}
+}
+
+func TestSMove(t *testing.T) {
+	var mu sync.RWMutex
+	storedData := map[string]model.StoredData{
+		"src": {Value: newTestSet("a", "b")},
+	}
+
+	if result := SMove([]any{"SMOVE", "src", "dst", "a"}, storedData, &mu); result != ":1\r\n" {
+		t.Errorf("expected :1, got %q", result)
+	}
+	if result := SMove([]any{"SMOVE", "src", "dst", "a"}, storedData, &mu); result != ":0\r\n" {
+		t.Errorf("expected :0, got %q", result)
+	}
+	if !storedData["dst"].Value.(*model.Set).Contains("a") {
+		t.Error("expected member to be moved to destination")
+	}
+}
+
+func TestSetOperations(t *testing.T) {
+	var mu sync.RWMutex
+	storedData := map[string]model.StoredData{
+		"s1":  {Value: newTestSet("1", "2", "3")},
+		"s2":  {Value: newTestSet("2", "3", "4")},
+		"s3":  {Value: newTestSet("3", "5")},
+		"str": {Value: "value"},
+	}
+
+	tests := []struct {
+		name     string
+		cmdArray []any
+		expected string
+		fn       func([]any, map[string]model.StoredData, *sync.RWMutex) string
+	}{
+		{"inter", []any{"SINTER", "s1", "s2", "s3"}, "*1\r\n$1\r\n3\r\n", SInter},
+		{"inter with missing key", []any{"SINTER", "s1", "missing"}, "*0\r\n", SInter},
+		{"diff", []any{"SDIFF", "s1", "s2"}, "*1\r\n$1\r\n1\r\n", SDiff},
+		{"union with wrong type", []any{"SUNION", "s1", "str"}, "-ERR value is not type of set\r\n", SUnion},
+		{"intercard", []any{"SINTERCARD", 2, "s1", "s2"}, ":2\r\n", SInterCard},
+		{"intercard with limit", []any{"SINTERCARD", 2, "s1", "s2", "LIMIT", 1}, ":1\r\n", SInterCard},
+		{"intercard bad numkeys", []any{"SINTERCARD", 3, "s1", "s2"}, "-ERR Number of keys can't be greater than number of args\r\n", SInterCard},
+		{"unionstore", []any{"SUNIONSTORE", "dst", "s1", "s2", "s3"}, ":5\r\n", SUnionStore},
+		{"interstore", []any{"SINTERSTORE", "dst2", "s1", "s2"}, ":2\r\n", SInterStore},
+		{"diffstore empty", []any{"SDIFFSTORE", "dst3", "s3", "s1", "s3"}, ":0\r\n", SDiffStore},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := tt.fn(tt.cmdArray, storedData, &mu); result != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+
+	if _, found := storedData["dst3"]; found {
+		t.Error("expected empty store result to leave no key")
+	}
+}
+
+func TestSMembersBulkStrings(t *testing.T) {
+	var mu sync.RWMutex
+	storedData := make(map[string]model.StoredData)
+
+	SAdd([]any{"SADD", "bs", "a\r\n:1"}, storedData, &mu)
+	expected := "*1\r\n$5\r\na\r\n:1\r\n"
+	if result := SMembers([]any{"SMEMBERS", "bs"}, storedData, &mu); result != expected {
+		t.Errorf("expected %q, got %q", expected, result)
+	}
+}
+
+func TestSScan(t *testing.T) {
+	var mu sync.RWMutex
+	storedData := map[string]model.StoredData{
+		"myset": {Value: newTestSet("apple", "banana", "avocado", "cherry")},
+	}
+
+	tests := []struct {
+		cmdArray []any
+		expected string
+	}{
+		{[]any{"SSCAN", "myset", 0, "MATCH", "b*"}, "*2\r\n$1\r\n0\r\n*1\r\n$6\r\nbanana\r\n"},
+		{[]any{"SSCAN", "myset", 0, "COUNT", 0}, "-ERR value is out of range, must be positive\r\n"},
+		{[]any{"SSCAN", "myset", -1}, "-ERR invalid cursor\r\n"},
+		{[]any{"SSCAN", "missing", 0}, "*2\r\n$1\r\n0\r\n*0\r\n"},
+	}
+
+	for _, tt := range tests {
+		if result := SScan(tt.cmdArray, storedData, &mu); result != tt.expected {
+			t.Errorf("expected %q, got %q", tt.expected, result)
+		}
+	}
+
+	seen := scanAll(t, SScan, storedData, "myset", nil)
+	slices.Sort(seen)
+	if expected := []string{"apple", "avocado", "banana", "cherry"}; !slices.Equal(seen, expected) {
+		t.Errorf("expected a full scan to return %v, got %v", expected, seen)
+	}
+}
+
+// scanAll runs a SCAN family command two elements at a time until the cursor
+// is back to 0, calling between with every page, and returns the elements of
+// all the pages.
+func scanAll(t *testing.T, scan func([]any, map[string]model.StoredData, *sync.RWMutex) string, storedData map[string]model.StoredData, key string, between func(page []string)) []string {
+	t.Helper()
+	var mu sync.RWMutex
+	var all []string
+	cursor := "0"
+	for {
+		reply, err := resp.DeserializeRESP(scan([]any{"SCAN", key, cursor, "COUNT", 2}, storedData, &mu))
+		if err != nil {
+			t.Fatalf("invalid scan reply: %v", err)
+		}
+		parts := reply.([]any)
+		var page []string
+		for _, element := range parts[1].([]any) {
+			page = append(page, fmt.Sprint(element))
+		}
+		all = append(all, page...)
+		if between != nil {
+			between(page)
+		}
+		if cursor = fmt.Sprint(parts[0]); cursor == "0" {
+			return all
+		}
+	}
+}
+
+func TestSScanWhileRemoving(t *testing.T) {
+	var mu sync.RWMutex
+	storedData := make(map[string]model.StoredData)
+	var members []string
+	for i := 1; i <= 20; i++ {
+		members = append(members, "m"+strconv.Itoa(i))
+		SAdd([]any{"SADD", "s", "m" + strconv.Itoa(i)}, storedData, &mu)
+	}
+
+	// Removing the members already returned must not make the scan skip
+	// the others.
+	seen := scanAll(t, SScan, storedData, "s", func(page []string) {
+		for _, member := range page {
+			SRem([]any{"SREM", "s", member}, storedData, &mu)
+		}
+	})
+	slices.Sort(seen)
+	slices.Sort(members)
+	if !slices.Equal(seen, members) {
+		t.Errorf("expected every member once, got %v", seen)
+	}
+}
@@ -0,0 +1,729 @@
+package redis_command
+
+import (
+	"fmt"
+	"redis-go-clone/internal/model"
+	"redis-go-clone/internal/notify"
+	"redis-go-clone/pkg/resp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const invalidStreamIDReply = "-ERR Invalid stream ID specified as stream command argument\r\n"
+
+const (
+	trimNone = iota
+	trimMaxLen
+	trimMinID
+)
+
+// streamTrimSpec holds the MAXLEN/MINID arguments shared by XADD and XTRIM.
+type streamTrimSpec struct {
+	strategy   int
+	approx     bool
+	maxLen     int
+	minID      model.StreamID
+	limit      int
+	limitGiven bool
+}
+
+func XAdd(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) < 5 {
+		return "-ERR wrong number of arguments for XADD\r\n"
+	}
+
+	key, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR invalid argument for XADD\r\n"
+	}
+
+	var noMkStream bool
+	var spec streamTrimSpec
+	idx := 2
+	for ; idx < len(cmdArray); idx++ {
+		opt, _ := argToString(cmdArray[idx])
+		if strings.EqualFold(opt, "NOMKSTREAM") {
+			noMkStream = true
+			continue
+		}
+		next, errReply := parseStreamTrimOption(cmdArray, idx, &spec)
+		if errReply != "" {
+			return errReply
+		}
+		if next == idx {
+			break
+		}
+		idx = next - 1
+	}
+	if errReply := validateStreamTrimSpec(&spec); errReply != "" {
+		return errReply
+	}
+
+	if idx >= len(cmdArray) {
+		return "-ERR wrong number of arguments for XADD\r\n"
+	}
+	idArg, ok := argToString(cmdArray[idx])
+	if !ok {
+		return invalidStreamIDReply
+	}
+	fields, ok := stringArgs(cmdArray[idx+1:])
+	if !ok {
+		return "-ERR invalid argument for XADD\r\n"
+	}
+	if len(fields) == 0 || len(fields)%2 != 0 {
+		return "-ERR wrong number of arguments for XADD\r\n"
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	stream, errReply := lookupStream(storedData, key)
+	if errReply != "" {
+		return errReply
+	}
+	if stream == nil {
+		if noMkStream {
+			return "$-1\r\n"
+		}
+		stream = model.NewStream()
+	}
+
+	id, errReply := nextStreamID(stream, idArg)
+	if errReply != "" {
+		return errReply
+	}
+
+	stream.Add(id, fields)
+	if _, found := storedData[key]; !found {
+		storedData[key] = model.StoredData{Value: stream}
+		keyspaceEvent(notify.New, "new", key)
+	}
+	keyspaceEvent(notify.Stream, "xadd", key)
+	if trimStream(stream, spec) > 0 {
+		keyspaceEvent(notify.Stream, "xtrim", key)
+	}
+	signalKeyAsReady(key)
+	return resp.SerializeRESP(id.String(), true)
+}
+
+// nextStreamID resolves the ID argument of XADD, which is "*", "<ms>-*" or
+// an explicit ID greater than the last one.
+func nextStreamID(stream *model.Stream, arg string) (model.StreamID, string) {
+	last := stream.LastID
+	exhausted := "-ERR The stream has exhausted the last possible ID, unable to add more items\r\n"
+	tooSmall := "-ERR The ID specified in XADD is equal or smaller than the target stream top item\r\n"
+
+	if arg == "*" {
+		ms := uint64(time.Now().UnixMilli())
+		if ms > last.Ms {
+			return model.StreamID{Ms: ms}, ""
+		}
+		id, ok := last.Next()
+		if !ok {
+			return id, exhausted
+		}
+		return id, ""
+	}
+
+	if msPart, found := strings.CutSuffix(arg, "-*"); found {
+		ms, err := strconv.ParseUint(msPart, 10, 64)
+		if err != nil {
+			return model.StreamID{}, invalidStreamIDReply
+		}
+		switch {
+		case ms > last.Ms:
+			return model.StreamID{Ms: ms}, ""
+		case ms < last.Ms:
+			return model.StreamID{}, tooSmall
+		}
+		id, ok := last.Next()
+		if !ok || id.Ms != ms {
+			return id, tooSmall
+		}
+		return id, ""
+	}
+
+	id, err := model.ParseStreamID(arg, 0)
+	if err != nil {
+		return id, invalidStreamIDReply
+	}
+	if id == (model.StreamID{}) {
+		return id, "-ERR The ID specified in XADD must be greater than 0-0\r\n"
+	}
+	if id.Compare(last) <= 0 {
+		return id, tooSmall
+	}
+	return id, ""
+}
+
+// parseStreamTrimOption parses a MAXLEN, MINID or LIMIT option at idx and
+// returns the index after it, or idx if the argument is not one of them.
+func parseStreamTrimOption(cmdArray []any, idx int, spec *streamTrimSpec) (int, string) {
+	opt, _ := argToString(cmdArray[idx])
+	switch strings.ToUpper(opt) {
+	case "MAXLEN", "MINID":
+		strategy := trimMaxLen
+		if strings.EqualFold(opt, "MINID") {
+			strategy = trimMinID
+		}
+		if spec.strategy != trimNone && spec.strategy != strategy {
+			return idx, "-ERR syntax error, MAXLEN and MINID options at the same time are not compatible\r\n"
+		}
+		spec.strategy = strategy
+
+		next := idx + 1
+		if next < len(cmdArray) {
+			if modifier, ok := cmdArray[next].(string); ok && (modifier == "~" || modifier == "=") {
+				spec.approx = modifier == "~"
+				next++
+			}
+		}
+		if next >= len(cmdArray) {
+			return idx, "-ERR syntax error\r\n"
+		}
+
+		if strategy == trimMaxLen {
+			maxLen, ok := argToInt64(cmdArray[next])
+			if !ok {
+				return idx, "-ERR value is not an integer or out of range\r\n"
+			}
+			if maxLen < 0 {
+				return idx, "-ERR The MAXLEN argument must be >= 0.\r\n"
+			}
+			spec.maxLen = int(maxLen)
+		} else {
+			arg, _ := argToString(cmdArray[next])
+			minID, err := model.ParseStreamID(arg, 0)
+			if err != nil {
+				return idx, invalidStreamIDReply
+			}
+			spec.minID = minID
+		}
+		return next + 1, ""
+	case "LIMIT":
+		if idx+1 >= len(cmdArray) {
+			return idx, "-ERR syntax error\r\n"
+		}
+		limit, ok := argToInt64(cmdArray[idx+1])
+		if !ok {
+			return idx, "-ERR value is not an integer or out of range\r\n"
+		}
+		if limit < 0 {
+			return idx, "-ERR The LIMIT argument must be >= 0.\r\n"
+		}
+		spec.limit, spec.limitGiven = int(limit), true
+		return idx + 2, ""
+	}
+	return idx, ""
+}
+
+func validateStreamTrimSpec(spec *streamTrimSpec) string {
+	if spec.limitGiven && !spec.approx {
+		return "-ERR syntax error, LIMIT cannot be used without the special ~ option\r\n"
+	}
+	// Approximate trimming does a bounded amount of work by default.
+	if spec.approx && !spec.limitGiven {
+		spec.limit = 100 * model.StreamNodeMaxEntries
+	}
+	return ""
+}
+
+func trimStream(stream *model.Stream, spec streamTrimSpec) int {
+	switch spec.strategy {
+	case trimMaxLen:
+		return stream.TrimMaxLen(spec.maxLen, spec.approx, spec.limit)
+	case trimMinID:
+		return stream.TrimMinID(spec.minID, spec.approx, spec.limit)
+	default:
+		return 0
+	}
+}
+
+func XTrim(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) < 4 {
+		return "-ERR wrong number of arguments for XTRIM\r\n"
+	}
+
+	key, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR invalid argument for XTRIM\r\n"
+	}
+
+	var spec streamTrimSpec
+	for idx := 2; idx < len(cmdArray); {
+		next, errReply := parseStreamTrimOption(cmdArray, idx, &spec)
+		if errReply != "" {
+			return errReply
+		}
+		if next == idx {
+			return "-ERR syntax error\r\n"
+		}
+		idx = next
+	}
+	if spec.strategy == trimNone {
+		return "-ERR syntax error\r\n"
+	}
+	if errReply := validateStreamTrimSpec(&spec); errReply != "" {
+		return errReply
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	stream, errReply := lookupStream(storedData, key)
+	if errReply != "" {
+		return errReply
+	}
+	if stream == nil {
+		return ":0\r\n"
+	}
+	trimmed := trimStream(stream, spec)
+	if trimmed > 0 {
+		keyspaceEvent(notify.Stream, "xtrim", key)
+	}
+	return ":" + strconv.Itoa(trimmed) + "\r\n"
+}
+
+func XLen(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) != 2 {
+		return "-ERR wrong number of arguments for XLEN\r\n"
+	}
+
+	key, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR invalid argument for XLEN\r\n"
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+
+	stream, errReply := lookupStream(storedData, key)
+	if errReply != "" {
+		return errReply
+	}
+	if stream == nil {
+		return ":0\r\n"
+	}
+	return ":" + strconv.Itoa(stream.Len()) + "\r\n"
+}
+
+func XDel(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) < 3 {
+		return "-ERR wrong number of arguments for XDEL\r\n"
+	}
+
+	key, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR invalid argument for XDEL\r\n"
+	}
+	ids, errReply := parseStreamIDs(cmdArray[2:])
+	if errReply != "" {
+		return errReply
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	stream, errReply := lookupStream(storedData, key)
+	if errReply != "" {
+		return errReply
+	}
+	if stream == nil {
+		return ":0\r\n"
+	}
+
+	deleted := 0
+	for _, id := range ids {
+		if stream.Delete(id) {
+			deleted++
+		}
+	}
+	if deleted > 0 {
+		keyspaceEvent(notify.Stream, "xdel", key)
+	}
+	return ":" + strconv.Itoa(deleted) + "\r\n"
+}
+
+func XRange(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	return streamRange("XRANGE", cmdArray, storedData, mu, false)
+}
+
+func XRevRange(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	return streamRange("XREVRANGE", cmdArray, storedData, mu, true)
+}
+
+func streamRange(name string, cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex, reverse bool) string {
+	if len(cmdArray) != 4 && len(cmdArray) != 6 {
+		return "-ERR wrong number of arguments for " + name + "\r\n"
+	}
+
+	key, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR invalid argument for " + name + "\r\n"
+	}
+
+	// XREVRANGE takes the end of the interval first.
+	startArg, endArg := cmdArray[2], cmdArray[3]
+	if reverse {
+		startArg, endArg = endArg, startArg
+	}
+	start, errReply := parseStreamRangeID(startArg, false)
+	if errReply != "" {
+		return errReply
+	}
+	end, errReply := parseStreamRangeID(endArg, true)
+	if errReply != "" {
+		return errReply
+	}
+
+	count := 0
+	if len(cmdArray) == 6 {
+		opt, _ := cmdArray[4].(string)
+		if !strings.EqualFold(opt, "COUNT") {
+			return "-ERR syntax error\r\n"
+		}
+		n, ok := argToInt64(cmdArray[5])
+		if !ok {
+			return "-ERR value is not an integer or out of range\r\n"
+		}
+		if n <= 0 {
+			return "*0\r\n"
+		}
+		count = int(n)
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+
+	stream, errReply := lookupStream(storedData, key)
+	if errReply != "" {
+		return errReply
+	}
+	if stream == nil {
+		return "*0\r\n"
+	}
+	return resp.SerializeBulk(streamEntriesReply(stream.Range(start, end, reverse, count)))
+}
+
+// parseStreamRangeID parses an XRANGE bound: "-", "+", an ID, an
+// incomplete "<ms>" ID or an exclusive "(" ID.
+func parseStreamRangeID(arg any, isEnd bool) (model.StreamID, string) {
+	s, _ := argToString(arg)
+	switch s {
+	case "-":
+		return model.StreamID{}, ""
+	case "+":
+		return model.MaxStreamID, ""
+	}
+
+	exclusive := strings.HasPrefix(s, "(")
+	s = strings.TrimPrefix(s, "(")
+	if exclusive && (s == "-" || s == "+") {
+		return model.StreamID{}, invalidStreamIDReply
+	}
+
+	var missingSeq uint64
+	if isEnd {
+		missingSeq = model.MaxStreamID.Seq
+	}
+	id, err := model.ParseStreamID(s, missingSeq)
+	if err != nil {
+		return id, invalidStreamIDReply
+	}
+
+	if exclusive {
+		var ok bool
+		if isEnd {
+			id, ok = id.Prev()
+		} else {
+			id, ok = id.Next()
+		}
+		if !ok {
+			if isEnd {
+				return id, "-ERR invalid end ID for the interval\r\n"
+			}
+			return id, "-ERR invalid start ID for the interval\r\n"
+		}
+	}
+	return id, ""
+}
+
+func parseStreamIDs(args []any) ([]model.StreamID, string) {
+	ids := make([]model.StreamID, 0, len(args))
+	for _, arg := range args {
+		s, _ := argToString(arg)
+		id, err := model.ParseStreamID(s, 0)
+		if err != nil {
+			return nil, invalidStreamIDReply
+		}
+		ids = append(ids, id)
+	}
+	return ids, ""
+}
+
+// XRead serves entries with IDs greater than the given ones. With BLOCK it
+// returns a BlockingRequest when no stream has new entries yet.
+func XRead(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) (string, *BlockingRequest) {
+	if len(cmdArray) < 4 {
+		return "-ERR wrong number of arguments for XREAD\r\n", nil
+	}
+
+	count := 0
+	block := false
+	var timeout time.Duration
+	idx := 1
+	for ; idx < len(cmdArray); idx++ {
+		opt, _ := cmdArray[idx].(string)
+		switch strings.ToUpper(opt) {
+		case "COUNT":
+			if idx+1 >= len(cmdArray) {
+				return "-ERR syntax error\r\n", nil
+			}
+			n, ok := argToInt64(cmdArray[idx+1])
+			if !ok {
+				return "-ERR value is not an integer or out of range\r\n", nil
+			}
+			count = max(int(n), 0)
+			idx++
+			continue
+		case "BLOCK":
+			if idx+1 >= len(cmdArray) {
+				return "-ERR syntax error\r\n", nil
+			}
+			ms, ok := argToInt64(cmdArray[idx+1])
+			if !ok {
+				return "-ERR timeout is not an integer or out of range\r\n", nil
+			}
+			if ms < 0 {
+				return "-ERR timeout is negative\r\n", nil
+			}
+			block, timeout = true, time.Duration(ms)*time.Millisecond
+			idx++
+			continue
+		case "STREAMS":
+		default:
+			return "-ERR syntax error\r\n", nil
+		}
+		break
+	}
+
+	args := cmdArray[min(idx+1, len(cmdArray)):]
+	if idx >= len(cmdArray) || len(args) == 0 {
+		return "-ERR syntax error\r\n", nil
+	}
+	if len(args)%2 != 0 {
+		return "-ERR Unbalanced 'xread' list of streams: for each stream key an ID or '$' must be specified.\r\n", nil
+	}
+	keys, ok := keyArgs(args[:len(args)/2])
+	if !ok {
+		return "-ERR invalid argument for XREAD\r\n", nil
+	}
+
+	mu.RLock()
+	ids := make([]model.StreamID, len(keys))
+	for i, arg := range args[len(args)/2:] {
+		stream, errReply := lookupStream(storedData, keys[i])
+		if errReply != "" {
+			mu.RUnlock()
+			return errReply, nil
+		}
+
+		s, _ := argToString(arg)
+		switch s {
+		case "$":
+			// Only entries added after this call.
+			if stream != nil {
+				ids[i] = stream.LastID
+			}
+		case "+":
+			// The last entry is served as well.
+			if stream != nil {
+				if last, found := stream.Last(); found {
+					ids[i], _ = last.ID.Prev()
+				}
+			}
+		default:
+			id, err := model.ParseStreamID(s, 0)
+			if err != nil {
+				mu.RUnlock()
+				return invalidStreamIDReply, nil
+			}
+			ids[i] = id
+		}
+	}
+	mu.RUnlock()
+
+	retry := func(storedData map[string]model.StoredData, mu *sync.RWMutex) (string, bool) {
+		mu.RLock()
+		defer mu.RUnlock()
+
+		var result []any
+		for i, key := range keys {
+			stream, errReply := lookupStream(storedData, key)
+			if errReply != "" {
+				return errReply, true
+			}
+			if stream == nil {
+				continue
+			}
+			start, ok := ids[i].Next()
+			if !ok {
+				continue
+			}
+			if entries := stream.Range(start, model.MaxStreamID, false, count); len(entries) > 0 {
+				result = append(result, []any{key, streamEntriesReply(entries)})
+			}
+		}
+		if len(result) == 0 {
+			return "", false
+		}
+		return resp.SerializeBulk(result), true
+	}
+
+	if reply, served := retry(storedData, mu); served {
+		return reply, nil
+	}
+	if !block {
+		return "*-1\r\n", nil
+	}
+	return "", &BlockingRequest{Keys: keys, Timeout: timeout, Retry: retry, TimeoutReply: "*-1\r\n"}
+}
+
+// XInfo implements XINFO STREAM key [FULL [COUNT count]], XINFO GROUPS key
+// and XINFO CONSUMERS key group.
+func XInfo(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) < 3 {
+		return "-ERR wrong number of arguments for XINFO\r\n"
+	}
+
+	subcommand, _ := cmdArray[1].(string)
+	subcommand = strings.ToUpper(subcommand)
+	key, ok := cmdArray[2].(string)
+	if !ok {
+		return "-ERR invalid argument for XINFO\r\n"
+	}
+
+	full := false
+	count := 10
+	var groupName string
+	switch subcommand {
+	case "STREAM":
+		for idx := 3; idx < len(cmdArray); idx++ {
+			opt, _ := cmdArray[idx].(string)
+			switch {
+			case strings.EqualFold(opt, "FULL"):
+				full = true
+			case strings.EqualFold(opt, "COUNT") && full && idx+1 < len(cmdArray):
+				n, ok := argToInt64(cmdArray[idx+1])
+				if !ok {
+					return "-ERR value is not an integer or out of range\r\n"
+				}
+				count = max(int(n), 0)
+				idx++
+			default:
+				return "-ERR syntax error\r\n"
+			}
+		}
+	case "GROUPS":
+		if len(cmdArray) != 3 {
+			return "-ERR wrong number of arguments for XINFO\r\n"
+		}
+	case "CONSUMERS":
+		if len(cmdArray) != 4 {
+			return "-ERR wrong number of arguments for XINFO\r\n"
+		}
+		groupName, _ = argToString(cmdArray[3])
+	default:
+		return "-ERR unknown subcommand '" + subcommand + "'. Try XINFO HELP.\r\n"
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+
+	stream, errReply := lookupStream(storedData, key)
+	if errReply != "" {
+		return errReply
+	}
+	if stream == nil {
+		return "-ERR no such key\r\n"
+	}
+
+	switch subcommand {
+	case "GROUPS":
+		return resp.SerializeBulk(xinfoGroups(stream))
+	case "CONSUMERS":
+		group := stream.Group(groupName)
+		if group == nil {
+			return fmt.Sprintf("-NOGROUP No such consumer group '%s' for key name '%s'\r\n", groupName, key)
+		}
+		return resp.SerializeBulk(xinfoConsumers(group))
+	}
+	return resp.SerializeBulk(streamInfo(stream, full, count))
+}
+
+func streamInfo(stream *model.Stream, full bool, count int) []any {
+	firstID := model.StreamID{}
+	first, hasFirst := stream.First()
+	if hasFirst {
+		firstID = first.ID
+	}
+
+	info := []any{
+		"length", stream.Len(),
+		"radix-tree-keys", stream.NodeCount(),
+		"radix-tree-nodes", stream.NodeCount(),
+		"last-generated-id", stream.LastID.String(),
+		"max-deleted-entry-id", stream.MaxDeletedID.String(),
+		"entries-added", int(stream.EntriesAdded),
+		"recorded-first-entry-id", firstID.String(),
+	}
+
+	if full {
+		// COUNT 0 returns every entry.
+		entries := stream.Range(model.StreamID{}, model.MaxStreamID, false, count)
+		return append(info, "entries", streamEntriesReply(entries), "groups", streamGroupsFull(stream, count))
+	}
+
+	last, hasLast := stream.Last()
+	info = append(info, "groups", len(stream.Groups()), "first-entry", nil, "last-entry", nil)
+	if hasFirst {
+		info[len(info)-3] = streamEntryReply(first)
+	}
+	if hasLast {
+		info[len(info)-1] = streamEntryReply(last)
+	}
+	return info
+}
+
+func streamEntryReply(entry model.StreamEntry) []any {
+	fields := make([]any, len(entry.Fields))
+	for i, field := range entry.Fields {
+		fields[i] = field
+	}
+	return []any{entry.ID.String(), fields}
+}
+
+func streamEntriesReply(entries []model.StreamEntry) []any {
+	result := make([]any, 0, len(entries))
+	for _, entry := range entries {
+		result = append(result, streamEntryReply(entry))
+	}
+	return result
+}
+
+// lookupStream returns the stream stored at key, or nil if the key does not
+// exist.
+func lookupStream(storedData map[string]model.StoredData, key string) (*model.Stream, string) {
+	value, found := storedData[key]
+	if !found {
+		return nil, ""
+	}
+
+	stream, ok := value.Value.(*model.Stream)
+	if !ok {
+		return nil, "-ERR value is not type of stream\r\n"
+	}
+	return stream, ""
+}
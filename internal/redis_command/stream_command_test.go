@@ -0,0 +1,235 @@
+package redis_command
+
+import (
+	"encoding/json"
+	"redis-go-clone/internal/model"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestXAdd(t *testing.T) {
+	storedData := map[string]model.StoredData{
+		"str": {Value: "x"},
+	}
+
+	runCommands(t, storedData, []commandCase{
+		{"explicit id", []any{"XADD", "s", "1-1", "name", "a"}, "$3\r\n1-1\r\n", XAdd},
+		{"sequence wildcard", []any{"XADD", "s", "1-*", "name", "b"}, "$3\r\n1-2\r\n", XAdd},
+		{"milliseconds only", []any{"XADD", "s", 5, "name", "c"}, "$3\r\n5-0\r\n", XAdd},
+		{"smaller id", []any{"XADD", "s", "4-0", "name", "d"}, "-ERR The ID specified in XADD is equal or smaller than the target stream top item\r\n", XAdd},
+		{"equal id", []any{"XADD", "s", "5-0", "name", "d"}, "-ERR The ID specified in XADD is equal or smaller than the target stream top item\r\n", XAdd},
+		{"smaller wildcard", []any{"XADD", "s", "4-*", "name", "d"}, "-ERR The ID specified in XADD is equal or smaller than the target stream top item\r\n", XAdd},
+		{"zero id", []any{"XADD", "other", "0-0", "name", "d"}, "-ERR The ID specified in XADD must be greater than 0-0\r\n", XAdd},
+		{"invalid id", []any{"XADD", "s", "abc", "name", "d"}, "-ERR Invalid stream ID specified as stream command argument\r\n", XAdd},
+		{"odd fields", []any{"XADD", "s", "*", "name", "d", "extra"}, "-ERR wrong number of arguments for XADD\r\n", XAdd},
+		{"nomkstream", []any{"XADD", "missing", "NOMKSTREAM", "*", "name", "d"}, "$-1\r\n", XAdd},
+		{"wrong type", []any{"XADD", "str", "*", "name", "d"}, "-ERR value is not type of stream\r\n", XAdd},
+		{"length", []any{"XLEN", "s"}, ":3\r\n", XLen},
+		{"missing length", []any{"XLEN", "missing"}, ":0\r\n", XLen},
+	})
+
+	if _, found := storedData["missing"]; found {
+		t.Error("expected NOMKSTREAM not to create the key")
+	}
+
+	mu := &sync.RWMutex{}
+	before := uint64(time.Now().UnixMilli())
+	reply := XAdd([]any{"XADD", "auto", "*", "a", 1}, storedData, mu)
+	id, err := model.ParseStreamID(strings.Split(reply, "\r\n")[1], 0)
+	if err != nil || id.Ms < before || id.Seq != 0 {
+		t.Errorf("expected a time based id, got %q", reply)
+	}
+
+	// An id in the future keeps auto generated ids increasing.
+	XAdd([]any{"XADD", "future", strconv.FormatUint(before+100000, 10) + "-5", "a", 1}, storedData, mu)
+	expected := strconv.FormatUint(before+100000, 10) + "-6"
+	if reply := XAdd([]any{"XADD", "future", "*", "a", 1}, storedData, mu); !strings.Contains(reply, expected) {
+		t.Errorf("expected %s, got %q", expected, reply)
+	}
+}
+
+func TestXRange(t *testing.T) {
+	storedData := make(map[string]model.StoredData)
+	mu := &sync.RWMutex{}
+	for i := 1; i <= 5; i++ {
+		XAdd([]any{"XADD", "s", strconv.Itoa(i) + "-0", "n", i}, storedData, mu)
+	}
+	XAdd([]any{"XADD", "s", "5-1", "other", "x", "y", "z"}, storedData, mu)
+	XAdd([]any{"XADD", "crlf", "1-0", "f", "a\r\n:1"}, storedData, mu)
+
+	entry := func(id, field, value string) string {
+		return "*2\r\n$3\r\n" + id + "\r\n*2\r\n$1\r\n" + field + "\r\n$1\r\n" + value + "\r\n"
+	}
+
+	runCommands(t, storedData, []commandCase{
+		{"bounded range", []any{"XRANGE", "s", 2, 3}, "*2\r\n" + entry("2-0", "n", "2") + entry("3-0", "n", "3"), XRange},
+		{"count", []any{"XRANGE", "s", "-", "+", "COUNT", 1}, "*1\r\n" + entry("1-0", "n", "1"), XRange},
+		{"exclusive start", []any{"XRANGE", "s", "(4-0", 4}, "*0\r\n", XRange},
+		{"incomplete end includes all sequences", []any{"XRANGE", "s", "(5-0", 5}, "*1\r\n*2\r\n$3\r\n5-1\r\n*4\r\n$5\r\nother\r\n$1\r\nx\r\n$1\r\ny\r\n$1\r\nz\r\n", XRange},
+		{"reverse", []any{"XREVRANGE", "s", 4, "-", "COUNT", 2}, "*2\r\n" + entry("4-0", "n", "4") + entry("3-0", "n", "3"), XRevRange},
+		{"empty interval", []any{"XRANGE", "s", 3, 2}, "*0\r\n", XRange},
+		{"missing key", []any{"XRANGE", "missing", "-", "+"}, "*0\r\n", XRange},
+		{"invalid bound", []any{"XRANGE", "s", "(-", "+"}, "-ERR Invalid stream ID specified as stream command argument\r\n", XRange},
+		{"delete", []any{"XDEL", "s", "2-0", "2-0", "9-0"}, ":1\r\n", XDel},
+		{"range skips deleted", []any{"XRANGE", "s", 1, 3}, "*2\r\n" + entry("1-0", "n", "1") + entry("3-0", "n", "3"), XRange},
+		{"length after delete", []any{"XLEN", "s"}, ":5\r\n", XLen},
+		{"value with CRLF", []any{"XRANGE", "crlf", "-", "+"}, "*1\r\n*2\r\n$3\r\n1-0\r\n*2\r\n$1\r\nf\r\n$5\r\na\r\n:1\r\n", XRange},
+	})
+}
+
+func TestXTrim(t *testing.T) {
+	defer func(entries int) { model.StreamNodeMaxEntries = entries }(model.StreamNodeMaxEntries)
+	model.StreamNodeMaxEntries = 10
+
+	newStream := func() map[string]model.StoredData {
+		storedData := make(map[string]model.StoredData)
+		for i := 1; i <= 35; i++ {
+			XAdd([]any{"XADD", "s", strconv.Itoa(i) + "-0", "n", i}, storedData, &sync.RWMutex{})
+		}
+		return storedData
+	}
+
+	tests := []struct {
+		name     string
+		cmdArray []any
+		expected string
+		length   int
+	}{
+		{"exact maxlen", []any{"XTRIM", "s", "MAXLEN", 12}, ":23\r\n", 12},
+		{"approximate maxlen keeps whole nodes", []any{"XTRIM", "s", "MAXLEN", "~", 12}, ":20\r\n", 15},
+		{"approximate with limit", []any{"XTRIM", "s", "MAXLEN", "~", 0, "LIMIT", 15}, ":10\r\n", 25},
+		{"exact minid", []any{"XTRIM", "s", "MINID", "=", 13}, ":12\r\n", 23},
+		{"approximate minid", []any{"XTRIM", "s", "MINID", "~", 13}, ":10\r\n", 25},
+		{"limit without approx", []any{"XTRIM", "s", "MAXLEN", 1, "LIMIT", 10}, "-ERR syntax error, LIMIT cannot be used without the special ~ option\r\n", 35},
+		{"both strategies", []any{"XTRIM", "s", "MAXLEN", 1, "MINID", 1}, "-ERR syntax error, MAXLEN and MINID options at the same time are not compatible\r\n", 35},
+		{"negative maxlen", []any{"XTRIM", "s", "MAXLEN", -1}, "-ERR The MAXLEN argument must be >= 0.\r\n", 35},
+	}
+
+	for _, tt := range tests {
+		storedData := newStream()
+		mu := &sync.RWMutex{}
+		if result := XTrim(tt.cmdArray, storedData, mu); result != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.expected, result)
+		}
+		if length := storedData["s"].Value.(*model.Stream).Len(); length != tt.length {
+			t.Errorf("%s: expected length %d, got %d", tt.name, tt.length, length)
+		}
+	}
+
+	storedData := newStream()
+	XAdd([]any{"XADD", "s", "MAXLEN", 5, "36-0", "n", 36}, storedData, &sync.RWMutex{})
+	stream := storedData["s"].Value.(*model.Stream)
+	if first, _ := stream.First(); stream.Len() != 5 || first.ID.Ms != 32 {
+		t.Errorf("expected XADD MAXLEN to keep 32-0..36-0, got %d entries from %v", stream.Len(), first.ID)
+	}
+}
+
+func TestXInfoStream(t *testing.T) {
+	storedData := make(map[string]model.StoredData)
+	mu := &sync.RWMutex{}
+	XAdd([]any{"XADD", "s", "1-0", "a", 1}, storedData, mu)
+	XAdd([]any{"XADD", "s", "2-0", "b", 2}, storedData, mu)
+	XAdd([]any{"XADD", "s", "3-0", "c", 3}, storedData, mu)
+	XDel([]any{"XDEL", "s", "3-0"}, storedData, mu)
+
+	expected := "*20\r\n" +
+		"$6\r\nlength\r\n:2\r\n" +
+		"$15\r\nradix-tree-keys\r\n:1\r\n" +
+		"$16\r\nradix-tree-nodes\r\n:1\r\n" +
+		"$17\r\nlast-generated-id\r\n$3\r\n3-0\r\n" +
+		"$20\r\nmax-deleted-entry-id\r\n$3\r\n3-0\r\n" +
+		"$13\r\nentries-added\r\n:3\r\n" +
+		"$23\r\nrecorded-first-entry-id\r\n$3\r\n1-0\r\n" +
+		"$6\r\ngroups\r\n:0\r\n" +
+		"$11\r\nfirst-entry\r\n*2\r\n$3\r\n1-0\r\n*2\r\n$1\r\na\r\n$1\r\n1\r\n" +
+		"$10\r\nlast-entry\r\n*2\r\n$3\r\n2-0\r\n*2\r\n$1\r\nb\r\n$1\r\n2\r\n"
+
+	runCommands(t, storedData, []commandCase{
+		{"stream info", []any{"XINFO", "STREAM", "s"}, expected, XInfo},
+		{"missing key", []any{"XINFO", "STREAM", "missing"}, "-ERR no such key\r\n", XInfo},
+	})
+}
+
+func TestXRead(t *testing.T) {
+	storedData := make(map[string]model.StoredData)
+	mu := &sync.RWMutex{}
+	XAdd([]any{"XADD", "a", "1-0", "f", "v1"}, storedData, mu)
+	XAdd([]any{"XADD", "a", "2-0", "f", "v2"}, storedData, mu)
+	XAdd([]any{"XADD", "b", "1-0", "f", "w1"}, storedData, mu)
+
+	tests := []struct {
+		name     string
+		cmdArray []any
+		expected string
+	}{
+		{"several streams", []any{"XREAD", "COUNT", 1, "STREAMS", "a", "b", "0", "0"}, "*2\r\n*2\r\n$1\r\na\r\n*1\r\n*2\r\n$3\r\n1-0\r\n*2\r\n$1\r\nf\r\n$2\r\nv1\r\n*2\r\n$1\r\nb\r\n*1\r\n*2\r\n$3\r\n1-0\r\n*2\r\n$1\r\nf\r\n$2\r\nw1\r\n"},
+		{"only newer entries", []any{"XREAD", "STREAMS", "a", "b", "1-0", "1-0"}, "*1\r\n*2\r\n$1\r\na\r\n*1\r\n*2\r\n$3\r\n2-0\r\n*2\r\n$1\r\nf\r\n$2\r\nv2\r\n"},
+		{"last entry", []any{"XREAD", "STREAMS", "a", "+"}, "*1\r\n*2\r\n$1\r\na\r\n*1\r\n*2\r\n$3\r\n2-0\r\n*2\r\n$1\r\nf\r\n$2\r\nv2\r\n"},
+		{"nothing new", []any{"XREAD", "STREAMS", "a", "$"}, "*-1\r\n"},
+		{"unbalanced", []any{"XREAD", "STREAMS", "a", "b", "0"}, "-ERR Unbalanced 'xread' list of streams: for each stream key an ID or '$' must be specified.\r\n"},
+		{"missing streams", []any{"XREAD", "COUNT", 1, "a"}, "-ERR syntax error\r\n"},
+	}
+
+	for _, tt := range tests {
+		reply, request := XRead(tt.cmdArray, storedData, mu)
+		if request != nil {
+			t.Fatalf("%s: expected an immediate reply", tt.name)
+		}
+		if reply != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.expected, reply)
+		}
+	}
+
+	var ready []string
+	SetKeyReadyHook(func(key string) { ready = append(ready, key) })
+	defer SetKeyReadyHook(nil)
+
+	_, request := XRead([]any{"XREAD", "BLOCK", 100, "STREAMS", "a", "$"}, storedData, mu)
+	if request == nil {
+		t.Fatal("expected XREAD BLOCK with $ to block")
+	}
+	if request.Timeout != 100*time.Millisecond {
+		t.Errorf("expected a 100ms timeout, got %v", request.Timeout)
+	}
+
+	XAdd([]any{"XADD", "a", "3-0", "f", "v3"}, storedData, mu)
+	if len(ready) != 1 || ready[0] != "a" {
+		t.Fatalf("expected XADD to signal a as ready, got %v", ready)
+	}
+	reply, served := request.Retry(storedData, mu)
+	if !served || reply != "*1\r\n*2\r\n$1\r\na\r\n*1\r\n*2\r\n$3\r\n3-0\r\n*2\r\n$1\r\nf\r\n$2\r\nv3\r\n" {
+		t.Errorf("expected the new entry, got %q (served=%v)", reply, served)
+	}
+}
+
+func TestStreamSurvivesSnapshot(t *testing.T) {
+	defer func(entries int) { model.StreamNodeMaxEntries = entries }(model.StreamNodeMaxEntries)
+	model.StreamNodeMaxEntries = 4
+
+	storedData := make(map[string]model.StoredData)
+	mu := &sync.RWMutex{}
+	for i := 1; i <= 10; i++ {
+		XAdd([]any{"XADD", "s", strconv.Itoa(i) + "-0", "n", i}, storedData, mu)
+	}
+	XDel([]any{"XDEL", "s", "10-0"}, storedData, mu)
+
+	data, err := json.Marshal(storedData)
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	loaded := make(map[string]model.StoredData)
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+
+	want := XInfo([]any{"XINFO", "STREAM", "s", "FULL"}, storedData, mu)
+	if got := XInfo([]any{"XINFO", "STREAM", "s", "FULL"}, loaded, mu); got != want {
+		t.Errorf("expected %q after reload, got %q", want, got)
+	}
+	if reply := XAdd([]any{"XADD", "s", "10-0", "n", 10}, loaded, mu); !strings.HasPrefix(reply, "-ERR The ID specified") {
+		t.Errorf("expected the last generated id to survive, got %q", reply)
+	}
+}
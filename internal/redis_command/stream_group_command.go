@@ -0,0 +1,793 @@
+package redis_command
+
+import (
+	"fmt"
+	"redis-go-clone/internal/model"
+	"redis-go-clone/internal/notify"
+	"redis-go-clone/pkg/resp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// streamNowMs is the clock used for delivery, idle and seen times.
+var streamNowMs = func() int64 { return time.Now().UnixMilli() }
+
+func noGroupReply(key, group string) string {
+	return fmt.Sprintf("-NOGROUP No such key '%s' or consumer group '%s'\r\n", key, group)
+}
+
+// lookupStreamGroup returns the stream at key and its named group, or the
+// NOGROUP error if either is missing.
+func lookupStreamGroup(storedData map[string]model.StoredData, key, groupName string) (*model.Stream, *model.StreamGroup, string) {
+	stream, errReply := lookupStream(storedData, key)
+	if errReply != "" {
+		return nil, nil, errReply
+	}
+	if stream == nil {
+		return nil, nil, noGroupReply(key, groupName)
+	}
+	group := stream.Group(groupName)
+	if group == nil {
+		return nil, nil, noGroupReply(key, groupName)
+	}
+	return stream, group, ""
+}
+
+// streamConsumer returns the named consumer of group, creating it the way
+// XREADGROUP and XCLAIM implicitly do.
+func streamConsumer(key string, group *model.StreamGroup, consumerName string, now int64) *model.StreamConsumer {
+	consumer, created := group.Consumer(consumerName, true, now)
+	if created {
+		keyspaceEvent(notify.Stream, "xgroup-createconsumer", key)
+	}
+	return consumer
+}
+
+// XGroup implements the CREATE, SETID, DESTROY, CREATECONSUMER and
+// DELCONSUMER subcommands.
+func XGroup(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) < 4 {
+		return "-ERR wrong number of arguments for XGROUP\r\n"
+	}
+
+	subcommand, _ := cmdArray[1].(string)
+	subcommand = strings.ToUpper(subcommand)
+	key, ok := cmdArray[2].(string)
+	if !ok {
+		return "-ERR invalid argument for XGROUP\r\n"
+	}
+	groupName, ok := argToString(cmdArray[3])
+	if !ok {
+		return "-ERR invalid argument for XGROUP\r\n"
+	}
+
+	switch subcommand {
+	case "CREATE", "SETID":
+		if len(cmdArray) < 5 {
+			return "-ERR wrong number of arguments for XGROUP\r\n"
+		}
+	case "DESTROY":
+		if len(cmdArray) != 4 {
+			return "-ERR wrong number of arguments for XGROUP\r\n"
+		}
+	case "CREATECONSUMER", "DELCONSUMER":
+		if len(cmdArray) != 5 {
+			return "-ERR wrong number of arguments for XGROUP\r\n"
+		}
+	default:
+		return "-ERR unknown subcommand '" + subcommand + "'. Try XGROUP HELP.\r\n"
+	}
+
+	mkStream := false
+	entriesRead := int64(model.EntriesReadUnknown)
+	if subcommand == "CREATE" || subcommand == "SETID" {
+		for idx := 5; idx < len(cmdArray); idx++ {
+			opt, _ := cmdArray[idx].(string)
+			switch {
+			case subcommand == "CREATE" && strings.EqualFold(opt, "MKSTREAM"):
+				mkStream = true
+			case strings.EqualFold(opt, "ENTRIESREAD") && idx+1 < len(cmdArray):
+				n, ok := argToInt64(cmdArray[idx+1])
+				if !ok {
+					return "-ERR value is not an integer or out of range\r\n"
+				}
+				if n < 0 && n != model.EntriesReadUnknown {
+					return "-ERR value for ENTRIESREAD must be positive or -1\r\n"
+				}
+				entriesRead = n
+				idx++
+			default:
+				return "-ERR syntax error\r\n"
+			}
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	stream, errReply := lookupStream(storedData, key)
+	if errReply != "" {
+		return errReply
+	}
+	if stream == nil {
+		if !mkStream {
+			return "-ERR The XGROUP subcommand requires the key to exist. Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically.\r\n"
+		}
+		stream = model.NewStream()
+		storedData[key] = model.StoredData{Value: stream}
+		keyspaceEvent(notify.New, "new", key)
+	}
+
+	var group *model.StreamGroup
+	if subcommand != "CREATE" && subcommand != "DESTROY" {
+		if group = stream.Group(groupName); group == nil {
+			return fmt.Sprintf("-NOGROUP No such consumer group '%s' for key name '%s'\r\n", groupName, key)
+		}
+	}
+
+	switch subcommand {
+	case "CREATE", "SETID":
+		idArg, _ := argToString(cmdArray[4])
+		id := stream.LastID
+		if idArg != "$" {
+			var err error
+			if id, err = model.ParseStreamID(idArg, 0); err != nil {
+				return invalidStreamIDReply
+			}
+		}
+
+		if subcommand == "SETID" {
+			group.LastID = id
+			group.EntriesRead = entriesRead
+			keyspaceEvent(notify.Stream, "xgroup-setid", key)
+			return "+OK\r\n"
+		}
+		if !stream.CreateGroup(groupName, id, entriesRead) {
+			return "-BUSYGROUP Consumer Group name already exists\r\n"
+		}
+		keyspaceEvent(notify.Stream, "xgroup-create", key)
+		return "+OK\r\n"
+
+	case "DESTROY":
+		if !stream.DestroyGroup(groupName) {
+			return ":0\r\n"
+		}
+		keyspaceEvent(notify.Stream, "xgroup-destroy", key)
+		// Clients blocked on the group get a NOGROUP error.
+		signalKeyAsReady(key)
+		return ":1\r\n"
+
+	case "CREATECONSUMER":
+		consumerName, _ := argToString(cmdArray[4])
+		if _, created := group.Consumer(consumerName, true, streamNowMs()); created {
+			keyspaceEvent(notify.Stream, "xgroup-createconsumer", key)
+			return ":1\r\n"
+		}
+		return ":0\r\n"
+
+	default: // DELCONSUMER
+		consumerName, _ := argToString(cmdArray[4])
+		pending, deleted := group.DeleteConsumer(consumerName)
+		if deleted {
+			keyspaceEvent(notify.Stream, "xgroup-delconsumer", key)
+		}
+		return ":" + strconv.Itoa(pending) + "\r\n"
+	}
+}
+
+// XReadGroup implements XREADGROUP GROUP group consumer [COUNT count]
+// [BLOCK ms] [NOACK] STREAMS key [key ...] id [id ...]. The ">" ID serves
+// entries never delivered to the group; any other ID serves the consumer's
+// pending entries after it.
+func XReadGroup(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) (string, *BlockingRequest) {
+	if len(cmdArray) < 7 {
+		return "-ERR wrong number of arguments for XREADGROUP\r\n", nil
+	}
+
+	var groupName, consumerName string
+	groupGiven := false
+	count := 0
+	block, noAck := false, false
+	var timeout time.Duration
+	idx := 1
+	for ; idx < len(cmdArray); idx++ {
+		opt, _ := cmdArray[idx].(string)
+		switch strings.ToUpper(opt) {
+		case "GROUP":
+			if idx+2 >= len(cmdArray) {
+				return "-ERR syntax error\r\n", nil
+			}
+			groupName, _ = argToString(cmdArray[idx+1])
+			consumerName, _ = argToString(cmdArray[idx+2])
+			groupGiven = true
+			idx += 2
+			continue
+		case "COUNT":
+			if idx+1 >= len(cmdArray) {
+				return "-ERR syntax error\r\n", nil
+			}
+			n, ok := argToInt64(cmdArray[idx+1])
+			if !ok {
+				return "-ERR value is not an integer or out of range\r\n", nil
+			}
+			count = max(int(n), 0)
+			idx++
+			continue
+		case "BLOCK":
+			if idx+1 >= len(cmdArray) {
+				return "-ERR syntax error\r\n", nil
+			}
+			ms, ok := argToInt64(cmdArray[idx+1])
+			if !ok {
+				return "-ERR timeout is not an integer or out of range\r\n", nil
+			}
+			if ms < 0 {
+				return "-ERR timeout is negative\r\n", nil
+			}
+			block, timeout = true, time.Duration(ms)*time.Millisecond
+			idx++
+			continue
+		case "NOACK":
+			noAck = true
+			continue
+		case "STREAMS":
+		default:
+			return "-ERR syntax error\r\n", nil
+		}
+		break
+	}
+
+	if !groupGiven {
+		return "-ERR Missing GROUP option for XREADGROUP\r\n", nil
+	}
+	args := cmdArray[min(idx+1, len(cmdArray)):]
+	if idx >= len(cmdArray) || len(args) == 0 {
+		return "-ERR syntax error\r\n", nil
+	}
+	if len(args)%2 != 0 {
+		return "-ERR Unbalanced 'xreadgroup' list of streams: for each stream key an ID or '>' must be specified.\r\n", nil
+	}
+	keys, ok := keyArgs(args[:len(args)/2])
+	if !ok {
+		return "-ERR invalid argument for XREADGROUP\r\n", nil
+	}
+
+	// A nil ID stands for ">".
+	ids := make([]*model.StreamID, len(keys))
+	history := false
+	for i, arg := range args[len(args)/2:] {
+		s, _ := argToString(arg)
+		if s == ">" {
+			continue
+		}
+		id, err := model.ParseStreamID(s, 0)
+		if err != nil {
+			return invalidStreamIDReply, nil
+		}
+		ids[i] = &id
+		history = true
+	}
+
+	retry := func(storedData map[string]model.StoredData, mu *sync.RWMutex) (string, bool) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		now := streamNowMs()
+		var result []any
+		for i, key := range keys {
+			stream, group, errReply := lookupStreamGroup(storedData, key, groupName)
+			if errReply != "" {
+				if strings.HasPrefix(errReply, "-NOGROUP") {
+					errReply = fmt.Sprintf("-NOGROUP No such key '%s' or consumer group '%s' in XREADGROUP with GROUP option\r\n", key, groupName)
+				}
+				return errReply, true
+			}
+			consumer := streamConsumer(key, group, consumerName, now)
+			consumer.SeenTime = now
+
+			if ids[i] != nil {
+				// History is always served, even when empty.
+				result = append(result, []any{key, consumerHistory(stream, group, consumerName, *ids[i], count, now)})
+				continue
+			}
+
+			start, ok := group.LastID.Next()
+			if !ok {
+				continue
+			}
+			entries := stream.Range(start, model.MaxStreamID, false, count)
+			if len(entries) == 0 {
+				continue
+			}
+			for _, entry := range entries {
+				stream.AdvanceGroup(group, entry.ID)
+				if !noAck {
+					group.Deliver(entry.ID, consumer, now)
+				}
+			}
+			consumer.ActiveTime = now
+			result = append(result, []any{key, streamEntriesReply(entries)})
+		}
+		if len(result) == 0 {
+			return "", false
+		}
+		return resp.SerializeBulk(result), true
+	}
+
+	if reply, served := retry(storedData, mu); served {
+		return reply, nil
+	}
+	if !block || history {
+		return "*-1\r\n", nil
+	}
+	return "", &BlockingRequest{Keys: keys, Timeout: timeout, Retry: retry, TimeoutReply: "*-1\r\n"}
+}
+
+// consumerHistory re-delivers the consumer's pending entries after id.
+// Entries deleted from the stream are reported with nil fields.
+func consumerHistory(stream *model.Stream, group *model.StreamGroup, consumer string, id model.StreamID, count int, now int64) []any {
+	start, ok := id.Next()
+	if !ok {
+		return []any{}
+	}
+
+	result := []any{}
+	for _, pending := range group.PendingRange(start, model.MaxStreamID, consumer, count) {
+		entries := stream.Range(pending.ID, pending.ID, false, 1)
+		if len(entries) == 0 {
+			result = append(result, []any{pending.ID.String(), nil})
+			continue
+		}
+		pending.DeliveryTime = now
+		pending.DeliveryCount++
+		result = append(result, streamEntryReply(entries[0]))
+	}
+	return result
+}
+
+func XAck(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) < 4 {
+		return "-ERR wrong number of arguments for XACK\r\n"
+	}
+
+	key, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR invalid argument for XACK\r\n"
+	}
+	groupName, _ := argToString(cmdArray[2])
+	ids, errReply := parseStreamIDs(cmdArray[3:])
+	if errReply != "" {
+		return errReply
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	stream, errReply := lookupStream(storedData, key)
+	if errReply != "" {
+		return errReply
+	}
+	if stream == nil || stream.Group(groupName) == nil {
+		return ":0\r\n"
+	}
+
+	group := stream.Group(groupName)
+	acked := 0
+	for _, id := range ids {
+		if group.Ack(id) {
+			acked++
+		}
+	}
+	return ":" + strconv.Itoa(acked) + "\r\n"
+}
+
+// XPending implements both the summary form, XPENDING key group, and the
+// extended form, XPENDING key group [IDLE min-idle] start end count
+// [consumer].
+func XPending(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) < 3 {
+		return "-ERR wrong number of arguments for XPENDING\r\n"
+	}
+
+	key, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR invalid argument for XPENDING\r\n"
+	}
+	groupName, _ := argToString(cmdArray[2])
+
+	args := cmdArray[3:]
+	minIdle := int64(0)
+	if len(args) > 0 {
+		if opt, _ := args[0].(string); strings.EqualFold(opt, "IDLE") {
+			if len(args) < 2 {
+				return "-ERR syntax error\r\n"
+			}
+			n, ok := argToInt64(args[1])
+			if !ok {
+				return "-ERR value is not an integer or out of range\r\n"
+			}
+			minIdle = n
+			args = args[2:]
+			if len(args) == 0 {
+				return "-ERR syntax error\r\n"
+			}
+		}
+	}
+	extended := len(args) > 0
+	if extended && len(args) != 3 && len(args) != 4 {
+		return "-ERR syntax error\r\n"
+	}
+
+	var start, end model.StreamID
+	var count int64
+	var consumer string
+	if extended {
+		var errReply string
+		if start, errReply = parseStreamRangeID(args[0], false); errReply != "" {
+			return errReply
+		}
+		if end, errReply = parseStreamRangeID(args[1], true); errReply != "" {
+			return errReply
+		}
+		if count, ok = argToInt64(args[2]); !ok {
+			return "-ERR value is not an integer or out of range\r\n"
+		}
+		if len(args) == 4 {
+			consumer, _ = argToString(args[3])
+		}
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+
+	_, group, errReply := lookupStreamGroup(storedData, key, groupName)
+	if errReply != "" {
+		return errReply
+	}
+
+	if !extended {
+		pending := group.PendingRange(model.StreamID{}, model.MaxStreamID, "", 0)
+		if len(pending) == 0 {
+			return resp.SerializeBulk([]any{0, nil, nil, nil})
+		}
+
+		consumers := []any{}
+		for _, c := range group.SortedConsumers() {
+			if c.Pending > 0 {
+				consumers = append(consumers, []any{c.Name, strconv.Itoa(c.Pending)})
+			}
+		}
+		reply := []any{len(pending), pending[0].ID.String(), pending[len(pending)-1].ID.String(), consumers}
+		return resp.SerializeBulk(reply)
+	}
+
+	now := streamNowMs()
+	result := []any{}
+	for _, pending := range group.PendingRange(start, end, consumer, 0) {
+		if int64(len(result)) >= count {
+			break
+		}
+		idle := now - pending.DeliveryTime
+		if idle < minIdle {
+			continue
+		}
+		result = append(result, []any{pending.ID.String(), pending.Consumer, int(idle), int(pending.DeliveryCount)})
+	}
+	return resp.SerializeBulk(result)
+}
+
+// XClaim implements XCLAIM key group consumer min-idle-time id [id ...]
+// [IDLE ms] [TIME unix-ms] [RETRYCOUNT count] [FORCE] [JUSTID] [LASTID id].
+func XClaim(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) < 6 {
+		return "-ERR wrong number of arguments for XCLAIM\r\n"
+	}
+
+	key, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR invalid argument for XCLAIM\r\n"
+	}
+	groupName, _ := argToString(cmdArray[2])
+	consumerName, _ := argToString(cmdArray[3])
+	minIdle, ok := argToInt64(cmdArray[4])
+	if !ok {
+		return "-ERR Invalid min-idle-time argument for XCLAIM\r\n"
+	}
+	minIdle = max(minIdle, 0)
+
+	var ids []model.StreamID
+	idx := 5
+	for ; idx < len(cmdArray); idx++ {
+		s, _ := argToString(cmdArray[idx])
+		id, err := model.ParseStreamID(s, 0)
+		if err != nil {
+			break
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return invalidStreamIDReply
+	}
+
+	now := streamNowMs()
+	deliveryTime := int64(-1)
+	retryCount := int64(-1)
+	force, justID := false, false
+	var lastID *model.StreamID
+	for ; idx < len(cmdArray); idx++ {
+		opt, _ := argToString(cmdArray[idx])
+		hasValue := idx+1 < len(cmdArray)
+		switch strings.ToUpper(opt) {
+		case "FORCE":
+			force = true
+		case "JUSTID":
+			justID = true
+		case "IDLE", "TIME", "RETRYCOUNT":
+			if !hasValue {
+				return "-ERR syntax error\r\n"
+			}
+			n, ok := argToInt64(cmdArray[idx+1])
+			if !ok {
+				return "-ERR Invalid " + strings.ToUpper(opt) + " option argument for XCLAIM\r\n"
+			}
+			switch strings.ToUpper(opt) {
+			case "IDLE":
+				deliveryTime = now - n
+			case "TIME":
+				deliveryTime = n
+			default:
+				retryCount = n
+			}
+			idx++
+		case "LASTID":
+			if !hasValue {
+				return "-ERR syntax error\r\n"
+			}
+			s, _ := argToString(cmdArray[idx+1])
+			id, err := model.ParseStreamID(s, 0)
+			if err != nil {
+				return invalidStreamIDReply
+			}
+			lastID = &id
+			idx++
+		default:
+			return "-ERR Unrecognized XCLAIM option '" + opt + "'\r\n"
+		}
+	}
+	if deliveryTime < 0 || deliveryTime > now {
+		deliveryTime = now
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	stream, group, errReply := lookupStreamGroup(storedData, key, groupName)
+	if errReply != "" {
+		return errReply
+	}
+	if lastID != nil && lastID.Compare(group.LastID) > 0 {
+		group.LastID = *lastID
+	}
+
+	consumer := streamConsumer(key, group, consumerName, now)
+	consumer.SeenTime = now
+
+	result := []any{}
+	for _, id := range ids {
+		pending := group.PendingEntry(id)
+		exists := stream.Contains(id)
+		if pending == nil && force && exists {
+			group.Deliver(id, consumer, now)
+			pending = group.PendingEntry(id)
+		}
+		if pending == nil {
+			continue
+		}
+		if !exists {
+			// The entry was deleted: it can never be processed.
+			group.Ack(id)
+			continue
+		}
+		if now-pending.DeliveryTime < minIdle {
+			continue
+		}
+
+		group.Claim(pending, consumer)
+		pending.DeliveryTime = deliveryTime
+		if retryCount >= 0 {
+			pending.DeliveryCount = retryCount
+		} else if !justID {
+			pending.DeliveryCount++
+		}
+		consumer.ActiveTime = now
+
+		if justID {
+			result = append(result, id.String())
+		} else {
+			result = append(result, streamEntryReply(stream.Range(id, id, false, 1)[0]))
+		}
+	}
+	return resp.SerializeBulk(result)
+}
+
+// XAutoClaim implements XAUTOCLAIM key group consumer min-idle-time start
+// [COUNT count] [JUSTID]. It replies with the cursor to continue from, the
+// claimed entries and the IDs of deleted entries removed from the PEL.
+func XAutoClaim(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) < 6 {
+		return "-ERR wrong number of arguments for XAUTOCLAIM\r\n"
+	}
+
+	key, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR invalid argument for XAUTOCLAIM\r\n"
+	}
+	groupName, _ := argToString(cmdArray[2])
+	consumerName, _ := argToString(cmdArray[3])
+	minIdle, ok := argToInt64(cmdArray[4])
+	if !ok {
+		return "-ERR Invalid min-idle-time argument for XAUTOCLAIM\r\n"
+	}
+	minIdle = max(minIdle, 0)
+	start, errReply := parseStreamRangeID(cmdArray[5], false)
+	if errReply != "" {
+		return errReply
+	}
+
+	count := 100
+	justID := false
+	for idx := 6; idx < len(cmdArray); idx++ {
+		opt, _ := cmdArray[idx].(string)
+		switch {
+		case strings.EqualFold(opt, "JUSTID"):
+			justID = true
+		case strings.EqualFold(opt, "COUNT") && idx+1 < len(cmdArray):
+			n, ok := argToInt64(cmdArray[idx+1])
+			if !ok || n < 1 {
+				return "-ERR COUNT must be > 0\r\n"
+			}
+			count = int(n)
+			idx++
+		default:
+			return "-ERR syntax error\r\n"
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	stream, group, errReply := lookupStreamGroup(storedData, key, groupName)
+	if errReply != "" {
+		return errReply
+	}
+
+	now := streamNowMs()
+	consumer := streamConsumer(key, group, consumerName, now)
+	consumer.SeenTime = now
+
+	// Bound the scan so a PEL full of young entries can't stall the server.
+	attempts := count * 10
+	cursor := model.StreamID{}
+	claimed, deleted := []any{}, []any{}
+	for i, pending := range group.PendingRange(start, model.MaxStreamID, "", attempts+1) {
+		if i == attempts || count == 0 {
+			cursor = pending.ID
+			break
+		}
+
+		entries := stream.Range(pending.ID, pending.ID, false, 1)
+		if len(entries) == 0 {
+			group.Ack(pending.ID)
+			deleted = append(deleted, pending.ID.String())
+			continue
+		}
+		if now-pending.DeliveryTime < minIdle {
+			continue
+		}
+
+		group.Claim(pending, consumer)
+		pending.DeliveryTime = now
+		if !justID {
+			pending.DeliveryCount++
+		}
+		consumer.ActiveTime = now
+		count--
+
+		if justID {
+			claimed = append(claimed, pending.ID.String())
+		} else {
+			claimed = append(claimed, streamEntryReply(entries[0]))
+		}
+	}
+	return resp.SerializeBulk([]any{cursor.String(), claimed, deleted})
+}
+
+// xinfoGroups implements XINFO GROUPS key.
+func xinfoGroups(stream *model.Stream) []any {
+	result := []any{}
+	for _, group := range stream.Groups() {
+		result = append(result, []any{
+			"name", group.Name,
+			"consumers", len(group.Consumers),
+			"pending", group.PendingLen(),
+			"last-delivered-id", group.LastID.String(),
+			"entries-read", entriesReadReply(group),
+			"lag", lagReply(stream, group),
+		})
+	}
+	return result
+}
+
+// xinfoConsumers implements XINFO CONSUMERS key group.
+func xinfoConsumers(group *model.StreamGroup) []any {
+	now := streamNowMs()
+	result := []any{}
+	for _, consumer := range group.SortedConsumers() {
+		inactive := -1
+		if consumer.ActiveTime >= 0 {
+			inactive = int(now - consumer.ActiveTime)
+		}
+		result = append(result, []any{
+			"name", consumer.Name,
+			"pending", consumer.Pending,
+			"idle", int(now - consumer.SeenTime),
+			"inactive", inactive,
+		})
+	}
+	return result
+}
+
+// streamGroupsFull is the groups section of XINFO STREAM FULL, listing at
+// most count pending entries per group and consumer (0 means all).
+func streamGroupsFull(stream *model.Stream, count int) []any {
+	result := []any{}
+	for _, group := range stream.Groups() {
+		pel := []any{}
+		for _, p := range group.PendingRange(model.StreamID{}, model.MaxStreamID, "", count) {
+			pel = append(pel, []any{p.ID.String(), p.Consumer, int(p.DeliveryTime), int(p.DeliveryCount)})
+		}
+
+		consumers := []any{}
+		for _, consumer := range group.SortedConsumers() {
+			consumerPEL := []any{}
+			for _, p := range group.PendingRange(model.StreamID{}, model.MaxStreamID, consumer.Name, count) {
+				consumerPEL = append(consumerPEL, []any{p.ID.String(), int(p.DeliveryTime), int(p.DeliveryCount)})
+			}
+			consumers = append(consumers, []any{
+				"name", consumer.Name,
+				"seen-time", int(consumer.SeenTime),
+				"active-time", int(consumer.ActiveTime),
+				"pel-count", consumer.Pending,
+				"pending", consumerPEL,
+			})
+		}
+
+		result = append(result, []any{
+			"name", group.Name,
+			"last-delivered-id", group.LastID.String(),
+			"entries-read", entriesReadReply(group),
+			"lag", lagReply(stream, group),
+			"pel-count", group.PendingLen(),
+			"pending", pel,
+			"consumers", consumers,
+		})
+	}
+	return result
+}
+
+func entriesReadReply(group *model.StreamGroup) any {
+	if group.EntriesRead == model.EntriesReadUnknown {
+		return nil
+	}
+	return int(group.EntriesRead)
+}
+
+func lagReply(stream *model.Stream, group *model.StreamGroup) any {
+	lag, ok := stream.Lag(group)
+	if !ok {
+		return nil
+	}
+	return int(lag)
+}
@@ -0,0 +1,185 @@
+package redis_command
+
+import (
+	"encoding/json"
+	"redis-go-clone/internal/model"
+	"sync"
+	"testing"
+)
+
+func xReadGroup(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	reply, _ := XReadGroup(cmdArray, storedData, mu)
+	return reply
+}
+
+func setStreamClock(t *testing.T, now *int64) {
+	t.Helper()
+	previous := streamNowMs
+	streamNowMs = func() int64 { return *now }
+	t.Cleanup(func() { streamNowMs = previous })
+}
+
+func TestStreamConsumerGroups(t *testing.T) {
+	now := int64(1000)
+	setStreamClock(t, &now)
+
+	storedData := make(map[string]model.StoredData)
+	mu := &sync.RWMutex{}
+	for _, id := range []string{"1-0", "2-0", "3-0"} {
+		XAdd([]any{"XADD", "s", id, "f", id}, storedData, mu)
+	}
+
+	entry := func(id string) string { return "*2\r\n$3\r\n" + id + "\r\n*2\r\n$1\r\nf\r\n$3\r\n" + id + "\r\n" }
+
+	runCommands(t, storedData, []commandCase{
+		{"create", []any{"XGROUP", "CREATE", "s", "g", "0"}, "+OK\r\n", XGroup},
+		{"create twice", []any{"XGROUP", "CREATE", "s", "g", "$"}, "-BUSYGROUP Consumer Group name already exists\r\n", XGroup},
+		{"create on missing key", []any{"XGROUP", "CREATE", "missing", "g", "$"}, "-ERR The XGROUP subcommand requires the key to exist. Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically.\r\n", XGroup},
+		{"create with MKSTREAM", []any{"XGROUP", "CREATE", "empty", "g", "$", "MKSTREAM"}, "+OK\r\n", XGroup},
+		{"setid on missing group", []any{"XGROUP", "SETID", "s", "nope", "0"}, "-NOGROUP No such consumer group 'nope' for key name 's'\r\n", XGroup},
+		{"create consumer", []any{"XGROUP", "CREATECONSUMER", "s", "g", "carol"}, ":1\r\n", XGroup},
+		{"create existing consumer", []any{"XGROUP", "CREATECONSUMER", "s", "g", "carol"}, ":0\r\n", XGroup},
+		{"read new entries", []any{"XREADGROUP", "GROUP", "g", "alice", "COUNT", 2, "STREAMS", "s", ">"}, "*1\r\n*2\r\n$1\r\ns\r\n*2\r\n" + entry("1-0") + entry("2-0"), xReadGroup},
+		{"read the rest", []any{"XREADGROUP", "GROUP", "g", "bob", "STREAMS", "s", ">"}, "*1\r\n*2\r\n$1\r\ns\r\n*1\r\n" + entry("3-0"), xReadGroup},
+		{"nothing new", []any{"XREADGROUP", "GROUP", "g", "bob", "STREAMS", "s", ">"}, "*-1\r\n", xReadGroup},
+		{"missing group", []any{"XREADGROUP", "GROUP", "nope", "bob", "STREAMS", "s", ">"}, "-NOGROUP No such key 's' or consumer group 'nope' in XREADGROUP with GROUP option\r\n", xReadGroup},
+		{"missing GROUP option", []any{"XREADGROUP", "COUNT", 1, "NOACK", "STREAMS", "s", ">"}, "-ERR Missing GROUP option for XREADGROUP\r\n", xReadGroup},
+		{"pending summary", []any{"XPENDING", "s", "g"}, "*4\r\n:3\r\n$3\r\n1-0\r\n$3\r\n3-0\r\n*2\r\n*2\r\n$5\r\nalice\r\n$1\r\n2\r\n*2\r\n$3\r\nbob\r\n$1\r\n1\r\n", XPending},
+		{"ack", []any{"XACK", "s", "g", "1-0", "9-0"}, ":1\r\n", XAck},
+		{"ack twice", []any{"XACK", "s", "g", "1-0"}, ":0\r\n", XAck},
+		{"pending on missing group", []any{"XPENDING", "s", "nope"}, "-NOGROUP No such key 's' or consumer group 'nope'\r\n", XPending},
+		{"empty pending summary", []any{"XPENDING", "empty", "g"}, "*4\r\n:0\r\n$-1\r\n$-1\r\n$-1\r\n", XPending},
+	})
+
+	now = 6000
+	runCommands(t, storedData, []commandCase{
+		{"pending extended", []any{"XPENDING", "s", "g", "IDLE", 5000, "-", "+", 10}, "*2\r\n*4\r\n$3\r\n2-0\r\n$5\r\nalice\r\n:5000\r\n:1\r\n*4\r\n$3\r\n3-0\r\n$3\r\nbob\r\n:5000\r\n:1\r\n", XPending},
+		{"pending by consumer", []any{"XPENDING", "s", "g", "-", "+", 10, "bob"}, "*1\r\n*4\r\n$3\r\n3-0\r\n$3\r\nbob\r\n:5000\r\n:1\r\n", XPending},
+		{"pending too young", []any{"XPENDING", "s", "g", "IDLE", 5001, "-", "+", 10}, "*0\r\n", XPending},
+		{"claim too young", []any{"XCLAIM", "s", "g", "bob", 10000, "2-0"}, "*0\r\n", XClaim},
+		{"claim", []any{"XCLAIM", "s", "g", "bob", 1000, "2-0"}, "*1\r\n" + entry("2-0"), XClaim},
+		{"claim just id", []any{"XCLAIM", "s", "g", "bob", 0, "2-0", "JUSTID"}, "*1\r\n$3\r\n2-0\r\n", XClaim},
+		{"bad claim option", []any{"XCLAIM", "s", "g", "bob", 0, "2-0", "FOO"}, "-ERR Unrecognized XCLAIM option 'FOO'\r\n", XClaim},
+		{"history", []any{"XREADGROUP", "GROUP", "g", "bob", "STREAMS", "s", "0"}, "*1\r\n*2\r\n$1\r\ns\r\n*2\r\n" + entry("2-0") + entry("3-0"), xReadGroup},
+		{"delivery counts", []any{"XPENDING", "s", "g", "-", "+", 10}, "*2\r\n*4\r\n$3\r\n2-0\r\n$3\r\nbob\r\n:0\r\n:3\r\n*4\r\n$3\r\n3-0\r\n$3\r\nbob\r\n:0\r\n:2\r\n", XPending},
+		{"delete an entry", []any{"XDEL", "s", "3-0"}, ":1\r\n", XDel},
+	})
+
+	now = 20000
+	runCommands(t, storedData, []commandCase{
+		{"history of a deleted entry", []any{"XREADGROUP", "GROUP", "g", "bob", "STREAMS", "s", "2-0"}, "*1\r\n*2\r\n$1\r\ns\r\n*1\r\n*2\r\n$3\r\n3-0\r\n$-1\r\n", xReadGroup},
+		{"autoclaim", []any{"XAUTOCLAIM", "s", "g", "alice", 1000, "-"}, "*3\r\n$3\r\n0-0\r\n*1\r\n" + entry("2-0") + "*1\r\n$3\r\n3-0\r\n", XAutoClaim},
+		{"autoclaim cursor", []any{"XAUTOCLAIM", "s", "g", "alice", 0, "-", "COUNT", 1, "JUSTID"}, "*3\r\n$3\r\n0-0\r\n*1\r\n$3\r\n2-0\r\n*0\r\n", XAutoClaim},
+		{
+			"groups",
+			[]any{"XINFO", "GROUPS", "s"},
+			"*1\r\n*12\r\n$4\r\nname\r\n$1\r\ng\r\n$9\r\nconsumers\r\n:3\r\n$7\r\npending\r\n:1\r\n$17\r\nlast-delivered-id\r\n$3\r\n3-0\r\n$12\r\nentries-read\r\n:3\r\n$3\r\nlag\r\n:0\r\n",
+			XInfo,
+		},
+		{
+			"consumers",
+			[]any{"XINFO", "CONSUMERS", "s", "g"},
+			"*3\r\n" +
+				"*8\r\n$4\r\nname\r\n$5\r\nalice\r\n$7\r\npending\r\n:1\r\n$4\r\nidle\r\n:0\r\n$8\r\ninactive\r\n:0\r\n" +
+				"*8\r\n$4\r\nname\r\n$3\r\nbob\r\n$7\r\npending\r\n:0\r\n$4\r\nidle\r\n:0\r\n$8\r\ninactive\r\n:14000\r\n" +
+				"*8\r\n$4\r\nname\r\n$5\r\ncarol\r\n$7\r\npending\r\n:0\r\n$4\r\nidle\r\n:19000\r\n$8\r\ninactive\r\n:-1\r\n",
+			XInfo,
+		},
+		{"delete consumer", []any{"XGROUP", "DELCONSUMER", "s", "g", "alice"}, ":1\r\n", XGroup},
+		{"nothing pending", []any{"XPENDING", "s", "g"}, "*4\r\n:0\r\n$-1\r\n$-1\r\n$-1\r\n", XPending},
+		{"destroy", []any{"XGROUP", "DESTROY", "s", "g"}, ":1\r\n", XGroup},
+		{"destroy twice", []any{"XGROUP", "DESTROY", "s", "g"}, ":0\r\n", XGroup},
+	})
+}
+
+func TestStreamGroupLag(t *testing.T) {
+	storedData := make(map[string]model.StoredData)
+	mu := &sync.RWMutex{}
+	for _, id := range []string{"1-0", "2-0", "3-0", "4-0"} {
+		XAdd([]any{"XADD", "s", id, "f", "v"}, storedData, mu)
+	}
+
+	lag := func(expected string) {
+		t.Helper()
+		reply := XInfo([]any{"XINFO", "GROUPS", "s"}, storedData, mu)
+		if want := "$3\r\nlag\r\n" + expected; len(reply) < len(want) || reply[len(reply)-len(want):] != want {
+			t.Errorf("expected lag %q, got %q", expected, reply)
+		}
+	}
+
+	XGroup([]any{"XGROUP", "CREATE", "s", "g", "0"}, storedData, mu)
+	lag(":4\r\n")
+	xReadGroup([]any{"XREADGROUP", "GROUP", "g", "c", "COUNT", 1, "STREAMS", "s", ">"}, storedData, mu)
+	lag(":3\r\n")
+
+	// A deletion ahead of the group makes the lag unknown until it catches up.
+	XDel([]any{"XDEL", "s", "3-0"}, storedData, mu)
+	lag("$-1\r\n")
+	xReadGroup([]any{"XREADGROUP", "GROUP", "g", "c", "STREAMS", "s", ">"}, storedData, mu)
+	lag(":0\r\n")
+
+	XAdd([]any{"XADD", "s", "5-0", "f", "v"}, storedData, mu)
+	lag(":1\r\n")
+}
+
+func TestXReadGroupBlocking(t *testing.T) {
+	storedData := make(map[string]model.StoredData)
+	mu := &sync.RWMutex{}
+	XGroup([]any{"XGROUP", "CREATE", "s", "g", "$", "MKSTREAM"}, storedData, mu)
+
+	_, request := XReadGroup([]any{"XREADGROUP", "GROUP", "g", "c", "BLOCK", 0, "NOACK", "STREAMS", "s", ">"}, storedData, mu)
+	if request == nil {
+		t.Fatal("expected XREADGROUP BLOCK to block on an empty stream")
+	}
+	if _, served := request.Retry(storedData, mu); served {
+		t.Fatal("expected no reply before an entry is added")
+	}
+
+	XAdd([]any{"XADD", "s", "1-0", "f", "v"}, storedData, mu)
+	reply, served := request.Retry(storedData, mu)
+	if !served || reply != "*1\r\n*2\r\n$1\r\ns\r\n*1\r\n*2\r\n$3\r\n1-0\r\n*2\r\n$1\r\nf\r\n$1\r\nv\r\n" {
+		t.Errorf("expected the new entry, got %q (served=%v)", reply, served)
+	}
+	if pending := XPending([]any{"XPENDING", "s", "g"}, storedData, mu); pending != "*4\r\n:0\r\n$-1\r\n$-1\r\n$-1\r\n" {
+		t.Errorf("expected NOACK to skip the PEL, got %q", pending)
+	}
+
+	// History reads never block.
+	if _, request := XReadGroup([]any{"XREADGROUP", "GROUP", "g", "c", "BLOCK", 0, "STREAMS", "s", "0"}, storedData, mu); request != nil {
+		t.Error("expected a history read to reply immediately")
+	}
+
+	_, request = XReadGroup([]any{"XREADGROUP", "GROUP", "g", "c", "BLOCK", 0, "STREAMS", "s", ">"}, storedData, mu)
+	XGroup([]any{"XGROUP", "DESTROY", "s", "g"}, storedData, mu)
+	reply, served = request.Retry(storedData, mu)
+	if !served || reply != "-NOGROUP No such key 's' or consumer group 'g' in XREADGROUP with GROUP option\r\n" {
+		t.Errorf("expected a NOGROUP error after DESTROY, got %q (served=%v)", reply, served)
+	}
+}
+
+func TestStreamGroupsSurviveSnapshot(t *testing.T) {
+	storedData := make(map[string]model.StoredData)
+	mu := &sync.RWMutex{}
+	XAdd([]any{"XADD", "s", "1-0", "f", "v"}, storedData, mu)
+	XAdd([]any{"XADD", "s", "2-0", "f", "v"}, storedData, mu)
+	XGroup([]any{"XGROUP", "CREATE", "s", "g", "0"}, storedData, mu)
+	XGroup([]any{"XGROUP", "CREATE", "s", "other", "$"}, storedData, mu)
+	xReadGroup([]any{"XREADGROUP", "GROUP", "g", "c", "COUNT", 1, "STREAMS", "s", ">"}, storedData, mu)
+
+	data, err := json.Marshal(storedData)
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	loaded := make(map[string]model.StoredData)
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+
+	want := XInfo([]any{"XINFO", "STREAM", "s", "FULL"}, storedData, mu)
+	if got := XInfo([]any{"XINFO", "STREAM", "s", "FULL"}, loaded, mu); got != want {
+		t.Errorf("expected %q after reload, got %q", want, got)
+	}
+	if reply := XAck([]any{"XACK", "s", "g", "1-0"}, loaded, mu); reply != ":1\r\n" {
+		t.Errorf("expected the pending entry to survive, got %q", reply)
+	}
+}
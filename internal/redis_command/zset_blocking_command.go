@@ -0,0 +1,155 @@
+package redis_command
+
+import (
+	"redis-go-clone/internal/model"
+	"redis-go-clone/pkg/resp"
+	"strings"
+	"sync"
+)
+
+func BZPopMin(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) (string, *BlockingRequest) {
+	return bzsetPop("BZPOPMIN", cmdArray, storedData, mu, false)
+}
+
+func BZPopMax(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) (string, *BlockingRequest) {
+	return bzsetPop("BZPOPMAX", cmdArray, storedData, mu, true)
+}
+
+func bzsetPop(name string, cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex, max bool) (string, *BlockingRequest) {
+	if len(cmdArray) < 3 {
+		return "-ERR wrong number of arguments for " + name + "\r\n", nil
+	}
+
+	keys, ok := keyArgs(cmdArray[1 : len(cmdArray)-1])
+	if !ok {
+		return "-ERR invalid argument for " + name + "\r\n", nil
+	}
+	timeout, errReply := parseBlockingTimeout(cmdArray[len(cmdArray)-1])
+	if errReply != "" {
+		return errReply, nil
+	}
+
+	// The reply is the key, the member and its score.
+	retry := func(storedData map[string]model.StoredData, mu *sync.RWMutex) (string, bool) {
+		return popFirstNonEmptyZSet(storedData, mu, keys, max, 1, func(key string, popped []model.ZMember) any {
+			return []any{key, popped[0].Member, formatFloat(popped[0].Score)}
+		})
+	}
+
+	if reply, served := retry(storedData, mu); served {
+		return reply, nil
+	}
+	return "", &BlockingRequest{Keys: keys, Timeout: timeout, Retry: retry, TimeoutReply: "*-1\r\n"}
+}
+
+func ZMPop(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	keys, max, count, errReply := parseZMPop("ZMPOP", cmdArray[1:])
+	if errReply != "" {
+		return errReply
+	}
+
+	reply, served := popFirstNonEmptyZSet(storedData, mu, keys, max, count, zmpopReply)
+	if !served {
+		return "*-1\r\n"
+	}
+	return reply
+}
+
+func BZMPop(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) (string, *BlockingRequest) {
+	if len(cmdArray) < 2 {
+		return "-ERR wrong number of arguments for BZMPOP\r\n", nil
+	}
+
+	timeout, errReply := parseBlockingTimeout(cmdArray[1])
+	if errReply != "" {
+		return errReply, nil
+	}
+	keys, max, count, errReply := parseZMPop("BZMPOP", cmdArray[2:])
+	if errReply != "" {
+		return errReply, nil
+	}
+
+	retry := func(storedData map[string]model.StoredData, mu *sync.RWMutex) (string, bool) {
+		return popFirstNonEmptyZSet(storedData, mu, keys, max, count, zmpopReply)
+	}
+
+	if reply, served := retry(storedData, mu); served {
+		return reply, nil
+	}
+	return "", &BlockingRequest{Keys: keys, Timeout: timeout, Retry: retry, TimeoutReply: "*-1\r\n"}
+}
+
+// parseZMPop parses "numkeys key [key ...] MIN|MAX [COUNT count]".
+func parseZMPop(name string, args []any) ([]string, bool, int, string) {
+	if len(args) < 3 {
+		return nil, false, 0, "-ERR wrong number of arguments for " + name + "\r\n"
+	}
+
+	numKeys, ok := argToInt64(args[0])
+	if !ok || numKeys <= 0 {
+		return nil, false, 0, "-ERR numkeys should be greater than 0\r\n"
+	}
+	if int64(len(args)-1) <= numKeys {
+		return nil, false, 0, "-ERR syntax error\r\n"
+	}
+	keys, ok := keyArgs(args[1 : 1+numKeys])
+	if !ok {
+		return nil, false, 0, "-ERR invalid argument for " + name + "\r\n"
+	}
+
+	rest := args[1+numKeys:]
+	where, _ := rest[0].(string)
+	var max bool
+	switch strings.ToUpper(where) {
+	case "MIN":
+		max = false
+	case "MAX":
+		max = true
+	default:
+		return nil, false, 0, "-ERR syntax error\r\n"
+	}
+
+	count := 1
+	if len(rest) > 1 {
+		opt, _ := rest[1].(string)
+		if len(rest) != 3 || strings.ToUpper(opt) != "COUNT" {
+			return nil, false, 0, "-ERR syntax error\r\n"
+		}
+		n, ok := argToInt64(rest[2])
+		if !ok || n <= 0 {
+			return nil, false, 0, "-ERR count should be greater than 0\r\n"
+		}
+		count = int(n)
+	}
+	return keys, max, count, ""
+}
+
+// zmpopReply builds the [key, [[member, score], ...]] reply of ZMPOP.
+func zmpopReply(key string, popped []model.ZMember) any {
+	elements := make([]any, 0, len(popped))
+	for _, m := range popped {
+		elements = append(elements, []any{m.Member, formatFloat(m.Score)})
+	}
+	return []any{key, elements}
+}
+
+// popFirstNonEmptyZSet pops from the first of keys holding a non-empty
+// sorted set. It reports false when every key is missing.
+func popFirstNonEmptyZSet(storedData map[string]model.StoredData, mu *sync.RWMutex, keys []string, max bool, count int, reply func(string, []model.ZMember) any) (string, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, key := range keys {
+		zset, errReply := lookupZSet(storedData, key)
+		if errReply != "" {
+			return errReply, true
+		}
+		if zset == nil || zset.Len() == 0 {
+			continue
+		}
+
+		popped := zsetPop(storedData, key, zset, max, count)
+		return resp.SerializeBulk(reply(key, popped)), true
+	}
+	return "", false
+}
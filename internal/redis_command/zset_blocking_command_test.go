@@ -0,0 +1,130 @@
+package redis_command
+
+import (
+	"redis-go-clone/internal/model"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBZPopMin(t *testing.T) {
+	tests := []struct {
+		name       string
+		cmdArray   []any
+		storedData map[string]model.StoredData
+		expected   string
+		blocks     bool
+	}{
+		{
+			name:     "missing timeout",
+			cmdArray: []any{"BZPOPMIN", "z"},
+			expected: "-ERR wrong number of arguments for BZPOPMIN\r\n",
+		},
+		{
+			name:     "negative timeout",
+			cmdArray: []any{"BZPOPMIN", "z", -1},
+			expected: "-ERR timeout is negative\r\n",
+		},
+		{
+			name:     "invalid timeout",
+			cmdArray: []any{"BZPOPMIN", "z", "soon"},
+			expected: "-ERR timeout is not a float or out of range\r\n",
+		},
+		{
+			name:     "pops from first non-empty key",
+			cmdArray: []any{"BZPOPMIN", "empty", "z", 0},
+			storedData: map[string]model.StoredData{
+				"z": {Value: newTestZSet(1, "a", 2, "b")},
+			},
+			expected: "*3\r\n$1\r\nz\r\n$1\r\na\r\n$1\r\n1\r\n",
+		},
+		{
+			name:     "blocks when every key is empty",
+			cmdArray: []any{"BZPOPMIN", "empty", "0.5"},
+			blocks:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.storedData == nil {
+				tt.storedData = make(map[string]model.StoredData)
+			}
+			mu := &sync.RWMutex{}
+			reply, request := BZPopMin(tt.cmdArray, tt.storedData, mu)
+			if tt.blocks {
+				if request == nil {
+					t.Fatalf("expected a blocking request, got reply %q", reply)
+				}
+				if request.Timeout != 500*time.Millisecond {
+					t.Errorf("expected 500ms timeout, got %v", request.Timeout)
+				}
+				return
+			}
+			if request != nil {
+				t.Fatalf("expected an immediate reply, got a blocking request")
+			}
+			if reply != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, reply)
+			}
+		})
+	}
+}
+
+func TestBZPopMaxRetry(t *testing.T) {
+	storedData := make(map[string]model.StoredData)
+	mu := &sync.RWMutex{}
+
+	var ready []string
+	SetKeyReadyHook(func(key string) { ready = append(ready, key) })
+	defer SetKeyReadyHook(nil)
+
+	_, request := BZPopMax([]any{"BZPOPMAX", "z", 0}, storedData, mu)
+	if request == nil {
+		t.Fatal("expected a blocking request")
+	}
+	if _, served := request.Retry(storedData, mu); served {
+		t.Fatal("expected retry on an empty key to fail")
+	}
+
+	ZAdd([]any{"ZADD", "z", 1, "a", 2, "b"}, storedData, mu)
+	if len(ready) != 1 || ready[0] != "z" {
+		t.Fatalf("expected ZADD to signal z as ready, got %v", ready)
+	}
+
+	reply, served := request.Retry(storedData, mu)
+	if !served || reply != "*3\r\n$1\r\nz\r\n$1\r\nb\r\n$1\r\n2\r\n" {
+		t.Errorf("expected to pop b, got %q (served=%v)", reply, served)
+	}
+}
+
+func TestZMPop(t *testing.T) {
+	storedData := map[string]model.StoredData{
+		"z": {Value: newTestZSet(1, "a", 2, "b", 3, "c")},
+	}
+	mu := &sync.RWMutex{}
+
+	tests := []struct {
+		cmdArray []any
+		expected string
+	}{
+		{[]any{"ZMPOP", 1, "z", "LEFT"}, "-ERR syntax error\r\n"},
+		{[]any{"ZMPOP", 0, "z", "MIN"}, "-ERR numkeys should be greater than 0\r\n"},
+		{[]any{"ZMPOP", 2, "missing", "z", "MAX", "COUNT", 2}, "*2\r\n$1\r\nz\r\n*2\r\n*2\r\n$1\r\nc\r\n$1\r\n3\r\n*2\r\n$1\r\nb\r\n$1\r\n2\r\n"},
+		{[]any{"ZMPOP", 1, "missing", "MIN"}, "*-1\r\n"},
+	}
+
+	for _, tt := range tests {
+		if result := ZMPop(tt.cmdArray, storedData, mu); result != tt.expected {
+			t.Errorf("expected %q, got %q", tt.expected, result)
+		}
+	}
+
+	_, request := BZMPop([]any{"BZMPOP", 0, 1, "z", "MIN", "COUNT", 5}, storedData, mu)
+	if request != nil {
+		t.Fatal("expected BZMPOP to be served immediately")
+	}
+	if _, found := storedData["z"]; found {
+		t.Error("expected the sorted set to be emptied")
+	}
+}
@@ -0,0 +1,1134 @@
+package redis_command
+
+import (
+	"math"
+	"redis-go-clone/internal/model"
+	"redis-go-clone/internal/notify"
+	"redis-go-clone/pkg/glob"
+	"redis-go-clone/pkg/resp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+func ZAdd(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) < 4 {
+		return "-ERR wrong number of arguments for ZADD\r\n"
+	}
+
+	key, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR invalid argument for ZADD\r\n"
+	}
+
+	var nx, xx, gt, lt, ch, incr bool
+	idx := 2
+flags:
+	for ; idx < len(cmdArray); idx++ {
+		opt, ok := cmdArray[idx].(string)
+		if !ok {
+			break
+		}
+		switch strings.ToUpper(opt) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "GT":
+			gt = true
+		case "LT":
+			lt = true
+		case "CH":
+			ch = true
+		case "INCR":
+			incr = true
+		default:
+			break flags
+		}
+	}
+
+	pairs := cmdArray[idx:]
+	if len(pairs) == 0 || len(pairs)%2 != 0 {
+		return "-ERR syntax error\r\n"
+	}
+	if nx && xx {
+		return "-ERR XX and NX options at the same time are not compatible\r\n"
+	}
+	if (gt && nx) || (lt && nx) || (gt && lt) {
+		return "-ERR GT, LT, and/or NX options at the same time are not compatible\r\n"
+	}
+	if incr && len(pairs) > 2 {
+		return "-ERR INCR option supports a single increment-element pair\r\n"
+	}
+
+	elements := make([]model.ZMember, 0, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		score, ok := argToFloat(pairs[i])
+		if !ok {
+			return "-ERR value is not a valid float\r\n"
+		}
+		member, ok := argToString(pairs[i+1])
+		if !ok {
+			return "-ERR invalid argument for ZADD\r\n"
+		}
+		elements = append(elements, model.ZMember{Member: member, Score: score})
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	zset, errReply := lookupZSet(storedData, key)
+	if errReply != "" {
+		return errReply
+	}
+	created := zset == nil
+	if created {
+		zset = model.NewSortedSet()
+	}
+
+	added, changed := 0, 0
+	var incrResult any
+	for _, element := range elements {
+		current, exists := zset.Score(element.Member)
+		if exists {
+			if nx {
+				continue
+			}
+			newScore := element.Score
+			if incr {
+				newScore += current
+				if math.IsNaN(newScore) {
+					return "-ERR resulting score is not a number (NaN)\r\n"
+				}
+			}
+			if (gt && newScore <= current) || (lt && newScore >= current) {
+				continue
+			}
+			incrResult = formatFloat(newScore)
+			if newScore != current {
+				zset.Add(element.Member, newScore)
+				changed++
+			}
+			continue
+		}
+
+		if xx {
+			continue
+		}
+		zset.Add(element.Member, element.Score)
+		incrResult = formatFloat(element.Score)
+		added++
+	}
+
+	if created && zset.Len() > 0 {
+		storedData[key] = model.StoredData{Value: zset}
+		keyspaceEvent(notify.New, "new", key)
+		signalKeyAsReady(key)
+	}
+	if added+changed > 0 {
+		event := "zadd"
+		if incr {
+			event = "zincr"
+		}
+		keyspaceEvent(notify.Zset, event, key)
+	}
+
+	if incr {
+		return resp.SerializeRESP(incrResult, true)
+	}
+	if ch {
+		return ":" + strconv.Itoa(added+changed) + "\r\n"
+	}
+	return ":" + strconv.Itoa(added) + "\r\n"
+}
+
+func ZIncrBy(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) != 4 {
+		return "-ERR wrong number of arguments for ZINCRBY\r\n"
+	}
+	return ZAdd([]any{"ZADD", cmdArray[1], "INCR", cmdArray[2], cmdArray[3]}, storedData, mu)
+}
+
+func ZRem(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) < 3 {
+		return "-ERR missing argument for ZREM\r\n"
+	}
+
+	key, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR invalid argument for ZREM\r\n"
+	}
+	members, ok := stringArgs(cmdArray[2:])
+	if !ok {
+		return "-ERR invalid argument for ZREM\r\n"
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	zset, errReply := lookupZSet(storedData, key)
+	if errReply != "" {
+		return errReply
+	}
+	if zset == nil {
+		return ":0\r\n"
+	}
+
+	removed := 0
+	for _, member := range members {
+		if zset.Remove(member) {
+			removed++
+		}
+	}
+	if removed > 0 {
+		keyspaceEvent(notify.Zset, "zrem", key)
+	}
+	deleteIfEmptyZSet(storedData, key, zset)
+	return ":" + strconv.Itoa(removed) + "\r\n"
+}
+
+func ZScore(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) != 3 {
+		return "-ERR wrong number of arguments for ZSCORE\r\n"
+	}
+
+	key, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR invalid argument for ZSCORE\r\n"
+	}
+	member, ok := argToString(cmdArray[2])
+	if !ok {
+		return "-ERR invalid argument for ZSCORE\r\n"
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+
+	zset, errReply := lookupZSet(storedData, key)
+	if errReply != "" {
+		return errReply
+	}
+	if zset == nil {
+		return "$-1\r\n"
+	}
+
+	score, found := zset.Score(member)
+	if !found {
+		return "$-1\r\n"
+	}
+	return resp.SerializeRESP(formatFloat(score), true)
+}
+
+func ZMScore(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) < 3 {
+		return "-ERR missing argument for ZMSCORE\r\n"
+	}
+
+	key, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR invalid argument for ZMSCORE\r\n"
+	}
+	members, ok := stringArgs(cmdArray[2:])
+	if !ok {
+		return "-ERR invalid argument for ZMSCORE\r\n"
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+
+	zset, errReply := lookupZSet(storedData, key)
+	if errReply != "" {
+		return errReply
+	}
+
+	result := make([]any, 0, len(members))
+	for _, member := range members {
+		if zset == nil {
+			result = append(result, nil)
+			continue
+		}
+		if score, found := zset.Score(member); found {
+			result = append(result, formatFloat(score))
+		} else {
+			result = append(result, nil)
+		}
+	}
+	return resp.SerializeBulk(result)
+}
+
+func ZCard(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) != 2 {
+		return "-ERR wrong number of arguments for ZCARD\r\n"
+	}
+
+	key, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR invalid argument for ZCARD\r\n"
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+
+	zset, errReply := lookupZSet(storedData, key)
+	if errReply != "" {
+		return errReply
+	}
+	if zset == nil {
+		return ":0\r\n"
+	}
+	return ":" + strconv.Itoa(zset.Len()) + "\r\n"
+}
+
+func ZRank(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	return zsetRank("ZRANK", cmdArray, storedData, mu, false)
+}
+
+func ZRevRank(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	return zsetRank("ZREVRANK", cmdArray, storedData, mu, true)
+}
+
+func zsetRank(name string, cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex, reverse bool) string {
+	if len(cmdArray) != 3 && len(cmdArray) != 4 {
+		return "-ERR wrong number of arguments for " + name + "\r\n"
+	}
+
+	key, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR invalid argument for " + name + "\r\n"
+	}
+	member, ok := argToString(cmdArray[2])
+	if !ok {
+		return "-ERR invalid argument for " + name + "\r\n"
+	}
+
+	withScore := false
+	if len(cmdArray) == 4 {
+		opt, _ := cmdArray[3].(string)
+		if strings.ToUpper(opt) != "WITHSCORE" {
+			return "-ERR syntax error\r\n"
+		}
+		withScore = true
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+
+	zset, errReply := lookupZSet(storedData, key)
+	if errReply != "" {
+		return errReply
+	}
+
+	var rank int
+	found := false
+	if zset != nil {
+		rank, found = zset.Rank(member, reverse)
+	}
+	if !found {
+		if withScore {
+			return "*-1\r\n"
+		}
+		return "$-1\r\n"
+	}
+
+	if withScore {
+		score, _ := zset.Score(member)
+		return resp.SerializeBulk([]any{rank, formatFloat(score)})
+	}
+	return ":" + strconv.Itoa(rank) + "\r\n"
+}
+
+// zrangeSpec holds the parsed arguments shared by ZRANGE and ZRANGESTORE.
+type zrangeSpec struct {
+	start, stop any
+	byScore     bool
+	byLex       bool
+	rev         bool
+	withScores  bool
+	offset      int
+	limit       int
+	hasLimit    bool
+}
+
+func parseZRangeSpec(args []any, allowWithScores bool) (zrangeSpec, string) {
+	spec := zrangeSpec{start: args[0], stop: args[1], limit: -1}
+
+	for i := 2; i < len(args); i++ {
+		opt, ok := args[i].(string)
+		if !ok {
+			return spec, "-ERR syntax error\r\n"
+		}
+		switch strings.ToUpper(opt) {
+		case "BYSCORE":
+			spec.byScore = true
+		case "BYLEX":
+			spec.byLex = true
+		case "REV":
+			spec.rev = true
+		case "WITHSCORES":
+			if !allowWithScores {
+				return spec, "-ERR syntax error\r\n"
+			}
+			spec.withScores = true
+		case "LIMIT":
+			if i+2 >= len(args) {
+				return spec, "-ERR syntax error\r\n"
+			}
+			offset, ok1 := argToInt64(args[i+1])
+			limit, ok2 := argToInt64(args[i+2])
+			if !ok1 || !ok2 {
+				return spec, "-ERR value is not an integer or out of range\r\n"
+			}
+			spec.offset, spec.limit, spec.hasLimit = int(offset), int(limit), true
+			i += 2
+		default:
+			return spec, "-ERR syntax error\r\n"
+		}
+	}
+
+	if spec.byScore && spec.byLex {
+		return spec, "-ERR syntax error\r\n"
+	}
+	if spec.hasLimit && !spec.byScore && !spec.byLex {
+		return spec, "-ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX\r\n"
+	}
+	if spec.withScores && spec.byLex {
+		return spec, "-ERR syntax error, WITHSCORES not supported in combination with BYLEX\r\n"
+	}
+	return spec, ""
+}
+
+// zrangeMembers evaluates a parsed ZRANGE request against zset.
+func zrangeMembers(zset *model.SortedSet, spec zrangeSpec) ([]model.ZMember, string) {
+	// With REV the bounds of BYSCORE and BYLEX are given as max then min.
+	minArg, maxArg := spec.start, spec.stop
+	if spec.rev {
+		minArg, maxArg = spec.stop, spec.start
+	}
+
+	switch {
+	case spec.byScore:
+		r, ok := parseScoreRange(minArg, maxArg)
+		if !ok {
+			return nil, "-ERR min or max is not a float\r\n"
+		}
+		if zset == nil || spec.offset < 0 {
+			return nil, ""
+		}
+		return zset.RangeByScore(r, spec.rev, spec.offset, spec.limit), ""
+	case spec.byLex:
+		r, ok := parseLexRange(minArg, maxArg)
+		if !ok {
+			return nil, "-ERR min or max not valid string range item\r\n"
+		}
+		if zset == nil || spec.offset < 0 {
+			return nil, ""
+		}
+		return zset.RangeByLex(r, spec.rev, spec.offset, spec.limit), ""
+	default:
+		start, ok1 := argToInt64(spec.start)
+		stop, ok2 := argToInt64(spec.stop)
+		if !ok1 || !ok2 {
+			return nil, "-ERR value is not an integer or out of range\r\n"
+		}
+		if zset == nil {
+			return nil, ""
+		}
+		from, to, ok := normalizeRankRange(int(start), int(stop), zset.Len())
+		if !ok {
+			return nil, ""
+		}
+		return zset.RangeByRank(from, to, spec.rev), ""
+	}
+}
+
+func ZRange(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) < 4 {
+		return "-ERR wrong number of arguments for ZRANGE\r\n"
+	}
+
+	key, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR invalid argument for ZRANGE\r\n"
+	}
+
+	spec, errReply := parseZRangeSpec(cmdArray[2:], true)
+	if errReply != "" {
+		return errReply
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+
+	zset, errReply := lookupZSet(storedData, key)
+	if errReply != "" {
+		return errReply
+	}
+
+	members, errReply := zrangeMembers(zset, spec)
+	if errReply != "" {
+		return errReply
+	}
+	return serializeZMembers(members, spec.withScores)
+}
+
+func ZRangeStore(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) < 5 {
+		return "-ERR wrong number of arguments for ZRANGESTORE\r\n"
+	}
+
+	destination, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR invalid argument for ZRANGESTORE\r\n"
+	}
+	source, ok := cmdArray[2].(string)
+	if !ok {
+		return "-ERR invalid argument for ZRANGESTORE\r\n"
+	}
+
+	spec, errReply := parseZRangeSpec(cmdArray[3:], false)
+	if errReply != "" {
+		return errReply
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	zset, errReply := lookupZSet(storedData, source)
+	if errReply != "" {
+		return errReply
+	}
+
+	members, errReply := zrangeMembers(zset, spec)
+	if errReply != "" {
+		return errReply
+	}
+	return storeZMembers(storedData, destination, members, "zrangestore")
+}
+
+func ZCount(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) != 4 {
+		return "-ERR wrong number of arguments for ZCOUNT\r\n"
+	}
+
+	key, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR invalid argument for ZCOUNT\r\n"
+	}
+	r, ok := parseScoreRange(cmdArray[2], cmdArray[3])
+	if !ok {
+		return "-ERR min or max is not a float\r\n"
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+
+	zset, errReply := lookupZSet(storedData, key)
+	if errReply != "" {
+		return errReply
+	}
+	if zset == nil {
+		return ":0\r\n"
+	}
+	return ":" + strconv.Itoa(zset.CountInScoreRange(r)) + "\r\n"
+}
+
+func ZLexCount(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) != 4 {
+		return "-ERR wrong number of arguments for ZLEXCOUNT\r\n"
+	}
+
+	key, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR invalid argument for ZLEXCOUNT\r\n"
+	}
+	r, ok := parseLexRange(cmdArray[2], cmdArray[3])
+	if !ok {
+		return "-ERR min or max not valid string range item\r\n"
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+
+	zset, errReply := lookupZSet(storedData, key)
+	if errReply != "" {
+		return errReply
+	}
+	if zset == nil {
+		return ":0\r\n"
+	}
+	return ":" + strconv.Itoa(zset.CountInLexRange(r)) + "\r\n"
+}
+
+func ZPopMin(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	return zsetPopCommand("ZPOPMIN", cmdArray, storedData, mu, false)
+}
+
+func ZPopMax(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	return zsetPopCommand("ZPOPMAX", cmdArray, storedData, mu, true)
+}
+
+func zsetPopCommand(name string, cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex, max bool) string {
+	if len(cmdArray) != 2 && len(cmdArray) != 3 {
+		return "-ERR wrong number of arguments for " + name + "\r\n"
+	}
+
+	key, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR invalid argument for " + name + "\r\n"
+	}
+
+	count := int64(1)
+	if len(cmdArray) == 3 {
+		count, ok = argToInt64(cmdArray[2])
+		if !ok || count < 0 {
+			return "-ERR value is out of range, must be positive\r\n"
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	zset, errReply := lookupZSet(storedData, key)
+	if errReply != "" {
+		return errReply
+	}
+	if zset == nil {
+		return "*0\r\n"
+	}
+
+	return serializeZMembers(zsetPop(storedData, key, zset, max, int(count)), true)
+}
+
+// zsetPop removes up to count members from the low or high end of zset and
+// deletes the key once it is empty.
+func zsetPop(storedData map[string]model.StoredData, key string, zset *model.SortedSet, max bool, count int) []model.ZMember {
+	if count == 0 {
+		return nil
+	}
+
+	popped := zset.RangeByRank(0, min(count, zset.Len())-1, max)
+	for _, m := range popped {
+		zset.Remove(m.Member)
+	}
+	if max {
+		keyspaceEvent(notify.Zset, "zpopmax", key)
+	} else {
+		keyspaceEvent(notify.Zset, "zpopmin", key)
+	}
+	deleteIfEmptyZSet(storedData, key, zset)
+	return popped
+}
+
+func ZRemRangeByRank(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	return zsetRemRange("ZREMRANGEBYRANK", cmdArray, storedData, mu, func(zset *model.SortedSet) ([]model.ZMember, string) {
+		start, ok1 := argToInt64(cmdArray[2])
+		stop, ok2 := argToInt64(cmdArray[3])
+		if !ok1 || !ok2 {
+			return nil, "-ERR value is not an integer or out of range\r\n"
+		}
+		from, to, ok := normalizeRankRange(int(start), int(stop), zset.Len())
+		if !ok {
+			return nil, ""
+		}
+		return zset.RangeByRank(from, to, false), ""
+	})
+}
+
+func ZRemRangeByScore(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	return zsetRemRange("ZREMRANGEBYSCORE", cmdArray, storedData, mu, func(zset *model.SortedSet) ([]model.ZMember, string) {
+		r, ok := parseScoreRange(cmdArray[2], cmdArray[3])
+		if !ok {
+			return nil, "-ERR min or max is not a float\r\n"
+		}
+		return zset.RangeByScore(r, false, 0, -1), ""
+	})
+}
+
+func ZRemRangeByLex(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	return zsetRemRange("ZREMRANGEBYLEX", cmdArray, storedData, mu, func(zset *model.SortedSet) ([]model.ZMember, string) {
+		r, ok := parseLexRange(cmdArray[2], cmdArray[3])
+		if !ok {
+			return nil, "-ERR min or max not valid string range item\r\n"
+		}
+		return zset.RangeByLex(r, false, 0, -1), ""
+	})
+}
+
+func zsetRemRange(name string, cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex, selectMembers func(*model.SortedSet) ([]model.ZMember, string)) string {
+	if len(cmdArray) != 4 {
+		return "-ERR wrong number of arguments for " + name + "\r\n"
+	}
+
+	key, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR invalid argument for " + name + "\r\n"
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	zset, errReply := lookupZSet(storedData, key)
+	if errReply != "" {
+		return errReply
+	}
+
+	// Validate the range even when the key is missing.
+	members, errReply := selectMembers(orEmptyZSet(zset))
+	if errReply != "" {
+		return errReply
+	}
+	if zset == nil {
+		return ":0\r\n"
+	}
+
+	for _, m := range members {
+		zset.Remove(m.Member)
+	}
+	if len(members) > 0 {
+		keyspaceEvent(notify.Zset, strings.ToLower(name), key)
+	}
+	deleteIfEmptyZSet(storedData, key, zset)
+	return ":" + strconv.Itoa(len(members)) + "\r\n"
+}
+
+func ZUnionStore(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	return zsetSetOperation("ZUNIONSTORE", cmdArray, storedData, mu, zsetUnion, true)
+}
+
+func ZInterStore(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	return zsetSetOperation("ZINTERSTORE", cmdArray, storedData, mu, zsetInter, true)
+}
+
+func ZDiffStore(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	return zsetSetOperation("ZDIFFSTORE", cmdArray, storedData, mu, zsetDiff, true)
+}
+
+func ZUnion(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	return zsetSetOperation("ZUNION", cmdArray, storedData, mu, zsetUnion, false)
+}
+
+func ZInter(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	return zsetSetOperation("ZINTER", cmdArray, storedData, mu, zsetInter, false)
+}
+
+func ZDiff(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	return zsetSetOperation("ZDIFF", cmdArray, storedData, mu, zsetDiff, false)
+}
+
+// zsetInput is one source of a ZUNIONSTORE-style operation. Plain sets are
+// accepted as sorted sets where every member has score 1.
+type zsetInput struct {
+	scores map[string]float64
+	weight float64
+}
+
+type zsetAggregate func(a, b float64) float64
+
+type zsetOperationFunc func(inputs []zsetInput, aggregate zsetAggregate) map[string]float64
+
+func zsetSetOperation(name string, cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex, op zsetOperationFunc, store bool) string {
+	args := cmdArray[1:]
+	destination := ""
+	if store {
+		if len(args) < 1 {
+			return "-ERR wrong number of arguments for " + name + "\r\n"
+		}
+		var ok bool
+		destination, ok = args[0].(string)
+		if !ok {
+			return "-ERR invalid argument for " + name + "\r\n"
+		}
+		args = args[1:]
+	}
+
+	if len(args) < 2 {
+		return "-ERR wrong number of arguments for " + name + "\r\n"
+	}
+	numKeys, ok := argToInt64(args[0])
+	if !ok || numKeys <= 0 {
+		return "-ERR at least 1 input key is needed for '" + strings.ToLower(name) + "' command\r\n"
+	}
+	if int64(len(args)-1) < numKeys {
+		return "-ERR syntax error\r\n"
+	}
+	keys, ok := keyArgs(args[1 : 1+numKeys])
+	if !ok {
+		return "-ERR invalid argument for " + name + "\r\n"
+	}
+
+	isDiff := strings.HasPrefix(name, "ZDIFF")
+	weights := make([]float64, numKeys)
+	for i := range weights {
+		weights[i] = 1
+	}
+	var aggregate zsetAggregate = zsetSum
+	withScores := false
+
+	rest := args[1+numKeys:]
+	for i := 0; i < len(rest); i++ {
+		opt, ok := rest[i].(string)
+		if !ok {
+			return "-ERR syntax error\r\n"
+		}
+		switch upper := strings.ToUpper(opt); {
+		case upper == "WEIGHTS" && !isDiff:
+			if i+int(numKeys) >= len(rest) {
+				return "-ERR syntax error\r\n"
+			}
+			for j := range weights {
+				w, ok := argToFloat(rest[i+1+j])
+				if !ok {
+					return "-ERR weight value is not a float\r\n"
+				}
+				weights[j] = w
+			}
+			i += int(numKeys)
+		case upper == "AGGREGATE" && !isDiff:
+			if i+1 >= len(rest) {
+				return "-ERR syntax error\r\n"
+			}
+			mode, _ := rest[i+1].(string)
+			switch strings.ToUpper(mode) {
+			case "SUM":
+				aggregate = zsetSum
+			case "MIN":
+				aggregate = math.Min
+			case "MAX":
+				aggregate = math.Max
+			default:
+				return "-ERR syntax error\r\n"
+			}
+			i++
+		case upper == "WITHSCORES" && !store:
+			withScores = true
+		default:
+			return "-ERR syntax error\r\n"
+		}
+	}
+
+	if store {
+		mu.Lock()
+		defer mu.Unlock()
+	} else {
+		mu.RLock()
+		defer mu.RUnlock()
+	}
+
+	inputs := make([]zsetInput, 0, len(keys))
+	for i, key := range keys {
+		scores, errReply := lookupZSetScores(storedData, key)
+		if errReply != "" {
+			return errReply
+		}
+		inputs = append(inputs, zsetInput{scores: scores, weight: weights[i]})
+	}
+
+	result := model.NewSortedSet()
+	for member, score := range op(inputs, aggregate) {
+		result.Add(member, score)
+	}
+
+	if store {
+		return storeZMembers(storedData, destination, result.Members(), strings.ToLower(name))
+	}
+	return serializeZMembers(result.Members(), withScores)
+}
+
+// zsetSum adds two scores, treating inf + -inf as 0 like Redis does.
+func zsetSum(a, b float64) float64 {
+	sum := a + b
+	if math.IsNaN(sum) {
+		return 0
+	}
+	return sum
+}
+
+func weightedScore(score, weight float64) float64 {
+	result := score * weight
+	if math.IsNaN(result) {
+		return 0
+	}
+	return result
+}
+
+func zsetUnion(inputs []zsetInput, aggregate zsetAggregate) map[string]float64 {
+	result := make(map[string]float64)
+	for _, input := range inputs {
+		for member, score := range input.scores {
+			weighted := weightedScore(score, input.weight)
+			if current, found := result[member]; found {
+				result[member] = aggregate(current, weighted)
+			} else {
+				result[member] = weighted
+			}
+		}
+	}
+	return result
+}
+
+func zsetInter(inputs []zsetInput, aggregate zsetAggregate) map[string]float64 {
+	result := make(map[string]float64)
+	for member, score := range inputs[0].scores {
+		total := weightedScore(score, inputs[0].weight)
+		inAll := true
+		for _, input := range inputs[1:] {
+			other, found := input.scores[member]
+			if !found {
+				inAll = false
+				break
+			}
+			total = aggregate(total, weightedScore(other, input.weight))
+		}
+		if inAll {
+			result[member] = total
+		}
+	}
+	return result
+}
+
+func zsetDiff(inputs []zsetInput, _ zsetAggregate) map[string]float64 {
+	result := make(map[string]float64)
+	for member, score := range inputs[0].scores {
+		inOther := false
+		for _, input := range inputs[1:] {
+			if _, found := input.scores[member]; found {
+				inOther = true
+				break
+			}
+		}
+		if !inOther {
+			result[member] = score
+		}
+	}
+	return result
+}
+
+func ZScan(cmdArray []any, storedData map[string]model.StoredData, mu *sync.RWMutex) string {
+	if len(cmdArray) < 3 {
+		return "-ERR wrong number of arguments for ZSCAN\r\n"
+	}
+
+	key, ok := cmdArray[1].(string)
+	if !ok {
+		return "-ERR invalid argument for ZSCAN\r\n"
+	}
+
+	cursor, ok := argToInt64(cmdArray[2])
+	if !ok || cursor < 0 {
+		return "-ERR invalid cursor\r\n"
+	}
+
+	pattern, count, errReply := parseScanOptions(cmdArray[3:])
+	if errReply != "" {
+		return errReply
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+
+	zset, errReply := lookupZSet(storedData, key)
+	if errReply != "" {
+		return errReply
+	}
+
+	// The cursor is a scan position rather than a rank, which ZREM or a
+	// ZINCRBY would shift under it.
+	var members []string
+	if zset != nil {
+		for _, m := range zset.Members() {
+			members = append(members, m.Member)
+		}
+	}
+	scanned, nextCursor := scanMembers(members, cursor, count)
+
+	page := make([]any, 0, len(scanned)*2)
+	for _, member := range scanned {
+		if pattern == "" || glob.Match(pattern, member) {
+			score, _ := zset.Score(member)
+			page = append(page, member, formatFloat(score))
+		}
+	}
+	return resp.SerializeBulk([]any{strconv.FormatInt(nextCursor, 10), page})
+}
+
+// normalizeRankRange turns possibly negative start/stop ranks into an
+// inclusive range inside [0, length), reporting false if it is empty.
+func normalizeRankRange(start, stop, length int) (int, int, bool) {
+	if start < 0 {
+		start += length
+	}
+	if stop < 0 {
+		stop += length
+	}
+	if start < 0 {
+		start = 0
+	}
+	if start > stop || start >= length {
+		return 0, 0, false
+	}
+	if stop >= length {
+		stop = length - 1
+	}
+	return start, stop, true
+}
+
+// parseScoreRange parses min/max arguments such as "(1", "5" or "+inf".
+func parseScoreRange(minArg, maxArg any) (model.ScoreRange, bool) {
+	var r model.ScoreRange
+	var ok bool
+	if r.Min, r.MinEx, ok = parseScoreBound(minArg); !ok {
+		return r, false
+	}
+	if r.Max, r.MaxEx, ok = parseScoreBound(maxArg); !ok {
+		return r, false
+	}
+	return r, true
+}
+
+func parseScoreBound(arg any) (float64, bool, bool) {
+	if s, ok := arg.(string); ok && strings.HasPrefix(s, "(") {
+		value, ok := argToFloat(s[1:])
+		return value, true, ok
+	}
+	value, ok := argToFloat(arg)
+	return value, false, ok
+}
+
+// parseLexRange parses min/max arguments such as "[a", "(b", "-" or "+".
+func parseLexRange(minArg, maxArg any) (model.LexRange, bool) {
+	minStr, ok1 := argToString(minArg)
+	maxStr, ok2 := argToString(maxArg)
+	if !ok1 || !ok2 || minStr == "" || maxStr == "" {
+		return model.LexRange{}, false
+	}
+
+	var r model.LexRange
+	switch minStr[0] {
+	case '-':
+		if len(minStr) != 1 {
+			return r, false
+		}
+		r.MinInf = true
+	case '+':
+		if len(minStr) != 1 {
+			return r, false
+		}
+		// A "+" minimum can never match anything.
+		r.Min, r.MinEx = "", true
+		r.Max, r.MaxEx = "", true
+		return r, true
+	case '[', '(':
+		r.Min, r.MinEx = minStr[1:], minStr[0] == '('
+	default:
+		return r, false
+	}
+
+	switch maxStr[0] {
+	case '+':
+		if len(maxStr) != 1 {
+			return r, false
+		}
+		r.MaxInf = true
+	case '-':
+		if len(maxStr) != 1 {
+			return r, false
+		}
+		// A "-" maximum can never match anything.
+		return model.LexRange{MinEx: true, MaxEx: true}, true
+	case '[', '(':
+		r.Max, r.MaxEx = maxStr[1:], maxStr[0] == '('
+	default:
+		return r, false
+	}
+	return r, true
+}
+
+// lookupZSet returns the sorted set stored at key, or nil if the key does
+// not exist.
+func lookupZSet(storedData map[string]model.StoredData, key string) (*model.SortedSet, string) {
+	value, found := storedData[key]
+	if !found {
+		return nil, ""
+	}
+
+	zset, ok := value.Value.(*model.SortedSet)
+	if !ok {
+		return nil, "-ERR value is not type of zset\r\n"
+	}
+	return zset, ""
+}
+
+// lookupZSetScores returns the member scores of a sorted set or a plain set
+// stored at key.
+func lookupZSetScores(storedData map[string]model.StoredData, key string) (map[string]float64, string) {
+	value, found := storedData[key]
+	if !found {
+		return nil, ""
+	}
+
+	scores := make(map[string]float64)
+	switch v := value.Value.(type) {
+	case *model.SortedSet:
+		for _, m := range v.Members() {
+			scores[m.Member] = m.Score
+		}
+	case *model.Set:
+		for _, member := range v.Members() {
+			scores[member] = 1
+		}
+	default:
+		return nil, "-ERR value is not type of zset\r\n"
+	}
+	return scores, ""
+}
+
+func orEmptyZSet(zset *model.SortedSet) *model.SortedSet {
+	if zset == nil {
+		return model.NewSortedSet()
+	}
+	return zset
+}
+
+func deleteIfEmptyZSet(storedData map[string]model.StoredData, key string, zset *model.SortedSet) {
+	if zset.Len() == 0 {
+		delete(storedData, key)
+		keyspaceEvent(notify.Generic, "del", key)
+	}
+}
+
+// storeZMembers replaces destination with a sorted set holding members and
+// replies with its cardinality. event is the notification sent for the
+// stored key.
+func storeZMembers(storedData map[string]model.StoredData, destination string, members []model.ZMember, event string) string {
+	_, existed := storedData[destination]
+	if len(members) == 0 {
+		delete(storedData, destination)
+		if existed {
+			keyspaceEvent(notify.Generic, "del", destination)
+		}
+		return ":0\r\n"
+	}
+
+	result := model.NewSortedSet()
+	for _, m := range members {
+		result.Add(m.Member, m.Score)
+	}
+	storedData[destination] = model.StoredData{Value: result}
+	if !existed {
+		keyspaceEvent(notify.New, "new", destination)
+	}
+	keyspaceEvent(notify.Zset, event, destination)
+	signalKeyAsReady(destination)
+	return ":" + strconv.Itoa(result.Len()) + "\r\n"
+}
+
+func serializeZMembers(members []model.ZMember, withScores bool) string {
+	result := make([]any, 0, len(members)*2)
+	for _, m := range members {
+		result = append(result, m.Member)
+		if withScores {
+			result = append(result, formatFloat(m.Score))
+		}
+	}
+	return resp.SerializeBulk(result)
+}
@@ -0,0 +1,291 @@
+package redis_command
+
+import (
+	"math/rand/v2"
+	"redis-go-clone/internal/model"
+	"redis-go-clone/internal/notify"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func newTestZSet(pairs ...any) *model.SortedSet {
+	zset := model.NewSortedSet()
+	for i := 0; i < len(pairs); i += 2 {
+		zset.Add(pairs[i+1].(string), float64(pairs[i].(int)))
+	}
+	return zset
+}
+
+func TestZAdd(t *testing.T) {
+	storedData := map[string]model.StoredData{"str": {Value: "value"}}
+
+	runCommands(t, storedData, []commandCase{
+		{"missing argument", []any{"ZADD", "z", 1}, "-ERR wrong number of arguments for ZADD\r\n", ZAdd},
+		{"invalid score", []any{"ZADD", "z", "abc", "a"}, "-ERR value is not a valid float\r\n", ZAdd},
+		{"wrong type", []any{"ZADD", "str", 1, "a"}, "-ERR value is not type of zset\r\n", ZAdd},
+		{"nx and xx", []any{"ZADD", "z", "NX", "XX", 1, "a"}, "-ERR XX and NX options at the same time are not compatible\r\n", ZAdd},
+		{"gt and lt", []any{"ZADD", "z", "GT", "LT", 1, "a"}, "-ERR GT, LT, and/or NX options at the same time are not compatible\r\n", ZAdd},
+		{"incr with pairs", []any{"ZADD", "z", "INCR", 1, "a", 2, "b"}, "-ERR INCR option supports a single increment-element pair\r\n", ZAdd},
+		{"xx on missing key", []any{"ZADD", "z", "XX", 1, "a"}, ":0\r\n", ZAdd},
+		{"add members", []any{"ZADD", "z", 1, "a", "2.5", "b", 3, "c"}, ":3\r\n", ZAdd},
+		{"update without CH", []any{"ZADD", "z", 5, "a", 4, "d"}, ":1\r\n", ZAdd},
+		{"update with CH", []any{"ZADD", "z", "CH", 6, "a", 4, "d"}, ":1\r\n", ZAdd},
+		{"NX keeps existing", []any{"ZADD", "z", "NX", 100, "a"}, ":0\r\n", ZAdd},
+		{"GT only raises", []any{"ZADD", "z", "GT", "CH", 1, "a", 10, "b"}, ":1\r\n", ZAdd},
+		{"LT only lowers", []any{"ZADD", "z", "LT", "CH", 1, "a", 20, "b"}, ":1\r\n", ZAdd},
+		{"INCR", []any{"ZADD", "z", "INCR", "1.5", "a"}, "$3\r\n2.5\r\n", ZAdd},
+		{"INCR aborted by NX", []any{"ZADD", "z", "NX", "INCR", 1, "a"}, "$-1\r\n", ZAdd},
+		{"ZINCRBY", []any{"ZINCRBY", "z", -1, "a"}, "$3\r\n1.5\r\n", ZIncrBy},
+		{"ZSCORE", []any{"ZSCORE", "z", "b"}, "$2\r\n10\r\n", ZScore},
+		{"ZMSCORE", []any{"ZMSCORE", "z", "a", "nope"}, "*2\r\n$3\r\n1.5\r\n$-1\r\n", ZMScore},
+		{"ZCARD", []any{"ZCARD", "z"}, ":4\r\n", ZCard},
+	})
+}
+
+func TestZRemAndPop(t *testing.T) {
+	storedData := map[string]model.StoredData{
+		"z": {Value: newTestZSet(1, "a", 2, "b", 3, "c", 4, "d")},
+	}
+
+	runCommands(t, storedData, []commandCase{
+		{"ZREM", []any{"ZREM", "z", "a", "nope"}, ":1\r\n", ZRem},
+		{"ZPOPMIN", []any{"ZPOPMIN", "z"}, "*2\r\n$1\r\nb\r\n$1\r\n2\r\n", ZPopMin},
+		{"ZPOPMAX with count", []any{"ZPOPMAX", "z", 5}, "*4\r\n$1\r\nd\r\n$1\r\n4\r\n$1\r\nc\r\n$1\r\n3\r\n", ZPopMax},
+		{"ZPOPMIN on missing key", []any{"ZPOPMIN", "z"}, "*0\r\n", ZPopMin},
+		{"ZPOPMIN negative count", []any{"ZPOPMIN", "z", -1}, "-ERR value is out of range, must be positive\r\n", ZPopMin},
+	})
+
+	if _, found := storedData["z"]; found {
+		t.Error("expected empty sorted set to be deleted")
+	}
+}
+
+func TestZSetKeyspaceEvents(t *testing.T) {
+	var events []string
+	notify.SetPublisher(func(channel, message string) int {
+		events = append(events, message)
+		return 0
+	})
+	notify.SetFlags("Kzgn")
+	t.Cleanup(func() {
+		notify.SetPublisher(nil)
+		notify.SetFlags("")
+	})
+
+	storedData := map[string]model.StoredData{}
+	var mu sync.RWMutex
+	tests := []struct {
+		cmdArray []any
+		fn       commandFunc
+		want     []string
+	}{
+		{[]any{"ZADD", "z", 1, "a", 2, "b"}, ZAdd, []string{"new", "zadd"}},
+		{[]any{"ZADD", "z", "NX", 5, "a"}, ZAdd, nil},
+		{[]any{"ZINCRBY", "z", 1, "a"}, ZIncrBy, []string{"zincr"}},
+		{[]any{"ZREM", "z", "missing"}, ZRem, nil},
+		{[]any{"ZREM", "z", "a"}, ZRem, []string{"zrem"}},
+		{[]any{"ZRANGESTORE", "dst", "z", 0, -1}, ZRangeStore, []string{"new", "zrangestore"}},
+		{[]any{"ZPOPMAX", "z"}, ZPopMax, []string{"zpopmax", "del"}},
+		{[]any{"ZREMRANGEBYRANK", "dst", 0, -1}, ZRemRangeByRank, []string{"zremrangebyrank", "del"}},
+	}
+
+	for _, tt := range tests {
+		events = nil
+		tt.fn(tt.cmdArray, storedData, &mu)
+		if !slices.Equal(events, tt.want) {
+			t.Errorf("%v: expected events %v, got %v", tt.cmdArray, tt.want, events)
+		}
+	}
+}
+
+func TestZRankAndRange(t *testing.T) {
+	storedData := map[string]model.StoredData{
+		"z":    {Value: newTestZSet(1, "a", 2, "b", 3, "c", 4, "d", 5, "e")},
+		"lex":  {Value: newTestZSet(0, "a", 0, "b", 0, "c", 0, "d", 0, "e")},
+		"crlf": {Value: newTestZSet(1, "a\r\n:1")},
+	}
+
+	runCommands(t, storedData, []commandCase{
+		{"ZRANK", []any{"ZRANK", "z", "c"}, ":2\r\n", ZRank},
+		{"ZREVRANK", []any{"ZREVRANK", "z", "c"}, ":2\r\n", ZRevRank},
+		{"ZRANK WITHSCORE", []any{"ZRANK", "z", "d", "WITHSCORE"}, "*2\r\n:3\r\n$1\r\n4\r\n", ZRank},
+		{"ZRANK missing", []any{"ZRANK", "z", "nope"}, "$-1\r\n", ZRank},
+		{"by rank", []any{"ZRANGE", "z", 0, 1}, "*2\r\n$1\r\na\r\n$1\r\nb\r\n", ZRange},
+		{"negative ranks", []any{"ZRANGE", "z", -2, -1, "WITHSCORES"}, "*4\r\n$1\r\nd\r\n$1\r\n4\r\n$1\r\ne\r\n$1\r\n5\r\n", ZRange},
+		{"rank REV", []any{"ZRANGE", "z", 0, 1, "REV"}, "*2\r\n$1\r\ne\r\n$1\r\nd\r\n", ZRange},
+		{"out of range", []any{"ZRANGE", "z", 10, 20}, "*0\r\n", ZRange},
+		{"BYSCORE", []any{"ZRANGE", "z", "(1", 3, "BYSCORE"}, "*2\r\n$1\r\nb\r\n$1\r\nc\r\n", ZRange},
+		{"BYSCORE infinite", []any{"ZRANGE", "z", "-inf", "+inf", "BYSCORE", "LIMIT", 1, 2}, "*2\r\n$1\r\nb\r\n$1\r\nc\r\n", ZRange},
+		{"BYSCORE REV", []any{"ZRANGE", "z", 4, 2, "BYSCORE", "REV"}, "*3\r\n$1\r\nd\r\n$1\r\nc\r\n$1\r\nb\r\n", ZRange},
+		{"BYSCORE bad float", []any{"ZRANGE", "z", "x", 2, "BYSCORE"}, "-ERR min or max is not a float\r\n", ZRange},
+		{"BYLEX", []any{"ZRANGE", "lex", "[b", "(d", "BYLEX"}, "*2\r\n$1\r\nb\r\n$1\r\nc\r\n", ZRange},
+		{"BYLEX REV LIMIT", []any{"ZRANGE", "lex", "+", "-", "BYLEX", "REV", "LIMIT", 0, 2}, "*2\r\n$1\r\ne\r\n$1\r\nd\r\n", ZRange},
+		{"BYLEX invalid", []any{"ZRANGE", "lex", "b", "d", "BYLEX"}, "-ERR min or max not valid string range item\r\n", ZRange},
+		{"LIMIT without BY", []any{"ZRANGE", "z", 0, 1, "LIMIT", 0, 1}, "-ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX\r\n", ZRange},
+		{"ZCOUNT", []any{"ZCOUNT", "z", "(1", "+inf"}, ":4\r\n", ZCount},
+		{"ZLEXCOUNT", []any{"ZLEXCOUNT", "lex", "-", "[c"}, ":3\r\n", ZLexCount},
+		{"ZRANGESTORE", []any{"ZRANGESTORE", "dst", "z", 2, 5, "BYSCORE"}, ":4\r\n", ZRangeStore},
+		{"ZRANGESTORE result", []any{"ZRANGE", "dst", 0, -1}, "*4\r\n$1\r\nb\r\n$1\r\nc\r\n$1\r\nd\r\n$1\r\ne\r\n", ZRange},
+		{"member with CRLF", []any{"ZRANGE", "crlf", 0, -1}, "*1\r\n$5\r\na\r\n:1\r\n", ZRange},
+	})
+}
+
+func TestZRemRange(t *testing.T) {
+	storedData := map[string]model.StoredData{
+		"z":   {Value: newTestZSet(1, "a", 2, "b", 3, "c", 4, "d", 5, "e", 6, "f")},
+		"lex": {Value: newTestZSet(0, "a", 0, "b", 0, "c")},
+	}
+
+	runCommands(t, storedData, []commandCase{
+		{"by rank", []any{"ZREMRANGEBYRANK", "z", 0, 1}, ":2\r\n", ZRemRangeByRank},
+		{"by score", []any{"ZREMRANGEBYSCORE", "z", "(3", 5}, ":2\r\n", ZRemRangeByScore},
+		{"remaining", []any{"ZRANGE", "z", 0, -1}, "*2\r\n$1\r\nc\r\n$1\r\nf\r\n", ZRange},
+		{"by lex", []any{"ZREMRANGEBYLEX", "lex", "[a", "[b"}, ":2\r\n", ZRemRangeByLex},
+		{"missing key", []any{"ZREMRANGEBYSCORE", "nope", 0, 1}, ":0\r\n", ZRemRangeByScore},
+	})
+}
+
+func TestZSetOperations(t *testing.T) {
+	storedData := map[string]model.StoredData{
+		"z1":  {Value: newTestZSet(1, "a", 2, "b", 3, "c")},
+		"z2":  {Value: newTestZSet(10, "b", 20, "c", 30, "d")},
+		"set": {Value: newTestSet("a", "d")},
+		"str": {Value: "value"},
+	}
+
+	runCommands(t, storedData, []commandCase{
+		{"union", []any{"ZUNIONSTORE", "out", 2, "z1", "z2"}, ":4\r\n", ZUnionStore},
+		{"union result", []any{"ZRANGE", "out", 0, -1, "WITHSCORES"}, "*8\r\n$1\r\na\r\n$1\r\n1\r\n$1\r\nb\r\n$2\r\n12\r\n$1\r\nc\r\n$2\r\n23\r\n$1\r\nd\r\n$2\r\n30\r\n", ZRange},
+		{"union weights max", []any{"ZUNIONSTORE", "out", 2, "z1", "z2", "WEIGHTS", 10, 1, "AGGREGATE", "MAX"}, ":4\r\n", ZUnionStore},
+		{"union weights max result", []any{"ZRANGE", "out", 0, -1, "WITHSCORES"}, "*8\r\n$1\r\na\r\n$2\r\n10\r\n$1\r\nb\r\n$2\r\n20\r\n$1\r\nc\r\n$2\r\n30\r\n$1\r\nd\r\n$2\r\n30\r\n", ZRange},
+		{"inter min", []any{"ZINTERSTORE", "out", 2, "z1", "z2", "AGGREGATE", "MIN"}, ":2\r\n", ZInterStore},
+		{"inter min result", []any{"ZRANGE", "out", 0, -1, "WITHSCORES"}, "*4\r\n$1\r\nb\r\n$1\r\n2\r\n$1\r\nc\r\n$1\r\n3\r\n", ZRange},
+		{"inter with plain set", []any{"ZINTER", 2, "z1", "set", "WITHSCORES"}, "*2\r\n$1\r\na\r\n$1\r\n2\r\n", ZInter},
+		{"diff", []any{"ZDIFF", 2, "z1", "z2", "WITHSCORES"}, "*2\r\n$1\r\na\r\n$1\r\n1\r\n", ZDiff},
+		{"diff rejects weights", []any{"ZDIFF", 2, "z1", "z2", "WEIGHTS", 1, 1}, "-ERR syntax error\r\n", ZDiff},
+		{"wrong type", []any{"ZUNIONSTORE", "out", 2, "z1", "str"}, "-ERR value is not type of zset\r\n", ZUnionStore},
+		{"bad numkeys", []any{"ZUNIONSTORE", "out", 0, "z1"}, "-ERR at least 1 input key is needed for 'zunionstore' command\r\n", ZUnionStore},
+		{"empty result deletes destination", []any{"ZINTERSTORE", "out", 2, "z1", "missing"}, ":0\r\n", ZInterStore},
+	})
+
+	if _, found := storedData["out"]; found {
+		t.Error("expected empty result to delete the destination")
+	}
+}
+
+func TestZScan(t *testing.T) {
+	storedData := map[string]model.StoredData{
+		"z": {Value: newTestZSet(1, "apple", 2, "banana", 3, "avocado")},
+	}
+
+	runCommands(t, storedData, []commandCase{
+		{"match", []any{"ZSCAN", "z", 0, "MATCH", "b*"}, "*2\r\n$1\r\n0\r\n*2\r\n$6\r\nbanana\r\n$1\r\n2\r\n", ZScan},
+		{"missing key", []any{"ZSCAN", "missing", 0}, "*2\r\n$1\r\n0\r\n*0\r\n", ZScan},
+	})
+
+	seen := scanAll(t, ZScan, storedData, "z", nil)
+	if expected := []string{"apple", "1", "avocado", "3", "banana", "2"}; !slices.Equal(pairsByMember(seen), expected) {
+		t.Errorf("expected a full scan to return %v, got %v", expected, seen)
+	}
+}
+
+// pairsByMember sorts the member/score pairs of a ZSCAN reply by member.
+func pairsByMember(elements []string) []string {
+	var pairs [][]string
+	for i := 0; i+1 < len(elements); i += 2 {
+		pairs = append(pairs, elements[i:i+2])
+	}
+	slices.SortFunc(pairs, func(a, b []string) int { return strings.Compare(a[0], b[0]) })
+	return slices.Concat(pairs...)
+}
+
+func TestZScanWhileReordering(t *testing.T) {
+	var mu sync.RWMutex
+	storedData := make(map[string]model.StoredData)
+	var members []string
+	for i := 1; i <= 20; i++ {
+		member := "m" + strconv.Itoa(i)
+		members = append(members, member)
+		ZAdd([]any{"ZADD", "z", i, member}, storedData, &mu)
+	}
+
+	// Moving the members already returned to the end and removing others
+	// must not make the scan skip the members that stay.
+	seen := scanAll(t, ZScan, storedData, "z", func(page []string) {
+		for i := 0; i < len(page); i += 2 {
+			if i%4 == 0 {
+				ZIncrBy([]any{"ZINCRBY", "z", 100, page[i]}, storedData, &mu)
+			} else {
+				ZRem([]any{"ZREM", "z", page[i]}, storedData, &mu)
+			}
+		}
+	})
+
+	var seenMembers []string
+	for i := 0; i < len(seen); i += 2 {
+		seenMembers = append(seenMembers, seen[i])
+	}
+	slices.Sort(seenMembers)
+	slices.Sort(members)
+	if !slices.Equal(seenMembers, members) {
+		t.Errorf("expected every member once, got %v", seenMembers)
+	}
+}
+
+// TestSortedSetRanks checks the skiplist spans against a sorted slice after
+// a random mix of inserts, updates and removals.
+func TestSortedSetRanks(t *testing.T) {
+	zset := model.NewSortedSet()
+	scores := make(map[string]float64)
+
+	for i := 0; i < 2000; i++ {
+		member := "m" + strconv.Itoa(rand.IntN(300))
+		if rand.IntN(4) == 0 {
+			zset.Remove(member)
+			delete(scores, member)
+			continue
+		}
+		score := float64(rand.IntN(50))
+		zset.Add(member, score)
+		scores[member] = score
+	}
+
+	expected := make([]model.ZMember, 0, len(scores))
+	for member, score := range scores {
+		expected = append(expected, model.ZMember{Member: member, Score: score})
+	}
+	sort.Slice(expected, func(i, j int) bool {
+		if expected[i].Score != expected[j].Score {
+			return expected[i].Score < expected[j].Score
+		}
+		return expected[i].Member < expected[j].Member
+	})
+
+	if zset.Len() != len(expected) {
+		t.Fatalf("expected %d members, got %d", len(expected), zset.Len())
+	}
+	for i, m := range expected {
+		rank, found := zset.Rank(m.Member, false)
+		if !found || rank != i {
+			t.Fatalf("expected %s at rank %d, got %d", m.Member, i, rank)
+		}
+		if got := zset.RangeByRank(i, i, false); len(got) != 1 || got[0] != m {
+			t.Fatalf("expected %v at rank %d, got %v", m, i, got)
+		}
+	}
+
+	r := model.ScoreRange{Min: 10, Max: 20, MaxEx: true}
+	count := 0
+	for _, m := range expected {
+		if m.Score >= 10 && m.Score < 20 {
+			count++
+		}
+	}
+	if got := zset.CountInScoreRange(r); got != count {
+		t.Errorf("expected %d members in range, got %d", count, got)
+	}
+}
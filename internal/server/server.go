@@ -2,16 +2,28 @@ package server
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"net"
+	"net/http"
+	"os"
 	"redis-go-clone/cmd/config"
 	"redis-go-clone/internal/handler"
+	"redis-go-clone/internal/logging"
 	"redis-go-clone/internal/manager"
+	"redis-go-clone/internal/stats"
 	"time"
 )
 
-func StartServer() {
-	config := config.NewConfig()
+func StartServer(config *config.Config) {
+	if err := logging.SetLevel(config.LogLevel); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid loglevel %q: %v\n", config.LogLevel, err)
+		os.Exit(1)
+	}
+	if err := logging.Setup(config.LogFile, config.LogFormat); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open the log: %v\n", err)
+		os.Exit(1)
+	}
+	logging.ReopenOnSIGHUP()
 
 	manager.LoadData(config.DB, config.Lock)
 
@@ -19,27 +31,52 @@ func StartServer() {
 
 	h := handler.NewClientHandler(config)
 
+	stats.StartSampling(100 * time.Millisecond)
+
+	manager.LoadFunctions(h.RestoreFunctions)
+
+	if config.MetricsAddr != "" {
+		startMetrics(config.MetricsAddr, h.Metrics())
+	}
+
 	listener, err := net.Listen("tcp", ":6379")
 	if err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+		slog.Error("Failed to start server", "error", err)
+		os.Exit(1)
 	}
 
 	defer func(listener net.Listener) {
 		err := listener.Close()
 		if err != nil {
-			log.Fatalf("Failed to close server: %v", err)
+			slog.Error("Failed to close server", "error", err)
+			os.Exit(1)
 		}
 	}(listener)
 
-	fmt.Println("Redis Lite server listening on port 6379")
+	slog.Info("Redis Lite server listening on port 6379")
 
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
-			log.Printf("Failed to accept connection: %v", err)
+			slog.Warn("Failed to accept connection", "error", err)
 			continue
 		}
 
 		go h.HandleClient(conn)
 	}
 }
+
+// startMetrics serves the metrics over HTTP at addr in the background.
+func startMetrics(addr string, metrics http.Handler) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		slog.Error("Failed to start metrics listener", "error", err)
+		os.Exit(1)
+	}
+	slog.Info("Serving metrics on " + listener.Addr().String() + "/metrics")
+
+	go func() {
+		err := http.Serve(listener, metrics)
+		slog.Warn("Metrics listener stopped", "error", err)
+	}()
+}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package stats
+
+import (
+	"syscall"
+	"time"
+)
+
+// CPU returns the system and user CPU time the process has used.
+func CPU() (sys, user time.Duration) {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		return 0, 0
+	}
+	return time.Duration(usage.Stime.Nano()), time.Duration(usage.Utime.Nano())
+}
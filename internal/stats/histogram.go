@@ -0,0 +1,118 @@
+package stats
+
+import (
+	"math/bits"
+	"time"
+)
+
+// subBuckets is how many buckets each power of two is split into, which
+// keeps every bucket within about 6% of the durations it holds.
+const subBuckets = 16
+
+// Histogram counts durations in nanoseconds. Durations below subBuckets
+// have a bucket each; above, every power of two is split into subBuckets
+// buckets of the same width, like an HDR histogram with one significant
+// digit in hexadecimal.
+type Histogram struct {
+	counts [subBuckets + (64-4)*subBuckets]int64
+	total  int64
+}
+
+func bucketOf(v uint64) int {
+	if v < subBuckets {
+		return int(v)
+	}
+	exp := bits.Len64(v) - 1
+	sub := int(v>>(exp-4)) & (subBuckets - 1)
+	return (exp-3)*subBuckets + sub
+}
+
+// bucketLow returns the smallest duration counted in bucket i.
+func bucketLow(i int) uint64 {
+	if i < subBuckets {
+		return uint64(i)
+	}
+	exp := i/subBuckets + 3
+	return uint64(subBuckets+i%subBuckets) << (exp - 4)
+}
+
+// bucketHigh returns the largest duration counted in bucket i.
+func bucketHigh(i int) uint64 {
+	if i+1 >= len(Histogram{}.counts) {
+		return 1<<63 - 1
+	}
+	return bucketLow(i+1) - 1
+}
+
+func (h *Histogram) Record(d time.Duration) {
+	h.counts[bucketOf(uint64(max(d, 0)))]++
+	h.total++
+}
+
+// Count returns how many durations were recorded.
+func (h *Histogram) Count() int64 {
+	return h.total
+}
+
+// Percentile returns the duration under which p percent of the recorded
+// ones fall, rounded up to the end of its bucket.
+func (h *Histogram) Percentile(p float64) time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	rank := int64(p/100*float64(h.total) + 0.5)
+	rank = min(max(rank, 1), h.total)
+	var seen int64
+	for i, n := range h.counts {
+		seen += n
+		if seen >= rank {
+			return time.Duration(bucketHigh(i))
+		}
+	}
+	return time.Duration(bucketHigh(len(h.counts) - 1))
+}
+
+// CountBelow returns how many recorded durations are below limit, which is
+// exact when limit is a power of two nanoseconds.
+func (h *Histogram) CountBelow(limit time.Duration) int64 {
+	var n int64
+	for i, count := range h.counts {
+		if bucketLow(i) >= uint64(max(limit, 0)) {
+			break
+		}
+		n += count
+	}
+	return n
+}
+
+// Cumulative is how many recorded durations are below Limit.
+type Cumulative struct {
+	Limit time.Duration
+	Count int64
+}
+
+// PowersOfTwo returns how many durations are below each power of two from
+// 1024ns, which is how LATENCY HISTOGRAM reports them. Powers below which
+// there are no more durations than below the previous one are left out.
+func (h *Histogram) PowersOfTwo() []Cumulative {
+	var powers []Cumulative
+	var seen, reported int64
+	limit := uint64(1024)
+	for i, n := range h.counts {
+		if n == 0 {
+			continue
+		}
+		for bucketLow(i) >= limit && limit < 1<<63 {
+			if seen != reported {
+				powers = append(powers, Cumulative{time.Duration(limit), seen})
+				reported = seen
+			}
+			limit <<= 1
+		}
+		seen += n
+	}
+	if seen != reported {
+		powers = append(powers, Cumulative{time.Duration(limit), seen})
+	}
+	return powers
+}
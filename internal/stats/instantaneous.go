@@ -0,0 +1,78 @@
+package stats
+
+import "time"
+
+// instantaneousSamples is how many samples the instantaneous rates are
+// averaged over.
+const instantaneousSamples = 16
+
+// rate tracks how fast a counter grows from the samples taken of it.
+type rate struct {
+	lastValue int64
+	lastTime  time.Time
+	samples   [instantaneousSamples]float64
+	next      int
+}
+
+func (r *rate) sample(value int64, now time.Time) {
+	if !r.lastTime.IsZero() {
+		if elapsed := now.Sub(r.lastTime).Seconds(); elapsed > 0 {
+			r.samples[r.next] = float64(value-r.lastValue) / elapsed
+			r.next = (r.next + 1) % instantaneousSamples
+		}
+	}
+	r.lastValue, r.lastTime = value, now
+}
+
+func (r *rate) perSecond() float64 {
+	var sum float64
+	for _, s := range r.samples {
+		sum += s
+	}
+	return sum / instantaneousSamples
+}
+
+// The rates are guarded by mu.
+var opsRate, inputRate, outputRate rate
+
+// Instantaneous are the rates of the last samples, per second.
+type Instantaneous struct {
+	Ops         float64
+	InputBytes  float64
+	OutputBytes float64
+}
+
+// Sample samples the counters behind the instantaneous rates.
+func Sample() {
+	now := time.Now()
+	mu.Lock()
+	defer mu.Unlock()
+	opsRate.sample(commandsProcessed.Load(), now)
+	inputRate.sample(netInputBytes.Load(), now)
+	outputRate.sample(netOutputBytes.Load(), now)
+}
+
+// StartSampling samples the counters every interval, which Redis does 10
+// times per second.
+func StartSampling(interval time.Duration) {
+	go func() {
+		for {
+			time.Sleep(interval)
+			Sample()
+		}
+	}()
+}
+
+func GetInstantaneous() Instantaneous {
+	mu.Lock()
+	defer mu.Unlock()
+	return Instantaneous{
+		Ops:         opsRate.perSecond(),
+		InputBytes:  inputRate.perSecond(),
+		OutputBytes: outputRate.perSecond(),
+	}
+}
+
+func resetInstantaneous() {
+	opsRate, inputRate, outputRate = rate{}, rate{}, rate{}
+}
@@ -0,0 +1,236 @@
+// Package stats collects the server counters INFO reports: connections,
+// commands processed with their latency, error replies, keyspace hits and
+// misses, expired keys and changes since the last save.
+package stats
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxErrorTypes bounds how many distinct error prefixes are tracked, so a
+// script raising made-up errors cannot grow the table forever.
+const maxErrorTypes = 128
+
+// StartTime is when the server started.
+var StartTime = time.Now()
+
+// RunID is a random identifier of this run of the server.
+var RunID = newRunID()
+
+func newRunID() string {
+	id := make([]byte, 20)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+var (
+	connectedClients    atomic.Int64
+	connectionsReceived atomic.Int64
+	commandsProcessed   atomic.Int64
+	keyspaceHits        atomic.Int64
+	keyspaceMisses      atomic.Int64
+	expiredKeys         atomic.Int64
+	netInputBytes       atomic.Int64
+	netOutputBytes      atomic.Int64
+	errorReplies        atomic.Int64
+	dirty               atomic.Int64
+	lastSave            atomic.Int64
+	lastSaveFailed      atomic.Bool
+
+	mu       sync.Mutex
+	commands = make(map[string]*Command)
+	errors   = make(map[string]int64)
+)
+
+func init() {
+	lastSave.Store(StartTime.Unix())
+}
+
+// Command is what was counted for one command.
+type Command struct {
+	Calls         int64
+	Duration      time.Duration
+	RejectedCalls int64
+	FailedCalls   int64
+	Latency       Histogram
+}
+
+// Counters is a snapshot of the global counters.
+type Counters struct {
+	ConnectedClients    int64
+	ConnectionsReceived int64
+	CommandsProcessed   int64
+	KeyspaceHits        int64
+	KeyspaceMisses      int64
+	ExpiredKeys         int64
+	NetInputBytes       int64
+	NetOutputBytes      int64
+	ErrorReplies        int64
+	// Dirty is how many changes were made since LastSave.
+	Dirty          int64
+	LastSave       time.Time
+	LastSaveFailed bool
+}
+
+func Get() Counters {
+	return Counters{
+		ConnectedClients:    connectedClients.Load(),
+		ConnectionsReceived: connectionsReceived.Load(),
+		CommandsProcessed:   commandsProcessed.Load(),
+		KeyspaceHits:        keyspaceHits.Load(),
+		KeyspaceMisses:      keyspaceMisses.Load(),
+		ExpiredKeys:         expiredKeys.Load(),
+		NetInputBytes:       netInputBytes.Load(),
+		NetOutputBytes:      netOutputBytes.Load(),
+		ErrorReplies:        errorReplies.Load(),
+		Dirty:               dirty.Load(),
+		LastSave:            time.Unix(lastSave.Load(), 0),
+		LastSaveFailed:      lastSaveFailed.Load(),
+	}
+}
+
+// Reset clears the counters, like CONFIG RESETSTAT. The connected clients
+// and the changes since the last save are state rather than statistics and
+// are kept.
+func Reset() {
+	connectionsReceived.Store(0)
+	commandsProcessed.Store(0)
+	keyspaceHits.Store(0)
+	keyspaceMisses.Store(0)
+	expiredKeys.Store(0)
+	netInputBytes.Store(0)
+	netOutputBytes.Store(0)
+	errorReplies.Store(0)
+
+	mu.Lock()
+	defer mu.Unlock()
+	clear(commands)
+	clear(errors)
+	resetInstantaneous()
+}
+
+func ClientConnected() {
+	connectionsReceived.Add(1)
+	connectedClients.Add(1)
+}
+
+func ClientDisconnected() {
+	connectedClients.Add(-1)
+}
+
+// KeyspaceLookup counts a read of a key that was found or missing.
+func KeyspaceLookup(found bool) {
+	if found {
+		keyspaceHits.Add(1)
+	} else {
+		keyspaceMisses.Add(1)
+	}
+}
+
+func KeyExpired() {
+	expiredKeys.Add(1)
+}
+
+func NetInput(n int) {
+	netInputBytes.Add(int64(n))
+}
+
+func NetOutput(n int) {
+	netOutputBytes.Add(int64(n))
+}
+
+// Changed counts a change to the store since the last save.
+func Changed() {
+	dirty.Add(1)
+}
+
+// Saved records that the store was saved at t.
+func Saved(t time.Time) {
+	dirty.Store(0)
+	lastSave.Store(t.Unix())
+	lastSaveFailed.Store(false)
+}
+
+// SaveFailed records that the last attempt to save the store failed.
+func SaveFailed() {
+	lastSaveFailed.Store(true)
+}
+
+func command(name string) *Command {
+	cmd, found := commands[name]
+	if !found {
+		cmd = &Command{}
+		commands[name] = cmd
+	}
+	return cmd
+}
+
+// Call counts a command that ran for d and replied reply.
+func Call(name string, d time.Duration, reply string) {
+	commandsProcessed.Add(1)
+	mu.Lock()
+	defer mu.Unlock()
+	cmd := command(name)
+	cmd.Calls++
+	cmd.Duration += d
+	cmd.Latency.Record(d)
+	if isError(reply) {
+		cmd.FailedCalls++
+		countError(reply)
+	}
+}
+
+// Rejected counts a command refused with the error reply before it ran.
+// name is empty for a command that does not exist.
+func Rejected(name, reply string) {
+	mu.Lock()
+	defer mu.Unlock()
+	if name != "" {
+		command(name).RejectedCalls++
+	}
+	countError(reply)
+}
+
+func isError(reply string) bool {
+	return strings.HasPrefix(reply, "-")
+}
+
+// countError counts an error reply by its prefix, the first word of the
+// message, such as ERR or WRONGTYPE.
+func countError(reply string) {
+	errorReplies.Add(1)
+	prefix, _, _ := strings.Cut(strings.TrimPrefix(reply, "-"), " ")
+	prefix = strings.TrimSuffix(prefix, "\r\n")
+	if _, found := errors[prefix]; !found && len(errors) >= maxErrorTypes {
+		return
+	}
+	errors[prefix]++
+}
+
+// Commands returns a copy of what was counted for every command that ran
+// or was rejected, by name.
+func Commands() map[string]Command {
+	mu.Lock()
+	defer mu.Unlock()
+	snapshot := make(map[string]Command, len(commands))
+	for name, cmd := range commands {
+		snapshot[name] = *cmd
+	}
+	return snapshot
+}
+
+// Errors returns the error replies counted for every prefix.
+func Errors() map[string]int64 {
+	mu.Lock()
+	defer mu.Unlock()
+	snapshot := make(map[string]int64, len(errors))
+	for prefix, n := range errors {
+		snapshot[prefix] = n
+	}
+	return snapshot
+}
@@ -0,0 +1,92 @@
+package stats
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestHistogramBuckets(t *testing.T) {
+	for _, v := range []uint64{0, 1, 15, 16, 17, 31, 32, 33, 1000, 1023, 1024, 123456789, 1<<63 - 1} {
+		i := bucketOf(v)
+		if low, high := bucketLow(i), bucketHigh(i); v < low || v > high {
+			t.Errorf("bucketOf(%d) = %d, which holds [%d, %d]", v, i, low, high)
+		}
+	}
+}
+
+func TestHistogramPercentile(t *testing.T) {
+	var h Histogram
+	if got := h.Percentile(50); got != 0 {
+		t.Errorf("Percentile of an empty histogram = %v, want 0", got)
+	}
+	for i := 1; i <= 100; i++ {
+		h.Record(time.Duration(i) * time.Microsecond)
+	}
+
+	tests := []struct {
+		percentile float64
+		want       time.Duration
+	}{
+		{50, 50 * time.Microsecond},
+		{99, 99 * time.Microsecond},
+		{100, 100 * time.Microsecond},
+	}
+	for _, tt := range tests {
+		got := h.Percentile(tt.percentile)
+		// Durations are only known to the width of their bucket.
+		if got < tt.want || float64(got) > float64(tt.want)*1.07 {
+			t.Errorf("Percentile(%v) = %v, want about %v", tt.percentile, got, tt.want)
+		}
+	}
+	if h.Count() != 100 {
+		t.Errorf("Count() = %d, want 100", h.Count())
+	}
+}
+
+func TestHistogramPowersOfTwo(t *testing.T) {
+	var h Histogram
+	for _, d := range []time.Duration{500, 1000, 1500, 3000, 3500, 100000} {
+		h.Record(d)
+	}
+	want := []Cumulative{{1024, 2}, {2048, 3}, {4096, 5}, {131072, 6}}
+	if got := h.PowersOfTwo(); !slices.Equal(got, want) {
+		t.Errorf("PowersOfTwo() = %v, want %v", got, want)
+	}
+	for _, c := range want {
+		if got := h.CountBelow(c.Limit); got != c.Count {
+			t.Errorf("CountBelow(%v) = %d, want %d", c.Limit, got, c.Count)
+		}
+	}
+}
+
+func TestCommandCounters(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+
+	Call("get", time.Millisecond, "$1\r\nv\r\n")
+	Call("get", 3*time.Millisecond, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n")
+	Rejected("set", "-OOM command not allowed when used memory > 'maxmemory'.\r\n")
+	Rejected("", "-ERR unknown command\r\n")
+
+	get := Commands()["get"]
+	if get.Calls != 2 || get.Duration != 4*time.Millisecond || get.FailedCalls != 1 || get.RejectedCalls != 0 {
+		t.Errorf("get counted %+v", get)
+	}
+	if set := Commands()["set"]; set.Calls != 0 || set.RejectedCalls != 1 {
+		t.Errorf("set counted %+v", set)
+	}
+	if len(Commands()) != 2 {
+		t.Errorf("Commands() has %d commands, want 2", len(Commands()))
+	}
+
+	errors := Errors()
+	for prefix, want := range map[string]int64{"WRONGTYPE": 1, "OOM": 1, "ERR": 1} {
+		if errors[prefix] != want {
+			t.Errorf("errors[%q] = %d, want %d", prefix, errors[prefix], want)
+		}
+	}
+	if counters := Get(); counters.CommandsProcessed != 2 || counters.ErrorReplies != 3 {
+		t.Errorf("Get() = %+v, want 2 commands and 3 errors", counters)
+	}
+}
@@ -0,0 +1,104 @@
+// Package watch implements the bookkeeping behind WATCH: it remembers which
+// keys each client watches and marks the client as dirty as soon as one of
+// them is modified, so that its next EXEC fails.
+package watch
+
+import "sync"
+
+// Watcher is the set of keys watched by one client.
+type Watcher struct {
+	// keys maps each watched key to its expiry date at WATCH time, or 0.
+	keys  map[string]int64
+	dirty bool
+}
+
+var (
+	mu      sync.Mutex
+	watched = make(map[string]map[*Watcher]struct{})
+)
+
+func NewWatcher() *Watcher {
+	return &Watcher{keys: make(map[string]int64)}
+}
+
+// Watch adds key to the watched keys. expiryDate is the Unix time at which
+// the key expires, or 0 if it has no TTL or does not exist.
+func (w *Watcher) Watch(key string, expiryDate int64) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, found := w.keys[key]; found {
+		return
+	}
+	w.keys[key] = expiryDate
+	watchers, found := watched[key]
+	if !found {
+		watchers = make(map[*Watcher]struct{})
+		watched[key] = watchers
+	}
+	watchers[w] = struct{}{}
+}
+
+// Unwatch forgets every watched key and clears the dirty flag.
+func (w *Watcher) Unwatch() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for key := range w.keys {
+		delete(watched[key], w)
+		if len(watched[key]) == 0 {
+			delete(watched, key)
+		}
+	}
+	clear(w.keys)
+	w.dirty = false
+}
+
+// Len returns how many keys are watched.
+func (w *Watcher) Len() int {
+	mu.Lock()
+	defer mu.Unlock()
+	return len(w.keys)
+}
+
+// Dirty reports whether a watched key was modified since it was watched, or
+// has expired since then even if it was not removed yet. now is a Unix time.
+func (w *Watcher) Dirty(now int64) bool {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if w.dirty {
+		return true
+	}
+	for _, expiryDate := range w.keys {
+		if expiryDate > 0 && expiryDate <= now {
+			return true
+		}
+	}
+	return false
+}
+
+// Touch signals that key was modified, expired or deleted.
+func Touch(key string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for w := range watched[key] {
+		w.dirty = true
+	}
+}
+
+// Watching returns how many clients watch keys and how many distinct keys
+// are watched.
+func Watching() (clients, keys int) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	seen := make(map[*Watcher]struct{})
+	for _, watchers := range watched {
+		for w := range watchers {
+			seen[w] = struct{}{}
+		}
+	}
+	return len(seen), len(watched)
+}
@@ -0,0 +1,54 @@
+package watch
+
+import "testing"
+
+func TestWatcher(t *testing.T) {
+	w := NewWatcher()
+	other := NewWatcher()
+	w.Watch("a", 0)
+	w.Watch("b", 0)
+	other.Watch("b", 0)
+
+	Touch("c")
+	if w.Dirty(0) {
+		t.Fatal("expected an unwatched key not to make the watcher dirty")
+	}
+	Touch("a")
+	if !w.Dirty(0) {
+		t.Fatal("expected a touched key to make the watcher dirty")
+	}
+	if other.Dirty(0) {
+		t.Fatal("expected other watchers to stay clean")
+	}
+
+	w.Unwatch()
+	if w.Dirty(0) {
+		t.Fatal("expected Unwatch to clear the dirty flag")
+	}
+	Touch("a")
+	if w.Dirty(0) {
+		t.Fatal("expected Unwatch to forget the keys")
+	}
+	Touch("b")
+	if !other.Dirty(0) {
+		t.Fatal("expected other watchers to keep their keys")
+	}
+
+	other.Unwatch()
+	if len(watched) != 0 {
+		t.Errorf("expected no watched keys left, got %v", watched)
+	}
+}
+
+func TestWatcherExpiry(t *testing.T) {
+	w := NewWatcher()
+	defer w.Unwatch()
+	w.Watch("k", 100)
+
+	if w.Dirty(99) {
+		t.Error("expected the key not to have expired yet")
+	}
+	if !w.Dirty(100) {
+		t.Error("expected an expired key to make the watcher dirty")
+	}
+}
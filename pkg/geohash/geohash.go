@@ -0,0 +1,360 @@
+// Package geohash implements the interleaved geohash encoding and the
+// search-area estimation used by the Redis GEO commands.
+package geohash
+
+import "math"
+
+const (
+	// Step is the precision used for stored scores: 26 bits per axis, 52 in
+	// total, which is exactly representable in a float64 sorted set score.
+	Step = 26
+
+	LatMin  = -85.05112878
+	LatMax  = 85.05112878
+	LongMin = -180.0
+	LongMax = 180.0
+
+	earthRadiusInMeters = 6372797.560856
+	mercatorMax         = 20037726.37
+)
+
+// Range is an interval of latitudes or longitudes.
+type Range struct {
+	Min, Max float64
+}
+
+// Bits is a geohash of Step*2 significant bits.
+type Bits struct {
+	Bits uint64
+	Step uint8
+}
+
+// IsZero reports whether the hash was excluded from a search.
+func (b Bits) IsZero() bool {
+	return b.Bits == 0 && b.Step == 0
+}
+
+// Area is the cell covered by a hash.
+type Area struct {
+	Hash      Bits
+	Longitude Range
+	Latitude  Range
+}
+
+// Neighbors are the eight cells surrounding a hash.
+type Neighbors struct {
+	North, East, West, South                   Bits
+	NorthEast, SouthEast, NorthWest, SouthWest Bits
+}
+
+// Radius is the cell containing the search center, its neighbors and the
+// cell area.
+type Radius struct {
+	Hash      Bits
+	Area      Area
+	Neighbors Neighbors
+}
+
+// Cells returns the cells to scan in the order Redis visits them, skipping
+// excluded neighbors and repeats of the previous cell, which happen with
+// very large radii.
+func (r Radius) Cells() []Bits {
+	n := r.Neighbors
+	all := []Bits{r.Hash, n.North, n.South, n.East, n.West, n.NorthEast, n.NorthWest, n.SouthEast, n.SouthWest}
+
+	cells := make([]Bits, 0, len(all))
+	last := 0
+	for i, cell := range all {
+		if cell.IsZero() {
+			continue
+		}
+		// Like Redis, a neighbor is never compared with the center cell.
+		if last != 0 && cell == all[last] {
+			continue
+		}
+		cells = append(cells, cell)
+		last = i
+	}
+	return cells
+}
+
+// WGS84 ranges used for scores; latitudes are limited to the Web Mercator
+// bounds.
+var (
+	WGS84Long = Range{LongMin, LongMax}
+	WGS84Lat  = Range{LatMin, LatMax}
+)
+
+// ValidCoordinates reports whether the pair can be indexed.
+func ValidCoordinates(longitude, latitude float64) bool {
+	return longitude >= LongMin && longitude <= LongMax &&
+		latitude >= LatMin && latitude <= LatMax
+}
+
+// Encode interleaves the scaled latitude and longitude offsets.
+func Encode(longRange, latRange Range, longitude, latitude float64, step uint8) (Bits, bool) {
+	if step > 32 || step == 0 || !ValidCoordinates(longitude, latitude) {
+		return Bits{}, false
+	}
+	if latitude < latRange.Min || latitude > latRange.Max ||
+		longitude < longRange.Min || longitude > longRange.Max {
+		return Bits{}, false
+	}
+
+	latOffset := (latitude - latRange.Min) / (latRange.Max - latRange.Min)
+	longOffset := (longitude - longRange.Min) / (longRange.Max - longRange.Min)
+	latOffset *= float64(uint64(1) << step)
+	longOffset *= float64(uint64(1) << step)
+	return Bits{Bits: interleave64(uint32(latOffset), uint32(longOffset)), Step: step}, true
+}
+
+// EncodeWGS84 returns the 52-bit score for a coordinate pair.
+func EncodeWGS84(longitude, latitude float64) (uint64, bool) {
+	hash, ok := Encode(WGS84Long, WGS84Lat, longitude, latitude, Step)
+	return hash.Bits, ok
+}
+
+// Decode returns the area covered by hash.
+func Decode(longRange, latRange Range, hash Bits) Area {
+	sep := deinterleave64(hash.Bits)
+	latScale := latRange.Max - latRange.Min
+	longScale := longRange.Max - longRange.Min
+	ilato := uint32(sep)
+	ilono := uint32(sep >> 32)
+	cells := float64(uint64(1) << hash.Step)
+
+	return Area{
+		Hash: hash,
+		Latitude: Range{
+			Min: latRange.Min + float64(ilato)/cells*latScale,
+			Max: latRange.Min + (float64(ilato)+1)/cells*latScale,
+		},
+		Longitude: Range{
+			Min: longRange.Min + float64(ilono)/cells*longScale,
+			Max: longRange.Min + (float64(ilono)+1)/cells*longScale,
+		},
+	}
+}
+
+// DecodeWGS84 returns the center of the cell identified by a stored score.
+func DecodeWGS84(bits uint64) (longitude, latitude float64) {
+	area := Decode(WGS84Long, WGS84Lat, Bits{Bits: bits, Step: Step})
+	longitude = math.Max(LongMin, math.Min(LongMax, (area.Longitude.Min+area.Longitude.Max)/2))
+	latitude = math.Max(LatMin, math.Min(LatMax, (area.Latitude.Min+area.Latitude.Max)/2))
+	return longitude, latitude
+}
+
+// String returns the standard 11 character base32 geohash of a stored score.
+// The score is re-encoded against the full [-90, 90] latitude range first,
+// since the stored value uses the Web Mercator limits.
+func String(bits uint64) string {
+	const alphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+	longitude, latitude := DecodeWGS84(bits)
+	hash, _ := Encode(Range{-180, 180}, Range{-90, 90}, longitude, latitude, Step)
+
+	buf := make([]byte, 11)
+	for i := range buf {
+		idx := 0
+		if i < 10 {
+			idx = int(hash.Bits>>(52-(i+1)*5)) & 0x1f
+		}
+		buf[i] = alphabet[idx]
+	}
+	return string(buf)
+}
+
+// Distance returns the haversine distance in meters between two points.
+func Distance(lon1, lat1, lon2, lat2 float64) float64 {
+	lat1r, lon1r := degRad(lat1), degRad(lon1)
+	lat2r, lon2r := degRad(lat2), degRad(lon2)
+	v := math.Sin((lon2r - lon1r) / 2)
+	if v == 0 {
+		return latDistance(lat1, lat2)
+	}
+	u := math.Sin((lat2r - lat1r) / 2)
+	a := u*u + math.Cos(lat1r)*math.Cos(lat2r)*v*v
+	return 2 * earthRadiusInMeters * math.Asin(math.Sqrt(a))
+}
+
+// DistanceIfInRectangle returns the distance from the center (x1, y1) to
+// (x2, y2) if the point lies inside the width by height box, in meters.
+func DistanceIfInRectangle(widthM, heightM, x1, y1, x2, y2 float64) (float64, bool) {
+	if latDistance(y2, y1) > heightM/2 {
+		return 0, false
+	}
+	if Distance(x2, y2, x1, y2) > widthM/2 {
+		return 0, false
+	}
+	return Distance(x1, y1, x2, y2), true
+}
+
+func latDistance(lat1, lat2 float64) float64 {
+	return earthRadiusInMeters * math.Abs(degRad(lat2)-degRad(lat1))
+}
+
+// AreasByShape returns the cells to scan for a search centered on
+// (longitude, latitude). radiusM is the search radius, or half the box
+// diagonal, and halfWidthM/halfHeightM bound the shape, all in meters.
+func AreasByShape(longitude, latitude, radiusM, halfWidthM, halfHeightM float64) Radius {
+	latDelta := radDeg(halfHeightM / earthRadiusInMeters)
+	longDeltaTop := radDeg(halfWidthM / earthRadiusInMeters / math.Cos(degRad(latitude+latDelta)))
+	longDeltaBottom := radDeg(halfWidthM / earthRadiusInMeters / math.Cos(degRad(latitude-latDelta)))
+
+	// The hemispheres bend in opposite directions, so the widest edge of the
+	// box differs.
+	minLon, maxLon := longitude-longDeltaTop, longitude+longDeltaTop
+	if latitude < 0 {
+		minLon, maxLon = longitude-longDeltaBottom, longitude+longDeltaBottom
+	}
+	minLat, maxLat := latitude-latDelta, latitude+latDelta
+
+	steps := estimateStepsByRadius(radiusM, latitude)
+	hash, _ := Encode(WGS84Long, WGS84Lat, longitude, latitude, steps)
+	neighbors := neighborsOf(hash)
+	area := Decode(WGS84Long, WGS84Lat, hash)
+
+	// Near the edge of the cell the neighbors may not cover the whole
+	// search area, in which case a larger cell is needed.
+	north := Decode(WGS84Long, WGS84Lat, neighbors.North)
+	south := Decode(WGS84Long, WGS84Lat, neighbors.South)
+	east := Decode(WGS84Long, WGS84Lat, neighbors.East)
+	west := Decode(WGS84Long, WGS84Lat, neighbors.West)
+	decreaseStep := north.Latitude.Max < maxLat || south.Latitude.Min > minLat ||
+		east.Longitude.Max < maxLon || west.Longitude.Min > minLon
+
+	if steps > 1 && decreaseStep {
+		steps--
+		hash, _ = Encode(WGS84Long, WGS84Lat, longitude, latitude, steps)
+		neighbors = neighborsOf(hash)
+		area = Decode(WGS84Long, WGS84Lat, hash)
+	}
+
+	// Drop neighbors that cannot contain matches.
+	if steps >= 2 {
+		if area.Latitude.Min < minLat {
+			neighbors.South, neighbors.SouthWest, neighbors.SouthEast = Bits{}, Bits{}, Bits{}
+		}
+		if area.Latitude.Max > maxLat {
+			neighbors.North, neighbors.NorthEast, neighbors.NorthWest = Bits{}, Bits{}, Bits{}
+		}
+		if area.Longitude.Min < minLon {
+			neighbors.West, neighbors.SouthWest, neighbors.NorthWest = Bits{}, Bits{}, Bits{}
+		}
+		if area.Longitude.Max > maxLon {
+			neighbors.East, neighbors.SouthEast, neighbors.NorthEast = Bits{}, Bits{}, Bits{}
+		}
+	}
+
+	return Radius{Hash: hash, Area: area, Neighbors: neighbors}
+}
+
+// ScoreRange returns the [min, max) score interval covered by hash at full
+// precision.
+func ScoreRange(hash Bits) (uint64, uint64) {
+	shift := 2 * (Step - uint(hash.Step))
+	return hash.Bits << shift, (hash.Bits + 1) << shift
+}
+
+func estimateStepsByRadius(rangeMeters, latitude float64) uint8 {
+	if rangeMeters == 0 {
+		return Step
+	}
+	step := 1
+	for rangeMeters < mercatorMax {
+		rangeMeters *= 2
+		step++
+	}
+	// Make sure the range is included in most of the base cases.
+	step -= 2
+
+	// Cells get narrower towards the poles.
+	if latitude > 66 || latitude < -66 {
+		step--
+		if latitude > 80 || latitude < -80 {
+			step--
+		}
+	}
+
+	return uint8(max(1, min(Step, step)))
+}
+
+func neighborsOf(hash Bits) Neighbors {
+	move := func(dx, dy int) Bits {
+		return moveY(moveX(hash, dx), dy)
+	}
+	return Neighbors{
+		East:      move(1, 0),
+		West:      move(-1, 0),
+		South:     move(0, -1),
+		North:     move(0, 1),
+		NorthWest: move(-1, 1),
+		SouthWest: move(-1, -1),
+		NorthEast: move(1, 1),
+		SouthEast: move(1, -1),
+	}
+}
+
+func moveX(hash Bits, d int) Bits {
+	if d == 0 {
+		return hash
+	}
+	x := hash.Bits & 0xaaaaaaaaaaaaaaaa
+	y := hash.Bits & 0x5555555555555555
+	zz := uint64(0x5555555555555555) >> (64 - uint(hash.Step)*2)
+	if d > 0 {
+		x = x + (zz + 1)
+	} else {
+		x = x | zz
+		x = x - (zz + 1)
+	}
+	x &= uint64(0xaaaaaaaaaaaaaaaa) >> (64 - uint(hash.Step)*2)
+	return Bits{Bits: x | y, Step: hash.Step}
+}
+
+func moveY(hash Bits, d int) Bits {
+	if d == 0 {
+		return hash
+	}
+	x := hash.Bits & 0xaaaaaaaaaaaaaaaa
+	y := hash.Bits & 0x5555555555555555
+	zz := uint64(0xaaaaaaaaaaaaaaaa) >> (64 - uint(hash.Step)*2)
+	if d > 0 {
+		y = y + (zz + 1)
+	} else {
+		y = y | zz
+		y = y - (zz + 1)
+	}
+	y &= uint64(0x5555555555555555) >> (64 - uint(hash.Step)*2)
+	return Bits{Bits: x | y, Step: hash.Step}
+}
+
+// interleave64 places the bits of x at even positions and the bits of y at
+// odd positions.
+func interleave64(x, y uint32) uint64 {
+	var result uint64
+	for i := 0; i < 32; i++ {
+		result |= uint64(x>>i&1) << (2 * i)
+		result |= uint64(y>>i&1) << (2*i + 1)
+	}
+	return result
+}
+
+// deinterleave64 returns the even bits in the low half and the odd bits in
+// the high half.
+func deinterleave64(interleaved uint64) uint64 {
+	var x, y uint64
+	for i := 0; i < 32; i++ {
+		x |= (interleaved >> (2 * i) & 1) << i
+		y |= (interleaved >> (2*i + 1) & 1) << i
+	}
+	return x | y<<32
+}
+
+func degRad(deg float64) float64 {
+	return deg * (math.Pi / 180.0)
+}
+
+func radDeg(rad float64) float64 {
+	return rad / (math.Pi / 180.0)
+}
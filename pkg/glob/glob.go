@@ -0,0 +1,97 @@
+package glob
+
+// Match reports whether s matches the Redis-style glob pattern. It supports
+// '*', '?', character classes such as [abc], [^a-z] and backslash escapes,
+// following the semantics of stringmatchlen in Redis.
+func Match(pattern, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			// Collapse consecutive stars.
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if Match(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+			s = s[1:]
+		case '[':
+			if len(s) == 0 {
+				return false
+			}
+			var matched bool
+			matched, pattern = matchClass(pattern[1:], s[0])
+			if !matched {
+				return false
+			}
+			s = s[1:]
+			// matchClass leaves pattern on the closing bracket.
+		case '\\':
+			if len(pattern) >= 2 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if len(s) == 0 || pattern[0] != s[0] {
+				return false
+			}
+			s = s[1:]
+		}
+		if len(pattern) > 0 {
+			pattern = pattern[1:]
+		}
+	}
+	return len(s) == 0
+}
+
+// matchClass matches c against the character class starting right after '['.
+// It returns whether c matched and the pattern positioned on the closing ']'
+// (or on the last byte if the class is unterminated).
+func matchClass(pattern string, c byte) (bool, string) {
+	negate := false
+	if len(pattern) > 0 && pattern[0] == '^' {
+		negate = true
+		pattern = pattern[1:]
+	}
+
+	matched := false
+	for {
+		if len(pattern) == 0 {
+			// Unterminated class: Redis treats the end of the pattern as ']'.
+			return matched != negate, "]"
+		}
+		switch {
+		case pattern[0] == '\\' && len(pattern) >= 2:
+			pattern = pattern[1:]
+			if pattern[0] == c {
+				matched = true
+			}
+		case pattern[0] == ']':
+			return matched != negate, pattern
+		case len(pattern) >= 3 && pattern[1] == '-':
+			start, end := pattern[0], pattern[2]
+			if start > end {
+				start, end = end, start
+			}
+			if c >= start && c <= end {
+				matched = true
+			}
+			pattern = pattern[2:]
+		default:
+			if pattern[0] == c {
+				matched = true
+			}
+		}
+		pattern = pattern[1:]
+	}
+}
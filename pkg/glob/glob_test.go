@@ -0,0 +1,38 @@
+package glob
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	testCases := []struct {
+		pattern  string
+		input    string
+		expected bool
+	}{
+		{pattern: "*", input: "", expected: true},
+		{pattern: "*", input: "anything", expected: true},
+		{pattern: "h?llo", input: "hello", expected: true},
+		{pattern: "h?llo", input: "hllo", expected: false},
+		{pattern: "h*llo", input: "heeeello", expected: true},
+		{pattern: "h*llo", input: "hello world", expected: false},
+		{pattern: "h[ae]llo", input: "hallo", expected: true},
+		{pattern: "h[ae]llo", input: "hillo", expected: false},
+		{pattern: "h[^e]llo", input: "hallo", expected: true},
+		{pattern: "h[^e]llo", input: "hello", expected: false},
+		{pattern: "h[a-c]llo", input: "hbllo", expected: true},
+		{pattern: "h[a-c]llo", input: "hdllo", expected: false},
+		{pattern: "news.*", input: "news.sport", expected: true},
+		{pattern: "news.*", input: "news", expected: false},
+		{pattern: "a\\*b", input: "a*b", expected: true},
+		{pattern: "a\\*b", input: "axb", expected: false},
+		{pattern: "user:*:name", input: "user:42:name", expected: true},
+		{pattern: "user:*:name", input: "user:42:email", expected: false},
+		{pattern: "", input: "", expected: true},
+		{pattern: "", input: "a", expected: false},
+	}
+
+	for _, tc := range testCases {
+		if result := Match(tc.pattern, tc.input); result != tc.expected {
+			t.Errorf("Match(%q, %q) = %v, expected %v", tc.pattern, tc.input, result, tc.expected)
+		}
+	}
+}
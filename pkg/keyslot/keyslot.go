@@ -0,0 +1,36 @@
+// Package keyslot maps keys to Redis Cluster hash slots.
+package keyslot
+
+import "strings"
+
+// Slots is the number of hash slots in a cluster.
+const Slots = 16384
+
+// Slot returns the hash slot of key. If the key contains a non-empty
+// "{...}" hash tag only the tag is hashed, so related keys can be forced
+// into the same slot.
+func Slot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return int(CRC16(key)) & (Slots - 1)
+}
+
+// CRC16 computes the CRC-16/XMODEM checksum used by Redis Cluster
+// (polynomial 0x1021, initial value 0).
+func CRC16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for range 8 {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,37 @@
+package keyslot
+
+import "testing"
+
+func TestCRC16(t *testing.T) {
+	if crc := CRC16("123456789"); crc != 0x31c3 {
+		t.Errorf("expected 0x31c3, got %#x", crc)
+	}
+}
+
+func TestSlot(t *testing.T) {
+	tests := []struct {
+		key  string
+		slot int
+	}{
+		{"foo", 12182},
+		{"bar", 5061},
+		{"hello", 866},
+		{"", 0},
+		{"{user1000}.following", 3443},
+		{"{user1000}.followers", 3443},
+		{"user1000", 3443},
+		{"foo{{bar}}zap", Slot("{bar")},
+		{"foo{bar}{zap}", Slot("bar")},
+	}
+
+	for _, tt := range tests {
+		if slot := Slot(tt.key); slot != tt.slot {
+			t.Errorf("Slot(%q): expected %d, got %d", tt.key, tt.slot, slot)
+		}
+	}
+
+	// An empty tag hashes the whole key.
+	if Slot("foo{}{bar}") != int(CRC16("foo{}{bar}"))&(Slots-1) {
+		t.Error("expected an empty hash tag to be ignored")
+	}
+}
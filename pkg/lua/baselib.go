@@ -0,0 +1,242 @@
+package lua
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Argument checking for library functions. Positions are 1-based, like in
+// Lua's error messages.
+
+func argError(l *State, pos int, fname, msg string) {
+	l.RaiseError("bad argument #%d to '%s' (%s)", pos, fname, msg)
+}
+
+func typeError(l *State, args []Value, pos int, fname, expected string) {
+	got := "no value"
+	if pos <= len(args) {
+		got = TypeName(args[pos-1])
+	}
+	argError(l, pos, fname, expected+" expected, got "+got)
+}
+
+// CheckTable returns argument pos, raising an error if it is not a table.
+func CheckTable(l *State, args []Value, pos int, fname string) *Table {
+	t, ok := nth(args, pos-1).(*Table)
+	if !ok {
+		typeError(l, args, pos, fname, "table")
+	}
+	return t
+}
+
+// CheckNumber returns argument pos converted to a number.
+func CheckNumber(l *State, args []Value, pos int, fname string) float64 {
+	n, ok := ToNumber(nth(args, pos-1))
+	if !ok {
+		typeError(l, args, pos, fname, "number")
+	}
+	return n
+}
+
+// CheckInt returns argument pos converted to an integer.
+func CheckInt(l *State, args []Value, pos int, fname string) int {
+	return int(CheckNumber(l, args, pos, fname))
+}
+
+// OptInt is like CheckInt, returning def if the argument is missing or nil.
+func OptInt(l *State, args []Value, pos int, fname string, def int) int {
+	if nth(args, pos-1) == nil {
+		return def
+	}
+	return CheckInt(l, args, pos, fname)
+}
+
+// CheckString returns argument pos, converting numbers to strings.
+func CheckString(l *State, args []Value, pos int, fname string) string {
+	s, ok := concatString(nth(args, pos-1))
+	if !ok {
+		typeError(l, args, pos, fname, "string")
+	}
+	return s
+}
+
+func checkAny(l *State, args []Value, pos int, fname string) Value {
+	if pos > len(args) {
+		argError(l, pos, fname, "value expected")
+	}
+	return args[pos-1]
+}
+
+func openBase(l *State) {
+	for name, fn := range map[string]func(*State, []Value) []Value{
+		"assert":   baseAssert,
+		"error":    baseError,
+		"ipairs":   baseIpairs,
+		"next":     baseNext,
+		"pairs":    basePairs,
+		"pcall":    basePcall,
+		"xpcall":   baseXpcall,
+		"rawequal": baseRawequal,
+		"rawget":   baseRawget,
+		"rawset":   baseRawset,
+		"select":   baseSelect,
+		"tonumber": baseTonumber,
+		"tostring": baseTostring,
+		"type":     baseType,
+		"unpack":   baseUnpack,
+	} {
+		l.Register(name, fn)
+	}
+	l.Globals.Set("_G", l.Globals)
+	l.Globals.Set("_VERSION", "Lua 5.1")
+}
+
+func baseAssert(l *State, args []Value) []Value {
+	if !Truthy(checkAny(l, args, 1, "assert")) {
+		if len(args) > 1 {
+			l.Raise(args[1])
+		}
+		l.RaiseError("assertion failed!")
+	}
+	return args
+}
+
+// baseError raises its argument. A string message gets the position of the
+// error prefixed, unless level is 0.
+func baseError(l *State, args []Value) []Value {
+	v := nth(args, 0)
+	if s, ok := v.(string); ok && OptInt(l, args, 2, "error", 1) > 0 {
+		v = l.where() + s
+	}
+	l.Raise(v)
+	return nil
+}
+
+func baseIpairs(l *State, args []Value) []Value {
+	t := CheckTable(l, args, 1, "ipairs")
+	return []Value{&GoFunction{Name: "ipairs_iterator", Fn: ipairsIterator}, t, 0.0}
+}
+
+func ipairsIterator(l *State, args []Value) []Value {
+	t := CheckTable(l, args, 1, "ipairs")
+	i := CheckNumber(l, args, 2, "ipairs") + 1
+	v := t.Get(i)
+	if v == nil {
+		return []Value{nil}
+	}
+	return []Value{i, v}
+}
+
+func baseNext(l *State, args []Value) []Value {
+	t := CheckTable(l, args, 1, "next")
+	key, value, ok := t.Next(nth(args, 1))
+	if !ok {
+		l.RaiseError("invalid key to 'next'")
+	}
+	if key == nil {
+		return []Value{nil}
+	}
+	return []Value{key, value}
+}
+
+var nextFunction = &GoFunction{Name: "next", Fn: baseNext}
+
+func basePairs(l *State, args []Value) []Value {
+	t := CheckTable(l, args, 1, "pairs")
+	return []Value{nextFunction, t, nil}
+}
+
+func basePcall(l *State, args []Value) []Value {
+	fn := checkAny(l, args, 1, "pcall")
+	results, err := l.pcall(fn, args[1:])
+	if err != nil {
+		return []Value{false, err.Value}
+	}
+	return append([]Value{true}, results...)
+}
+
+func baseXpcall(l *State, args []Value) []Value {
+	fn := checkAny(l, args, 1, "xpcall")
+	handler := nth(args, 1)
+	results, err := l.pcall(fn, nil)
+	if err != nil {
+		return append([]Value{false}, l.call(handler, []Value{err.Value}, l.line, "")...)
+	}
+	return append([]Value{true}, results...)
+}
+
+func baseRawequal(l *State, args []Value) []Value {
+	return []Value{rawEqual(checkAny(l, args, 1, "rawequal"), checkAny(l, args, 2, "rawequal"))}
+}
+
+func baseRawget(l *State, args []Value) []Value {
+	return []Value{CheckTable(l, args, 1, "rawget").Get(checkAny(l, args, 2, "rawget"))}
+}
+
+func baseRawset(l *State, args []Value) []Value {
+	t := CheckTable(l, args, 1, "rawset")
+	l.rawSet(t, checkAny(l, args, 2, "rawset"), checkAny(l, args, 3, "rawset"), l.line)
+	return []Value{t}
+}
+
+func baseSelect(l *State, args []Value) []Value {
+	if s, ok := nth(args, 0).(string); ok && s == "#" {
+		return []Value{float64(len(args) - 1)}
+	}
+	n := CheckInt(l, args, 1, "select")
+	if n < 0 {
+		n += len(args)
+	}
+	if n < 1 {
+		argError(l, 1, "select", "index out of range")
+	}
+	if n >= len(args) {
+		return nil
+	}
+	return args[n:]
+}
+
+func baseTonumber(l *State, args []Value) []Value {
+	base := OptInt(l, args, 2, "tonumber", 10)
+	if base == 10 {
+		n, ok := ToNumber(checkAny(l, args, 1, "tonumber"))
+		if !ok {
+			return []Value{nil}
+		}
+		return []Value{n}
+	}
+	if base < 2 || base > 36 {
+		argError(l, 2, "tonumber", "base out of range")
+	}
+	s := strings.TrimSpace(CheckString(l, args, 1, "tonumber"))
+	n, err := strconv.ParseInt(s, base, 64)
+	if err != nil {
+		return []Value{nil}
+	}
+	return []Value{float64(n)}
+}
+
+func baseTostring(l *State, args []Value) []Value {
+	return []Value{ToString(checkAny(l, args, 1, "tostring"))}
+}
+
+func baseType(l *State, args []Value) []Value {
+	return []Value{TypeName(checkAny(l, args, 1, "type"))}
+}
+
+func baseUnpack(l *State, args []Value) []Value {
+	t := CheckTable(l, args, 1, "unpack")
+	i := OptInt(l, args, 2, "unpack", 1)
+	j := OptInt(l, args, 3, "unpack", t.Len())
+	if i > j {
+		return nil
+	}
+	if j-i >= 1<<20 {
+		l.RaiseError("too many results to unpack")
+	}
+	results := make([]Value, 0, j-i+1)
+	for k := i; k <= j; k++ {
+		results = append(results, t.Get(float64(k)))
+	}
+	return results
+}
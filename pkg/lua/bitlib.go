@@ -0,0 +1,94 @@
+package lua
+
+import (
+	"math"
+	"math/bits"
+	"strings"
+)
+
+// openBit loads the bit library of LuaBitOp, which Redis scripts have:
+// bitwise operations on numbers taken as signed 32-bit integers.
+func openBit(l *State) {
+	l.Globals.Set("bit", NewLib("bit", map[string]func(*State, []Value) []Value{
+		"tobit": func(l *State, args []Value) []Value {
+			return bitResult(checkBit(l, args, 1, "tobit"))
+		},
+		"tohex": bitTohex,
+		"bnot": func(l *State, args []Value) []Value {
+			return bitResult(^checkBit(l, args, 1, "bnot"))
+		},
+		"band": func(l *State, args []Value) []Value {
+			return bitFold(l, args, "band", func(a, b uint32) uint32 { return a & b })
+		},
+		"bor": func(l *State, args []Value) []Value {
+			return bitFold(l, args, "bor", func(a, b uint32) uint32 { return a | b })
+		},
+		"bxor": func(l *State, args []Value) []Value {
+			return bitFold(l, args, "bxor", func(a, b uint32) uint32 { return a ^ b })
+		},
+		"lshift": func(l *State, args []Value) []Value {
+			return bitShift(l, args, "lshift", func(b uint32, n uint) uint32 { return b << n })
+		},
+		"rshift": func(l *State, args []Value) []Value {
+			return bitShift(l, args, "rshift", func(b uint32, n uint) uint32 { return b >> n })
+		},
+		"arshift": func(l *State, args []Value) []Value {
+			return bitShift(l, args, "arshift", func(b uint32, n uint) uint32 { return uint32(int32(b) >> n) })
+		},
+		"rol": func(l *State, args []Value) []Value {
+			return bitShift(l, args, "rol", func(b uint32, n uint) uint32 { return bits.RotateLeft32(b, int(n)) })
+		},
+		"ror": func(l *State, args []Value) []Value {
+			return bitShift(l, args, "ror", func(b uint32, n uint) uint32 { return bits.RotateLeft32(b, -int(n)) })
+		},
+		"bswap": func(l *State, args []Value) []Value {
+			return bitResult(bits.ReverseBytes32(checkBit(l, args, 1, "bswap")))
+		},
+	}))
+}
+
+// checkBit returns argument pos as 32 bits: numbers are rounded to an
+// integer and wrap around modulo 2^32.
+func checkBit(l *State, args []Value, pos int, fname string) uint32 {
+	n := CheckNumber(l, args, pos, fname)
+	return uint32(int64(math.RoundToEven(math.Mod(n, 1<<32))))
+}
+
+func bitResult(b uint32) []Value {
+	return []Value{float64(int32(b))}
+}
+
+func bitFold(l *State, args []Value, fname string, op func(a, b uint32) uint32) []Value {
+	b := checkBit(l, args, 1, fname)
+	for i := 2; i <= len(args); i++ {
+		b = op(b, checkBit(l, args, i, fname))
+	}
+	return bitResult(b)
+}
+
+func bitShift(l *State, args []Value, fname string, op func(b uint32, n uint) uint32) []Value {
+	b := checkBit(l, args, 1, fname)
+	n := checkBit(l, args, 2, fname) & 31
+	return bitResult(op(b, uint(n)))
+}
+
+// bitTohex formats its argument as n hexadecimal digits, 8 by default, in
+// upper case when n is negative.
+func bitTohex(l *State, args []Value) []Value {
+	b := checkBit(l, args, 1, "tohex")
+	n := 8
+	if nth(args, 1) != nil {
+		n = int(int32(checkBit(l, args, 2, "tohex")))
+	}
+	digits := "0123456789abcdef"
+	if n < 0 {
+		n, digits = -n, strings.ToUpper(digits)
+	}
+	n = min(n, 8)
+	hex := make([]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		hex[i] = digits[b&15]
+		b >>= 4
+	}
+	return []Value{string(hex)}
+}
@@ -0,0 +1,212 @@
+package lua
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// jsonNull is the type of cjson.null, which stands for JSON null in decoded
+// tables since nil cannot be stored in them.
+type jsonNull struct{}
+
+// JSONNull is the value of cjson.null.
+var JSONNull = &jsonNull{}
+
+// cjsonMaxDepth is how deeply tables may nest when encoding, like the
+// encode_max_depth default of lua-cjson.
+const cjsonMaxDepth = 1000
+
+// openCJSON loads the cjson library of lua-cjson, which Redis scripts have,
+// with its default settings: sparse arrays are refused, and NaN and
+// infinity cannot be encoded.
+func openCJSON(l *State) {
+	lib := NewLib("cjson", map[string]func(*State, []Value) []Value{
+		"encode": cjsonEncode,
+		"decode": cjsonDecode,
+	})
+	lib.Set("null", JSONNull)
+	l.Globals.Set("cjson", lib)
+}
+
+func cjsonEncode(l *State, args []Value) []Value {
+	v := checkAny(l, args, 1, "encode")
+	var b strings.Builder
+	if err := encodeJSON(&b, v, 0); err != nil {
+		l.RaiseError("%s", err.Error())
+	}
+	return []Value{b.String()}
+}
+
+func encodeJSON(b *strings.Builder, v Value, depth int) error {
+	switch v := v.(type) {
+	case nil, *jsonNull:
+		b.WriteString("null")
+	case bool:
+		b.WriteString(strconv.FormatBool(v))
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return errors.New("Cannot serialise number: must not be NaN or Infinity")
+		}
+		b.WriteString(FormatNumber(v))
+	case string:
+		encodeJSONString(b, v)
+	case *Table:
+		if depth++; depth > cjsonMaxDepth {
+			return errors.New("Cannot serialise, excessive nesting (" + strconv.Itoa(depth) + ")")
+		}
+		return encodeJSONTable(b, v, depth)
+	default:
+		return errors.New("Cannot serialise " + TypeName(v) + ": type not supported")
+	}
+	return nil
+}
+
+// encodeJSONTable encodes t as an array when all its keys are positive
+// integers and it is not too sparse, and as an object otherwise. The empty
+// table is an object.
+func encodeJSONTable(b *strings.Builder, t *Table, depth int) error {
+	n, items, isArray := 0, 0, true
+	for k, _, _ := t.Next(nil); k != nil; k, _, _ = t.Next(k) {
+		items++
+		i, ok := arrayIndex(k)
+		if !ok {
+			isArray = false
+			break
+		}
+		n = max(n, i+1)
+	}
+
+	if isArray && items > 0 {
+		if n > 10 && n > items*2 {
+			return errors.New("Cannot serialise table: excessively sparse array")
+		}
+		b.WriteByte('[')
+		for i := 1; i <= n; i++ {
+			if i > 1 {
+				b.WriteByte(',')
+			}
+			if err := encodeJSON(b, t.Get(float64(i)), depth); err != nil {
+				return err
+			}
+		}
+		b.WriteByte(']')
+		return nil
+	}
+
+	b.WriteByte('{')
+	first := true
+	for k, v, _ := t.Next(nil); k != nil; k, v, _ = t.Next(k) {
+		var key string
+		switch k := k.(type) {
+		case string:
+			key = k
+		case float64:
+			key = FormatNumber(k)
+		default:
+			return errors.New("Cannot serialise table: table key must be a number or string")
+		}
+		if !first {
+			b.WriteByte(',')
+		}
+		first = false
+		encodeJSONString(b, key)
+		b.WriteByte(':')
+		if err := encodeJSON(b, v, depth); err != nil {
+			return err
+		}
+	}
+	b.WriteByte('}')
+	return nil
+}
+
+// encodeJSONString quotes s the way lua-cjson does, which also escapes
+// slashes. Bytes that are not ASCII are copied as they are.
+func encodeJSONString(b *strings.Builder, s string) {
+	const hex = "0123456789abcdef"
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"', '\\', '/':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if c < 0x20 || c == 0x7f {
+				b.WriteString(`\u00`)
+				b.WriteByte(hex[c>>4])
+				b.WriteByte(hex[c&15])
+			} else {
+				b.WriteByte(c)
+			}
+		}
+	}
+	b.WriteByte('"')
+}
+
+func cjsonDecode(l *State, args []Value) []Value {
+	s := CheckString(l, args, 1, "decode")
+	decoder := json.NewDecoder(strings.NewReader(s))
+	decoder.UseNumber()
+	var v any
+	if err := decoder.Decode(&v); err != nil {
+		l.RaiseError("%s", decodeError(err, s))
+	}
+	rest := s[decoder.InputOffset():]
+	if trimmed := strings.TrimLeft(rest, " \t\r\n"); trimmed != "" {
+		l.RaiseError("Expected the end but found invalid token at character %d", len(s)-len(trimmed)+1)
+	}
+	return []Value{fromJSON(v)}
+}
+
+// decodeError words a decoding error like lua-cjson, with 1-based
+// character positions.
+func decodeError(err error, s string) string {
+	var syntax *json.SyntaxError
+	switch {
+	case errors.As(err, &syntax):
+		return "Expected value but found invalid token at character " + strconv.FormatInt(syntax.Offset, 10)
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return "Expected value but found T_END at character " + strconv.Itoa(len(s)+1)
+	default:
+		return err.Error()
+	}
+}
+
+// fromJSON converts a value decoded by encoding/json to Lua.
+func fromJSON(v any) Value {
+	switch v := v.(type) {
+	case nil:
+		return JSONNull
+	case json.Number:
+		f, _ := strconv.ParseFloat(string(v), 64)
+		return f
+	case []any:
+		t := NewTable()
+		for i, element := range v {
+			t.Set(float64(i+1), fromJSON(element))
+		}
+		return t
+	case map[string]any:
+		t := NewTable()
+		for key, element := range v {
+			t.Set(key, fromJSON(element))
+		}
+		return t
+	default:
+		// Strings and booleans.
+		return v
+	}
+}
@@ -0,0 +1,1125 @@
+package lua
+
+import "math"
+
+// The parser compiles source code directly into Go closures. Locals are
+// resolved at compile time to slots of their function's frame; each slot
+// holds a cell, so closures capturing a local share it with the frame.
+
+type funcProto struct {
+	name    string
+	chunk   string
+	nparams int
+	vararg  bool
+	nslots  int
+	body    []execFn
+	upvals  []upvalDesc
+}
+
+// upvalDesc tells a closure where to find one of its upvalues when it is
+// created: in a slot of the enclosing frame or in an upvalue of the
+// enclosing function.
+type upvalDesc struct {
+	fromLocal bool
+	index     int
+}
+
+type frame struct {
+	l       *State
+	slots   []*cell
+	upvals  []*cell
+	varargs []Value
+	ret     []Value
+}
+
+type (
+	evalFn  func(f *frame) Value
+	multiFn func(f *frame) []Value
+	execFn  func(f *frame) ctrl
+)
+
+// ctrl tells a block how its last statement finished.
+type ctrl int
+
+const (
+	ctrlNone ctrl = iota
+	ctrlBreak
+	ctrlReturn
+)
+
+type expKind int
+
+const (
+	expValue expKind = iota
+	expMulti         // a call or "...", which may produce several values
+	expLocal
+	expUpval
+	expGlobal
+	expIndex
+)
+
+// expr is a compiled expression. Variables also keep what is needed to
+// assign to them.
+type expr struct {
+	kind  expKind
+	eval  evalFn
+	multi multiFn
+	index int
+	name  string
+	obj   evalFn
+	key   evalFn
+	// desc names the expression in error messages, e.g. "global 'f'".
+	desc string
+}
+
+type localVar struct {
+	name string
+	slot int
+}
+
+type funcState struct {
+	parent     *funcState
+	proto      *funcProto
+	actives    []localVar
+	upvalNames map[string]int
+	loops      int
+}
+
+type parser struct {
+	lx       *lexer
+	tok      token
+	ahead    token
+	hasAhead bool
+	fs       *funcState
+}
+
+// compile parses a chunk into the prototype of its main function.
+func compile(src, chunk string) (proto *funcProto, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			se, ok := r.(*syntaxError)
+			if !ok {
+				panic(r)
+			}
+			err = &Error{Value: se.msg}
+		}
+	}()
+
+	p := &parser{lx: &lexer{src: src, line: 1, chunk: chunk}}
+	if len(src) > 0 && src[0] == '#' {
+		// Skip a shebang line.
+		for p.lx.pos < len(src) && src[p.lx.pos] != '\n' {
+			p.lx.pos++
+		}
+	}
+	p.next()
+	p.fs = &funcState{proto: &funcProto{name: "main chunk", chunk: chunk, vararg: true}, upvalNames: map[string]int{}}
+	body := p.block()
+	p.check(tokEOF, "<eof>")
+	p.fs.proto.body = body
+	return p.fs.proto, nil
+}
+
+// Parser helpers.
+
+func (p *parser) next() {
+	if p.hasAhead {
+		p.tok, p.hasAhead = p.ahead, false
+		return
+	}
+	p.tok = p.lx.next()
+}
+
+func (p *parser) peek() token {
+	if !p.hasAhead {
+		p.ahead, p.hasAhead = p.lx.next(), true
+	}
+	return p.ahead
+}
+
+func (p *parser) isOp(text string) bool {
+	return p.tok.kind == tokOp && p.tok.text == text
+}
+
+func (p *parser) accept(text string) bool {
+	if p.isOp(text) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(text string) {
+	if !p.accept(text) {
+		p.errorExpected("'" + text + "'")
+	}
+}
+
+// expectMatch expects the token closing what opened at line.
+func (p *parser) expectMatch(closing, opening string, line int) {
+	if p.accept(closing) {
+		return
+	}
+	if line == p.tok.line {
+		p.errorExpected("'" + closing + "'")
+	}
+	p.lx.errorf(p.tok.line, p.tok.text, "'"+closing+"' expected (to close '"+opening+"' at line "+itoa(line)+")")
+}
+
+func (p *parser) check(kind tokenKind, what string) {
+	if p.tok.kind != kind {
+		p.errorExpected(what)
+	}
+}
+
+func (p *parser) errorExpected(what string) {
+	p.lx.errorf(p.tok.line, p.tok.text, what+" expected")
+}
+
+func (p *parser) name() string {
+	p.check(tokName, "<name>")
+	name := p.tok.text
+	p.next()
+	return name
+}
+
+// Scopes.
+
+func (p *parser) declareLocal(name string) int {
+	fs := p.fs
+	slot := fs.proto.nslots
+	fs.proto.nslots++
+	fs.actives = append(fs.actives, localVar{name, slot})
+	return slot
+}
+
+func (fs *funcState) findLocal(name string) (int, bool) {
+	for i := len(fs.actives) - 1; i >= 0; i-- {
+		if fs.actives[i].name == name {
+			return fs.actives[i].slot, true
+		}
+	}
+	return 0, false
+}
+
+func (fs *funcState) findUpval(name string) (int, bool) {
+	if i, found := fs.upvalNames[name]; found {
+		return i, true
+	}
+	if fs.parent == nil {
+		return 0, false
+	}
+	desc := upvalDesc{}
+	if slot, found := fs.parent.findLocal(name); found {
+		desc = upvalDesc{fromLocal: true, index: slot}
+	} else if i, found := fs.parent.findUpval(name); found {
+		desc = upvalDesc{index: i}
+	} else {
+		return 0, false
+	}
+	fs.upvalNames[name] = len(fs.proto.upvals)
+	fs.proto.upvals = append(fs.proto.upvals, desc)
+	return len(fs.proto.upvals) - 1, true
+}
+
+// Statements.
+
+func blockEnds(t token) bool {
+	if t.kind == tokEOF {
+		return true
+	}
+	if t.kind != tokOp {
+		return false
+	}
+	switch t.text {
+	case "end", "else", "elseif", "until":
+		return true
+	}
+	return false
+}
+
+// block parses statements in a new scope.
+func (p *parser) block() []execFn {
+	active := len(p.fs.actives)
+	stmts := p.statements()
+	p.fs.actives = p.fs.actives[:active]
+	return stmts
+}
+
+func (p *parser) statements() []execFn {
+	var stmts []execFn
+	for !blockEnds(p.tok) {
+		if p.isOp("return") {
+			stmts = append(stmts, p.returnStat())
+			break
+		}
+		if stmt := p.statement(); stmt != nil {
+			stmts = append(stmts, stmt)
+		}
+	}
+	return stmts
+}
+
+func execBlock(stmts []execFn, f *frame) ctrl {
+	for _, stmt := range stmts {
+		if c := stmt(f); c != ctrlNone {
+			return c
+		}
+	}
+	return ctrlNone
+}
+
+// atLine makes a statement record its line, which error() reports.
+func atLine(line int, stmt execFn) execFn {
+	return func(f *frame) ctrl {
+		f.l.line = line
+		return stmt(f)
+	}
+}
+
+func (p *parser) statement() execFn {
+	line := p.tok.line
+	if p.tok.kind == tokOp {
+		switch p.tok.text {
+		case ";":
+			p.next()
+			return nil
+		case "if":
+			return atLine(line, p.ifStat())
+		case "while":
+			return atLine(line, p.whileStat())
+		case "do":
+			p.next()
+			body := p.block()
+			p.expectMatch("end", "do", line)
+			return func(f *frame) ctrl { return execBlock(body, f) }
+		case "for":
+			return atLine(line, p.forStat())
+		case "repeat":
+			return atLine(line, p.repeatStat())
+		case "function":
+			return atLine(line, p.functionStat())
+		case "local":
+			p.next()
+			if p.accept("function") {
+				return atLine(line, p.localFunctionStat())
+			}
+			return atLine(line, p.localStat())
+		case "break":
+			p.next()
+			if p.fs.loops == 0 {
+				p.lx.errorf(line, "break", "no loop to break")
+			}
+			return func(*frame) ctrl { return ctrlBreak }
+		}
+	}
+	return atLine(line, p.exprStat())
+}
+
+func (p *parser) returnStat() execFn {
+	line := p.tok.line
+	p.next()
+	var values multiFn
+	if !blockEnds(p.tok) && !p.isOp(";") {
+		values = exprList(p.exprList())
+	}
+	p.accept(";")
+	if !blockEnds(p.tok) {
+		p.errorExpected("'end'")
+	}
+	return atLine(line, func(f *frame) ctrl {
+		if values != nil {
+			f.ret = values(f)
+		} else {
+			f.ret = nil
+		}
+		return ctrlReturn
+	})
+}
+
+func (p *parser) ifStat() execFn {
+	line := p.tok.line
+	var conds []evalFn
+	var blocks [][]execFn
+	var elseBlock []execFn
+	p.next()
+	for {
+		conds = append(conds, p.expr().eval)
+		p.expect("then")
+		blocks = append(blocks, p.block())
+		if p.accept("elseif") {
+			continue
+		}
+		if p.accept("else") {
+			elseBlock = p.block()
+		}
+		p.expectMatch("end", "if", line)
+		break
+	}
+	return func(f *frame) ctrl {
+		for i, cond := range conds {
+			if Truthy(cond(f)) {
+				return execBlock(blocks[i], f)
+			}
+		}
+		return execBlock(elseBlock, f)
+	}
+}
+
+// loopBody parses a loop body, inside which break is allowed.
+func (p *parser) loopBody() []execFn {
+	p.fs.loops++
+	body := p.block()
+	p.fs.loops--
+	return body
+}
+
+func (p *parser) whileStat() execFn {
+	line := p.tok.line
+	p.next()
+	cond := p.expr().eval
+	p.expect("do")
+	body := p.loopBody()
+	p.expectMatch("end", "while", line)
+	return func(f *frame) ctrl {
+		for {
+			f.l.tick()
+			if !Truthy(cond(f)) {
+				return ctrlNone
+			}
+			switch execBlock(body, f) {
+			case ctrlBreak:
+				return ctrlNone
+			case ctrlReturn:
+				return ctrlReturn
+			}
+		}
+	}
+}
+
+func (p *parser) repeatStat() execFn {
+	line := p.tok.line
+	p.next()
+	// The condition can see the locals of the body.
+	active := len(p.fs.actives)
+	p.fs.loops++
+	body := p.statements()
+	p.fs.loops--
+	p.expectMatch("until", "repeat", line)
+	cond := p.expr().eval
+	p.fs.actives = p.fs.actives[:active]
+	return func(f *frame) ctrl {
+		for {
+			f.l.tick()
+			switch execBlock(body, f) {
+			case ctrlBreak:
+				return ctrlNone
+			case ctrlReturn:
+				return ctrlReturn
+			}
+			if Truthy(cond(f)) {
+				return ctrlNone
+			}
+		}
+	}
+}
+
+func (p *parser) forStat() execFn {
+	line := p.tok.line
+	p.next()
+	first := p.name()
+	if p.isOp("=") {
+		return p.numericFor(first, line)
+	}
+	return p.genericFor(first, line)
+}
+
+func (p *parser) numericFor(name string, line int) execFn {
+	p.next()
+	start := p.expr().eval
+	p.expect(",")
+	limit := p.expr().eval
+	var step evalFn
+	if p.accept(",") {
+		step = p.expr().eval
+	}
+	p.expect("do")
+	active := len(p.fs.actives)
+	slot := p.declareLocal(name)
+	body := p.loopBody()
+	p.fs.actives = p.fs.actives[:active]
+	p.expectMatch("end", "for", line)
+
+	return func(f *frame) ctrl {
+		forNumber := func(v Value, what string) float64 {
+			n, ok := ToNumber(v)
+			if !ok {
+				f.l.runtimeError(line, "'for' "+what+" must be a number")
+			}
+			return n
+		}
+		i := forNumber(start(f), "initial value")
+		stop := forNumber(limit(f), "limit")
+		inc := 1.0
+		if step != nil {
+			inc = forNumber(step(f), "step")
+		}
+		for ; (inc > 0 && i <= stop) || (inc <= 0 && i >= stop); i += inc {
+			f.l.tick()
+			f.slots[slot] = &cell{i}
+			switch execBlock(body, f) {
+			case ctrlBreak:
+				return ctrlNone
+			case ctrlReturn:
+				return ctrlReturn
+			}
+		}
+		return ctrlNone
+	}
+}
+
+func (p *parser) genericFor(first string, line int) execFn {
+	names := []string{first}
+	for p.accept(",") {
+		names = append(names, p.name())
+	}
+	p.expect("in")
+	values := exprList(p.exprList())
+	p.expect("do")
+	active := len(p.fs.actives)
+	slots := make([]int, len(names))
+	for i, name := range names {
+		slots[i] = p.declareLocal(name)
+	}
+	body := p.loopBody()
+	p.fs.actives = p.fs.actives[:active]
+	p.expectMatch("end", "for", line)
+
+	return func(f *frame) ctrl {
+		init := values(f)
+		iter, state, control := nth(init, 0), nth(init, 1), nth(init, 2)
+		for {
+			f.l.tick()
+			results := f.l.call(iter, []Value{state, control}, line, "for iterator")
+			control = nth(results, 0)
+			if control == nil {
+				return ctrlNone
+			}
+			for i, slot := range slots {
+				f.slots[slot] = &cell{nth(results, i)}
+			}
+			switch execBlock(body, f) {
+			case ctrlBreak:
+				return ctrlNone
+			case ctrlReturn:
+				return ctrlReturn
+			}
+		}
+	}
+}
+
+// functionStat parses "function a.b.c:m() ... end".
+func (p *parser) functionStat() execFn {
+	line := p.tok.line
+	p.next()
+	name := p.name()
+	target := p.variable(name)
+	fullName := name
+	isMethod := false
+	for p.isOp(".") || p.isOp(":") {
+		isMethod = p.isOp(":")
+		p.next()
+		field := p.name()
+		fullName += map[bool]string{true: ":", false: "."}[isMethod] + field
+		target = p.indexed(target, constant(field), "field '"+field+"'")
+		if isMethod {
+			break
+		}
+	}
+	fn := p.body(isMethod, fullName, line)
+	assign := p.assigner(target)
+	return func(f *frame) ctrl {
+		assign(f, fn.eval(f))
+		return ctrlNone
+	}
+}
+
+func (p *parser) localFunctionStat() execFn {
+	line := p.tok.line
+	name := p.name()
+	// The function can refer to itself.
+	slot := p.declareLocal(name)
+	fn := p.body(false, name, line)
+	return func(f *frame) ctrl {
+		c := &cell{}
+		f.slots[slot] = c
+		c.v = fn.eval(f)
+		return ctrlNone
+	}
+}
+
+func (p *parser) localStat() execFn {
+	names := []string{p.name()}
+	for p.accept(",") {
+		names = append(names, p.name())
+	}
+	var exprs []*expr
+	if p.accept("=") {
+		exprs = p.exprList()
+	}
+
+	slots := make([]int, len(names))
+	for i, name := range names {
+		slots[i] = p.declareLocal(name)
+	}
+
+	if len(slots) == 1 && len(exprs) == 1 {
+		slot, value := slots[0], exprs[0].eval
+		return func(f *frame) ctrl {
+			f.slots[slot] = &cell{value(f)}
+			return ctrlNone
+		}
+	}
+	values := exprList(exprs)
+	return func(f *frame) ctrl {
+		var vals []Value
+		if values != nil {
+			vals = values(f)
+		}
+		for i, slot := range slots {
+			f.slots[slot] = &cell{nth(vals, i)}
+		}
+		return ctrlNone
+	}
+}
+
+func (p *parser) exprStat() execFn {
+	e := p.suffixedExpr()
+	if !p.isOp("=") && !p.isOp(",") {
+		if e.kind != expMulti || e.multi == nil || e.desc == "..." {
+			p.lx.errorf(p.tok.line, p.tok.text, "syntax error")
+		}
+		call := e.multi
+		return func(f *frame) ctrl {
+			call(f)
+			return ctrlNone
+		}
+	}
+
+	targets := []*expr{e}
+	for p.accept(",") {
+		targets = append(targets, p.suffixedExpr())
+	}
+	p.expect("=")
+	exprs := p.exprList()
+
+	assigners := make([]func(*frame, Value), len(targets))
+	for i, target := range targets {
+		assigners[i] = p.assigner(target)
+	}
+	if len(targets) == 1 && len(exprs) == 1 {
+		assign, value := assigners[0], exprs[0].eval
+		return func(f *frame) ctrl {
+			assign(f, value(f))
+			return ctrlNone
+		}
+	}
+	values := exprList(exprs)
+	return func(f *frame) ctrl {
+		vals := values(f)
+		for i, assign := range assigners {
+			assign(f, nth(vals, i))
+		}
+		return ctrlNone
+	}
+}
+
+func (p *parser) assigner(e *expr) func(*frame, Value) {
+	switch e.kind {
+	case expLocal:
+		slot := e.index
+		return func(f *frame, v Value) { f.slots[slot].v = v }
+	case expUpval:
+		i := e.index
+		return func(f *frame, v Value) { f.upvals[i].v = v }
+	case expGlobal:
+		name, line := e.name, p.tok.line
+		return func(f *frame, v Value) { f.l.setGlobal(name, v, line) }
+	case expIndex:
+		obj, key, line := e.obj, e.key, p.tok.line
+		return func(f *frame, v Value) { f.l.setIndex(obj(f), key(f), v, line) }
+	default:
+		p.lx.errorf(p.tok.line, p.tok.text, "syntax error")
+		return nil
+	}
+}
+
+// Expressions.
+
+func constant(v Value) *expr {
+	return &expr{kind: expValue, eval: func(*frame) Value { return v }}
+}
+
+func (p *parser) exprList() []*expr {
+	exprs := []*expr{p.expr()}
+	for p.accept(",") {
+		exprs = append(exprs, p.expr())
+	}
+	return exprs
+}
+
+// exprList evaluates expressions to a list of values, expanding the last
+// one if it is a call or "...".
+func exprList(exprs []*expr) multiFn {
+	if len(exprs) == 0 {
+		return nil
+	}
+	last := exprs[len(exprs)-1]
+	if last.kind == expMulti {
+		if len(exprs) == 1 {
+			return last.multi
+		}
+		init := exprs[:len(exprs)-1]
+		return func(f *frame) []Value {
+			vals := make([]Value, 0, len(exprs))
+			for _, e := range init {
+				vals = append(vals, e.eval(f))
+			}
+			return append(vals, last.multi(f)...)
+		}
+	}
+	return func(f *frame) []Value {
+		vals := make([]Value, len(exprs))
+		for i, e := range exprs {
+			vals[i] = e.eval(f)
+		}
+		return vals
+	}
+}
+
+func nth(vals []Value, i int) Value {
+	if i < len(vals) {
+		return vals[i]
+	}
+	return nil
+}
+
+// Operator priorities, as {left, right}, from the Lua 5.1 reference parser.
+var binaryPriority = map[string][2]int{
+	"or": {1, 1}, "and": {2, 2},
+	"<": {3, 3}, ">": {3, 3}, "<=": {3, 3}, ">=": {3, 3}, "~=": {3, 3}, "==": {3, 3},
+	"..": {5, 4}, "+": {6, 6}, "-": {6, 6},
+	"*": {7, 7}, "/": {7, 7}, "%": {7, 7}, "^": {10, 9},
+}
+
+const unaryPriority = 8
+
+func (p *parser) expr() *expr {
+	return p.subExpr(0)
+}
+
+func (p *parser) subExpr(limit int) *expr {
+	var e *expr
+	if p.tok.kind == tokOp && (p.tok.text == "not" || p.tok.text == "-" || p.tok.text == "#") {
+		op, line := p.tok.text, p.tok.line
+		p.next()
+		e = unaryOp(op, p.subExpr(unaryPriority), line)
+	} else {
+		e = p.simpleExpr()
+	}
+
+	for p.tok.kind == tokOp {
+		op := p.tok.text
+		priority, isBinary := binaryPriority[op]
+		if !isBinary || priority[0] <= limit {
+			break
+		}
+		line := p.tok.line
+		p.next()
+		e = binaryOp(op, e, p.subExpr(priority[1]), line)
+	}
+	return e
+}
+
+func (p *parser) simpleExpr() *expr {
+	t := p.tok
+	switch {
+	case t.kind == tokNumber:
+		p.next()
+		return constant(t.num)
+	case t.kind == tokString:
+		p.next()
+		return constant(t.text)
+	case t.kind == tokOp:
+		switch t.text {
+		case "nil":
+			p.next()
+			return constant(nil)
+		case "true":
+			p.next()
+			return constant(true)
+		case "false":
+			p.next()
+			return constant(false)
+		case "...":
+			if !p.fs.proto.vararg {
+				p.lx.errorf(t.line, "...", "cannot use '...' outside a vararg function")
+			}
+			p.next()
+			return &expr{
+				kind:  expMulti,
+				desc:  "...",
+				eval:  func(f *frame) Value { return nth(f.varargs, 0) },
+				multi: func(f *frame) []Value { return f.varargs },
+			}
+		case "{":
+			return p.tableConstructor()
+		case "function":
+			p.next()
+			return p.body(false, "anonymous", t.line)
+		}
+	}
+	return p.suffixedExpr()
+}
+
+func (p *parser) primaryExpr() *expr {
+	switch {
+	case p.tok.kind == tokName:
+		name := p.tok.text
+		p.next()
+		return p.variable(name)
+	case p.isOp("("):
+		line := p.tok.line
+		p.next()
+		inner := p.expr()
+		p.expectMatch(")", "(", line)
+		// Parentheses truncate to a single value.
+		return &expr{kind: expValue, eval: inner.eval}
+	default:
+		p.lx.errorf(p.tok.line, p.tok.text, "unexpected symbol")
+		return nil
+	}
+}
+
+// variable resolves a name to a local, an upvalue or a global.
+func (p *parser) variable(name string) *expr {
+	if slot, found := p.fs.findLocal(name); found {
+		return &expr{kind: expLocal, index: slot, desc: "local '" + name + "'",
+			eval: func(f *frame) Value { return f.slots[slot].v }}
+	}
+	if i, found := p.fs.findUpval(name); found {
+		return &expr{kind: expUpval, index: i, desc: "upvalue '" + name + "'",
+			eval: func(f *frame) Value { return f.upvals[i].v }}
+	}
+	line := p.tok.line
+	return &expr{kind: expGlobal, name: name, desc: "global '" + name + "'",
+		eval: func(f *frame) Value { return f.l.getGlobal(name, line) }}
+}
+
+func (p *parser) indexed(obj *expr, key *expr, desc string) *expr {
+	objEval, keyEval, line := obj.eval, key.eval, p.tok.line
+	return &expr{kind: expIndex, obj: objEval, key: keyEval, desc: desc,
+		eval: func(f *frame) Value { return f.l.index(objEval(f), keyEval(f), line) }}
+}
+
+func (p *parser) suffixedExpr() *expr {
+	e := p.primaryExpr()
+	for {
+		switch {
+		case p.isOp("."):
+			p.next()
+			field := p.name()
+			e = p.indexed(e, constant(field), "field '"+field+"'")
+		case p.isOp("["):
+			p.next()
+			key := p.expr()
+			p.expect("]")
+			e = p.indexed(e, key, "field '?'")
+		case p.isOp(":"):
+			p.next()
+			method := p.name()
+			e = p.methodCall(e, method)
+		case p.isOp("(") || p.isOp("{") || p.tok.kind == tokString:
+			e = p.call(e)
+		default:
+			return e
+		}
+	}
+}
+
+func (p *parser) callArgs() multiFn {
+	switch {
+	case p.tok.kind == tokString:
+		s := p.tok.text
+		p.next()
+		return func(*frame) []Value { return []Value{s} }
+	case p.isOp("{"):
+		table := p.tableConstructor().eval
+		return func(f *frame) []Value { return []Value{table(f)} }
+	default:
+		line := p.tok.line
+		p.expect("(")
+		if p.accept(")") {
+			return func(*frame) []Value { return nil }
+		}
+		args := exprList(p.exprList())
+		p.expectMatch(")", "(", line)
+		return args
+	}
+}
+
+func multiExpr(multi multiFn, desc string) *expr {
+	return &expr{
+		kind:  expMulti,
+		desc:  desc,
+		multi: multi,
+		eval:  func(f *frame) Value { return nth(multi(f), 0) },
+	}
+}
+
+func (p *parser) call(fn *expr) *expr {
+	line := p.tok.line
+	fnEval, desc := fn.eval, fn.desc
+	args := p.callArgs()
+	return multiExpr(func(f *frame) []Value {
+		callee := fnEval(f)
+		return f.l.call(callee, args(f), line, desc)
+	}, "")
+}
+
+func (p *parser) methodCall(obj *expr, method string) *expr {
+	line := p.tok.line
+	objEval := obj.eval
+	args := p.callArgs()
+	return multiExpr(func(f *frame) []Value {
+		self := objEval(f)
+		fn := f.l.index(self, method, line)
+		return f.l.call(fn, append([]Value{self}, args(f)...), line, "method '"+method+"'")
+	}, "")
+}
+
+func (p *parser) tableConstructor() *expr {
+	line := p.tok.line
+	p.expect("{")
+	type item struct {
+		key   evalFn
+		value *expr
+	}
+	var items []item
+	for !p.isOp("}") {
+		switch {
+		case p.tok.kind == tokName && p.peek().kind == tokOp && p.peek().text == "=":
+			key := constant(p.tok.text).eval
+			p.next()
+			p.next()
+			items = append(items, item{key, p.expr()})
+		case p.isOp("["):
+			p.next()
+			key := p.expr().eval
+			p.expect("]")
+			p.expect("=")
+			items = append(items, item{key, p.expr()})
+		default:
+			items = append(items, item{nil, p.expr()})
+		}
+		if !p.accept(",") && !p.accept(";") {
+			break
+		}
+	}
+	p.expectMatch("}", "{", line)
+
+	return &expr{kind: expValue, eval: func(f *frame) Value {
+		t := NewTable()
+		n := 1
+		for i, it := range items {
+			switch {
+			case it.key != nil:
+				f.l.setIndex(t, it.key(f), it.value.eval(f), line)
+			case i == len(items)-1 && it.value.kind == expMulti:
+				for _, v := range it.value.multi(f) {
+					t.Set(float64(n), v)
+					n++
+				}
+			default:
+				t.Set(float64(n), it.value.eval(f))
+				n++
+			}
+		}
+		return t
+	}}
+}
+
+// body parses a function's parameters and body and returns the expression
+// creating the closure.
+func (p *parser) body(isMethod bool, name string, line int) *expr {
+	fs := &funcState{
+		parent:     p.fs,
+		proto:      &funcProto{name: name, chunk: p.lx.chunk},
+		upvalNames: map[string]int{},
+	}
+	p.fs = fs
+	if isMethod {
+		p.declareLocal("self")
+	}
+	p.expect("(")
+	for !p.isOp(")") {
+		if p.accept("...") {
+			fs.proto.vararg = true
+			break
+		}
+		p.declareLocal(p.name())
+		if !p.accept(",") {
+			break
+		}
+	}
+	p.expect(")")
+	fs.proto.nparams = len(fs.actives)
+	fs.proto.body = p.block()
+	p.expectMatch("end", "function", line)
+	p.fs = fs.parent
+
+	proto := fs.proto
+	return &expr{kind: expValue, eval: func(f *frame) Value {
+		upvals := make([]*cell, len(proto.upvals))
+		for i, u := range proto.upvals {
+			if u.fromLocal {
+				upvals[i] = f.slots[u.index]
+			} else {
+				upvals[i] = f.upvals[u.index]
+			}
+		}
+		return &Function{proto: proto, upvals: upvals}
+	}}
+}
+
+func unaryOp(op string, operand *expr, line int) *expr {
+	e := operand.eval
+	switch op {
+	case "not":
+		return &expr{kind: expValue, eval: func(f *frame) Value { return !Truthy(e(f)) }}
+	case "-":
+		return &expr{kind: expValue, eval: func(f *frame) Value {
+			v := e(f)
+			if n, ok := v.(float64); ok {
+				return -n
+			}
+			n, ok := ToNumber(v)
+			if !ok {
+				f.l.runtimeError(line, "attempt to perform arithmetic on "+describe(operand, v))
+			}
+			return -n
+		}}
+	default: // "#"
+		return &expr{kind: expValue, eval: func(f *frame) Value {
+			switch v := e(f).(type) {
+			case string:
+				return float64(len(v))
+			case *Table:
+				return float64(v.Len())
+			default:
+				f.l.runtimeError(line, "attempt to get length of "+describe(operand, v))
+				return nil
+			}
+		}}
+	}
+}
+
+// describe names a value in an error message, e.g. "global 'x' (a nil
+// value)" or "a nil value".
+func describe(e *expr, v Value) string {
+	if e.desc != "" && e.desc != "..." {
+		return e.desc + " (a " + TypeName(v) + " value)"
+	}
+	return "a " + TypeName(v) + " value"
+}
+
+func binaryOp(op string, left, right *expr, line int) *expr {
+	l, r := left.eval, right.eval
+	var eval evalFn
+	switch op {
+	case "and":
+		eval = func(f *frame) Value {
+			if v := l(f); !Truthy(v) {
+				return v
+			}
+			return r(f)
+		}
+	case "or":
+		eval = func(f *frame) Value {
+			if v := l(f); Truthy(v) {
+				return v
+			}
+			return r(f)
+		}
+	case "==":
+		eval = func(f *frame) Value { return rawEqual(l(f), r(f)) }
+	case "~=":
+		eval = func(f *frame) Value { return !rawEqual(l(f), r(f)) }
+	case "<":
+		eval = func(f *frame) Value { return f.l.less(l(f), r(f), line) }
+	case "<=":
+		eval = func(f *frame) Value { return f.l.lessEqual(l(f), r(f), line) }
+	case ">":
+		eval = func(f *frame) Value { a := l(f); return f.l.less(r(f), a, line) }
+	case ">=":
+		eval = func(f *frame) Value { a := l(f); return f.l.lessEqual(r(f), a, line) }
+	case "..":
+		eval = func(f *frame) Value {
+			a, b := l(f), r(f)
+			if x, ok := a.(string); ok {
+				if y, ok := b.(string); ok {
+					return x + y
+				}
+			}
+			x, ok1 := concatString(a)
+			y, ok2 := concatString(b)
+			if !ok1 {
+				f.l.runtimeError(line, "attempt to concatenate "+describe(left, a))
+			}
+			if !ok2 {
+				f.l.runtimeError(line, "attempt to concatenate "+describe(right, b))
+			}
+			return x + y
+		}
+	default:
+		arith := arithmetic[op]
+		eval = func(f *frame) Value {
+			a, b := l(f), r(f)
+			if x, ok := a.(float64); ok {
+				if y, ok := b.(float64); ok {
+					return arith(x, y)
+				}
+			}
+			x, ok1 := ToNumber(a)
+			y, ok2 := ToNumber(b)
+			if !ok1 {
+				f.l.runtimeError(line, "attempt to perform arithmetic on "+describe(left, a))
+			}
+			if !ok2 {
+				f.l.runtimeError(line, "attempt to perform arithmetic on "+describe(right, b))
+			}
+			return arith(x, y)
+		}
+	}
+	return &expr{kind: expValue, eval: eval}
+}
+
+var arithmetic = map[string]func(a, b float64) float64{
+	"+": func(a, b float64) float64 { return a + b },
+	"-": func(a, b float64) float64 { return a - b },
+	"*": func(a, b float64) float64 { return a * b },
+	"/": func(a, b float64) float64 { return a / b },
+	"%": func(a, b float64) float64 { return a - math.Floor(a/b)*b },
+	"^": math.Pow,
+}
+
+func concatString(v Value) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case float64:
+		return FormatNumber(v), true
+	default:
+		return "", false
+	}
+}
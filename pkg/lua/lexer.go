@@ -0,0 +1,275 @@
+package lua
+
+import (
+	"strconv"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokName
+	tokString
+	tokNumber
+	// tokOp covers keywords and operators; the token text tells them apart.
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	num  float64
+	line int
+}
+
+var keywords = map[string]bool{
+	"and": true, "break": true, "do": true, "else": true, "elseif": true,
+	"end": true, "false": true, "for": true, "function": true, "if": true,
+	"in": true, "local": true, "nil": true, "not": true, "or": true,
+	"repeat": true, "return": true, "then": true, "true": true,
+	"until": true, "while": true,
+}
+
+type lexer struct {
+	src   string
+	pos   int
+	line  int
+	chunk string
+}
+
+// syntaxError is raised by the lexer and parser and recovered by compile.
+type syntaxError struct {
+	msg string
+}
+
+func (lx *lexer) errorf(line int, near, msg string) {
+	if near != "" {
+		msg += " near '" + near + "'"
+	}
+	panic(&syntaxError{lx.chunk + ":" + strconv.Itoa(line) + ": " + msg})
+}
+
+func (lx *lexer) peekByte(offset int) byte {
+	if lx.pos+offset < len(lx.src) {
+		return lx.src[lx.pos+offset]
+	}
+	return 0
+}
+
+func (lx *lexer) next() token {
+	lx.skipSpaceAndComments()
+	if lx.pos >= len(lx.src) {
+		return token{kind: tokEOF, text: "<eof>", line: lx.line}
+	}
+
+	c := lx.src[lx.pos]
+	switch {
+	case isAlpha(c):
+		start := lx.pos
+		for lx.pos < len(lx.src) && isAlnum(lx.src[lx.pos]) {
+			lx.pos++
+		}
+		word := lx.src[start:lx.pos]
+		if keywords[word] {
+			return token{kind: tokOp, text: word, line: lx.line}
+		}
+		return token{kind: tokName, text: word, line: lx.line}
+	case isDigit(c) || (c == '.' && isDigit(lx.peekByte(1))):
+		return lx.number()
+	case c == '"' || c == '\'':
+		return lx.shortString(c)
+	case c == '[' && (lx.peekByte(1) == '[' || lx.peekByte(1) == '='):
+		line := lx.line
+		if s, ok := lx.longBracket(); ok {
+			return token{kind: tokString, text: s, line: line}
+		}
+		lx.pos++
+		return token{kind: tokOp, text: "[", line: line}
+	}
+
+	for _, op := range []string{"...", "..", "==", "~=", "<=", ">="} {
+		if strings.HasPrefix(lx.src[lx.pos:], op) {
+			lx.pos += len(op)
+			return token{kind: tokOp, text: op, line: lx.line}
+		}
+	}
+	if strings.IndexByte("+-*/%^#<>=(){}[];:,.", c) >= 0 {
+		lx.pos++
+		return token{kind: tokOp, text: string(c), line: lx.line}
+	}
+	lx.errorf(lx.line, string(c), "unexpected symbol")
+	return token{}
+}
+
+func (lx *lexer) skipSpaceAndComments() {
+	for lx.pos < len(lx.src) {
+		c := lx.src[lx.pos]
+		switch {
+		case c == '\n':
+			lx.line++
+			lx.pos++
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v':
+			lx.pos++
+		case c == '-' && lx.peekByte(1) == '-':
+			lx.pos += 2
+			if lx.peekByte(0) == '[' {
+				if _, ok := lx.longBracket(); ok {
+					continue
+				}
+			}
+			for lx.pos < len(lx.src) && lx.src[lx.pos] != '\n' {
+				lx.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// longBracket reads a [[...]] or [==[...]==] string or comment starting at
+// the current position. It reports false, consuming nothing, if the opening
+// bracket is not a long bracket.
+func (lx *lexer) longBracket() (string, bool) {
+	level := 0
+	for lx.peekByte(1+level) == '=' {
+		level++
+	}
+	if lx.peekByte(1+level) != '[' {
+		return "", false
+	}
+	line := lx.line
+	lx.pos += level + 2
+	// A newline right after the opening bracket is skipped.
+	if lx.peekByte(0) == '\r' {
+		lx.pos++
+	}
+	if lx.peekByte(0) == '\n' {
+		lx.pos++
+		lx.line++
+	}
+
+	closing := "]" + strings.Repeat("=", level) + "]"
+	end := strings.Index(lx.src[lx.pos:], closing)
+	if end < 0 {
+		lx.errorf(line, "<eof>", "unfinished long string")
+	}
+	s := lx.src[lx.pos : lx.pos+end]
+	lx.line += strings.Count(s, "\n")
+	lx.pos += end + len(closing)
+	return s, true
+}
+
+func (lx *lexer) number() token {
+	start := lx.pos
+	if lx.src[lx.pos] == '0' && (lx.peekByte(1) == 'x' || lx.peekByte(1) == 'X') {
+		lx.pos += 2
+	}
+	for lx.pos < len(lx.src) {
+		c := lx.src[lx.pos]
+		if (c == '+' || c == '-') && (lx.src[lx.pos-1] == 'e' || lx.src[lx.pos-1] == 'E') &&
+			!strings.HasPrefix(lx.src[start:], "0x") && !strings.HasPrefix(lx.src[start:], "0X") {
+			lx.pos++
+			continue
+		}
+		if !isAlnum(c) && c != '.' {
+			break
+		}
+		lx.pos++
+	}
+	text := lx.src[start:lx.pos]
+	n, ok := parseNumber(text)
+	if !ok {
+		lx.errorf(lx.line, text, "malformed number")
+	}
+	return token{kind: tokNumber, text: text, num: n, line: lx.line}
+}
+
+func (lx *lexer) shortString(quote byte) token {
+	line := lx.line
+	lx.pos++
+	var b strings.Builder
+	for {
+		if lx.pos >= len(lx.src) {
+			lx.errorf(line, "<eof>", "unfinished string")
+		}
+		c := lx.src[lx.pos]
+		switch {
+		case c == quote:
+			lx.pos++
+			return token{kind: tokString, text: b.String(), line: line}
+		case c == '\n':
+			lx.errorf(line, b.String(), "unfinished string")
+		case c == '\\':
+			lx.pos++
+			lx.escape(&b)
+		default:
+			b.WriteByte(c)
+			lx.pos++
+		}
+	}
+}
+
+func (lx *lexer) escape(b *strings.Builder) {
+	if lx.pos >= len(lx.src) {
+		lx.errorf(lx.line, "<eof>", "unfinished string")
+	}
+	c := lx.src[lx.pos]
+	lx.pos++
+	switch c {
+	case 'n':
+		b.WriteByte('\n')
+	case 't':
+		b.WriteByte('\t')
+	case 'r':
+		b.WriteByte('\r')
+	case 'a':
+		b.WriteByte('\a')
+	case 'b':
+		b.WriteByte('\b')
+	case 'f':
+		b.WriteByte('\f')
+	case 'v':
+		b.WriteByte('\v')
+	case '\n':
+		lx.line++
+		b.WriteByte('\n')
+	case 'x':
+		if lx.pos+2 > len(lx.src) {
+			lx.errorf(lx.line, "\\x", "hexadecimal digit expected")
+		}
+		n, err := strconv.ParseUint(lx.src[lx.pos:lx.pos+2], 16, 8)
+		if err != nil {
+			lx.errorf(lx.line, "\\x"+lx.src[lx.pos:lx.pos+2], "hexadecimal digit expected")
+		}
+		b.WriteByte(byte(n))
+		lx.pos += 2
+	default:
+		if !isDigit(c) {
+			// \\, \", \' and any other character stand for themselves.
+			b.WriteByte(c)
+			return
+		}
+		n := int(c - '0')
+		for i := 0; i < 2 && isDigit(lx.peekByte(0)); i++ {
+			n = n*10 + int(lx.src[lx.pos]-'0')
+			lx.pos++
+		}
+		if n > 255 {
+			lx.errorf(lx.line, "\\"+strconv.Itoa(n), "escape sequence too large")
+		}
+		b.WriteByte(byte(n))
+	}
+}
+
+func isAlpha(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isAlnum(c byte) bool {
+	return isAlpha(c) || isDigit(c)
+}
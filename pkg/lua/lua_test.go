@@ -0,0 +1,263 @@
+package lua
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func run(t *testing.T, src string) ([]Value, error) {
+	t.Helper()
+	l := NewState()
+	fn, err := l.Load(src, "test")
+	if err != nil {
+		return nil, err
+	}
+	return l.Call(fn)
+}
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []Value
+	}{
+		{"Arithmetic", "return 1 + 2 * 3 - 4 / 2, 7 % 3, -7 % 3, 2 ^ 10", []Value{5.0, 1.0, 2.0, 1024.0}},
+		{"Precedence", "return 2 ^ 3 ^ 2, -2 ^ 2, not nil == true, 1 .. 2 .. 3", []Value{512.0, -4.0, true, "123"}},
+		{"String coercion", "return '10' + 5, 10 .. ''", []Value{15.0, "10"}},
+		{"Comparison", "return 1 < 2, 'a' < 'b', 2 >= 2, 1 ~= 1, 'x' == 'x'", []Value{true, true, true, false, true}},
+		{"And or", "return nil or 'd', false and 1, 1 and 2, nil and nil or 3", []Value{"d", false, 2.0, 3.0}},
+		{"Length", "return #'abc', #{1, 2, 3}", []Value{3.0, 3.0}},
+		{"Locals and blocks", "local a = 1 do local a = 2 end return a", []Value{1.0}},
+		{"Multiple assignment", "local a, b = 1, 2 a, b = b, a return a, b", []Value{2.0, 1.0}},
+		{"Globals", "x = 5 return x", []Value{5.0}},
+		{"If", "local x = 5 if x < 3 then return 'a' elseif x < 6 then return 'b' else return 'c' end", []Value{"b"}},
+		{"While", "local i, s = 0, 0 while i < 10 do i = i + 1 s = s + i end return s", []Value{55.0}},
+		{"Repeat sees body locals", "local i = 0 repeat local j = i i = i + 1 until j >= 3 return i", []Value{4.0}},
+		{"Numeric for", "local s = 0 for i = 10, 1, -2 do s = s + i end return s", []Value{30.0}},
+		{"Generic for", "local s = '' for i, v in ipairs({'a', 'b', 'c'}) do s = s .. i .. v end return s", []Value{"1a2b3c"}},
+		{"Pairs", "local n = 0 for k, v in pairs({a = 1, b = 2, 3}) do n = n + v end return n", []Value{6.0}},
+		{"Break", "local i = 0 while true do i = i + 1 if i == 5 then break end end return i", []Value{5.0}},
+		{"Closures", `
+			local function counter()
+				local n = 0
+				return function() n = n + 1 return n end
+			end
+			local c = counter()
+			c() c()
+			return c()`, []Value{3.0}},
+		{"Closures per iteration", `
+			local fns = {}
+			for i = 1, 3 do fns[i] = function() return i end end
+			return fns[1]() + fns[2]() + fns[3]()`, []Value{6.0}},
+		{"Recursion", "local function fib(n) if n < 2 then return n end return fib(n - 1) + fib(n - 2) end return fib(15)", []Value{610.0}},
+		{"Varargs", "local function f(...) return select('#', ...), ... end return f(1, nil, 3)", []Value{3.0, 1.0, nil, 3.0}},
+		{"Vararg table", "local function f(...) local t = {...} return #t end return f(1, 2, 3)", []Value{3.0}},
+		{"Multiple results truncated", "local function f() return 1, 2 end return f(), (f())", []Value{1.0, 1.0}},
+		{"Multiple results expanded", "local function f() return 1, 2 end local t = {f(), f()} return #t", []Value{3.0}},
+		{"Methods", `
+			local obj = {n = 1}
+			function obj:add(k) self.n = self.n + k return self end
+			return obj:add(2):add(3).n`, []Value{6.0}},
+		{"Nested tables", "local t = {a = {b = {c = 'x'}}} t.a.b.d = 'y' return t.a.b.c .. t['a'].b.d", []Value{"xy"}},
+		{"Table keys", "local t = {[1] = 'a', [2.0] = 'b', ['1'] = 'c'} return t[1], t[2], t['1']", []Value{"a", "b", "c"}},
+		{"Long strings", "return [[a\nb]], [==[x]]y]==]", []Value{"a\nb", "x]]y"}},
+		{"Escapes", `return "a\tb\65\x41\\"`, []Value{"a\tbAA\\"}},
+		{"Comments", "--[[ long\ncomment ]] return 1 -- short", []Value{1.0}},
+		{"Hex numbers", "return 0xff, 1e2, .5", []Value{255.0, 100.0, 0.5}},
+		{"Pcall", "return pcall(error, 'boom', 0)", []Value{false, "boom"}},
+		{"Pcall table error", "local ok, e = pcall(error, {code = 1}) return e.code", []Value{1.0}},
+		{"Pcall success", "return pcall(function(a) return a * 2 end, 21)", []Value{true, 42.0}},
+		{"Error position", "local ok, e = pcall(function() error('x') end) return e", []Value{"test:1: x"}},
+		{"Runtime error message", "local ok, e = pcall(function() return nil + 1 end) return e", []Value{"test:1: attempt to perform arithmetic on a nil value"}},
+		{"Call error message", "local ok, e = pcall(function() undefined() end) return e", []Value{"test:1: attempt to call global 'undefined' (a nil value)"}},
+		{"Tostring and tonumber", "return tostring(1.5), tostring(10), tonumber('0x10'), tonumber('z', 36), tonumber('x')", []Value{"1.5", "10", 16.0, 35.0, nil}},
+		{"Type", "return type(nil), type({}), type(print), type('')", []Value{"nil", "table", "nil", "string"}},
+		{"Unpack", "return unpack({1, 2, 3}, 2)", []Value{2.0, 3.0}},
+		{"Select negative", "return select(-1, 'a', 'b')", []Value{"b"}},
+		{"Next", "local t = {} return next(t)", []Value{nil}},
+		{"Clear during traversal", `
+			local t = {1, 2, 3, x = 1, y = 2}
+			for k in pairs(t) do t[k] = nil end
+			return next(t)`, []Value{nil}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := run(t, tt.src)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLibraries(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []Value
+	}{
+		{"string.sub", "return ('hello'):sub(2, -2), string.sub('hello', -3)", []Value{"ell", "llo"}},
+		{"string.upper lower len rep reverse", "local s = 'aB' return s:upper(), s:lower(), #s:rep(3), s:rep(2, nil), s:reverse()", []Value{"AB", "ab", 6.0, "aBaB", "Ba"}},
+		{"string.byte char", "return string.char(72, 105), string.byte('AB', 1, 2)", []Value{"Hi", 65.0, 66.0}},
+		{"string.format", "return string.format('%d %5.2f %s %x %q %%', 42, 3.14159, 'x', 255, 'a\"b')", []Value{`42  3.14 x ff "a\"b" %`}},
+		{"string.find plain", "return string.find('a.b', '.', 1, true)", []Value{2.0, 2.0}},
+		{"string.find pattern", "return string.find('hello world', 'o w')", []Value{5.0, 7.0}},
+		{"string.find captures", "return string.find('key=val', '(%w+)=(%w+)')", []Value{1.0, 7.0, "key", "val"}},
+		{"string.match", "return string.match('  trim  ', '^%s*(.-)%s*$')", []Value{"trim"}},
+		{"string.match anchor", "return string.match('abc', '^b'), string.match('abc', 'c$')", []Value{nil, "c"}},
+		{"string.match classes", "return string.match('x = 12.5e3;', '[%d%.e]+')", []Value{"12.5e3"}},
+		{"string.match negated set", "return string.match('abc123', '[^%a]+')", []Value{"123"}},
+		{"string.match position capture", "return string.match('hello', '()ll()')", []Value{3.0, 5.0}},
+		{"string.match balance", "return string.match('f(a(b)c) d', '%b()')", []Value{"(a(b)c)"}},
+		{"string.match back reference", "return string.match('say \"hi\" now', '([\"\\'])(.-)%1')", []Value{"\"", "hi"}},
+		{"string.match frontier", "return string.match('THE (quick) fox', '%f[%a]%a+%f[%A]', 5)", []Value{"quick"}},
+		{"string.gmatch", `
+			local words = {}
+			for w in string.gmatch('one two  three', '%a+') do words[#words + 1] = w end
+			return table.concat(words, ',')`, []Value{"one,two,three"}},
+		{"string.gmatch pairs", `
+			local t = {}
+			for k, v in ('a=1, b=2'):gmatch('(%w+)=(%w+)') do t[k] = tonumber(v) end
+			return t.a + t.b`, []Value{3.0}},
+		{"string.gsub", "return string.gsub('hello world', 'o', '0')", []Value{"hell0 w0rld", 2.0}},
+		{"string.gsub captures", "return (string.gsub('hello world', '(%w+)', '<%1>'))", []Value{"<hello> <world>"}},
+		{"string.gsub limit", "return string.gsub('aaa', 'a', 'b', 2)", []Value{"bba", 2.0}},
+		{"string.gsub function", "return (string.gsub('1 2 3', '%d', function(d) return d * 2 end))", []Value{"2 4 6"}},
+		{"string.gsub table", "return (string.gsub('$a $b', '%$(%w+)', {a = 'x'}))", []Value{"x $b"}},
+		{"string.gsub empty matches", "return (string.gsub('abc', '', '-'))", []Value{"-a-b-c-"}},
+		{"table.insert remove", `
+			local t = {1, 2, 3}
+			table.insert(t, 4)
+			table.insert(t, 1, 0)
+			local removed = table.remove(t, 2)
+			return table.concat(t, ','), removed, table.remove(t)`, []Value{"0,2,3,4", 1.0, 4.0}},
+		{"table.sort", `
+			local t = {3, 1, 2}
+			table.sort(t)
+			local u = {'b', 'c', 'a'}
+			table.sort(u, function(a, b) return a > b end)
+			return table.concat(t), table.concat(u)`, []Value{"123", "cba"}},
+		{"table.getn", "return table.getn({1, 2})", []Value{2.0}},
+		{"math", "return math.floor(3.7), math.ceil(3.2), math.max(1, 5, 3), math.min(4, 2), math.abs(-2), math.huge > 1", []Value{3.0, 4.0, 5.0, 2.0, 2.0, true}},
+		{"math.random", "local r = math.random(1, 10) return r >= 1 and r <= 10 and r == math.floor(r)", []Value{true}},
+		{"bit", "return bit.band(0xff, 0x0f), bit.bor(1, 2, 4), bit.bxor(5, 3), bit.bnot(0), bit.tobit(2^32 + 1)", []Value{15.0, 7.0, 6.0, -1.0, 1.0}},
+		{"bit shifts", "return bit.lshift(1, 31), bit.rshift(-1, 28), bit.arshift(-256, 4), bit.rol(0x12345678, 8), bit.bswap(0x12345678)", []Value{-2147483648.0, 15.0, -16.0, 878082066.0, 2018915346.0}},
+		{"bit.tohex", "return bit.tohex(255), bit.tohex(-1, -4), bit.tohex(0x1234, 2)", []Value{"000000ff", "FFFF", "34"}},
+		{"cjson.encode", `return cjson.encode({1, 2, 'a/b'}), cjson.encode({}), cjson.encode({x = {true, cjson.null}}), cjson.encode('a"\n')`, []Value{`[1,2,"a\/b"]`, "{}", `{"x":[true,null]}`, `"a\"\n"`}},
+		{"cjson.decode", `
+			local t = cjson.decode('{"a": [1, 2.5, "x", null], "b": {"c": false}}')
+			return t.a[1], t.a[2], t.a[3], t.a[4] == cjson.null, #t.a, t.b.c`, []Value{1.0, 2.5, "x", true, 4.0, false}},
+		{"cjson round trip", `return cjson.encode(cjson.decode('[1,[2,{"k":"v"}]]'))`, []Value{`[1,[2,{"k":"v"}]]`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := run(t, tt.src)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"Syntax error", "x = = 1", "test:1: unexpected symbol near '='"},
+		{"Unfinished block", "if true then", "test:1: 'end' expected near '<eof>'"},
+		{"Unclosed block on another line", "while true do\n\n", "test:3: 'end' expected (to close 'while' at line 1) near '<eof>'"},
+		{"Unfinished string", "x = 'abc", "test:1: unfinished string near '<eof>'"},
+		{"Break outside loop", "break", "test:1: no loop to break near 'break'"},
+		{"Vararg outside vararg function", "function f() return ... end", "test:1: cannot use '...' outside a vararg function near '...'"},
+		{"Error value", "error('boom')", "test:1: boom"},
+		{"Error level 0", "error('boom', 0)", "boom"},
+		{"Arithmetic on field", "local t = {} return t.x + 1", "test:1: attempt to perform arithmetic on field 'x' (a nil value)"},
+		{"Index nil", "local t return t.x", "test:1: attempt to index a nil value"},
+		{"Compare", "return {} < 1", "test:1: attempt to compare table with number"},
+		{"Concatenate", "return 'a' .. {}", "test:1: attempt to concatenate a table value"},
+		{"Bad argument", "string.rep()", "test:1: bad argument #1 to 'rep' (string expected, got no value)"},
+		{"Nil index", "local t = {} t[nil] = 1", "test:1: table index is nil"},
+		{"Stack overflow", "local function f() return 1 + f() end f()", "test:1: stack overflow"},
+		{"Error on later line", "local x = 1\nlocal y = nil\nreturn x + y", "test:3: attempt to perform arithmetic on local 'y' (a nil value)"},
+		{"cjson sparse array", "return cjson.encode({[1] = 1, [20] = 2})", "test:1: Cannot serialise table: excessively sparse array"},
+		{"cjson function", "return cjson.encode({function() end})", "test:1: Cannot serialise function: type not supported"},
+		{"cjson invalid JSON", "return cjson.decode('{bad')", "test:1: Expected value but found invalid token at character 2"},
+		{"cjson trailing data", "return cjson.decode('1 2')", "test:1: Expected the end but found invalid token at character 3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := run(t, tt.src)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("got error %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestStrictGlobals(t *testing.T) {
+	l := NewState()
+	l.StrictGlobals = true
+	for src, want := range map[string]string{
+		"return undefined": "Script attempted to access nonexistent global variable 'undefined'",
+		"newglobal = 1":    "Attempt to modify a readonly table",
+	} {
+		fn, err := l.Load(src, "test")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := l.Call(fn); err == nil || !strings.HasSuffix(err.Error(), want) {
+			t.Errorf("%q: got error %v, want %q", src, err, want)
+		}
+	}
+}
+
+func TestInterrupt(t *testing.T) {
+	l := NewState()
+	errKilled := errors.New("killed")
+	calls := 0
+	l.Interrupt = func() error {
+		calls++
+		if calls == 3 {
+			return errKilled
+		}
+		return nil
+	}
+	// pcall must not catch the interruption.
+	fn, err := l.Load("while true do pcall(function() while true do end end) end", "test")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := l.Call(fn); err != errKilled {
+		t.Errorf("got error %v, want %v", err, errKilled)
+	}
+}
+
+func TestGoFunctions(t *testing.T) {
+	l := NewState()
+	l.Register("sum", func(l *State, args []Value) []Value {
+		total := 0.0
+		for i := range args {
+			total += CheckNumber(l, args, i+1, "sum")
+		}
+		return []Value{total}
+	})
+	fn, err := l.Load("return sum(1, 2, 3), pcall(sum, 'x')", "test")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := l.Call(fn)
+	want := []Value{6.0, false, "test:1: bad argument #1 to 'sum' (number expected, got string)"}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, %v, want %#v", got, err, want)
+	}
+}
@@ -0,0 +1,76 @@
+package lua
+
+import (
+	"math"
+	"math/rand"
+)
+
+func openMath(l *State) {
+	// Scripts get the same random sequence on every run unless they seed it.
+	rng := rand.New(rand.NewSource(0))
+
+	fns := map[string]func(*State, []Value) []Value{
+		"fmod": func(l *State, args []Value) []Value {
+			return []Value{math.Mod(CheckNumber(l, args, 1, "fmod"), CheckNumber(l, args, 2, "fmod"))}
+		},
+		"pow": func(l *State, args []Value) []Value {
+			return []Value{math.Pow(CheckNumber(l, args, 1, "pow"), CheckNumber(l, args, 2, "pow"))}
+		},
+		"modf": func(l *State, args []Value) []Value {
+			i, frac := math.Modf(CheckNumber(l, args, 1, "modf"))
+			return []Value{i, frac}
+		},
+		"max": func(l *State, args []Value) []Value {
+			m := CheckNumber(l, args, 1, "max")
+			for i := 2; i <= len(args); i++ {
+				m = math.Max(m, CheckNumber(l, args, i, "max"))
+			}
+			return []Value{m}
+		},
+		"min": func(l *State, args []Value) []Value {
+			m := CheckNumber(l, args, 1, "min")
+			for i := 2; i <= len(args); i++ {
+				m = math.Min(m, CheckNumber(l, args, i, "min"))
+			}
+			return []Value{m}
+		},
+		"random": func(l *State, args []Value) []Value {
+			r := rng.Float64()
+			switch len(args) {
+			case 0:
+				return []Value{r}
+			case 1, 2:
+				lo, hi := 1, CheckInt(l, args, 1, "random")
+				if len(args) == 2 {
+					lo, hi = hi, CheckInt(l, args, 2, "random")
+				}
+				if lo > hi {
+					argError(l, len(args), "random", "interval is empty")
+				}
+				return []Value{math.Floor(r*float64(hi-lo+1)) + float64(lo)}
+			default:
+				l.RaiseError("wrong number of arguments")
+				return nil
+			}
+		},
+		"randomseed": func(l *State, args []Value) []Value {
+			rng.Seed(int64(CheckNumber(l, args, 1, "randomseed")))
+			return nil
+		},
+	}
+	for name, fn := range map[string]func(float64) float64{
+		"abs": math.Abs, "ceil": math.Ceil, "floor": math.Floor, "sqrt": math.Sqrt,
+		"exp": math.Exp, "log": math.Log, "log10": math.Log10,
+		"sin": math.Sin, "cos": math.Cos, "tan": math.Tan,
+		"asin": math.Asin, "acos": math.Acos, "atan": math.Atan,
+	} {
+		fns[name] = func(l *State, args []Value) []Value {
+			return []Value{fn(CheckNumber(l, args, 1, name))}
+		}
+	}
+
+	lib := NewLib("math", fns)
+	lib.Set("huge", math.Inf(1))
+	lib.Set("pi", math.Pi)
+	l.Globals.Set("math", lib)
+}
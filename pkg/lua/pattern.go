@@ -0,0 +1,344 @@
+package lua
+
+// A port of the Lua 5.1 pattern matcher (lstrlib.c). Positions are byte
+// offsets into the subject; -1 means no match.
+
+const (
+	maxCaptures    = 32
+	capUnfinished  = -1
+	capPosition    = -2
+	patternSpecial = "^$*+?.([%-"
+)
+
+type matchState struct {
+	l       *State
+	src     string
+	pat     string
+	level   int
+	capture [maxCaptures]struct{ init, len int }
+}
+
+func (ms *matchState) classEnd(p int) int {
+	c := ms.pat[p]
+	p++
+	switch c {
+	case '%':
+		if p >= len(ms.pat) {
+			ms.l.RaiseError("malformed pattern (ends with '%%')")
+		}
+		return p + 1
+	case '[':
+		if p < len(ms.pat) && ms.pat[p] == '^' {
+			p++
+		}
+		// The first character is part of the set even if it is ']'.
+		for {
+			if p >= len(ms.pat) {
+				ms.l.RaiseError("malformed pattern (missing ']')")
+			}
+			c := ms.pat[p]
+			p++
+			if c == '%' && p < len(ms.pat) {
+				p++
+			}
+			if p < len(ms.pat) && ms.pat[p] == ']' {
+				return p + 1
+			}
+		}
+	default:
+		return p
+	}
+}
+
+func matchClass(c, class byte) bool {
+	var res bool
+	switch class | 0x20 {
+	case 'a':
+		res = isAlpha(c) && c != '_'
+	case 'c':
+		res = c < 32 || c == 127
+	case 'd':
+		res = isDigit(c)
+	case 'l':
+		res = c >= 'a' && c <= 'z'
+	case 'p':
+		res = isPunct(c)
+	case 's':
+		res = c == ' ' || (c >= '\t' && c <= '\r')
+	case 'u':
+		res = c >= 'A' && c <= 'Z'
+	case 'w':
+		res = isDigit(c) || (isAlpha(c) && c != '_')
+	case 'x':
+		res = isDigit(c) || (c|0x20 >= 'a' && c|0x20 <= 'f')
+	case 'z':
+		res = c == 0
+	default:
+		return class == c
+	}
+	if class >= 'A' && class <= 'Z' {
+		return !res
+	}
+	return res
+}
+
+func isPunct(c byte) bool {
+	return c > 32 && c < 127 && !isDigit(c) && !(isAlpha(c) && c != '_')
+}
+
+// matchBracketClass matches c against the set between p ('[') and ec (']').
+func (ms *matchState) matchBracketClass(c byte, p, ec int) bool {
+	sig := true
+	if ms.pat[p+1] == '^' {
+		sig = false
+		p++
+	}
+	for p++; p < ec; p++ {
+		switch {
+		case ms.pat[p] == '%':
+			p++
+			if matchClass(c, ms.pat[p]) {
+				return sig
+			}
+		case ms.pat[p+1] == '-' && p+2 < ec:
+			p += 2
+			if ms.pat[p-2] <= c && c <= ms.pat[p] {
+				return sig
+			}
+		case ms.pat[p] == c:
+			return sig
+		}
+	}
+	return !sig
+}
+
+func (ms *matchState) singleMatch(s, p, ep int) bool {
+	if s >= len(ms.src) {
+		return false
+	}
+	c := ms.src[s]
+	switch ms.pat[p] {
+	case '.':
+		return true
+	case '%':
+		return matchClass(c, ms.pat[p+1])
+	case '[':
+		return ms.matchBracketClass(c, p, ep-1)
+	default:
+		return ms.pat[p] == c
+	}
+}
+
+func (ms *matchState) match(s, p int) int {
+	for {
+		if p >= len(ms.pat) {
+			return s
+		}
+		switch ms.pat[p] {
+		case '(':
+			if p+1 < len(ms.pat) && ms.pat[p+1] == ')' {
+				return ms.startCapture(s, p+2, capPosition)
+			}
+			return ms.startCapture(s, p+1, capUnfinished)
+		case ')':
+			return ms.endCapture(s, p+1)
+		case '$':
+			if p+1 == len(ms.pat) {
+				if s == len(ms.src) {
+					return s
+				}
+				return -1
+			}
+		case '%':
+			if p+1 < len(ms.pat) {
+				switch next := ms.pat[p+1]; {
+				case next == 'b':
+					s = ms.matchBalance(s, p+2)
+					if s == -1 {
+						return -1
+					}
+					p += 4
+					continue
+				case next == 'f':
+					p += 2
+					if p >= len(ms.pat) || ms.pat[p] != '[' {
+						ms.l.RaiseError("missing '[' after '%%f' in pattern")
+					}
+					ep := ms.classEnd(p)
+					var prev, cur byte
+					if s > 0 {
+						prev = ms.src[s-1]
+					}
+					if s < len(ms.src) {
+						cur = ms.src[s]
+					}
+					if ms.matchBracketClass(prev, p, ep-1) || !ms.matchBracketClass(cur, p, ep-1) {
+						return -1
+					}
+					p = ep
+					continue
+				case isDigit(next):
+					s = ms.matchCapture(s, next)
+					if s == -1 {
+						return -1
+					}
+					p += 2
+					continue
+				}
+			}
+		}
+
+		ep := ms.classEnd(p)
+		m := ms.singleMatch(s, p, ep)
+		if ep < len(ms.pat) {
+			switch ms.pat[ep] {
+			case '?':
+				if m {
+					if res := ms.match(s+1, ep+1); res != -1 {
+						return res
+					}
+				}
+				p = ep + 1
+				continue
+			case '*':
+				return ms.maxExpand(s, p, ep)
+			case '+':
+				if !m {
+					return -1
+				}
+				return ms.maxExpand(s+1, p, ep)
+			case '-':
+				return ms.minExpand(s, p, ep)
+			}
+		}
+		if !m {
+			return -1
+		}
+		s++
+		p = ep
+	}
+}
+
+func (ms *matchState) maxExpand(s, p, ep int) int {
+	i := 0
+	for ms.singleMatch(s+i, p, ep) {
+		i++
+	}
+	for ; i >= 0; i-- {
+		if res := ms.match(s+i, ep+1); res != -1 {
+			return res
+		}
+	}
+	return -1
+}
+
+func (ms *matchState) minExpand(s, p, ep int) int {
+	for {
+		if res := ms.match(s, ep+1); res != -1 {
+			return res
+		}
+		if !ms.singleMatch(s, p, ep) {
+			return -1
+		}
+		s++
+	}
+}
+
+func (ms *matchState) startCapture(s, p, what int) int {
+	if ms.level >= maxCaptures {
+		ms.l.RaiseError("too many captures")
+	}
+	ms.capture[ms.level].init = s
+	ms.capture[ms.level].len = what
+	ms.level++
+	res := ms.match(s, p)
+	if res == -1 {
+		ms.level--
+	}
+	return res
+}
+
+func (ms *matchState) endCapture(s, p int) int {
+	l := -1
+	for i := ms.level - 1; i >= 0; i-- {
+		if ms.capture[i].len == capUnfinished {
+			l = i
+			break
+		}
+	}
+	if l < 0 {
+		ms.l.RaiseError("invalid pattern capture")
+	}
+	ms.capture[l].len = s - ms.capture[l].init
+	res := ms.match(s, p)
+	if res == -1 {
+		ms.capture[l].len = capUnfinished
+	}
+	return res
+}
+
+func (ms *matchState) matchBalance(s, p int) int {
+	if p+1 >= len(ms.pat) {
+		ms.l.RaiseError("unbalanced pattern")
+	}
+	if s >= len(ms.src) || ms.src[s] != ms.pat[p] {
+		return -1
+	}
+	open, close := ms.pat[p], ms.pat[p+1]
+	depth := 1
+	for s++; s < len(ms.src); s++ {
+		switch ms.src[s] {
+		case close:
+			depth--
+			if depth == 0 {
+				return s + 1
+			}
+		case open:
+			depth++
+		}
+	}
+	return -1
+}
+
+func (ms *matchState) matchCapture(s int, digit byte) int {
+	l := int(digit - '1')
+	if l < 0 || l >= ms.level || ms.capture[l].len == capUnfinished {
+		ms.l.RaiseError("invalid capture index")
+	}
+	c := ms.capture[l]
+	if len(ms.src)-s >= c.len && ms.src[c.init:c.init+c.len] == ms.src[s:s+c.len] {
+		return s + c.len
+	}
+	return -1
+}
+
+// captureValue returns capture i of the match s..e; without captures,
+// capture 0 is the whole match.
+func (ms *matchState) captureValue(i, s, e int) Value {
+	if i >= ms.level {
+		if i != 0 {
+			ms.l.RaiseError("invalid capture index")
+		}
+		return ms.src[s:e]
+	}
+	c := ms.capture[i]
+	switch c.len {
+	case capUnfinished:
+		ms.l.RaiseError("unfinished capture")
+	case capPosition:
+		return float64(c.init + 1)
+	}
+	return ms.src[c.init : c.init+c.len]
+}
+
+func (ms *matchState) captures(s, e int, wholeIfNone bool) []Value {
+	n := ms.level
+	if n == 0 && wholeIfNone {
+		n = 1
+	}
+	values := make([]Value, n)
+	for i := range values {
+		values[i] = ms.captureValue(i, s, e)
+	}
+	return values
+}
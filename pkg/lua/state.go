@@ -0,0 +1,286 @@
+package lua
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// maxCallDepth bounds the nesting of calls, so runaway recursion fails with
+// a Lua error instead of exhausting the Go stack.
+const maxCallDepth = 200
+
+// interruptEvery is how many loop iterations and calls run between two
+// checks of State.Interrupt.
+const interruptEvery = 1024
+
+// State is an interpreter with its global environment. A State is not safe
+// for concurrent use.
+type State struct {
+	Globals *Table
+	// StrictGlobals makes reading an undefined global and creating a new
+	// global errors.
+	StrictGlobals bool
+	// Interrupt, if set, is called periodically while code runs. A non-nil
+	// error stops the running code; pcall cannot catch it.
+	Interrupt func() error
+
+	strings *Table
+	chunk   string
+	line    int
+	depth   int
+	steps   int
+}
+
+// Error is a Lua error: a runtime error message or the value passed to
+// error().
+type Error struct {
+	Value Value
+}
+
+func (e *Error) Error() string {
+	return ToString(e.Value)
+}
+
+// interruptError carries the error returned by State.Interrupt.
+type interruptError struct {
+	err error
+}
+
+// NewState returns a State with the base, string, table, math, bit and
+// cjson libraries loaded.
+func NewState() *State {
+	l := &State{Globals: NewTable()}
+	openBase(l)
+	openString(l)
+	openTable(l)
+	openMath(l)
+	openBit(l)
+	openCJSON(l)
+	return l
+}
+
+// Register sets a global Go function.
+func (l *State) Register(name string, fn func(l *State, args []Value) []Value) {
+	l.Globals.Set(name, &GoFunction{Name: name, Fn: fn})
+}
+
+// NewLib returns a table of Go functions, named lib.name.
+func NewLib(lib string, fns map[string]func(l *State, args []Value) []Value) *Table {
+	t := NewTable()
+	for name, fn := range fns {
+		t.Set(name, &GoFunction{Name: lib + "." + name, Fn: fn})
+	}
+	return t
+}
+
+// Load compiles source into a function. chunk names the source in error
+// messages.
+func (l *State) Load(source, chunk string) (*Function, error) {
+	proto, err := compile(source, chunk)
+	if err != nil {
+		return nil, err
+	}
+	return &Function{proto: proto}, nil
+}
+
+// Call calls fn and returns its results. Lua errors are returned as *Error;
+// an interruption returns the error from Interrupt.
+func (l *State) Call(fn Value, args ...Value) (results []Value, err error) {
+	depth, chunk, line := l.depth, l.chunk, l.line
+	defer func() {
+		if r := recover(); r != nil {
+			l.depth, l.chunk, l.line = depth, chunk, line
+			switch r := r.(type) {
+			case *Error:
+				err = r
+			case *interruptError:
+				err = r.err
+			default:
+				panic(r)
+			}
+		}
+	}()
+	return l.call(fn, args, 0, ""), nil
+}
+
+// pcall calls fn, catching Lua errors but not interruptions.
+func (l *State) pcall(fn Value, args []Value) (results []Value, err *Error) {
+	depth, chunk, line := l.depth, l.chunk, l.line
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(*Error)
+			if !ok {
+				panic(r)
+			}
+			l.depth, l.chunk, l.line = depth, chunk, line
+			err = e
+		}
+	}()
+	return l.call(fn, args, l.line, ""), nil
+}
+
+// RaiseError raises a Lua error from a Go function. The message is prefixed
+// with the position of the running Lua code, like error() does.
+func (l *State) RaiseError(format string, args ...any) {
+	panic(&Error{Value: l.where() + fmt.Sprintf(format, args...)})
+}
+
+// Raise raises a Lua error with any value, like error(v, 0).
+func (l *State) Raise(v Value) {
+	panic(&Error{Value: v})
+}
+
+func (l *State) where() string {
+	if l.chunk == "" {
+		return ""
+	}
+	return l.chunk + ":" + strconv.Itoa(l.line) + ": "
+}
+
+func (l *State) runtimeError(line int, msg string) {
+	l.line = line
+	l.RaiseError("%s", msg)
+}
+
+// tick counts a step of execution and checks for interruption.
+func (l *State) tick() {
+	l.steps++
+	if l.steps%interruptEvery == 0 && l.Interrupt != nil {
+		if err := l.Interrupt(); err != nil {
+			panic(&interruptError{err})
+		}
+	}
+}
+
+// call calls fn with args. desc names fn in error messages.
+func (l *State) call(fn Value, args []Value, line int, desc string) []Value {
+	switch fn := fn.(type) {
+	case *Function:
+		return l.callLua(fn, args)
+	case *GoFunction:
+		return fn.Fn(l, args)
+	default:
+		msg := "attempt to call a " + TypeName(fn) + " value"
+		if desc != "" {
+			msg = "attempt to call " + desc + " (a " + TypeName(fn) + " value)"
+		}
+		l.runtimeError(line, msg)
+		return nil
+	}
+}
+
+func (l *State) callLua(fn *Function, args []Value) []Value {
+	if l.depth >= maxCallDepth {
+		l.RaiseError("stack overflow")
+	}
+	l.tick()
+	p := fn.proto
+	f := &frame{l: l, slots: make([]*cell, p.nslots), upvals: fn.upvals}
+	for i := 0; i < p.nparams; i++ {
+		f.slots[i] = &cell{nth(args, i)}
+	}
+	if p.vararg && len(args) > p.nparams {
+		f.varargs = args[p.nparams:]
+	}
+
+	chunk, line := l.chunk, l.line
+	l.chunk = p.chunk
+	l.depth++
+	c := execBlock(p.body, f)
+	l.depth--
+	l.chunk, l.line = chunk, line
+	if c == ctrlReturn {
+		return f.ret
+	}
+	return nil
+}
+
+func (l *State) getGlobal(name string, line int) Value {
+	v := l.Globals.Get(name)
+	if v == nil && l.StrictGlobals {
+		l.runtimeError(line, "Script attempted to access nonexistent global variable '"+name+"'")
+	}
+	return v
+}
+
+func (l *State) setGlobal(name string, v Value, line int) {
+	if l.StrictGlobals && l.Globals.Get(name) == nil {
+		l.runtimeError(line, "Attempt to modify a readonly table")
+	}
+	l.Globals.Set(name, v)
+}
+
+func (l *State) index(obj, key Value, line int) Value {
+	switch o := obj.(type) {
+	case *Table:
+		return o.Get(key)
+	case string:
+		return l.strings.Get(key)
+	default:
+		l.runtimeError(line, "attempt to index a "+TypeName(obj)+" value")
+		return nil
+	}
+}
+
+func (l *State) setIndex(obj, key, v Value, line int) {
+	t, ok := obj.(*Table)
+	if !ok {
+		l.runtimeError(line, "attempt to index a "+TypeName(obj)+" value")
+	}
+	l.rawSet(t, key, v, line)
+}
+
+func (l *State) rawSet(t *Table, key, v Value, line int) {
+	switch k := key.(type) {
+	case nil:
+		l.runtimeError(line, "table index is nil")
+	case float64:
+		if math.IsNaN(k) {
+			l.runtimeError(line, "table index is NaN")
+		}
+	}
+	t.Set(key, v)
+}
+
+func (l *State) less(a, b Value, line int) bool {
+	switch x := a.(type) {
+	case float64:
+		if y, ok := b.(float64); ok {
+			return x < y
+		}
+	case string:
+		if y, ok := b.(string); ok {
+			return x < y
+		}
+	}
+	l.compareError(a, b, line)
+	return false
+}
+
+func (l *State) lessEqual(a, b Value, line int) bool {
+	switch x := a.(type) {
+	case float64:
+		if y, ok := b.(float64); ok {
+			return x <= y
+		}
+	case string:
+		if y, ok := b.(string); ok {
+			return x <= y
+		}
+	}
+	l.compareError(a, b, line)
+	return false
+}
+
+func (l *State) compareError(a, b Value, line int) {
+	ta, tb := TypeName(a), TypeName(b)
+	if ta == tb {
+		l.runtimeError(line, "attempt to compare two "+ta+" values")
+	}
+	l.runtimeError(line, "attempt to compare "+ta+" with "+tb)
+}
+
+func itoa(n int) string {
+	return strconv.Itoa(n)
+}
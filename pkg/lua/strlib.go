@@ -0,0 +1,338 @@
+package lua
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxStringSize bounds the strings string.rep and string.format build.
+const maxStringSize = 512 << 20
+
+func openString(l *State) {
+	l.strings = NewLib("string", map[string]func(*State, []Value) []Value{
+		"byte":    strByte,
+		"char":    strChar,
+		"find":    strFind,
+		"format":  strFormat,
+		"gmatch":  strGmatch,
+		"gsub":    strGsub,
+		"len":     strLen,
+		"lower":   strLower,
+		"match":   strMatch,
+		"rep":     strRep,
+		"reverse": strReverse,
+		"sub":     strSub,
+		"upper":   strUpper,
+	})
+	l.Globals.Set("string", l.strings)
+}
+
+// strPos converts a possibly negative string position to a 1-based one.
+func strPos(pos, length int) int {
+	if pos < 0 {
+		pos += length + 1
+	}
+	return pos
+}
+
+func strLen(l *State, args []Value) []Value {
+	return []Value{float64(len(CheckString(l, args, 1, "len")))}
+}
+
+func strSub(l *State, args []Value) []Value {
+	s := CheckString(l, args, 1, "sub")
+	i := max(strPos(CheckInt(l, args, 2, "sub"), len(s)), 1)
+	j := min(strPos(OptInt(l, args, 3, "sub", -1), len(s)), len(s))
+	if i > j {
+		return []Value{""}
+	}
+	return []Value{s[i-1 : j]}
+}
+
+func strUpper(l *State, args []Value) []Value {
+	return []Value{strings.ToUpper(CheckString(l, args, 1, "upper"))}
+}
+
+func strLower(l *State, args []Value) []Value {
+	return []Value{strings.ToLower(CheckString(l, args, 1, "lower"))}
+}
+
+func strRep(l *State, args []Value) []Value {
+	s := CheckString(l, args, 1, "rep")
+	n := CheckInt(l, args, 2, "rep")
+	if n <= 0 || s == "" {
+		return []Value{""}
+	}
+	if len(s)*n > maxStringSize || len(s)*n/n != len(s) {
+		l.RaiseError("resulting string too large")
+	}
+	return []Value{strings.Repeat(s, n)}
+}
+
+func strReverse(l *State, args []Value) []Value {
+	s := []byte(CheckString(l, args, 1, "reverse"))
+	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+		s[i], s[j] = s[j], s[i]
+	}
+	return []Value{string(s)}
+}
+
+func strByte(l *State, args []Value) []Value {
+	s := CheckString(l, args, 1, "byte")
+	i := strPos(OptInt(l, args, 2, "byte", 1), len(s))
+	j := strPos(OptInt(l, args, 3, "byte", i), len(s))
+	i, j = max(i, 1), min(j, len(s))
+	var values []Value
+	for k := i; k <= j; k++ {
+		values = append(values, float64(s[k-1]))
+	}
+	return values
+}
+
+func strChar(l *State, args []Value) []Value {
+	b := make([]byte, len(args))
+	for i := range args {
+		c := CheckInt(l, args, i+1, "char")
+		if c < 0 || c > 255 {
+			argError(l, i+1, "char", "invalid value")
+		}
+		b[i] = byte(c)
+	}
+	return []Value{string(b)}
+}
+
+func strFind(l *State, args []Value) []Value {
+	return strFindAux(l, args, true)
+}
+
+func strMatch(l *State, args []Value) []Value {
+	return strFindAux(l, args, false)
+}
+
+func strFindAux(l *State, args []Value, find bool) []Value {
+	fname := "match"
+	if find {
+		fname = "find"
+	}
+	s := CheckString(l, args, 1, fname)
+	pat := CheckString(l, args, 2, fname)
+	init := max(strPos(OptInt(l, args, 3, fname, 1), len(s)), 1) - 1
+	if init > len(s) {
+		return []Value{nil}
+	}
+
+	if find && (Truthy(nth(args, 3)) || !strings.ContainsAny(pat, patternSpecial)) {
+		i := strings.Index(s[init:], pat)
+		if i < 0 {
+			return []Value{nil}
+		}
+		return []Value{float64(init + i + 1), float64(init + i + len(pat))}
+	}
+
+	anchor := strings.HasPrefix(pat, "^")
+	if anchor {
+		pat = pat[1:]
+	}
+	ms := &matchState{l: l, src: s, pat: pat}
+	for start := init; start <= len(s); start++ {
+		ms.level = 0
+		if e := ms.match(start, 0); e != -1 {
+			if find {
+				return append([]Value{float64(start + 1), float64(e)}, ms.captures(start, e, false)...)
+			}
+			return ms.captures(start, e, true)
+		}
+		if anchor {
+			break
+		}
+	}
+	return []Value{nil}
+}
+
+func strGmatch(l *State, args []Value) []Value {
+	s := CheckString(l, args, 1, "gmatch")
+	pat := CheckString(l, args, 2, "gmatch")
+	ms := &matchState{l: l, src: s, pat: pat}
+	pos := 0
+	iter := func(l *State, _ []Value) []Value {
+		ms.l = l
+		for ; pos <= len(s); pos++ {
+			ms.level = 0
+			if e := ms.match(pos, 0); e != -1 {
+				start := pos
+				pos = e
+				if e == start {
+					pos++
+				}
+				return ms.captures(start, e, true)
+			}
+		}
+		return []Value{nil}
+	}
+	return []Value{&GoFunction{Name: "gmatch_iterator", Fn: iter}}
+}
+
+func strGsub(l *State, args []Value) []Value {
+	s := CheckString(l, args, 1, "gsub")
+	pat := CheckString(l, args, 2, "gsub")
+	repl := nth(args, 2)
+	switch repl.(type) {
+	case string, float64, *Table, *Function, *GoFunction:
+	default:
+		typeError(l, args, 3, "gsub", "string/function/table")
+	}
+	maxN := OptInt(l, args, 4, "gsub", len(s)+1)
+
+	anchor := strings.HasPrefix(pat, "^")
+	if anchor {
+		pat = pat[1:]
+	}
+	ms := &matchState{l: l, src: s, pat: pat}
+	var b strings.Builder
+	src, n := 0, 0
+loop:
+	for n < maxN {
+		ms.level = 0
+		e := ms.match(src, 0)
+		if e != -1 {
+			n++
+			ms.addValue(&b, src, e, repl)
+		}
+		switch {
+		case e != -1 && e > src:
+			src = e
+		case src < len(s):
+			b.WriteByte(s[src])
+			src++
+		default:
+			break loop
+		}
+		if anchor {
+			break
+		}
+	}
+	b.WriteString(s[min(src, len(s)):])
+	return []Value{b.String(), float64(n)}
+}
+
+// addValue appends the replacement of the match s..e.
+func (ms *matchState) addValue(b *strings.Builder, s, e int, repl Value) {
+	var v Value
+	switch r := repl.(type) {
+	case float64:
+		ms.addString(b, s, e, FormatNumber(r))
+		return
+	case string:
+		ms.addString(b, s, e, r)
+		return
+	case *Table:
+		v = r.Get(ms.captureValue(0, s, e))
+	default:
+		v = nth(ms.l.call(r, ms.captures(s, e, true), ms.l.line, ""), 0)
+	}
+	if !Truthy(v) {
+		b.WriteString(ms.src[s:e])
+		return
+	}
+	str, ok := concatString(v)
+	if !ok {
+		ms.l.RaiseError("invalid replacement value (a %s)", TypeName(v))
+	}
+	b.WriteString(str)
+}
+
+// addString appends a replacement string, expanding %0 to %9.
+func (ms *matchState) addString(b *strings.Builder, s, e int, repl string) {
+	for i := 0; i < len(repl); i++ {
+		c := repl[i]
+		if c != '%' || i+1 == len(repl) {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		switch {
+		case !isDigit(repl[i]):
+			b.WriteByte(repl[i])
+		case repl[i] == '0':
+			b.WriteString(ms.src[s:e])
+		default:
+			v, _ := concatString(ms.captureValue(int(repl[i]-'1'), s, e))
+			b.WriteString(v)
+		}
+	}
+}
+
+func strFormat(l *State, args []Value) []Value {
+	format := CheckString(l, args, 1, "format")
+	var b strings.Builder
+	arg := 1
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		if c != '%' {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		if i < len(format) && format[i] == '%' {
+			b.WriteByte('%')
+			continue
+		}
+
+		// Flags, width and precision, as in C.
+		start := i
+		for i < len(format) && strings.IndexByte("-+ #0", format[i]) >= 0 {
+			i++
+		}
+		for i < len(format) && (isDigit(format[i]) || format[i] == '.') {
+			i++
+		}
+		if i >= len(format) || i-start > 6 {
+			l.RaiseError("invalid format (repeated flags)")
+		}
+		spec := "%" + format[start:i]
+		arg++
+		switch verb := format[i]; verb {
+		case 'd', 'i':
+			b.WriteString(fmt.Sprintf(spec+"d", int64(CheckNumber(l, args, arg, "format"))))
+		case 'u':
+			b.WriteString(fmt.Sprintf(spec+"d", uint64(int64(CheckNumber(l, args, arg, "format")))))
+		case 'c':
+			b.WriteByte(byte(CheckInt(l, args, arg, "format")))
+		case 'x', 'X', 'o':
+			b.WriteString(fmt.Sprintf(spec+string(verb), uint64(int64(CheckNumber(l, args, arg, "format")))))
+		case 'e', 'E', 'f', 'g', 'G':
+			b.WriteString(fmt.Sprintf(spec+string(verb), CheckNumber(l, args, arg, "format")))
+		case 'q':
+			b.WriteString(quoteString(CheckString(l, args, arg, "format")))
+		case 's':
+			b.WriteString(fmt.Sprintf(spec+"s", CheckString(l, args, arg, "format")))
+		default:
+			l.RaiseError("invalid option '%%%c' to 'format'", verb)
+		}
+		if b.Len() > maxStringSize {
+			l.RaiseError("resulting string too large")
+		}
+	}
+	return []Value{b.String()}
+}
+
+// quoteString quotes s so that Lua can read it back, like %q.
+func quoteString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"', '\\', '\n':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '\r':
+			b.WriteString("\\r")
+		case 0:
+			b.WriteString("\\000")
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
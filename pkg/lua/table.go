@@ -0,0 +1,165 @@
+package lua
+
+import "math"
+
+// Table is a Lua table. Keys 1..n live in an array part; every other key
+// lives in a hash part that remembers insertion order, so that next can
+// resume a traversal from any key even after fields were cleared.
+type Table struct {
+	array   []Value
+	index   map[Value]int
+	entries []tableEntry
+	// cleared counts the entries whose value was set to nil.
+	cleared int
+}
+
+type tableEntry struct {
+	key   Value
+	value Value
+}
+
+func NewTable() *Table {
+	return &Table{index: make(map[Value]int)}
+}
+
+// arrayIndex returns the 0-based array position for key, if key is a
+// positive integer.
+func arrayIndex(key Value) (int, bool) {
+	f, ok := key.(float64)
+	if !ok || f < 1 || f != math.Trunc(f) || f > math.MaxInt32 {
+		return 0, false
+	}
+	return int(f) - 1, true
+}
+
+func (t *Table) Get(key Value) Value {
+	if i, ok := arrayIndex(key); ok && i < len(t.array) {
+		return t.array[i]
+	}
+	if i, found := t.index[key]; found {
+		return t.entries[i].value
+	}
+	return nil
+}
+
+// Set assigns value to key; a nil value removes the key. The caller must
+// reject nil and NaN keys.
+func (t *Table) Set(key Value, value Value) {
+	if i, ok := arrayIndex(key); ok {
+		switch {
+		case i < len(t.array):
+			t.array[i] = value
+			if value == nil && i == len(t.array)-1 {
+				t.trimArray()
+			}
+			return
+		case i == len(t.array) && value != nil:
+			t.array = append(t.array, value)
+			t.clearEntry(key)
+			t.migrate()
+			return
+		}
+	}
+
+	if i, found := t.index[key]; found {
+		if t.entries[i].value != nil && value == nil {
+			t.cleared++
+		} else if t.entries[i].value == nil && value != nil {
+			t.cleared--
+		}
+		t.entries[i].value = value
+		return
+	}
+	if value == nil {
+		return
+	}
+	if t.cleared > 16 && t.cleared > len(t.entries)/2 {
+		t.compact()
+	}
+	t.index[key] = len(t.entries)
+	t.entries = append(t.entries, tableEntry{key, value})
+}
+
+// Append sets t[#t+1] = value.
+func (t *Table) Append(value Value) {
+	t.Set(float64(len(t.array)+1), value)
+}
+
+// Len returns a border of the table, like the # operator.
+func (t *Table) Len() int {
+	return len(t.array)
+}
+
+func (t *Table) trimArray() {
+	n := len(t.array)
+	for n > 0 && t.array[n-1] == nil {
+		n--
+	}
+	t.array = t.array[:n]
+}
+
+// migrate moves the integer keys following the array part into it.
+func (t *Table) migrate() {
+	for {
+		key := float64(len(t.array) + 1)
+		i, found := t.index[key]
+		if !found || t.entries[i].value == nil {
+			return
+		}
+		t.array = append(t.array, t.entries[i].value)
+		t.clearEntry(key)
+	}
+}
+
+func (t *Table) clearEntry(key Value) {
+	if i, found := t.index[key]; found && t.entries[i].value != nil {
+		t.entries[i].value = nil
+		t.cleared++
+	}
+}
+
+func (t *Table) compact() {
+	entries := t.entries[:0]
+	clear(t.index)
+	for _, e := range t.entries {
+		if e.value != nil {
+			t.index[e.key] = len(entries)
+			entries = append(entries, e)
+		}
+	}
+	clear(t.entries[len(entries):])
+	t.entries = entries
+	t.cleared = 0
+}
+
+// Next returns the key and value following key in a traversal, starting
+// with a nil key. It returns a nil key at the end of the traversal and
+// ok=false if key is not in the table.
+func (t *Table) Next(key Value) (Value, Value, bool) {
+	start := 0
+	if key != nil {
+		if i, ok := arrayIndex(key); ok && i < len(t.array) {
+			start = i + 1
+		} else if i, found := t.index[key]; found {
+			start = len(t.array) + i + 1
+		} else if ok {
+			// The array part shrank because the traversal cleared its
+			// last fields: continue with the hash part.
+			start = len(t.array)
+		} else {
+			return nil, nil, false
+		}
+	}
+
+	for i := start; i < len(t.array); i++ {
+		if t.array[i] != nil {
+			return float64(i + 1), t.array[i], true
+		}
+	}
+	for i := max(start-len(t.array), 0); i < len(t.entries); i++ {
+		if e := t.entries[i]; e.value != nil {
+			return e.key, e.value, true
+		}
+	}
+	return nil, nil, true
+}
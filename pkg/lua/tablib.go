@@ -0,0 +1,98 @@
+package lua
+
+import (
+	"sort"
+	"strings"
+)
+
+func openTable(l *State) {
+	l.Globals.Set("table", NewLib("table", map[string]func(*State, []Value) []Value{
+		"concat": tableConcat,
+		"getn":   tableGetn,
+		"insert": tableInsert,
+		"remove": tableRemove,
+		"sort":   tableSort,
+	}))
+}
+
+func tableConcat(l *State, args []Value) []Value {
+	t := CheckTable(l, args, 1, "concat")
+	sep := ""
+	if nth(args, 1) != nil {
+		sep = CheckString(l, args, 2, "concat")
+	}
+	i := OptInt(l, args, 3, "concat", 1)
+	j := OptInt(l, args, 4, "concat", t.Len())
+
+	var b strings.Builder
+	for k := i; k <= j; k++ {
+		s, ok := concatString(t.Get(float64(k)))
+		if !ok {
+			l.RaiseError("invalid value (at index %d) in table for 'concat'", k)
+		}
+		b.WriteString(s)
+		if k < j {
+			b.WriteString(sep)
+		}
+	}
+	return []Value{b.String()}
+}
+
+func tableGetn(l *State, args []Value) []Value {
+	return []Value{float64(CheckTable(l, args, 1, "getn").Len())}
+}
+
+func tableInsert(l *State, args []Value) []Value {
+	t := CheckTable(l, args, 1, "insert")
+	n := t.Len() + 1
+	switch len(args) {
+	case 2:
+		t.Set(float64(n), args[1])
+	case 3:
+		pos := CheckInt(l, args, 2, "insert")
+		if pos > n {
+			n = pos
+		}
+		for i := n; i > pos; i-- {
+			t.Set(float64(i), t.Get(float64(i-1)))
+		}
+		l.rawSet(t, float64(pos), args[2], l.line)
+	default:
+		l.RaiseError("wrong number of arguments to 'insert'")
+	}
+	return nil
+}
+
+func tableRemove(l *State, args []Value) []Value {
+	t := CheckTable(l, args, 1, "remove")
+	n := t.Len()
+	pos := OptInt(l, args, 2, "remove", n)
+	if n == 0 {
+		return nil
+	}
+	v := t.Get(float64(pos))
+	for i := pos; i < n; i++ {
+		t.Set(float64(i), t.Get(float64(i+1)))
+	}
+	t.Set(float64(n), nil)
+	return []Value{v}
+}
+
+func tableSort(l *State, args []Value) []Value {
+	t := CheckTable(l, args, 1, "sort")
+	comp := nth(args, 1)
+	values := make([]Value, t.Len())
+	for i := range values {
+		values[i] = t.Get(float64(i + 1))
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if comp != nil {
+			return Truthy(nth(l.call(comp, []Value{values[i], values[j]}, l.line, ""), 0))
+		}
+		return l.less(values[i], values[j], l.line)
+	})
+	for i, v := range values {
+		t.Set(float64(i+1), v)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+// Package lua is a small interpreter for the subset of Lua 5.1 used by Redis
+// scripts: all statements and expressions of the language, closures and
+// varargs, and the base, string, table and math libraries, along with the
+// bit and cjson libraries Redis adds. Metatables and coroutines are not
+// supported.
+//
+// Lua values are represented by Go values: nil, bool, float64, string,
+// *Table, *Function and *GoFunction, and JSONNull for cjson.null.
+package lua
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+type Value = any
+
+// GoFunction is a function implemented in Go. It receives its arguments and
+// returns its results; errors are raised with State.RaiseError.
+type GoFunction struct {
+	Name string
+	Fn   func(l *State, args []Value) []Value
+}
+
+// Function is a Lua closure.
+type Function struct {
+	proto  *funcProto
+	upvals []*cell
+}
+
+// cell holds a local variable, shared by the closures capturing it.
+type cell struct {
+	v Value
+}
+
+// TypeName returns the Lua type of v.
+func TypeName(v Value) string {
+	switch v.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case *Table:
+		return "table"
+	case *Function, *GoFunction:
+		return "function"
+	default:
+		return "userdata"
+	}
+}
+
+// Truthy reports whether v counts as true in a condition.
+func Truthy(v Value) bool {
+	return v != nil && v != false
+}
+
+// ToString converts v the way tostring does.
+func ToString(v Value) string {
+	switch v := v.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return FormatNumber(v)
+	case string:
+		return v
+	case *Table:
+		return fmt.Sprintf("table: %p", v)
+	case *Function:
+		return fmt.Sprintf("function: %p", v)
+	case *GoFunction:
+		return "function: builtin: " + v.Name
+	default:
+		return fmt.Sprintf("userdata: %p", v)
+	}
+}
+
+// FormatNumber formats a number like Lua's "%.14g".
+func FormatNumber(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "nan"
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case f == math.Trunc(f) && math.Abs(f) < 1e15:
+		return strconv.FormatInt(int64(f), 10)
+	default:
+		return strconv.FormatFloat(f, 'g', 14, 64)
+	}
+}
+
+// ToNumber converts numbers and numeric strings to a number.
+func ToNumber(v Value) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case string:
+		return parseNumber(v)
+	default:
+		return 0, false
+	}
+}
+
+// parseNumber parses decimal and hexadecimal numbers surrounded by optional
+// whitespace, rejecting the "inf" and "nan" spellings Go would accept.
+func parseNumber(s string) (float64, bool) {
+	s = strings.TrimSpace(s)
+	body, negative := s, false
+	if strings.HasPrefix(body, "-") {
+		body, negative = body[1:], true
+	} else if strings.HasPrefix(body, "+") {
+		body = body[1:]
+	}
+
+	if len(body) > 2 && body[0] == '0' && (body[1] == 'x' || body[1] == 'X') {
+		n, err := strconv.ParseUint(body[2:], 16, 64)
+		if err != nil {
+			return 0, false
+		}
+		if negative {
+			return -float64(n), true
+		}
+		return float64(n), true
+	}
+
+	if body == "" || strings.IndexFunc(body, func(r rune) bool {
+		return !strings.ContainsRune("0123456789.eE+-", r)
+	}) >= 0 {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil && !strings.Contains(err.Error(), "value out of range") {
+		return 0, false
+	}
+	return f, true
+}
+
+// rawEqual compares two values without metamethods.
+func rawEqual(a, b Value) bool {
+	return a == b
+}
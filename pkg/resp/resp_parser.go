@@ -6,11 +6,26 @@ import (
 	"strings"
 )
 
+// ErrIncomplete is returned when the input ends before the RESP value does.
+// A connection keeps what it has read and parses again once more arrives.
+var ErrIncomplete = errors.New("incomplete RESP message")
+
+// maxArrayPrealloc bounds the room reserved up front for an array, so that a
+// peer announcing a huge count cannot make us allocate it before sending it.
+const maxArrayPrealloc = 1024
+
 func DeserializeRESP(input string) (any, error) {
 	value, _, err := parseOneValue(input)
 	return value, err
 }
 
+// DeserializeNext parses the first RESP value of input and returns it with
+// the rest of input, which holds the values pipelined after it. It returns
+// ErrIncomplete when input only holds the beginning of a value.
+func DeserializeNext(input string) (any, string, error) {
+	return parseOneValue(input)
+}
+
 // parseOneValue reads a single RESP value from `input` and returns:
 // - the parsed Go value
 // - the remaining unconsumed part of `input`
@@ -18,7 +33,7 @@ func DeserializeRESP(input string) (any, error) {
 func parseOneValue(input string) (any, string, error) {
 	// Ensure we have something
 	if len(input) == 0 {
-		return nil, "", errors.New("empty input")
+		return nil, "", ErrIncomplete
 	}
 
 	switch input[0] {
@@ -41,7 +56,7 @@ func parseOneValue(input string) (any, string, error) {
 func parseSimpleStringValue(input string) (any, string, error) {
 	endIdx := strings.Index(input, "\r\n")
 	if endIdx == -1 {
-		return nil, "", errors.New("invalid RESP format for simple string")
+		return nil, "", ErrIncomplete
 	}
 	// e.g. +OK\r\n => "OK"
 	value := input[1:endIdx]
@@ -53,7 +68,7 @@ func parseSimpleStringValue(input string) (any, string, error) {
 func parseErrorValue(input string) (any, string, error) {
 	endIdx := strings.Index(input, "\r\n")
 	if endIdx == -1 {
-		return nil, "", errors.New("invalid RESP format for error")
+		return nil, "", ErrIncomplete
 	}
 	value := input[1:endIdx]
 	remaining := input[endIdx+2:]
@@ -64,7 +79,7 @@ func parseErrorValue(input string) (any, string, error) {
 func parseIntegerValue(input string) (any, string, error) {
 	endIdx := strings.Index(input, "\r\n")
 	if endIdx == -1 {
-		return nil, "", errors.New("invalid RESP format for integer")
+		return nil, "", ErrIncomplete
 	}
 
 	valueStr := input[1:endIdx]
@@ -82,13 +97,13 @@ func parseBulkStringValue(input string) (any, string, error) {
 	// 1) find the first line up to \r\n
 	firstLineEnd := strings.Index(input, "\r\n")
 	if firstLineEnd == -1 {
-		return nil, "", errors.New("invalid RESP format for bulk string length")
+		return nil, "", ErrIncomplete
 	}
 
 	// "$4\r\n1234\r\n..."
 	lengthStr := input[1:firstLineEnd] // the part after '$' but before "\r\n"
 	length, err := strconv.Atoi(lengthStr)
-	if err != nil {
+	if err != nil || length < -1 {
 		return nil, "", errors.New("invalid bulk string length")
 	}
 
@@ -100,8 +115,8 @@ func parseBulkStringValue(input string) (any, string, error) {
 	}
 
 	// We must have at least `length` characters + 2 bytes for the trailing "\r\n"
-	if len(remaining) < length+2 {
-		return nil, "", errors.New("not enough data for bulk string")
+	if length > len(remaining)-2 {
+		return nil, "", ErrIncomplete
 	}
 
 	content := remaining[:length]
@@ -112,8 +127,9 @@ func parseBulkStringValue(input string) (any, string, error) {
 
 	newRemaining := remaining[length+2:]
 
-	// Attempt to parse content as int
-	if intVal, err := strconv.Atoi(content); err == nil {
+	// Attempt to parse content as int. Only the canonical form is converted,
+	// so that values such as "007" or "+1" keep their exact bytes.
+	if intVal, err := strconv.Atoi(content); err == nil && strconv.Itoa(intVal) == content {
 		return intVal, newRemaining, nil
 	}
 
@@ -126,13 +142,13 @@ func parseArrayValue(input string) (any, string, error) {
 	// 1) find the first line up to \r\n
 	firstLineEnd := strings.Index(input, "\r\n")
 	if firstLineEnd == -1 {
-		return nil, "", errors.New("invalid RESP format for array")
+		return nil, "", ErrIncomplete
 	}
 
 	// "*3\r\n..."
 	countStr := input[1:firstLineEnd] // the part after '*' but before "\r\n"
 	count, err := strconv.Atoi(countStr)
-	if err != nil {
+	if err != nil || count < -1 {
 		return nil, "", errors.New("invalid array count")
 	}
 
@@ -143,7 +159,7 @@ func parseArrayValue(input string) (any, string, error) {
 		return nil, remaining, nil
 	}
 
-	elements := make([]any, 0, count)
+	elements := make([]any, 0, min(count, maxArrayPrealloc))
 
 	// Parse each element
 	for i := 0; i < count; i++ {
@@ -184,6 +200,25 @@ func SerializeRESP(data any, isGet bool) string {
 	}
 }
 
+// SerializeBulk is SerializeRESP for replies that carry user data: strings,
+// including those nested in arrays, are encoded as bulk strings, since keys,
+// fields and values may contain any byte, CRLF included.
+func SerializeBulk(data any) string {
+	switch v := data.(type) {
+	case string:
+		return "$" + strconv.Itoa(len(v)) + "\r\n" + v + "\r\n"
+	case []any:
+		var sb strings.Builder
+		sb.WriteString("*" + strconv.Itoa(len(v)) + "\r\n")
+		for _, element := range v {
+			sb.WriteString(SerializeBulk(element))
+		}
+		return sb.String()
+	default:
+		return SerializeRESP(data, false)
+	}
+}
+
 func serializeSimpleString(value string) string {
 	return "+" + value + "\r\n"
 }
@@ -29,6 +29,12 @@ func TestDeserializeRESP(t *testing.T) {
 		{input: "*2\r\n:1\r\n:2\r\n", expected: []any{1, 2}, hasError: false},
 		{input: "*2\r\n$3\r\nfoo\r\n:42\r\n", expected: []any{"foo", 42}, hasError: false},
 		{input: "*3\r\n$3\r\nfoo\r\n$-1\r\n$3\r\nbar\r\n", expected: []any{"foo", nil, "bar"}, hasError: false},
+		{input: "$3\r\n007\r\n", expected: "007", hasError: false},
+		{input: "$2\r\n+1\r\n", expected: "+1", hasError: false},
+		{input: "$4\r\na\r\nb\r\n", expected: "a\r\nb", hasError: false},
+		{input: "$-2\r\n", expected: nil, hasError: true},
+		{input: "*-2\r\n", expected: nil, hasError: true},
+		{input: "$9223372036854775807\r\nx\r\n", expected: nil, hasError: true},
 	}
 
 	for _, tc := range testCases {
@@ -49,6 +55,38 @@ func TestDeserializeRESP(t *testing.T) {
 	}
 }
 
+func TestDeserializeNext(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected any
+		rest     string
+		err      error
+	}{
+		{input: "+OK\r\n:1\r\n", expected: "OK", rest: ":1\r\n"},
+		{input: "*1\r\n$4\r\nPING\r\n*1\r\n$4\r\nPI", expected: []any{"PING"}, rest: "*1\r\n$4\r\nPI"},
+		{input: "", err: ErrIncomplete},
+		{input: "+OK", err: ErrIncomplete},
+		{input: "*2\r\n$3\r\nfoo\r\n", err: ErrIncomplete},
+		{input: "*1\r\n$5\r\nhel", err: ErrIncomplete},
+		{input: "$5\r\nhello\r", err: ErrIncomplete},
+		{input: "$5\r\nhelloXX", err: errors.New("invalid bulk string trailer")},
+		{input: "?\r\n", err: errors.New("unsupported RESP type")},
+	}
+
+	for _, tc := range testCases {
+		result, rest, err := DeserializeNext(tc.input)
+		if tc.err != nil {
+			if err == nil || err.Error() != tc.err.Error() {
+				t.Errorf("DeserializeNext(%q) error = %v, expected %v", tc.input, err, tc.err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(result, tc.expected) || rest != tc.rest {
+			t.Errorf("DeserializeNext(%q) = %v, %q, %v, expected %v, %q", tc.input, result, rest, err, tc.expected, tc.rest)
+		}
+	}
+}
+
 func TestSerializeRESP(t *testing.T) {
 	testCases := []struct {
 		input    any
@@ -80,3 +118,25 @@ func TestSerializeRESP(t *testing.T) {
 		}
 	}
 }
+
+func TestSerializeBulk(t *testing.T) {
+	testCases := []struct {
+		input    any
+		expected string
+	}{
+		{input: "hello", expected: "$5\r\nhello\r\n"},
+		{input: "", expected: "$0\r\n\r\n"},
+		{input: 42, expected: ":42\r\n"},
+		{input: nil, expected: "$-1\r\n"},
+		{input: []any{}, expected: "*0\r\n"},
+		{input: []any{"a\r\n:1", 2, nil}, expected: "*3\r\n$5\r\na\r\n:1\r\n:2\r\n$-1\r\n"},
+		{input: []any{"foo", []any{"bar", ""}}, expected: "*2\r\n$3\r\nfoo\r\n*2\r\n$3\r\nbar\r\n$0\r\n\r\n"},
+	}
+
+	for _, tc := range testCases {
+		result := SerializeBulk(tc.input)
+		if result != tc.expected {
+			t.Errorf("SerializeBulk(%v) = %q, expected %q", tc.input, result, tc.expected)
+		}
+	}
+}